@@ -0,0 +1,24 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package advmock provides mocks of the SDK service interfaces. The mocks are generated from
+// services.go by internal/mockgen; run go generate in the module root after changing an
+// interface.
+package advmock
+
+import "errors"
+
+var ErrNotStubbed = errors.New("advmock: method called without a stub")
@@ -0,0 +1,1088 @@
+// Code generated by internal/mockgen. DO NOT EDIT.
+
+package advmock
+
+import (
+	"context"
+	"fmt"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"sync"
+)
+
+// MockService is a mock of adv.Service composed of the mocks of each domain.
+type MockService struct {
+	*MockOrdersService
+	*MockAccountsService
+	*MockProductsService
+	*MockPortfoliosService
+	*MockFuturesService
+	*MockPerpetualsService
+	*MockConvertService
+}
+
+func NewMockService() *MockService {
+	return &MockService{
+		MockOrdersService:     &MockOrdersService{},
+		MockAccountsService:   &MockAccountsService{},
+		MockProductsService:   &MockProductsService{},
+		MockPortfoliosService: &MockPortfoliosService{},
+		MockFuturesService:    &MockFuturesService{},
+		MockPerpetualsService: &MockPerpetualsService{},
+		MockConvertService:    &MockConvertService{},
+	}
+}
+
+var _ adv.Service = NewMockService()
+
+// MockOrdersService is a mock of adv.OrdersService. Set the Func field of each method a test expects
+// to be called; calling a method without one returns ErrNotStubbed.
+type MockOrdersService struct {
+	CreateOrderFunc        func(ctx context.Context, request *adv.CreateOrderRequest) (*adv.CreateOrderResponse, error)
+	CreateOrderPreviewFunc func(ctx context.Context, request *adv.CreateOrderPreviewRequest) (*adv.CreateOrderPreviewResponse, error)
+	EditOrderFunc          func(ctx context.Context, request *adv.EditOrderRequest) (*adv.EditOrderResponse, error)
+	PreviewEditOrderFunc   func(ctx context.Context, request *adv.PreviewEditOrderRequest) (*adv.PreviewEditOrderResponse, error)
+	CancelOrdersFunc       func(ctx context.Context, request *adv.CancelOrdersRequest) (*adv.CancelOrdersResponse, error)
+	GetOrderFunc           func(ctx context.Context, request *adv.GetOrderRequest) (*adv.GetOrderResponse, error)
+	ListOrdersFunc         func(ctx context.Context, request *adv.ListOrdersRequest) (*adv.ListOrdersResponse, error)
+	ListFillsFunc          func(ctx context.Context, request *adv.ListFillsRequest) (*adv.ListFillsResponse, error)
+	ClosePositionFunc      func(ctx context.Context, request *adv.ClosePositionRequest) (*adv.ClosePositionResponse, error)
+
+	mu                      sync.Mutex
+	createOrderCalls        []*adv.CreateOrderRequest
+	createOrderPreviewCalls []*adv.CreateOrderPreviewRequest
+	editOrderCalls          []*adv.EditOrderRequest
+	previewEditOrderCalls   []*adv.PreviewEditOrderRequest
+	cancelOrdersCalls       []*adv.CancelOrdersRequest
+	getOrderCalls           []*adv.GetOrderRequest
+	listOrdersCalls         []*adv.ListOrdersRequest
+	listFillsCalls          []*adv.ListFillsRequest
+	closePositionCalls      []*adv.ClosePositionRequest
+}
+
+func (m *MockOrdersService) CreateOrder(ctx context.Context, request *adv.CreateOrderRequest) (*adv.CreateOrderResponse, error) {
+	m.mu.Lock()
+	m.createOrderCalls = append(m.createOrderCalls, request)
+	fn := m.CreateOrderFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: OrdersService.CreateOrder", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// CreateOrderCalls returns the requests passed to CreateOrder, oldest first.
+func (m *MockOrdersService) CreateOrderCalls() []*adv.CreateOrderRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.CreateOrderRequest(nil), m.createOrderCalls...)
+}
+
+func (m *MockOrdersService) CreateOrderPreview(ctx context.Context, request *adv.CreateOrderPreviewRequest) (*adv.CreateOrderPreviewResponse, error) {
+	m.mu.Lock()
+	m.createOrderPreviewCalls = append(m.createOrderPreviewCalls, request)
+	fn := m.CreateOrderPreviewFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: OrdersService.CreateOrderPreview", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// CreateOrderPreviewCalls returns the requests passed to CreateOrderPreview, oldest first.
+func (m *MockOrdersService) CreateOrderPreviewCalls() []*adv.CreateOrderPreviewRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.CreateOrderPreviewRequest(nil), m.createOrderPreviewCalls...)
+}
+
+func (m *MockOrdersService) EditOrder(ctx context.Context, request *adv.EditOrderRequest) (*adv.EditOrderResponse, error) {
+	m.mu.Lock()
+	m.editOrderCalls = append(m.editOrderCalls, request)
+	fn := m.EditOrderFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: OrdersService.EditOrder", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// EditOrderCalls returns the requests passed to EditOrder, oldest first.
+func (m *MockOrdersService) EditOrderCalls() []*adv.EditOrderRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.EditOrderRequest(nil), m.editOrderCalls...)
+}
+
+func (m *MockOrdersService) PreviewEditOrder(ctx context.Context, request *adv.PreviewEditOrderRequest) (*adv.PreviewEditOrderResponse, error) {
+	m.mu.Lock()
+	m.previewEditOrderCalls = append(m.previewEditOrderCalls, request)
+	fn := m.PreviewEditOrderFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: OrdersService.PreviewEditOrder", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// PreviewEditOrderCalls returns the requests passed to PreviewEditOrder, oldest first.
+func (m *MockOrdersService) PreviewEditOrderCalls() []*adv.PreviewEditOrderRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.PreviewEditOrderRequest(nil), m.previewEditOrderCalls...)
+}
+
+func (m *MockOrdersService) CancelOrders(ctx context.Context, request *adv.CancelOrdersRequest) (*adv.CancelOrdersResponse, error) {
+	m.mu.Lock()
+	m.cancelOrdersCalls = append(m.cancelOrdersCalls, request)
+	fn := m.CancelOrdersFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: OrdersService.CancelOrders", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// CancelOrdersCalls returns the requests passed to CancelOrders, oldest first.
+func (m *MockOrdersService) CancelOrdersCalls() []*adv.CancelOrdersRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.CancelOrdersRequest(nil), m.cancelOrdersCalls...)
+}
+
+func (m *MockOrdersService) GetOrder(ctx context.Context, request *adv.GetOrderRequest) (*adv.GetOrderResponse, error) {
+	m.mu.Lock()
+	m.getOrderCalls = append(m.getOrderCalls, request)
+	fn := m.GetOrderFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: OrdersService.GetOrder", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetOrderCalls returns the requests passed to GetOrder, oldest first.
+func (m *MockOrdersService) GetOrderCalls() []*adv.GetOrderRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetOrderRequest(nil), m.getOrderCalls...)
+}
+
+func (m *MockOrdersService) ListOrders(ctx context.Context, request *adv.ListOrdersRequest) (*adv.ListOrdersResponse, error) {
+	m.mu.Lock()
+	m.listOrdersCalls = append(m.listOrdersCalls, request)
+	fn := m.ListOrdersFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: OrdersService.ListOrders", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// ListOrdersCalls returns the requests passed to ListOrders, oldest first.
+func (m *MockOrdersService) ListOrdersCalls() []*adv.ListOrdersRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.ListOrdersRequest(nil), m.listOrdersCalls...)
+}
+
+func (m *MockOrdersService) ListFills(ctx context.Context, request *adv.ListFillsRequest) (*adv.ListFillsResponse, error) {
+	m.mu.Lock()
+	m.listFillsCalls = append(m.listFillsCalls, request)
+	fn := m.ListFillsFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: OrdersService.ListFills", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// ListFillsCalls returns the requests passed to ListFills, oldest first.
+func (m *MockOrdersService) ListFillsCalls() []*adv.ListFillsRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.ListFillsRequest(nil), m.listFillsCalls...)
+}
+
+func (m *MockOrdersService) ClosePosition(ctx context.Context, request *adv.ClosePositionRequest) (*adv.ClosePositionResponse, error) {
+	m.mu.Lock()
+	m.closePositionCalls = append(m.closePositionCalls, request)
+	fn := m.ClosePositionFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: OrdersService.ClosePosition", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// ClosePositionCalls returns the requests passed to ClosePosition, oldest first.
+func (m *MockOrdersService) ClosePositionCalls() []*adv.ClosePositionRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.ClosePositionRequest(nil), m.closePositionCalls...)
+}
+
+var _ adv.OrdersService = &MockOrdersService{}
+
+// MockAccountsService is a mock of adv.AccountsService. Set the Func field of each method a test expects
+// to be called; calling a method without one returns ErrNotStubbed.
+type MockAccountsService struct {
+	ListAccountsFunc           func(ctx context.Context, request *adv.ListAccountsRequest) (*adv.ListAccountsResponse, error)
+	GetAccountFunc             func(ctx context.Context, request *adv.GetAccountRequest) (*adv.GetAccountResponse, error)
+	GetTransactionsSummaryFunc func(ctx context.Context, request *adv.GetTransactionsSummaryRequest) (*adv.GetTransactionsSummaryResponse, error)
+	ListPaymentMethodsFunc     func(ctx context.Context, request *adv.ListPaymentMethodsRequest) (*adv.ListPaymentMethodsResponse, error)
+	GetPaymentMethodFunc       func(ctx context.Context, request *adv.GetPaymentMethodRequest) (*adv.GetPaymentMethodResponse, error)
+
+	mu                          sync.Mutex
+	listAccountsCalls           []*adv.ListAccountsRequest
+	getAccountCalls             []*adv.GetAccountRequest
+	getTransactionsSummaryCalls []*adv.GetTransactionsSummaryRequest
+	listPaymentMethodsCalls     []*adv.ListPaymentMethodsRequest
+	getPaymentMethodCalls       []*adv.GetPaymentMethodRequest
+}
+
+func (m *MockAccountsService) ListAccounts(ctx context.Context, request *adv.ListAccountsRequest) (*adv.ListAccountsResponse, error) {
+	m.mu.Lock()
+	m.listAccountsCalls = append(m.listAccountsCalls, request)
+	fn := m.ListAccountsFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: AccountsService.ListAccounts", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// ListAccountsCalls returns the requests passed to ListAccounts, oldest first.
+func (m *MockAccountsService) ListAccountsCalls() []*adv.ListAccountsRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.ListAccountsRequest(nil), m.listAccountsCalls...)
+}
+
+func (m *MockAccountsService) GetAccount(ctx context.Context, request *adv.GetAccountRequest) (*adv.GetAccountResponse, error) {
+	m.mu.Lock()
+	m.getAccountCalls = append(m.getAccountCalls, request)
+	fn := m.GetAccountFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: AccountsService.GetAccount", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetAccountCalls returns the requests passed to GetAccount, oldest first.
+func (m *MockAccountsService) GetAccountCalls() []*adv.GetAccountRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetAccountRequest(nil), m.getAccountCalls...)
+}
+
+func (m *MockAccountsService) GetTransactionsSummary(ctx context.Context, request *adv.GetTransactionsSummaryRequest) (*adv.GetTransactionsSummaryResponse, error) {
+	m.mu.Lock()
+	m.getTransactionsSummaryCalls = append(m.getTransactionsSummaryCalls, request)
+	fn := m.GetTransactionsSummaryFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: AccountsService.GetTransactionsSummary", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetTransactionsSummaryCalls returns the requests passed to GetTransactionsSummary, oldest first.
+func (m *MockAccountsService) GetTransactionsSummaryCalls() []*adv.GetTransactionsSummaryRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetTransactionsSummaryRequest(nil), m.getTransactionsSummaryCalls...)
+}
+
+func (m *MockAccountsService) ListPaymentMethods(ctx context.Context, request *adv.ListPaymentMethodsRequest) (*adv.ListPaymentMethodsResponse, error) {
+	m.mu.Lock()
+	m.listPaymentMethodsCalls = append(m.listPaymentMethodsCalls, request)
+	fn := m.ListPaymentMethodsFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: AccountsService.ListPaymentMethods", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// ListPaymentMethodsCalls returns the requests passed to ListPaymentMethods, oldest first.
+func (m *MockAccountsService) ListPaymentMethodsCalls() []*adv.ListPaymentMethodsRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.ListPaymentMethodsRequest(nil), m.listPaymentMethodsCalls...)
+}
+
+func (m *MockAccountsService) GetPaymentMethod(ctx context.Context, request *adv.GetPaymentMethodRequest) (*adv.GetPaymentMethodResponse, error) {
+	m.mu.Lock()
+	m.getPaymentMethodCalls = append(m.getPaymentMethodCalls, request)
+	fn := m.GetPaymentMethodFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: AccountsService.GetPaymentMethod", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetPaymentMethodCalls returns the requests passed to GetPaymentMethod, oldest first.
+func (m *MockAccountsService) GetPaymentMethodCalls() []*adv.GetPaymentMethodRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetPaymentMethodRequest(nil), m.getPaymentMethodCalls...)
+}
+
+var _ adv.AccountsService = &MockAccountsService{}
+
+// MockProductsService is a mock of adv.ProductsService. Set the Func field of each method a test expects
+// to be called; calling a method without one returns ErrNotStubbed.
+type MockProductsService struct {
+	ListProductsFunc            func(ctx context.Context, request *adv.ListProductsRequest) (*adv.ListProductsResponse, error)
+	GetProductFunc              func(ctx context.Context, request *adv.GetProductRequest) (*adv.GetProductResponse, error)
+	GetProductBookFunc          func(ctx context.Context, request *adv.GetProductBookRequest) (*adv.GetProductBookResponse, error)
+	GetBestBidAskFunc           func(ctx context.Context, request *adv.GetBestBidAskRequest) (*adv.GetBestBidAskResponse, error)
+	GetProductCandlesFunc       func(ctx context.Context, request *adv.GetProductCandlesRequest) (*adv.GetProductCandlesResponse, error)
+	GetMarketTradesFunc         func(ctx context.Context, request *adv.GetMarketTradesRequest) (*adv.GetMarketTradesResponse, error)
+	ListPublicProductsFunc      func(ctx context.Context, request *adv.ListPublicProductsRequest) (*adv.ListPublicProductsResponse, error)
+	GetPublicProductFunc        func(ctx context.Context, request *adv.GetPublicProductRequest) (*adv.GetPublicProductResponse, error)
+	GetPublicProductBookFunc    func(ctx context.Context, request *adv.GetPublicProductBookRequest) (*adv.GetPublicProductBookResponse, error)
+	GetPublicProductCandlesFunc func(ctx context.Context, request *adv.GetPublicProductCandlesRequest) (*adv.GetPublicProductCandlesResponse, error)
+	GetPublicMarketTradesFunc   func(ctx context.Context, request *adv.GetPublicMarketTradesRequest) (*adv.GetPublicMarketTradesResponse, error)
+	GetServerTimeFunc           func(ctx context.Context, request *adv.GetServerTimeRequest) (*adv.GetServerTimeResponse, error)
+
+	mu                           sync.Mutex
+	listProductsCalls            []*adv.ListProductsRequest
+	getProductCalls              []*adv.GetProductRequest
+	getProductBookCalls          []*adv.GetProductBookRequest
+	getBestBidAskCalls           []*adv.GetBestBidAskRequest
+	getProductCandlesCalls       []*adv.GetProductCandlesRequest
+	getMarketTradesCalls         []*adv.GetMarketTradesRequest
+	listPublicProductsCalls      []*adv.ListPublicProductsRequest
+	getPublicProductCalls        []*adv.GetPublicProductRequest
+	getPublicProductBookCalls    []*adv.GetPublicProductBookRequest
+	getPublicProductCandlesCalls []*adv.GetPublicProductCandlesRequest
+	getPublicMarketTradesCalls   []*adv.GetPublicMarketTradesRequest
+	getServerTimeCalls           []*adv.GetServerTimeRequest
+}
+
+func (m *MockProductsService) ListProducts(ctx context.Context, request *adv.ListProductsRequest) (*adv.ListProductsResponse, error) {
+	m.mu.Lock()
+	m.listProductsCalls = append(m.listProductsCalls, request)
+	fn := m.ListProductsFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: ProductsService.ListProducts", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// ListProductsCalls returns the requests passed to ListProducts, oldest first.
+func (m *MockProductsService) ListProductsCalls() []*adv.ListProductsRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.ListProductsRequest(nil), m.listProductsCalls...)
+}
+
+func (m *MockProductsService) GetProduct(ctx context.Context, request *adv.GetProductRequest) (*adv.GetProductResponse, error) {
+	m.mu.Lock()
+	m.getProductCalls = append(m.getProductCalls, request)
+	fn := m.GetProductFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: ProductsService.GetProduct", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetProductCalls returns the requests passed to GetProduct, oldest first.
+func (m *MockProductsService) GetProductCalls() []*adv.GetProductRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetProductRequest(nil), m.getProductCalls...)
+}
+
+func (m *MockProductsService) GetProductBook(ctx context.Context, request *adv.GetProductBookRequest) (*adv.GetProductBookResponse, error) {
+	m.mu.Lock()
+	m.getProductBookCalls = append(m.getProductBookCalls, request)
+	fn := m.GetProductBookFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: ProductsService.GetProductBook", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetProductBookCalls returns the requests passed to GetProductBook, oldest first.
+func (m *MockProductsService) GetProductBookCalls() []*adv.GetProductBookRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetProductBookRequest(nil), m.getProductBookCalls...)
+}
+
+func (m *MockProductsService) GetBestBidAsk(ctx context.Context, request *adv.GetBestBidAskRequest) (*adv.GetBestBidAskResponse, error) {
+	m.mu.Lock()
+	m.getBestBidAskCalls = append(m.getBestBidAskCalls, request)
+	fn := m.GetBestBidAskFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: ProductsService.GetBestBidAsk", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetBestBidAskCalls returns the requests passed to GetBestBidAsk, oldest first.
+func (m *MockProductsService) GetBestBidAskCalls() []*adv.GetBestBidAskRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetBestBidAskRequest(nil), m.getBestBidAskCalls...)
+}
+
+func (m *MockProductsService) GetProductCandles(ctx context.Context, request *adv.GetProductCandlesRequest) (*adv.GetProductCandlesResponse, error) {
+	m.mu.Lock()
+	m.getProductCandlesCalls = append(m.getProductCandlesCalls, request)
+	fn := m.GetProductCandlesFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: ProductsService.GetProductCandles", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetProductCandlesCalls returns the requests passed to GetProductCandles, oldest first.
+func (m *MockProductsService) GetProductCandlesCalls() []*adv.GetProductCandlesRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetProductCandlesRequest(nil), m.getProductCandlesCalls...)
+}
+
+func (m *MockProductsService) GetMarketTrades(ctx context.Context, request *adv.GetMarketTradesRequest) (*adv.GetMarketTradesResponse, error) {
+	m.mu.Lock()
+	m.getMarketTradesCalls = append(m.getMarketTradesCalls, request)
+	fn := m.GetMarketTradesFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: ProductsService.GetMarketTrades", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetMarketTradesCalls returns the requests passed to GetMarketTrades, oldest first.
+func (m *MockProductsService) GetMarketTradesCalls() []*adv.GetMarketTradesRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetMarketTradesRequest(nil), m.getMarketTradesCalls...)
+}
+
+func (m *MockProductsService) ListPublicProducts(ctx context.Context, request *adv.ListPublicProductsRequest) (*adv.ListPublicProductsResponse, error) {
+	m.mu.Lock()
+	m.listPublicProductsCalls = append(m.listPublicProductsCalls, request)
+	fn := m.ListPublicProductsFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: ProductsService.ListPublicProducts", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// ListPublicProductsCalls returns the requests passed to ListPublicProducts, oldest first.
+func (m *MockProductsService) ListPublicProductsCalls() []*adv.ListPublicProductsRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.ListPublicProductsRequest(nil), m.listPublicProductsCalls...)
+}
+
+func (m *MockProductsService) GetPublicProduct(ctx context.Context, request *adv.GetPublicProductRequest) (*adv.GetPublicProductResponse, error) {
+	m.mu.Lock()
+	m.getPublicProductCalls = append(m.getPublicProductCalls, request)
+	fn := m.GetPublicProductFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: ProductsService.GetPublicProduct", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetPublicProductCalls returns the requests passed to GetPublicProduct, oldest first.
+func (m *MockProductsService) GetPublicProductCalls() []*adv.GetPublicProductRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetPublicProductRequest(nil), m.getPublicProductCalls...)
+}
+
+func (m *MockProductsService) GetPublicProductBook(ctx context.Context, request *adv.GetPublicProductBookRequest) (*adv.GetPublicProductBookResponse, error) {
+	m.mu.Lock()
+	m.getPublicProductBookCalls = append(m.getPublicProductBookCalls, request)
+	fn := m.GetPublicProductBookFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: ProductsService.GetPublicProductBook", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetPublicProductBookCalls returns the requests passed to GetPublicProductBook, oldest first.
+func (m *MockProductsService) GetPublicProductBookCalls() []*adv.GetPublicProductBookRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetPublicProductBookRequest(nil), m.getPublicProductBookCalls...)
+}
+
+func (m *MockProductsService) GetPublicProductCandles(ctx context.Context, request *adv.GetPublicProductCandlesRequest) (*adv.GetPublicProductCandlesResponse, error) {
+	m.mu.Lock()
+	m.getPublicProductCandlesCalls = append(m.getPublicProductCandlesCalls, request)
+	fn := m.GetPublicProductCandlesFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: ProductsService.GetPublicProductCandles", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetPublicProductCandlesCalls returns the requests passed to GetPublicProductCandles, oldest first.
+func (m *MockProductsService) GetPublicProductCandlesCalls() []*adv.GetPublicProductCandlesRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetPublicProductCandlesRequest(nil), m.getPublicProductCandlesCalls...)
+}
+
+func (m *MockProductsService) GetPublicMarketTrades(ctx context.Context, request *adv.GetPublicMarketTradesRequest) (*adv.GetPublicMarketTradesResponse, error) {
+	m.mu.Lock()
+	m.getPublicMarketTradesCalls = append(m.getPublicMarketTradesCalls, request)
+	fn := m.GetPublicMarketTradesFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: ProductsService.GetPublicMarketTrades", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetPublicMarketTradesCalls returns the requests passed to GetPublicMarketTrades, oldest first.
+func (m *MockProductsService) GetPublicMarketTradesCalls() []*adv.GetPublicMarketTradesRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetPublicMarketTradesRequest(nil), m.getPublicMarketTradesCalls...)
+}
+
+func (m *MockProductsService) GetServerTime(ctx context.Context, request *adv.GetServerTimeRequest) (*adv.GetServerTimeResponse, error) {
+	m.mu.Lock()
+	m.getServerTimeCalls = append(m.getServerTimeCalls, request)
+	fn := m.GetServerTimeFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: ProductsService.GetServerTime", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetServerTimeCalls returns the requests passed to GetServerTime, oldest first.
+func (m *MockProductsService) GetServerTimeCalls() []*adv.GetServerTimeRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetServerTimeRequest(nil), m.getServerTimeCalls...)
+}
+
+var _ adv.ProductsService = &MockProductsService{}
+
+// MockPortfoliosService is a mock of adv.PortfoliosService. Set the Func field of each method a test expects
+// to be called; calling a method without one returns ErrNotStubbed.
+type MockPortfoliosService struct {
+	ListPortfoliosFunc        func(ctx context.Context, request *adv.ListPortfoliosRequest) (*adv.ListPortfoliosResponse, error)
+	CreatePortfolioFunc       func(ctx context.Context, request *adv.CreatePortfolioRequest) (*adv.CreatePortfolioResponse, error)
+	EditPortfolioFunc         func(ctx context.Context, request *adv.EditPortfolioRequest) (*adv.EditPortfolioResponse, error)
+	DeletePortfolioFunc       func(ctx context.Context, request *adv.DeletePortfolioRequest) (*adv.DeletePortfolioResponse, error)
+	GetPortfolioBreakdownFunc func(ctx context.Context, request *adv.GetPortfolioBreakdownRequest) (*adv.GetPortfolioBreakdownResponse, error)
+	MovePortfolioFundsFunc    func(ctx context.Context, request *adv.MovePortfolioFundsRequest) (*adv.MovePortfolioFundsResponse, error)
+
+	mu                         sync.Mutex
+	listPortfoliosCalls        []*adv.ListPortfoliosRequest
+	createPortfolioCalls       []*adv.CreatePortfolioRequest
+	editPortfolioCalls         []*adv.EditPortfolioRequest
+	deletePortfolioCalls       []*adv.DeletePortfolioRequest
+	getPortfolioBreakdownCalls []*adv.GetPortfolioBreakdownRequest
+	movePortfolioFundsCalls    []*adv.MovePortfolioFundsRequest
+}
+
+func (m *MockPortfoliosService) ListPortfolios(ctx context.Context, request *adv.ListPortfoliosRequest) (*adv.ListPortfoliosResponse, error) {
+	m.mu.Lock()
+	m.listPortfoliosCalls = append(m.listPortfoliosCalls, request)
+	fn := m.ListPortfoliosFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: PortfoliosService.ListPortfolios", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// ListPortfoliosCalls returns the requests passed to ListPortfolios, oldest first.
+func (m *MockPortfoliosService) ListPortfoliosCalls() []*adv.ListPortfoliosRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.ListPortfoliosRequest(nil), m.listPortfoliosCalls...)
+}
+
+func (m *MockPortfoliosService) CreatePortfolio(ctx context.Context, request *adv.CreatePortfolioRequest) (*adv.CreatePortfolioResponse, error) {
+	m.mu.Lock()
+	m.createPortfolioCalls = append(m.createPortfolioCalls, request)
+	fn := m.CreatePortfolioFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: PortfoliosService.CreatePortfolio", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// CreatePortfolioCalls returns the requests passed to CreatePortfolio, oldest first.
+func (m *MockPortfoliosService) CreatePortfolioCalls() []*adv.CreatePortfolioRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.CreatePortfolioRequest(nil), m.createPortfolioCalls...)
+}
+
+func (m *MockPortfoliosService) EditPortfolio(ctx context.Context, request *adv.EditPortfolioRequest) (*adv.EditPortfolioResponse, error) {
+	m.mu.Lock()
+	m.editPortfolioCalls = append(m.editPortfolioCalls, request)
+	fn := m.EditPortfolioFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: PortfoliosService.EditPortfolio", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// EditPortfolioCalls returns the requests passed to EditPortfolio, oldest first.
+func (m *MockPortfoliosService) EditPortfolioCalls() []*adv.EditPortfolioRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.EditPortfolioRequest(nil), m.editPortfolioCalls...)
+}
+
+func (m *MockPortfoliosService) DeletePortfolio(ctx context.Context, request *adv.DeletePortfolioRequest) (*adv.DeletePortfolioResponse, error) {
+	m.mu.Lock()
+	m.deletePortfolioCalls = append(m.deletePortfolioCalls, request)
+	fn := m.DeletePortfolioFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: PortfoliosService.DeletePortfolio", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// DeletePortfolioCalls returns the requests passed to DeletePortfolio, oldest first.
+func (m *MockPortfoliosService) DeletePortfolioCalls() []*adv.DeletePortfolioRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.DeletePortfolioRequest(nil), m.deletePortfolioCalls...)
+}
+
+func (m *MockPortfoliosService) GetPortfolioBreakdown(ctx context.Context, request *adv.GetPortfolioBreakdownRequest) (*adv.GetPortfolioBreakdownResponse, error) {
+	m.mu.Lock()
+	m.getPortfolioBreakdownCalls = append(m.getPortfolioBreakdownCalls, request)
+	fn := m.GetPortfolioBreakdownFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: PortfoliosService.GetPortfolioBreakdown", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetPortfolioBreakdownCalls returns the requests passed to GetPortfolioBreakdown, oldest first.
+func (m *MockPortfoliosService) GetPortfolioBreakdownCalls() []*adv.GetPortfolioBreakdownRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetPortfolioBreakdownRequest(nil), m.getPortfolioBreakdownCalls...)
+}
+
+func (m *MockPortfoliosService) MovePortfolioFunds(ctx context.Context, request *adv.MovePortfolioFundsRequest) (*adv.MovePortfolioFundsResponse, error) {
+	m.mu.Lock()
+	m.movePortfolioFundsCalls = append(m.movePortfolioFundsCalls, request)
+	fn := m.MovePortfolioFundsFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: PortfoliosService.MovePortfolioFunds", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// MovePortfolioFundsCalls returns the requests passed to MovePortfolioFunds, oldest first.
+func (m *MockPortfoliosService) MovePortfolioFundsCalls() []*adv.MovePortfolioFundsRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.MovePortfolioFundsRequest(nil), m.movePortfolioFundsCalls...)
+}
+
+var _ adv.PortfoliosService = &MockPortfoliosService{}
+
+// MockFuturesService is a mock of adv.FuturesService. Set the Func field of each method a test expects
+// to be called; calling a method without one returns ErrNotStubbed.
+type MockFuturesService struct {
+	GetFuturesBalanceSummaryFunc   func(ctx context.Context, request *adv.GetFuturesBalanceSummaryRequest) (*adv.GetFuturesBalanceSummaryResponse, error)
+	ListFuturesPositionsFunc       func(ctx context.Context, request *adv.ListFuturesPositionsRequest) (*adv.ListFuturesPositionsResponse, error)
+	GetFuturesPositionFunc         func(ctx context.Context, request *adv.GetFuturesPositionRequest) (*adv.GetFuturesPositionResponse, error)
+	ScheduleFuturesSweepFunc       func(ctx context.Context, request *adv.ScheduleFuturesSweepRequest) (*adv.ScheduleFuturesSweepResponse, error)
+	ListFuturesSweepsFunc          func(ctx context.Context, request *adv.ListFuturesSweepsRequest) (*adv.ListFuturesSweepsResponse, error)
+	CancelPendingFuturesSweepsFunc func(ctx context.Context, request *adv.CancelPendingFuturesSweepsRequest) (*adv.CancelPendingFuturesSweepsResponse, error)
+
+	mu                              sync.Mutex
+	getFuturesBalanceSummaryCalls   []*adv.GetFuturesBalanceSummaryRequest
+	listFuturesPositionsCalls       []*adv.ListFuturesPositionsRequest
+	getFuturesPositionCalls         []*adv.GetFuturesPositionRequest
+	scheduleFuturesSweepCalls       []*adv.ScheduleFuturesSweepRequest
+	listFuturesSweepsCalls          []*adv.ListFuturesSweepsRequest
+	cancelPendingFuturesSweepsCalls []*adv.CancelPendingFuturesSweepsRequest
+}
+
+func (m *MockFuturesService) GetFuturesBalanceSummary(ctx context.Context, request *adv.GetFuturesBalanceSummaryRequest) (*adv.GetFuturesBalanceSummaryResponse, error) {
+	m.mu.Lock()
+	m.getFuturesBalanceSummaryCalls = append(m.getFuturesBalanceSummaryCalls, request)
+	fn := m.GetFuturesBalanceSummaryFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: FuturesService.GetFuturesBalanceSummary", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetFuturesBalanceSummaryCalls returns the requests passed to GetFuturesBalanceSummary, oldest first.
+func (m *MockFuturesService) GetFuturesBalanceSummaryCalls() []*adv.GetFuturesBalanceSummaryRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetFuturesBalanceSummaryRequest(nil), m.getFuturesBalanceSummaryCalls...)
+}
+
+func (m *MockFuturesService) ListFuturesPositions(ctx context.Context, request *adv.ListFuturesPositionsRequest) (*adv.ListFuturesPositionsResponse, error) {
+	m.mu.Lock()
+	m.listFuturesPositionsCalls = append(m.listFuturesPositionsCalls, request)
+	fn := m.ListFuturesPositionsFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: FuturesService.ListFuturesPositions", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// ListFuturesPositionsCalls returns the requests passed to ListFuturesPositions, oldest first.
+func (m *MockFuturesService) ListFuturesPositionsCalls() []*adv.ListFuturesPositionsRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.ListFuturesPositionsRequest(nil), m.listFuturesPositionsCalls...)
+}
+
+func (m *MockFuturesService) GetFuturesPosition(ctx context.Context, request *adv.GetFuturesPositionRequest) (*adv.GetFuturesPositionResponse, error) {
+	m.mu.Lock()
+	m.getFuturesPositionCalls = append(m.getFuturesPositionCalls, request)
+	fn := m.GetFuturesPositionFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: FuturesService.GetFuturesPosition", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetFuturesPositionCalls returns the requests passed to GetFuturesPosition, oldest first.
+func (m *MockFuturesService) GetFuturesPositionCalls() []*adv.GetFuturesPositionRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetFuturesPositionRequest(nil), m.getFuturesPositionCalls...)
+}
+
+func (m *MockFuturesService) ScheduleFuturesSweep(ctx context.Context, request *adv.ScheduleFuturesSweepRequest) (*adv.ScheduleFuturesSweepResponse, error) {
+	m.mu.Lock()
+	m.scheduleFuturesSweepCalls = append(m.scheduleFuturesSweepCalls, request)
+	fn := m.ScheduleFuturesSweepFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: FuturesService.ScheduleFuturesSweep", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// ScheduleFuturesSweepCalls returns the requests passed to ScheduleFuturesSweep, oldest first.
+func (m *MockFuturesService) ScheduleFuturesSweepCalls() []*adv.ScheduleFuturesSweepRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.ScheduleFuturesSweepRequest(nil), m.scheduleFuturesSweepCalls...)
+}
+
+func (m *MockFuturesService) ListFuturesSweeps(ctx context.Context, request *adv.ListFuturesSweepsRequest) (*adv.ListFuturesSweepsResponse, error) {
+	m.mu.Lock()
+	m.listFuturesSweepsCalls = append(m.listFuturesSweepsCalls, request)
+	fn := m.ListFuturesSweepsFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: FuturesService.ListFuturesSweeps", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// ListFuturesSweepsCalls returns the requests passed to ListFuturesSweeps, oldest first.
+func (m *MockFuturesService) ListFuturesSweepsCalls() []*adv.ListFuturesSweepsRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.ListFuturesSweepsRequest(nil), m.listFuturesSweepsCalls...)
+}
+
+func (m *MockFuturesService) CancelPendingFuturesSweeps(ctx context.Context, request *adv.CancelPendingFuturesSweepsRequest) (*adv.CancelPendingFuturesSweepsResponse, error) {
+	m.mu.Lock()
+	m.cancelPendingFuturesSweepsCalls = append(m.cancelPendingFuturesSweepsCalls, request)
+	fn := m.CancelPendingFuturesSweepsFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: FuturesService.CancelPendingFuturesSweeps", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// CancelPendingFuturesSweepsCalls returns the requests passed to CancelPendingFuturesSweeps, oldest first.
+func (m *MockFuturesService) CancelPendingFuturesSweepsCalls() []*adv.CancelPendingFuturesSweepsRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.CancelPendingFuturesSweepsRequest(nil), m.cancelPendingFuturesSweepsCalls...)
+}
+
+var _ adv.FuturesService = &MockFuturesService{}
+
+// MockPerpetualsService is a mock of adv.PerpetualsService. Set the Func field of each method a test expects
+// to be called; calling a method without one returns ErrNotStubbed.
+type MockPerpetualsService struct {
+	AllocatePortfolioFunc             func(ctx context.Context, request *adv.AllocatePortfolioRequest) (*adv.AllocatePortfolioResponse, error)
+	GetPerpetualsPortfolioSummaryFunc func(ctx context.Context, request *adv.GetPerpetualsPortfolioSummaryRequest) (*adv.GetPerpetualsPortfolioSummaryResponse, error)
+	ListPerpetualsPositionsFunc       func(ctx context.Context, request *adv.ListPerpetualsPositionsRequest) (*adv.ListPerpetualsPositionsResponse, error)
+	GetPerpetualsPositionFunc         func(ctx context.Context, request *adv.GetPerpetualsPositionRequest) (*adv.GetPerpetualsPositionResponse, error)
+
+	mu                                 sync.Mutex
+	allocatePortfolioCalls             []*adv.AllocatePortfolioRequest
+	getPerpetualsPortfolioSummaryCalls []*adv.GetPerpetualsPortfolioSummaryRequest
+	listPerpetualsPositionsCalls       []*adv.ListPerpetualsPositionsRequest
+	getPerpetualsPositionCalls         []*adv.GetPerpetualsPositionRequest
+}
+
+func (m *MockPerpetualsService) AllocatePortfolio(ctx context.Context, request *adv.AllocatePortfolioRequest) (*adv.AllocatePortfolioResponse, error) {
+	m.mu.Lock()
+	m.allocatePortfolioCalls = append(m.allocatePortfolioCalls, request)
+	fn := m.AllocatePortfolioFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: PerpetualsService.AllocatePortfolio", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// AllocatePortfolioCalls returns the requests passed to AllocatePortfolio, oldest first.
+func (m *MockPerpetualsService) AllocatePortfolioCalls() []*adv.AllocatePortfolioRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.AllocatePortfolioRequest(nil), m.allocatePortfolioCalls...)
+}
+
+func (m *MockPerpetualsService) GetPerpetualsPortfolioSummary(ctx context.Context, request *adv.GetPerpetualsPortfolioSummaryRequest) (*adv.GetPerpetualsPortfolioSummaryResponse, error) {
+	m.mu.Lock()
+	m.getPerpetualsPortfolioSummaryCalls = append(m.getPerpetualsPortfolioSummaryCalls, request)
+	fn := m.GetPerpetualsPortfolioSummaryFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: PerpetualsService.GetPerpetualsPortfolioSummary", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetPerpetualsPortfolioSummaryCalls returns the requests passed to GetPerpetualsPortfolioSummary, oldest first.
+func (m *MockPerpetualsService) GetPerpetualsPortfolioSummaryCalls() []*adv.GetPerpetualsPortfolioSummaryRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetPerpetualsPortfolioSummaryRequest(nil), m.getPerpetualsPortfolioSummaryCalls...)
+}
+
+func (m *MockPerpetualsService) ListPerpetualsPositions(ctx context.Context, request *adv.ListPerpetualsPositionsRequest) (*adv.ListPerpetualsPositionsResponse, error) {
+	m.mu.Lock()
+	m.listPerpetualsPositionsCalls = append(m.listPerpetualsPositionsCalls, request)
+	fn := m.ListPerpetualsPositionsFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: PerpetualsService.ListPerpetualsPositions", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// ListPerpetualsPositionsCalls returns the requests passed to ListPerpetualsPositions, oldest first.
+func (m *MockPerpetualsService) ListPerpetualsPositionsCalls() []*adv.ListPerpetualsPositionsRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.ListPerpetualsPositionsRequest(nil), m.listPerpetualsPositionsCalls...)
+}
+
+func (m *MockPerpetualsService) GetPerpetualsPosition(ctx context.Context, request *adv.GetPerpetualsPositionRequest) (*adv.GetPerpetualsPositionResponse, error) {
+	m.mu.Lock()
+	m.getPerpetualsPositionCalls = append(m.getPerpetualsPositionCalls, request)
+	fn := m.GetPerpetualsPositionFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: PerpetualsService.GetPerpetualsPosition", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetPerpetualsPositionCalls returns the requests passed to GetPerpetualsPosition, oldest first.
+func (m *MockPerpetualsService) GetPerpetualsPositionCalls() []*adv.GetPerpetualsPositionRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetPerpetualsPositionRequest(nil), m.getPerpetualsPositionCalls...)
+}
+
+var _ adv.PerpetualsService = &MockPerpetualsService{}
+
+// MockConvertService is a mock of adv.ConvertService. Set the Func field of each method a test expects
+// to be called; calling a method without one returns ErrNotStubbed.
+type MockConvertService struct {
+	CreateConvertQuoteFunc func(ctx context.Context, request *adv.CreateConvertQuoteRequest) (*adv.CreateConvertQuoteResponse, error)
+	CommitConvertQuoteFunc func(ctx context.Context, request *adv.CommitConvertQuoteRequest) (*adv.CommitConvertQuoteResponse, error)
+	GetConvertTradeFunc    func(ctx context.Context, request *adv.GetConvertTradeRequest) (*adv.GetConvertTradeResponse, error)
+
+	mu                      sync.Mutex
+	createConvertQuoteCalls []*adv.CreateConvertQuoteRequest
+	commitConvertQuoteCalls []*adv.CommitConvertQuoteRequest
+	getConvertTradeCalls    []*adv.GetConvertTradeRequest
+}
+
+func (m *MockConvertService) CreateConvertQuote(ctx context.Context, request *adv.CreateConvertQuoteRequest) (*adv.CreateConvertQuoteResponse, error) {
+	m.mu.Lock()
+	m.createConvertQuoteCalls = append(m.createConvertQuoteCalls, request)
+	fn := m.CreateConvertQuoteFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: ConvertService.CreateConvertQuote", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// CreateConvertQuoteCalls returns the requests passed to CreateConvertQuote, oldest first.
+func (m *MockConvertService) CreateConvertQuoteCalls() []*adv.CreateConvertQuoteRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.CreateConvertQuoteRequest(nil), m.createConvertQuoteCalls...)
+}
+
+func (m *MockConvertService) CommitConvertQuote(ctx context.Context, request *adv.CommitConvertQuoteRequest) (*adv.CommitConvertQuoteResponse, error) {
+	m.mu.Lock()
+	m.commitConvertQuoteCalls = append(m.commitConvertQuoteCalls, request)
+	fn := m.CommitConvertQuoteFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: ConvertService.CommitConvertQuote", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// CommitConvertQuoteCalls returns the requests passed to CommitConvertQuote, oldest first.
+func (m *MockConvertService) CommitConvertQuoteCalls() []*adv.CommitConvertQuoteRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.CommitConvertQuoteRequest(nil), m.commitConvertQuoteCalls...)
+}
+
+func (m *MockConvertService) GetConvertTrade(ctx context.Context, request *adv.GetConvertTradeRequest) (*adv.GetConvertTradeResponse, error) {
+	m.mu.Lock()
+	m.getConvertTradeCalls = append(m.getConvertTradeCalls, request)
+	fn := m.GetConvertTradeFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, fmt.Errorf("%w: ConvertService.GetConvertTrade", ErrNotStubbed)
+	}
+
+	return fn(ctx, request)
+}
+
+// GetConvertTradeCalls returns the requests passed to GetConvertTrade, oldest first.
+func (m *MockConvertService) GetConvertTradeCalls() []*adv.GetConvertTradeRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*adv.GetConvertTradeRequest(nil), m.getConvertTradeCalls...)
+}
+
+var _ adv.ConvertService = &MockConvertService{}
@@ -0,0 +1,287 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advtest
+
+import (
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"strings"
+)
+
+func (s *Server) listAccounts(c *call) (interface{}, error) {
+
+	portfolioUuid := c.query.Get("retail_portfolio_id")
+
+	var accounts []*adv.Account
+	for _, a := range s.state.accounts {
+		if len(portfolioUuid) > 0 && a.RetailPortfolioId != portfolioUuid {
+			continue
+		}
+		copied := *a
+		accounts = append(accounts, &copied)
+	}
+
+	accounts, cursor := page(accounts, c.query.Get("cursor"), c.query.Get("limit"))
+
+	return &adv.ListAccountsResponse{
+		Accounts: accounts,
+		HasNext:  len(cursor) > 0,
+		Cursor:   cursor,
+	}, nil
+}
+
+func (s *Server) getAccount(c *call) (interface{}, error) {
+	for _, a := range s.state.accounts {
+		if a.Uuid == c.params[0] {
+			copied := *a
+			return &adv.GetAccountResponse{Accounts: &copied}, nil
+		}
+	}
+	return nil, notFound("account %s not found", c.params[0])
+}
+
+func (s *Server) listPortfolios(c *call) (interface{}, error) {
+
+	portfolioType := c.query.Get("portfolio_type")
+
+	portfolios := []*adv.Portfolio{}
+	for _, p := range s.state.portfolios {
+		if p.Deleted || (len(portfolioType) > 0 && p.Type != portfolioType) {
+			continue
+		}
+		copied := *p
+		portfolios = append(portfolios, &copied)
+	}
+
+	return &adv.ListPortfoliosResponse{Portfolios: portfolios}, nil
+}
+
+func (s *Server) createPortfolio(c *call) (interface{}, error) {
+
+	request := &adv.CreatePortfolioRequest{}
+	if err := c.decode(request); err != nil {
+		return nil, err
+	}
+
+	if len(request.Name) == 0 {
+		return nil, invalidArgument("portfolio name is required")
+	}
+
+	for _, p := range s.state.portfolios {
+		if !p.Deleted && p.Name == request.Name {
+			return nil, invalidArgument("portfolio %s already exists", request.Name)
+		}
+	}
+
+	p := &adv.Portfolio{Name: request.Name, Uuid: newId(), Type: "CONSUMER"}
+	s.state.portfolios = append(s.state.portfolios, p)
+
+	copied := *p
+	return &adv.CreatePortfolioResponse{Portfolio: &copied}, nil
+}
+
+func (s *Server) editPortfolio(c *call) (interface{}, error) {
+
+	request := &adv.EditPortfolioRequest{}
+	if err := c.decode(request); err != nil {
+		return nil, err
+	}
+
+	p := s.state.portfolio(c.params[0])
+	if p == nil {
+		return nil, notFound("portfolio %s not found", c.params[0])
+	}
+
+	p.Name = request.Name
+
+	copied := *p
+	return &adv.EditPortfolioResponse{Portfolio: &copied}, nil
+}
+
+func (s *Server) deletePortfolio(c *call) (interface{}, error) {
+
+	p := s.state.portfolio(c.params[0])
+	if p == nil {
+		return nil, notFound("portfolio %s not found", c.params[0])
+	}
+
+	if p.Uuid == s.state.defaultPortfolio {
+		return nil, invalidArgument("the default portfolio cannot be deleted")
+	}
+
+	p.Deleted = true
+
+	return &adv.DeletePortfolioResponse{}, nil
+}
+
+func (s *Server) movePortfolioFunds(c *call) (interface{}, error) {
+
+	request := &adv.MovePortfolioFundsRequest{}
+	if err := c.decode(request); err != nil {
+		return nil, err
+	}
+
+	if request.Funds == nil {
+		return nil, invalidArgument("funds are required")
+	}
+
+	if s.state.portfolio(request.SourcePortfolioUuid) == nil {
+		return nil, notFound("portfolio %s not found", request.SourcePortfolioUuid)
+	}
+
+	if s.state.portfolio(request.TargetPortfolioUuid) == nil {
+		return nil, notFound("portfolio %s not found", request.TargetPortfolioUuid)
+	}
+
+	amount := parseFloat(request.Funds.Value)
+	if amount <= 0 {
+		return nil, invalidArgument("invalid amount %s", request.Funds.Value)
+	}
+
+	source := s.state.account(request.SourcePortfolioUuid, request.Funds.Currency)
+	if source == nil || parseFloat(source.AvailableBalance.Value) < amount {
+		return nil, invalidArgument("insufficient %s in portfolio %s", request.Funds.Currency, request.SourcePortfolioUuid)
+	}
+
+	s.state.credit(request.SourcePortfolioUuid, request.Funds.Currency, -amount)
+	s.state.credit(request.TargetPortfolioUuid, request.Funds.Currency, amount)
+
+	return &adv.MovePortfolioFundsResponse{
+		SourcePortfolioUuid: request.SourcePortfolioUuid,
+		TargetPortfolioUuid: request.TargetPortfolioUuid,
+	}, nil
+}
+
+func (s *Server) getPortfolioBreakdown(c *call) (interface{}, error) {
+
+	p := s.state.portfolio(c.params[0])
+	if p == nil {
+		return nil, notFound("portfolio %s not found", c.params[0])
+	}
+
+	breakdown := &adv.Breakdown{
+		Portfolio:        *p,
+		SpotPositions:    []adv.SpotPosition{},
+		PerpPositions:    []adv.PerpPosition{},
+		FuturesPositions: []adv.FuturesPosition{},
+	}
+
+	var total, cash, crypto float64
+	for _, a := range s.state.accounts {
+		if a.RetailPortfolioId != p.Uuid {
+			continue
+		}
+
+		quantity := parseFloat(a.AvailableBalance.Value) + parseFloat(a.Hold.Value)
+		value := quantity * s.state.rate(a.Currency, "USD")
+		isCash := isUsdEquivalent(a.Currency)
+
+		breakdown.SpotPositions = append(breakdown.SpotPositions, adv.SpotPosition{
+			Asset:                strings.ToUpper(a.Currency),
+			AccountUuid:          a.Uuid,
+			TotalBalanceFiat:     value,
+			TotalBalanceCrypto:   quantity,
+			AvailableToTradeFiat: parseFloat(a.AvailableBalance.Value) * s.state.rate(a.Currency, "USD"),
+			IsCash:               isCash,
+		})
+
+		total += value
+		if isCash {
+			cash += value
+		} else {
+			crypto += value
+		}
+	}
+
+	for i := range breakdown.SpotPositions {
+		if total > 0 {
+			breakdown.SpotPositions[i].Allocation = breakdown.SpotPositions[i].TotalBalanceFiat / total
+		}
+	}
+
+	var futuresPnl float64
+	if p.Uuid == s.state.defaultPortfolio {
+		for _, f := range s.state.futuresPositions {
+			breakdown.FuturesPositions = append(breakdown.FuturesPositions, adv.FuturesPosition{
+				ProductId:     f.ProductId,
+				Side:          f.Side,
+				Amount:        f.NumberOfContracts,
+				AvgEntryPrice: f.AvgEntryPrice,
+				CurrentPrice:  f.CurrentPrice,
+				UnrealizedPnl: f.UnrealizedPnl,
+				Expiry:        f.ExpirationTime,
+			})
+			futuresPnl += parseFloat(f.UnrealizedPnl)
+		}
+	}
+
+	var perpPnl float64
+	for _, pos := range s.state.perpsPositions {
+		if pos.PortfolioUuid != p.Uuid {
+			continue
+		}
+		breakdown.PerpPositions = append(breakdown.PerpPositions, adv.PerpPosition{
+			ProductId:        pos.ProductId,
+			ProductUuid:      pos.ProductUuid,
+			Symbol:           pos.Symbol,
+			Vwap:             adv.DualCurrencyValue{RawCurrency: pos.Vwap, UserNativeCurrency: pos.Vwap},
+			PositionSide:     pos.PositionSide,
+			NetSize:          pos.NetSize,
+			BuyOrderSize:     pos.BuyOrderSize,
+			SellOrderSize:    pos.SellOrderSize,
+			ImContribution:   pos.ImContribution,
+			UnrealizedPnl:    adv.DualCurrencyValue{RawCurrency: pos.UnrealizedPnl, UserNativeCurrency: pos.UnrealizedPnl},
+			MarkPrice:        adv.DualCurrencyValue{RawCurrency: pos.MarkPrice, UserNativeCurrency: pos.MarkPrice},
+			LiquidationPrice: adv.DualCurrencyValue{RawCurrency: pos.LiquidationPrice, UserNativeCurrency: pos.LiquidationPrice},
+			Leverage:         pos.Leverage,
+			ImNotional:       adv.DualCurrencyValue{RawCurrency: pos.ImNotional, UserNativeCurrency: pos.ImNotional},
+			MmNotional:       adv.DualCurrencyValue{RawCurrency: pos.MmNotional, UserNativeCurrency: pos.MmNotional},
+			MarginType:       pos.MarginType,
+		})
+		perpPnl += parseFloat(pos.UnrealizedPnl.Value)
+	}
+
+	usd := func(v float64) adv.Amount { return adv.Amount{Value: formatFloat(v), Currency: "USD"} }
+
+	breakdown.PortfolioBalances = adv.PortfolioBalances{
+		TotalBalance:               usd(total + futuresPnl + perpPnl),
+		TotalCashEquivalentBalance: usd(cash),
+		TotalCryptoBalance:         usd(crypto),
+		FuturesUnrealizedPnl:       usd(futuresPnl),
+		PerpUnrealizedPnl:          usd(perpPnl),
+	}
+
+	return &adv.GetPortfolioBreakdownResponse{Breakdown: breakdown}, nil
+}
+
+func (s *Server) listPaymentMethods(c *call) (interface{}, error) {
+	methods := []*adv.PaymentMethod{}
+	for _, m := range s.state.paymentMethods {
+		copied := *m
+		methods = append(methods, &copied)
+	}
+	return &adv.ListPaymentMethodsResponse{PaymentMethods: methods}, nil
+}
+
+func (s *Server) getPaymentMethod(c *call) (interface{}, error) {
+	for _, m := range s.state.paymentMethods {
+		if m.Id == c.params[0] {
+			copied := *m
+			return &adv.GetPaymentMethodResponse{PaymentMethod: &copied}, nil
+		}
+	}
+	return nil, notFound("payment method %s not found", c.params[0])
+}
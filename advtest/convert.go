@@ -0,0 +1,184 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advtest
+
+import (
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"time"
+)
+
+const convertQuoteTtl = 10 * time.Second
+
+type convertTrade struct {
+	adv.Convert
+	fromAccount *adv.Account
+	toAccount   *adv.Account
+	amount      float64
+	received    float64
+	quotedAt    time.Time
+}
+
+// SetConvertTerms sets the fee rate, as a fraction of the source amount, and the user warnings
+// attached to subsequent convert quotes.
+func (s *Server) SetConvertTerms(feeRate float64, warnings ...adv.UserWarning) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.convertFeeRate = feeRate
+	s.state.convertWarnings = append([]adv.UserWarning(nil), warnings...)
+}
+
+func (st *state) accountByUuid(uuid string) *adv.Account {
+	for _, a := range st.accounts {
+		if a.Uuid == uuid {
+			return a
+		}
+	}
+	return nil
+}
+
+func (s *Server) createConvertQuote(c *call) (interface{}, error) {
+
+	request := &adv.CreateConvertQuoteRequest{}
+	if err := c.decode(request); err != nil {
+		return nil, err
+	}
+
+	from := s.state.accountByUuid(request.FromAccount)
+	if from == nil {
+		return nil, notFound("account %s not found", request.FromAccount)
+	}
+
+	to := s.state.accountByUuid(request.ToAccount)
+	if to == nil {
+		return nil, notFound("account %s not found", request.ToAccount)
+	}
+
+	amount := parseFloat(request.Amount)
+	if amount <= 0 {
+		return nil, invalidArgument("invalid amount %s", request.Amount)
+	}
+
+	if amount > parseFloat(from.AvailableBalance.Value) {
+		return nil, invalidArgument("insufficient %s balance", from.Currency)
+	}
+
+	rate := s.state.rate(from.Currency, to.Currency)
+	if rate <= 0 {
+		return nil, invalidArgument("cannot convert %s to %s", from.Currency, to.Currency)
+	}
+
+	fee := amount * s.state.convertFeeRate
+	received := (amount - fee) * rate
+
+	source := func(v float64) adv.Amount { return adv.Amount{Value: formatFloat(v), Currency: from.Currency} }
+	target := func(v float64) adv.Amount { return adv.Amount{Value: formatFloat(v), Currency: to.Currency} }
+
+	totalFee := adv.Fee{Title: "Coinbase fee", Amount: source(fee)}
+
+	trade := &convertTrade{
+		Convert: adv.Convert{
+			Id:                newId(),
+			Status:            "TRADE_STATUS_CREATED",
+			UserEnteredAmount: source(amount),
+			Amount:            source(amount),
+			Subtotal:          source(amount - fee),
+			Total:             target(received),
+			Fees:              []adv.Fee{totalFee},
+			TotalFee:          totalFee,
+			Source: adv.SourceTargetInfo{
+				Type:          "LEDGER_ACCOUNT",
+				LedgerAccount: adv.LedgerAccount{AccountId: from.Uuid, Currency: from.Currency},
+			},
+			Target: adv.SourceTargetInfo{
+				Type:          "LEDGER_ACCOUNT",
+				LedgerAccount: adv.LedgerAccount{AccountId: to.Uuid, Currency: to.Currency},
+			},
+			UnitPrice: adv.UnitPriceInfo{
+				TargetToSource: source(1 / rate),
+				SourceToFiat:   adv.Amount{Value: formatFloat(s.state.rate(from.Currency, "USD")), Currency: "USD"},
+				TargetToFiat:   adv.Amount{Value: formatFloat(s.state.rate(to.Currency, "USD")), Currency: "USD"},
+			},
+			UserWarnings:       append([]adv.UserWarning{}, s.state.convertWarnings...),
+			SourceCurrency:     from.Currency,
+			TargetCurrency:     to.Currency,
+			SourceId:           from.Uuid,
+			TargetId:           to.Uuid,
+			ExchangeRate:       target(rate),
+			TotalFeeWithoutTax: totalFee,
+		},
+		fromAccount: from,
+		toAccount:   to,
+		amount:      amount,
+		received:    received,
+		quotedAt:    time.Now(),
+	}
+
+	s.state.converts[trade.Id] = trade
+
+	copied := trade.Convert
+	return &adv.CreateConvertQuoteResponse{Convert: &copied}, nil
+}
+
+func (s *Server) commitConvertQuote(c *call) (interface{}, error) {
+
+	request := &adv.CommitConvertQuoteRequest{}
+	if err := c.decode(request); err != nil {
+		return nil, err
+	}
+
+	trade, ok := s.state.converts[c.params[0]]
+	if !ok {
+		return nil, notFound("convert trade %s not found", c.params[0])
+	}
+
+	if request.FromAccount != trade.fromAccount.Uuid || request.ToAccount != trade.toAccount.Uuid {
+		return nil, invalidArgument("accounts do not match the quote")
+	}
+
+	if trade.Status != "TRADE_STATUS_CREATED" {
+		return nil, invalidArgument("convert trade %s is %s", trade.Id, trade.Status)
+	}
+
+	if time.Since(trade.quotedAt) > convertQuoteTtl {
+		trade.Status = "TRADE_STATUS_CANCELED"
+		trade.CancellationReason = adv.ErrorInfo{Code: "QUOTE_EXPIRED", Message: "quote expired"}
+		return nil, invalidArgument("convert quote %s expired", trade.Id)
+	}
+
+	if trade.amount > parseFloat(trade.fromAccount.AvailableBalance.Value)+1e-9 {
+		trade.Status = "TRADE_STATUS_CANCELED"
+		trade.CancellationReason = adv.ErrorInfo{Code: "INSUFFICIENT_FUNDS", Message: "insufficient funds"}
+	} else {
+		s.state.credit(trade.fromAccount.RetailPortfolioId, trade.fromAccount.Currency, -trade.amount)
+		s.state.credit(trade.toAccount.RetailPortfolioId, trade.toAccount.Currency, trade.received)
+		trade.Status = "TRADE_STATUS_COMPLETED"
+	}
+
+	copied := trade.Convert
+	return &adv.CommitConvertQuoteResponse{Trade: &copied}, nil
+}
+
+func (s *Server) getConvertTrade(c *call) (interface{}, error) {
+
+	trade, ok := s.state.converts[c.params[0]]
+	if !ok {
+		return nil, notFound("convert trade %s not found", c.params[0])
+	}
+
+	copied := trade.Convert
+	return &adv.GetConvertTradeResponse{Convert: &copied}, nil
+}
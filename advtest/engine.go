@@ -0,0 +1,535 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advtest
+
+import (
+	"encoding/json"
+	"fmt"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	stopDirectionUp   = "STOP_DIRECTION_STOP_UP"
+	stopDirectionDown = "STOP_DIRECTION_STOP_DOWN"
+
+	sizeEpsilon = 1e-12
+
+	// maxTriggerRounds bounds the cascade of stops triggering further stops after a trade.
+	maxTriggerRounds = 100
+)
+
+// book is the order book of one product. Resting orders are kept in price-time priority: bids
+// from the highest price down, asks from the lowest price up, and the earliest order first within
+// a price. Liquidity seeded from snapshots rests alongside client orders with a nil order.
+type book struct {
+	productId string
+	bids      []*restingOrder
+	asks      []*restingOrder
+	stops     []*order
+	lastPrice float64
+}
+
+type restingOrder struct {
+	order *order
+	side  string
+	price float64
+	size  float64
+	seq   int64
+}
+
+func (r *restingOrder) remaining() float64 {
+	if r.order != nil {
+		return r.order.remaining()
+	}
+	return r.size
+}
+
+// SeedBook replaces the seeded liquidity of a product with a recorded book snapshot, such as the
+// pricebook of a GetProductBook response. Client orders already resting keep their place and any
+// that cross the new liquidity are filled as makers.
+func (s *Server) SeedBook(snapshot *adv.PriceBook) {
+	s.SetBook(snapshot.ProductId, snapshot.Bids, snapshot.Asks)
+}
+
+// SeedBookJson seeds a book from a recorded GetProductBook response body.
+func (s *Server) SeedBookJson(data []byte) error {
+	response := &adv.GetProductBookResponse{}
+	if err := json.Unmarshal(data, response); err != nil {
+		return fmt.Errorf("unable to decode book snapshot: %w", err)
+	}
+	if response.PriceBook == nil || len(response.PriceBook.ProductId) == 0 {
+		return fmt.Errorf("book snapshot has no pricebook")
+	}
+	s.SeedBook(response.PriceBook)
+	return nil
+}
+
+// Trade simulates another participant sending a limit IOC order of size at price. It trades
+// against resting orders, client orders included, moves the last traded price and fires any stop
+// or bracket triggers it crosses. The unfilled remainder is discarded.
+func (s *Server) Trade(productId, side string, price, size float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.expireOrders(time.Now())
+	b := s.state.book(productId)
+	s.state.match(b, nil, side, price, size, 0)
+	s.state.triggerStops(b)
+}
+
+// LastPrice returns the price of the most recent trade in the product.
+func (s *Server) LastPrice(productId string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.book(productId).lastPrice
+}
+
+func (st *state) book(productId string) *book {
+	b, ok := st.books[productId]
+	if !ok {
+		b = &book{productId: productId}
+		st.books[productId] = b
+	}
+	return b
+}
+
+func (st *state) nextSeq() int64 {
+	st.seq++
+	return st.seq
+}
+
+// seed replaces the seeded liquidity of the book and uncrosses it.
+func (st *state) seed(b *book, bids, asks []adv.Level) {
+
+	keep := func(levels []*restingOrder) []*restingOrder {
+		var kept []*restingOrder
+		for _, r := range levels {
+			if r.order != nil {
+				kept = append(kept, r)
+			}
+		}
+		return kept
+	}
+
+	b.bids, b.asks = keep(b.bids), keep(b.asks)
+
+	for _, l := range bids {
+		if size := parseFloat(l.Size); size > 0 {
+			b.insert(&restingOrder{side: "BUY", price: parseFloat(l.Price), size: size, seq: st.nextSeq()})
+		}
+	}
+	for _, l := range asks {
+		if size := parseFloat(l.Size); size > 0 {
+			b.insert(&restingOrder{side: "SELL", price: parseFloat(l.Price), size: size, seq: st.nextSeq()})
+		}
+	}
+
+	st.uncross(b)
+	st.triggerStops(b)
+}
+
+func (b *book) side(side string) *[]*restingOrder {
+	if side == "BUY" {
+		return &b.bids
+	}
+	return &b.asks
+}
+
+func (b *book) insert(r *restingOrder) {
+	levels := b.side(r.side)
+	i := sort.Search(len(*levels), func(i int) bool {
+		other := (*levels)[i]
+		if other.price != r.price {
+			if r.side == "BUY" {
+				return other.price < r.price
+			}
+			return other.price > r.price
+		}
+		return other.seq > r.seq
+	})
+	*levels = append(*levels, nil)
+	copy((*levels)[i+1:], (*levels)[i:])
+	(*levels)[i] = r
+}
+
+// remove takes the order out of the book and the pending stops.
+func (b *book) remove(o *order) {
+	for _, levels := range []*[]*restingOrder{&b.bids, &b.asks} {
+		for i, r := range *levels {
+			if r.order == o {
+				*levels = append((*levels)[:i], (*levels)[i+1:]...)
+				break
+			}
+		}
+	}
+	for i, stop := range b.stops {
+		if stop == o {
+			b.stops = append(b.stops[:i], b.stops[i+1:]...)
+			break
+		}
+	}
+}
+
+func (b *book) best(side string) *restingOrder {
+	levels := *b.side(side)
+	if len(levels) == 0 {
+		return nil
+	}
+	return levels[0]
+}
+
+func (b *book) bestBidAsk() (bid, ask float64) {
+	if r := b.best("BUY"); r != nil {
+		bid = r.price
+	}
+	if r := b.best("SELL"); r != nil {
+		ask = r.price
+	}
+	return bid, ask
+}
+
+// levels aggregates resting size by price, best price first.
+func (b *book) levels(side string) []adv.Level {
+	levels := []adv.Level{}
+	var price, size float64
+	for _, r := range *b.side(side) {
+		if len(levels) > 0 && r.price == price {
+			size += r.remaining()
+			levels[len(levels)-1].Size = formatFloat(size)
+			continue
+		}
+		price, size = r.price, r.remaining()
+		levels = append(levels, adv.Level{Price: formatFloat(price), Size: formatFloat(size)})
+	}
+	return levels
+}
+
+// crosses reports whether a taker on side with the limit price can trade at price. A zero limit
+// is a market order.
+func crosses(side string, limit, price float64) bool {
+	if limit == 0 {
+		return true
+	}
+	if side == "BUY" {
+		return price <= limit
+	}
+	return price >= limit
+}
+
+func opposite(side string) string {
+	if side == "BUY" {
+		return "SELL"
+	}
+	return "BUY"
+}
+
+// available is the resting size a taker on side could trade within the limit price.
+func (b *book) available(side string, limit float64) float64 {
+	var size float64
+	for _, r := range *b.side(opposite(side)) {
+		if !crosses(side, limit, r.price) {
+			break
+		}
+		size += r.remaining()
+	}
+	return size
+}
+
+// match trades an incoming order against the opposite side of the book until size, or quote when
+// sizing in quote currency, is exhausted or the limit no longer crosses. A nil taker is simulated
+// external flow. The traded base size is returned.
+func (st *state) match(b *book, taker *order, side string, limit, size, quote float64) float64 {
+
+	var traded float64
+	levels := b.side(opposite(side))
+
+	for len(*levels) > 0 {
+		maker := (*levels)[0]
+		if !crosses(side, limit, maker.price) {
+			break
+		}
+
+		qty := maker.remaining()
+		if quote > 0 {
+			qty = math.Min(qty, quote/maker.price)
+		} else {
+			qty = math.Min(qty, size-traded)
+		}
+
+		if qty <= sizeEpsilon {
+			break
+		}
+
+		st.execute(b, maker, taker, side, maker.price, qty)
+
+		traded += qty
+		if quote > 0 {
+			quote -= qty * maker.price
+		}
+
+		if maker.remaining() <= sizeEpsilon {
+			*levels = (*levels)[1:]
+			if maker.order != nil {
+				maker.order.Status = orderStatusFilled
+				st.refresh(maker.order)
+			}
+		}
+	}
+
+	return traded
+}
+
+// execute records a trade of qty at price between a resting maker and an incoming taker, either
+// of which may be external liquidity.
+func (st *state) execute(b *book, maker *restingOrder, taker *order, side string, price, qty float64) {
+
+	if maker.order != nil {
+		st.fill(maker.order, price, qty, liquidityMaker)
+	} else {
+		maker.size -= qty
+	}
+
+	if taker != nil {
+		st.fill(taker, price, qty, liquidityTaker)
+	}
+
+	b.lastPrice = price
+
+	bid, ask := b.bestBidAsk()
+	st.trades[b.productId] = append(st.trades[b.productId], &adv.Trade{
+		TradeId:   newId(),
+		ProductId: b.productId,
+		Price:     formatFloat(price),
+		Size:      formatFloat(qty),
+		Time:      time.Now().UTC(),
+		Side:      side,
+		Bid:       formatFloat(bid),
+		Ask:       formatFloat(ask),
+	})
+}
+
+// uncross trades resting orders that overlap after the book changed underneath them. The later
+// order of each crossing pair is the taker and trades at the earlier order's price.
+func (st *state) uncross(b *book) {
+	for {
+		bid, ask := b.best("BUY"), b.best("SELL")
+		if bid == nil || ask == nil || bid.price < ask.price {
+			return
+		}
+
+		maker, taker := bid, ask
+		if ask.seq < bid.seq {
+			maker, taker = ask, bid
+		}
+
+		qty := math.Min(maker.remaining(), taker.remaining())
+		st.execute(b, maker, taker.order, taker.side, maker.price, qty)
+		if taker.order == nil {
+			taker.size -= qty
+		}
+
+		for _, r := range []*restingOrder{maker, taker} {
+			if r.remaining() <= sizeEpsilon {
+				b.removeResting(r)
+				if r.order != nil {
+					r.order.Status = orderStatusFilled
+					st.refresh(r.order)
+				}
+			}
+		}
+	}
+}
+
+func (b *book) removeResting(r *restingOrder) {
+	levels := b.side(r.side)
+	for i, other := range *levels {
+		if other == r {
+			*levels = append((*levels)[:i], (*levels)[i+1:]...)
+			return
+		}
+	}
+}
+
+// place runs a newly accepted, or newly triggered, order through the book.
+func (st *state) place(o *order) {
+
+	b := st.book(o.ProductId)
+
+	if o.stopPrice > 0 && o.TriggerStatus == "STOP_PENDING" {
+		if !stopTriggered(o, st.referencePrice(b)) {
+			b.stops = append(b.stops, o)
+			if o.OrderType == orderTypeBracket {
+				// The take-profit leg of a bracket works like a limit order while its stop waits.
+				st.match(b, o, o.Side, o.limitPrice, o.remaining(), 0)
+				if o.complete() {
+					b.remove(o)
+					st.finish(o, orderStatusFilled)
+					return
+				}
+				st.rest(b, o)
+			}
+			return
+		}
+		o.TriggerStatus = "STOP_TRIGGERED"
+		if o.OrderType == orderTypeBracket {
+			st.marketOut(b, o)
+			return
+		}
+	}
+
+	switch {
+	case o.OrderType == orderTypeMarket:
+		if b.best(opposite(o.Side)) == nil {
+			st.reject(o, "NO_LIQUIDITY", "no liquidity available")
+			return
+		}
+		st.match(b, o, o.Side, 0, o.baseSize, o.quoteSize)
+		if o.complete() {
+			st.finish(o, orderStatusFilled)
+		} else {
+			st.finish(o, orderStatusCancelled)
+		}
+
+	case o.postOnly:
+		if r := b.best(opposite(o.Side)); r != nil && crosses(o.Side, o.limitPrice, r.price) {
+			st.reject(o, "INVALID_LIMIT_PRICE_POST_ONLY", "post only order would cross the book")
+			return
+		}
+		st.rest(b, o)
+
+	case o.TimeInForce == timeInForceFok:
+		if b.available(o.Side, o.limitPrice) < o.baseSize-sizeEpsilon {
+			st.finish(o, orderStatusCancelled)
+			return
+		}
+		st.match(b, o, o.Side, o.limitPrice, o.baseSize, 0)
+		st.finish(o, orderStatusFilled)
+
+	case o.TimeInForce == timeInForceIoc:
+		st.match(b, o, o.Side, o.limitPrice, o.remaining(), 0)
+		if o.complete() {
+			st.finish(o, orderStatusFilled)
+		} else {
+			st.finish(o, orderStatusCancelled)
+		}
+
+	default:
+		st.match(b, o, o.Side, o.limitPrice, o.remaining(), 0)
+		if o.complete() {
+			st.finish(o, orderStatusFilled)
+			return
+		}
+		st.rest(b, o)
+	}
+
+	st.triggerStops(b)
+}
+
+func (st *state) rest(b *book, o *order) {
+	b.insert(&restingOrder{order: o, side: o.Side, price: o.limitPrice, seq: st.nextSeq()})
+	st.refresh(o)
+}
+
+// marketOut removes a triggered bracket from the book and trades its remaining size at market.
+func (st *state) marketOut(b *book, o *order) {
+	b.remove(o)
+	st.match(b, o, o.Side, 0, o.remaining(), 0)
+	if o.complete() {
+		st.finish(o, orderStatusFilled)
+	} else {
+		st.finish(o, orderStatusCancelled)
+	}
+}
+
+// referencePrice is the price stops are compared against: the last trade, or the mid before the
+// product has traded.
+func (st *state) referencePrice(b *book) float64 {
+	if b.lastPrice > 0 {
+		return b.lastPrice
+	}
+	bid, ask := b.bestBidAsk()
+	if bid > 0 && ask > 0 {
+		return (bid + ask) / 2
+	}
+	return 0
+}
+
+func stopTriggered(o *order, price float64) bool {
+
+	if price <= 0 {
+		return false
+	}
+
+	direction := o.stopDirection
+	if len(direction) == 0 {
+		// Brackets and stops without a direction protect the position the order closes: a sell
+		// stops out on a fall and a buy on a rise.
+		direction = stopDirectionDown
+		if o.buy() {
+			direction = stopDirectionUp
+		}
+	}
+
+	if direction == stopDirectionUp {
+		return price >= o.stopPrice
+	}
+	return price <= o.stopPrice
+}
+
+// triggerStops places every stop whose trigger the last trade crossed. Triggered orders may trade
+// and move the price again, so it repeats until nothing more triggers.
+func (st *state) triggerStops(b *book) {
+	for round := 0; round < maxTriggerRounds; round++ {
+		price := st.referencePrice(b)
+
+		var triggered []*order
+		var pending []*order
+		for _, o := range b.stops {
+			if o.open() && stopTriggered(o, price) {
+				triggered = append(triggered, o)
+			} else if o.open() {
+				pending = append(pending, o)
+			}
+		}
+
+		if len(triggered) == 0 {
+			return
+		}
+
+		b.stops = pending
+		for _, o := range triggered {
+			o.TriggerStatus = "STOP_TRIGGERED"
+			if o.OrderType == orderTypeBracket {
+				st.marketOut(b, o)
+			} else {
+				st.place(o)
+			}
+		}
+	}
+}
+
+// expireOrders expires good-until-date orders whose end time has passed.
+func (st *state) expireOrders(now time.Time) {
+	for _, o := range st.orders {
+		if o.open() && !o.endTime.IsZero() && now.After(o.endTime) {
+			st.book(o.ProductId).remove(o)
+			st.finish(o, orderStatusExpired)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advtest
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Failure describes a fault to inject into matching requests. Failures are consulted in the order
+// they were added and the first match wins.
+type Failure struct {
+	// Method restricts the failure to one HTTP method. Empty matches any method.
+	Method string
+
+	// Path is matched against the request path from /brokerage onwards. A trailing "*" matches
+	// any path with that prefix and an empty path matches every request.
+	Path string
+
+	// Times is the number of requests to fail before the failure is removed. Zero never expires.
+	Times int
+
+	// Latency delays the response, or the normal handling when neither StatusCode nor Body is
+	// set. The delay ends early if the client gives up.
+	Latency time.Duration
+
+	// StatusCode replaces the response status. Without a Body a standard error body is written.
+	StatusCode int
+
+	// Body replaces the response body verbatim, which allows malformed JSON to be returned.
+	Body string
+}
+
+// Inject adds a failure to the server.
+func (s *Server) Inject(f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &f)
+}
+
+// ClearFailures removes every injected failure.
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = nil
+}
+
+func (s *Server) nextFailure(method, path string) *Failure {
+
+	for i, f := range s.failures {
+		if !f.matches(method, path) {
+			continue
+		}
+
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+
+		return f
+	}
+
+	return nil
+}
+
+func (f *Failure) matches(method, path string) bool {
+
+	if len(f.Method) > 0 && !strings.EqualFold(f.Method, method) {
+		return false
+	}
+
+	if prefix, ok := strings.CutSuffix(f.Path, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+
+	return len(f.Path) == 0 || f.Path == path
+}
+
+// apply writes the failure to w and reports whether the request has been fully handled.
+func (f *Failure) apply(w http.ResponseWriter, r *http.Request) bool {
+
+	if f.Latency > 0 {
+		timer := time.NewTimer(f.Latency)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return true
+		case <-timer.C:
+		}
+	}
+
+	if f.StatusCode == 0 && len(f.Body) == 0 {
+		return false
+	}
+
+	status := f.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if len(f.Body) == 0 {
+		writeError(w, status, "INJECTED_FAILURE", http.StatusText(status))
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(f.Body))
+
+	return true
+}
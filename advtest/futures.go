@@ -0,0 +1,166 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advtest
+
+import (
+	"fmt"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"time"
+)
+
+func (s *Server) getFuturesBalanceSummary(c *call) (interface{}, error) {
+	summary := s.state.futuresBalance
+	return &adv.GetFuturesBalanceSummaryResponse{BalanceSummary: &summary}, nil
+}
+
+func (s *Server) listFuturesPositions(c *call) (interface{}, error) {
+	positions := []*adv.CfmFuturesPosition{}
+	for _, p := range s.state.futuresPositions {
+		copied := *p
+		positions = append(positions, &copied)
+	}
+	return &adv.ListFuturesPositionsResponse{FuturesPositions: positions}, nil
+}
+
+func (s *Server) getFuturesPosition(c *call) (interface{}, error) {
+	for _, p := range s.state.futuresPositions {
+		if p.ProductId == c.params[0] {
+			copied := *p
+			return &adv.GetFuturesPositionResponse{Position: &copied}, nil
+		}
+	}
+	return nil, notFound("no position in %s", c.params[0])
+}
+
+func (s *Server) listFuturesSweeps(c *call) (interface{}, error) {
+	sweeps := []*adv.Sweep{}
+	for _, sweep := range s.state.sweeps {
+		copied := *sweep
+		sweeps = append(sweeps, &copied)
+	}
+	return &adv.ListFuturesSweepsResponse{Sweeps: sweeps}, nil
+}
+
+func (s *Server) scheduleFuturesSweep(c *call) (interface{}, error) {
+
+	request := &adv.ScheduleFuturesSweepRequest{}
+	if err := c.decode(request); err != nil {
+		return nil, err
+	}
+
+	for _, sweep := range s.state.sweeps {
+		if sweep.Status == "PENDING" {
+			return nil, invalidArgument("a sweep is already pending")
+		}
+	}
+
+	amount := parseFloat(request.UsdAmount)
+	if len(request.UsdAmount) > 0 && amount <= 0 {
+		return nil, invalidArgument("invalid amount %s", request.UsdAmount)
+	}
+
+	s.state.sweeps = append(s.state.sweeps, &adv.Sweep{
+		Id:              newId(),
+		RequestedAmount: adv.Amount{Value: request.UsdAmount, Currency: "USD"},
+		ShouldSweepAll:  len(request.UsdAmount) == 0,
+		Status:          "PENDING",
+		ScheduledTime:   timestamp(time.Now()),
+	})
+
+	return &adv.ScheduleFuturesSweepResponse{Success: true}, nil
+}
+
+func (s *Server) cancelPendingFuturesSweeps(c *call) (interface{}, error) {
+
+	var remaining []*adv.Sweep
+	var cancelled bool
+	for _, sweep := range s.state.sweeps {
+		if sweep.Status == "PENDING" {
+			cancelled = true
+			continue
+		}
+		remaining = append(remaining, sweep)
+	}
+
+	if !cancelled {
+		return nil, notFound("no pending sweep")
+	}
+
+	s.state.sweeps = remaining
+
+	return &adv.CancelPendingFuturesSweepsResponse{Success: true}, nil
+}
+
+func (s *Server) allocatePortfolio(c *call) (interface{}, error) {
+
+	request := &adv.AllocatePortfolioRequest{}
+	if err := c.decode(request); err != nil {
+		return nil, err
+	}
+
+	p, ok := s.state.perpsPortfolios[request.PortfolioUuid]
+	if !ok {
+		return nil, notFound("perpetuals portfolio %s not found", request.PortfolioUuid)
+	}
+
+	amount := parseFloat(request.Amount)
+	if amount <= 0 {
+		return nil, invalidArgument("invalid amount %s", request.Amount)
+	}
+
+	p.Collateral = formatFloat(parseFloat(p.Collateral) + amount)
+
+	return &adv.AllocatePortfolioResponse{Description: fmt.Sprintf("allocated %s %s", request.Amount, request.Currency)}, nil
+}
+
+func (s *Server) getPerpetualsPortfolioSummary(c *call) (interface{}, error) {
+	p, ok := s.state.perpsPortfolios[c.params[0]]
+	if !ok {
+		return nil, notFound("perpetuals portfolio %s not found", c.params[0])
+	}
+	copied := *p
+	return &adv.GetPerpetualsPortfolioSummaryResponse{Portfolios: &copied}, nil
+}
+
+func (s *Server) listPerpetualsPositions(c *call) (interface{}, error) {
+
+	positions := []*adv.IntxPosition{}
+	var pnl float64
+	for _, p := range s.state.perpsPositions {
+		if p.PortfolioUuid != c.params[0] {
+			continue
+		}
+		copied := *p
+		positions = append(positions, &copied)
+		pnl += parseFloat(p.UnrealizedPnl.Value)
+	}
+
+	return &adv.ListPerpetualsPositionsResponse{
+		Positions: positions,
+		Summary:   &adv.IntxSummary{AggregatedPnl: adv.Amount{Value: formatFloat(pnl), Currency: "USDC"}},
+	}, nil
+}
+
+func (s *Server) getPerpetualsPosition(c *call) (interface{}, error) {
+	for _, p := range s.state.perpsPositions {
+		if p.PortfolioUuid == c.params[0] && p.Symbol == c.params[1] {
+			copied := *p
+			return &adv.GetPerpetualsPositionResponse{Position: &copied}, nil
+		}
+	}
+	return nil, notFound("no position in %s", c.params[1])
+}
@@ -0,0 +1,282 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advtest
+
+import (
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxCandlesPerRequest mirrors the API's cap on the candles a single request may span.
+const maxCandlesPerRequest = 350
+
+func (s *Server) getServerTime(c *call) (interface{}, error) {
+	now := time.Now().UTC()
+	return &adv.GetServerTimeResponse{
+		Iso:          now,
+		EpochSeconds: strconv.FormatInt(now.Unix(), 10),
+		EpochMillis:  strconv.FormatInt(now.UnixMilli(), 10),
+	}, nil
+}
+
+func (s *Server) listProducts(c *call) (interface{}, error) {
+
+	ids := c.query["product_ids"]
+	productType := c.query.Get("product_type")
+	expiryType := c.query.Get("contract_expiry_type")
+
+	var products []*adv.Product
+	for _, p := range s.state.products {
+		if len(ids) > 0 && !contains(ids, p.ProductId) {
+			continue
+		}
+		if len(productType) > 0 && p.ProductType != productType {
+			continue
+		}
+		if len(expiryType) > 0 && p.FutureProductDetails.ContractExpiryType != expiryType {
+			continue
+		}
+		products = append(products, s.state.quotedProduct(p))
+	}
+
+	sort.Slice(products, func(i, j int) bool { return products[i].ProductId < products[j].ProductId })
+
+	products, _ = page(products, c.query.Get("cursor"), c.query.Get("limit"))
+
+	return &adv.ListProductsResponse{Products: products}, nil
+}
+
+func (s *Server) getProduct(c *call) (interface{}, error) {
+
+	p, ok := s.state.products[c.params[0]]
+	if !ok {
+		return nil, notFound("product %s not found", c.params[0])
+	}
+
+	q := s.state.quotedProduct(p)
+
+	return &adv.GetProductResponse{
+		ProductId:                 q.ProductId,
+		Price:                     q.Price,
+		PricePercentageChange24h:  q.PricePercentageChange24h,
+		Volume24h:                 q.Volume24h,
+		VolumePercentageChange24h: q.VolumePercentageChange24h,
+		BaseIncrement:             q.BaseIncrement,
+		QuoteIncrement:            q.QuoteIncrement,
+		QuoteMinSize:              q.QuoteMinSize,
+		QuoteMaxSize:              q.QuoteMaxSize,
+		BaseMinSize:               q.BaseMinSize,
+		BaseMaxSize:               q.BaseMaxSize,
+		BaseName:                  q.BaseName,
+		QuoteName:                 q.QuoteName,
+		Watched:                   q.Watched,
+		IsDisabled:                q.IsDisabled,
+		New:                       q.New,
+		Status:                    q.Status,
+		CancelOnly:                q.CancelOnly,
+		LimitOnly:                 q.LimitOnly,
+		PostOnly:                  q.PostOnly,
+		TradingDisabled:           q.TradingDisabled,
+		AuctionMode:               q.AuctionMode,
+		ProductType:               q.ProductType,
+		QuoteCurrencyId:           q.QuoteCurrencyId,
+		BaseCurrencyId:            q.BaseCurrencyId,
+		FCMSessionDetails:         q.FcmSessionDetails,
+		MidMarketPrice:            q.MidMarketPrice,
+		Alias:                     q.Alias,
+		AliasTo:                   q.AliasTo,
+		BaseDisplaySymbol:         q.BaseDisplaySymbol,
+		QuoteDisplaySymbol:        q.QuoteDisplaySymbol,
+		ViewOnly:                  q.ViewOnly,
+		PriceIncrement:            q.PriceIncrement,
+		FutureProductDetails:      q.FutureProductDetails,
+	}, nil
+}
+
+// quotedProduct returns a copy of the product with its price fields taken from the current book.
+func (st *state) quotedProduct(p *adv.Product) *adv.Product {
+	q := *p
+	if price := st.price(p.ProductId); price > 0 {
+		q.Price = formatFloat(price)
+		q.MidMarketPrice = q.Price
+	}
+	return &q
+}
+
+func (s *Server) getProductBook(c *call) (interface{}, error) {
+
+	productId := c.query.Get("product_id")
+	if _, ok := s.state.products[productId]; !ok {
+		return nil, notFound("product %s not found", productId)
+	}
+
+	book := s.state.priceBook(productId)
+
+	if limit, err := strconv.Atoi(c.query.Get("limit")); err == nil && limit > 0 {
+		if len(book.Bids) > limit {
+			book.Bids = book.Bids[:limit]
+		}
+		if len(book.Asks) > limit {
+			book.Asks = book.Asks[:limit]
+		}
+	}
+
+	return &adv.GetProductBookResponse{PriceBook: book}, nil
+}
+
+func (s *Server) getBestBidAsk(c *call) (interface{}, error) {
+
+	ids := c.query["product_ids"]
+	if len(ids) == 0 {
+		for id := range s.state.products {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+	}
+
+	books := make([]adv.PriceBook, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := s.state.products[id]; !ok {
+			continue
+		}
+		book := s.state.priceBook(id)
+		if len(book.Bids) > 1 {
+			book.Bids = book.Bids[:1]
+		}
+		if len(book.Asks) > 1 {
+			book.Asks = book.Asks[:1]
+		}
+		books = append(books, *book)
+	}
+
+	return &adv.GetBestBidAskResponse{PriceBooks: &books}, nil
+}
+
+// priceBook aggregates the product's resting orders into levels stamped with the current time.
+func (st *state) priceBook(productId string) *adv.PriceBook {
+	b := st.book(productId)
+	return &adv.PriceBook{
+		ProductId: productId,
+		Bids:      b.levels("BUY"),
+		Asks:      b.levels("SELL"),
+		Time:      timestamp(time.Now()),
+	}
+}
+
+func (s *Server) getProductCandles(c *call) (interface{}, error) {
+
+	productId := c.params[0]
+	if _, ok := s.state.products[productId]; !ok {
+		return nil, notFound("product %s not found", productId)
+	}
+
+	start, _ := strconv.ParseInt(c.query.Get("start"), 10, 64)
+	end, _ := strconv.ParseInt(c.query.Get("end"), 10, 64)
+
+	if granularity := c.query.Get("granularity"); len(granularity) > 0 {
+		step, err := adv.GranularityDuration(granularity)
+		if err != nil {
+			return nil, invalidArgument("%v", err)
+		}
+		if start > 0 && end > 0 && (end-start)/int64(step.Seconds()) > maxCandlesPerRequest {
+			return nil, invalidArgument("number of candles requested should be less than %d", maxCandlesPerRequest)
+		}
+	}
+
+	candles := []adv.Candle{}
+	for _, candle := range s.state.candles[productId] {
+		t, _ := strconv.ParseInt(candle.Start, 10, 64)
+		if (start > 0 && t < start) || (end > 0 && t > end) {
+			continue
+		}
+		candles = append(candles, candle)
+	}
+
+	// The API returns the most recent candle first.
+	sort.SliceStable(candles, func(i, j int) bool {
+		a, _ := strconv.ParseInt(candles[i].Start, 10, 64)
+		b, _ := strconv.ParseInt(candles[j].Start, 10, 64)
+		return a > b
+	})
+
+	return &adv.GetProductCandlesResponse{Candles: &candles}, nil
+}
+
+func (s *Server) getMarketTrades(c *call) (interface{}, error) {
+
+	productId := c.params[0]
+	if _, ok := s.state.products[productId]; !ok {
+		return nil, notFound("product %s not found", productId)
+	}
+
+	start, _ := strconv.ParseInt(c.query.Get("start"), 10, 64)
+	end, _ := strconv.ParseInt(c.query.Get("end"), 10, 64)
+
+	trades := []*adv.Trade{}
+	for _, t := range s.state.trades[productId] {
+		if (start > 0 && t.Time.Unix() < start) || (end > 0 && t.Time.Unix() > end) {
+			continue
+		}
+		trades = append(trades, t)
+	}
+
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].Time.After(trades[j].Time) })
+
+	if limit, err := strconv.Atoi(c.query.Get("limit")); err == nil && limit > 0 && len(trades) > limit {
+		trades = trades[:limit]
+	}
+
+	response := &adv.GetMarketTradesResponse{Trades: trades}
+	if bid, ask := s.state.bestBidAsk(productId); bid > 0 || ask > 0 {
+		response.BestBid = formatFloat(bid)
+		response.BestAsk = formatFloat(ask)
+	}
+
+	return response, nil
+}
+
+func (s *Server) getTransactionsSummary(c *call) (interface{}, error) {
+
+	var volume, fees float64
+	for _, f := range s.state.fills {
+		value := parseFloat(f.Price) * parseFloat(f.Size)
+		if f.SizeInQuote {
+			value = parseFloat(f.Size)
+		}
+		volume += value
+		fees += parseFloat(f.Commission)
+	}
+
+	return &adv.GetTransactionsSummaryResponse{
+		TotalVolume:             int(volume),
+		TotalFees:               fees,
+		FeeTier:                 s.state.currentFeeTier(),
+		AdvancedTradeOnlyVolume: int(volume),
+		AdvancedTradeOnlyFees:   fees,
+	}, nil
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
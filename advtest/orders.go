@@ -0,0 +1,822 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advtest
+
+import (
+	"fmt"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"strings"
+	"time"
+)
+
+const (
+	orderStatusOpen      = "OPEN"
+	orderStatusFilled    = "FILLED"
+	orderStatusCancelled = "CANCELLED"
+	orderStatusExpired   = "EXPIRED"
+	orderStatusFailed    = "FAILED"
+
+	orderTypeMarket    = "MARKET"
+	orderTypeLimit     = "LIMIT"
+	orderTypeStopLimit = "STOP_LIMIT"
+	orderTypeBracket   = "BRACKET"
+
+	timeInForceIoc = "IMMEDIATE_OR_CANCEL"
+	timeInForceGtc = "GOOD_UNTIL_CANCELLED"
+	timeInForceGtd = "GOOD_UNTIL_DATE_TIME"
+	timeInForceFok = "FILL_OR_KILL"
+
+	liquidityMaker = "MAKER"
+	liquidityTaker = "TAKER"
+)
+
+// order is an order as the server tracks it: the API representation plus the parsed terms of its
+// configuration and running totals.
+type order struct {
+	adv.Order
+
+	portfolio     string
+	baseSize      float64
+	quoteSize     float64
+	limitPrice    float64
+	stopPrice     float64
+	stopDirection string
+	postOnly      bool
+	endTime       time.Time
+
+	filledSize  float64
+	filledValue float64
+	fees        float64
+	fillCount   int
+}
+
+func (o *order) open() bool {
+	return o.Status == orderStatusOpen
+}
+
+func (o *order) remaining() float64 {
+	return o.baseSize - o.filledSize
+}
+
+// complete reports whether the order has traded its full size, measured in quote currency for
+// market orders sized in quote.
+func (o *order) complete() bool {
+	if o.quoteSize > 0 {
+		return o.filledValue >= o.quoteSize*(1-1e-9)
+	}
+	return o.filledSize >= o.baseSize-sizeEpsilon
+}
+
+func (o *order) buy() bool {
+	return o.Side == "BUY"
+}
+
+// parseOrder builds an order from the request, returning a failure reason when the request is not
+// one the exchange would accept.
+func (st *state) parseOrder(
+	productId,
+	side string,
+	configuration adv.OrderConfiguration,
+	portfolioUuid string,
+) (*order, string) {
+
+	product, ok := st.products[productId]
+	if !ok {
+		return nil, "INVALID_PRODUCT_ID"
+	}
+
+	if side != "BUY" && side != "SELL" {
+		return nil, "INVALID_SIDE"
+	}
+
+	if len(portfolioUuid) == 0 {
+		portfolioUuid = st.defaultPortfolio
+	}
+
+	o := &order{
+		Order: adv.Order{
+			ProductId:          productId,
+			Side:               side,
+			OrderConfiguration: configuration,
+			ProductType:        product.ProductType,
+			RetailPortfolioId:  portfolioUuid,
+		},
+		portfolio: portfolioUuid,
+	}
+
+	var configs int
+	var endTime string
+
+	if c := configuration.MarketMarketIoc; c != nil {
+		configs++
+		o.OrderType, o.TimeInForce = orderTypeMarket, timeInForceIoc
+		o.baseSize, o.quoteSize = parseFloat(c.BaseSize), parseFloat(c.QuoteSize)
+		o.SizeInQuote = len(c.QuoteSize) > 0
+		if (o.baseSize > 0) == (o.quoteSize > 0) {
+			return nil, "INVALID_SIZE"
+		}
+	}
+	if c := configuration.SorLimitIoc; c != nil {
+		configs++
+		o.OrderType, o.TimeInForce = orderTypeLimit, timeInForceIoc
+		o.baseSize, o.limitPrice = parseFloat(c.BaseSize), parseFloat(c.LimitPrice)
+	}
+	if c := configuration.LimitLimitGtc; c != nil {
+		configs++
+		o.OrderType, o.TimeInForce = orderTypeLimit, timeInForceGtc
+		o.baseSize, o.limitPrice, o.postOnly = parseFloat(c.BaseSize), parseFloat(c.LimitPrice), c.PostOnly
+	}
+	if c := configuration.LimitLimitGtd; c != nil {
+		configs++
+		o.OrderType, o.TimeInForce = orderTypeLimit, timeInForceGtd
+		o.baseSize, o.limitPrice, o.postOnly = parseFloat(c.BaseSize), parseFloat(c.LimitPrice), c.PostOnly
+		endTime = c.EndTime
+	}
+	if c := configuration.LimitLimitFok; c != nil {
+		configs++
+		o.OrderType, o.TimeInForce = orderTypeLimit, timeInForceFok
+		o.baseSize, o.limitPrice = parseFloat(c.BaseSize), parseFloat(c.LimitPrice)
+	}
+	if c := configuration.StopLimitStopLimitGtc; c != nil {
+		configs++
+		o.OrderType, o.TimeInForce = orderTypeStopLimit, timeInForceGtc
+		o.baseSize, o.limitPrice, o.stopPrice = parseFloat(c.BaseSize), parseFloat(c.LimitPrice), parseFloat(c.StopPrice)
+		o.stopDirection = c.StopDirection
+	}
+	if c := configuration.StopLimitStopLimitGtd; c != nil {
+		configs++
+		o.OrderType, o.TimeInForce = orderTypeStopLimit, timeInForceGtd
+		o.baseSize, o.limitPrice, o.stopPrice = parseFloat(c.BaseSize), parseFloat(c.LimitPrice), parseFloat(c.StopPrice)
+		o.stopDirection = c.StopDirection
+		endTime = c.EndTime
+	}
+	if c := configuration.TriggerBracketGtc; c != nil {
+		configs++
+		o.OrderType, o.TimeInForce = orderTypeBracket, timeInForceGtc
+		o.baseSize, o.limitPrice, o.stopPrice = parseFloat(c.BaseSize), parseFloat(c.LimitPrice), parseFloat(c.StopTriggerPrice)
+	}
+	if c := configuration.TriggerBracketGtd; c != nil {
+		configs++
+		o.OrderType, o.TimeInForce = orderTypeBracket, timeInForceGtd
+		o.baseSize, o.limitPrice, o.stopPrice = parseFloat(c.BaseSize), parseFloat(c.LimitPrice), parseFloat(c.StopTriggerPrice)
+		endTime = c.EndTime
+	}
+
+	if configs != 1 {
+		return nil, "UNSUPPORTED_ORDER_CONFIGURATION"
+	}
+
+	if o.OrderType != orderTypeMarket {
+		if o.baseSize <= 0 {
+			return nil, "INVALID_SIZE"
+		}
+		if o.limitPrice <= 0 {
+			return nil, "INVALID_LIMIT_PRICE"
+		}
+		if o.OrderType != orderTypeLimit && o.stopPrice <= 0 {
+			return nil, "INVALID_STOP_PRICE"
+		}
+	}
+
+	if o.baseSize > 0 && o.baseSize < parseFloat(product.BaseMinSize) {
+		return nil, "INVALID_BASE_SIZE_TOO_SMALL"
+	}
+
+	if max := parseFloat(product.BaseMaxSize); max > 0 && o.baseSize > max {
+		return nil, "INVALID_BASE_SIZE_TOO_LARGE"
+	}
+
+	if o.TimeInForce == timeInForceGtd {
+		t, err := time.Parse(time.RFC3339, endTime)
+		if err != nil || !t.After(time.Now()) {
+			return nil, "INVALID_END_TIME"
+		}
+		o.endTime = t
+	}
+
+	if o.stopDirection != "" && o.stopDirection != stopDirectionUp && o.stopDirection != stopDirectionDown {
+		return nil, "INVALID_STOP_DIRECTION"
+	}
+
+	o.TriggerStatus = "INVALID_ORDER_TYPE"
+	if o.stopPrice > 0 {
+		o.TriggerStatus = "STOP_PENDING"
+	}
+
+	if reason := st.checkFunds(o); len(reason) > 0 {
+		return nil, reason
+	}
+
+	return o, ""
+}
+
+// checkFunds rejects orders the portfolio cannot pay for. Balances are only enforced for spot
+// products and for currencies the portfolio holds an account in, so tests that never seed
+// accounts can still trade.
+func (st *state) checkFunds(o *order) string {
+
+	if o.ProductType != "SPOT" {
+		return ""
+	}
+
+	base, quote := splitProductId(o.ProductId)
+
+	if o.buy() {
+		a := st.account(o.portfolio, quote)
+		if a == nil {
+			return ""
+		}
+		cost := o.quoteSize
+		if cost == 0 {
+			price := o.limitPrice
+			if price == 0 {
+				_, price = st.bestBidAsk(o.ProductId)
+			}
+			cost = o.baseSize * price
+		}
+		if cost > parseFloat(a.AvailableBalance.Value)+1e-9 {
+			return "INSUFFICIENT_FUND"
+		}
+		return ""
+	}
+
+	a := st.account(o.portfolio, base)
+	if a == nil {
+		return ""
+	}
+	if o.baseSize > parseFloat(a.AvailableBalance.Value)+1e-9 {
+		return "INSUFFICIENT_FUND"
+	}
+	return ""
+}
+
+func (s *Server) createOrder(c *call) (interface{}, error) {
+
+	request := &adv.CreateOrderRequest{}
+	if err := c.decode(request); err != nil {
+		return nil, err
+	}
+
+	if len(request.ClientOrderId) > 0 {
+		for _, existing := range s.state.orders {
+			if existing.ClientOrderId == request.ClientOrderId {
+				return successfulOrderResponse(existing), nil
+			}
+		}
+	}
+
+	o, reason := s.state.parseOrder(request.ProductId, request.Side, request.OrderConfiguration, request.RetailPortfolioId)
+	if len(reason) > 0 {
+		return &adv.CreateOrderResponse{
+			Success:       false,
+			FailureReason: reason,
+			ErrorResponse: &adv.ErrorResponse{
+				Error:                 reason,
+				Message:               reason,
+				NewOrderFailureReason: reason,
+			},
+			OrderConfiguration: request.OrderConfiguration,
+		}, nil
+	}
+
+	o.ClientOrderId = request.ClientOrderId
+	s.state.submit(o)
+
+	if o.Status == orderStatusFailed {
+		return &adv.CreateOrderResponse{
+			Success:       false,
+			FailureReason: o.RejectReason,
+			OrderId:       o.OrderId,
+			ErrorResponse: &adv.ErrorResponse{
+				Error:                 o.RejectReason,
+				Message:               o.RejectMessage,
+				PreviewFailureReason:  o.RejectReason,
+				NewOrderFailureReason: o.RejectReason,
+			},
+			OrderConfiguration: request.OrderConfiguration,
+		}, nil
+	}
+
+	return successfulOrderResponse(o), nil
+}
+
+func successfulOrderResponse(o *order) *adv.CreateOrderResponse {
+	return &adv.CreateOrderResponse{
+		Success: true,
+		OrderId: o.OrderId,
+		SuccessResponse: &adv.SuccessResponse{
+			OrderId:       o.OrderId,
+			ProductId:     o.ProductId,
+			Side:          o.Side,
+			ClientOrderId: o.ClientOrderId,
+		},
+		OrderConfiguration: o.OrderConfiguration,
+	}
+}
+
+// submit assigns the order an id, records it and runs it through the book.
+func (st *state) submit(o *order) {
+
+	o.OrderId = newId()
+	o.CreatedTime = timestamp(time.Now())
+	o.Status = orderStatusOpen
+	o.OrderPlacementSource = "RETAIL_ADVANCED"
+
+	st.orders = append(st.orders, o)
+	st.ordersById[o.OrderId] = o
+
+	st.place(o)
+}
+
+func (st *state) reject(o *order, reason, message string) {
+	o.Status = orderStatusFailed
+	o.RejectReason = reason
+	o.RejectMessage = message
+}
+
+func (st *state) finish(o *order, status string) {
+	o.Status = status
+	st.refresh(o)
+}
+
+// fill records an execution of size at price against the order and settles it against the
+// portfolio's balances.
+func (st *state) fill(o *order, price, size float64, liquidity string) {
+
+	if size <= 0 {
+		return
+	}
+
+	tier := st.currentFeeTier()
+
+	rate := parseFloat(tier.TakerFeeRate)
+	if liquidity == liquidityMaker {
+		rate = parseFloat(tier.MakerFeeRate)
+	}
+
+	value := price * size
+	commission := value * rate
+	now := time.Now().UTC()
+
+	o.filledSize += size
+	o.filledValue += value
+	o.fees += commission
+	o.fillCount++
+	o.LastFillTime = timestamp(now)
+
+	st.fills = append(st.fills, &adv.Fill{
+		EntryId:            newId(),
+		TradeId:            newId(),
+		OrderId:            o.OrderId,
+		TradeTime:          now,
+		TradeType:          "FILL",
+		Price:              formatFloat(price),
+		Size:               formatFloat(size),
+		Commission:         formatFloat(commission),
+		ProductId:          o.ProductId,
+		SequenceTimestamp:  now,
+		LiquidityIndicator: liquidity,
+		Side:               o.Side,
+		RetailPortfolioId:  o.portfolio,
+	})
+
+	if o.ProductType == "SPOT" {
+		base, quote := splitProductId(o.ProductId)
+		if o.buy() {
+			st.credit(o.portfolio, base, size)
+			st.credit(o.portfolio, quote, -(value + commission))
+		} else {
+			st.credit(o.portfolio, base, -size)
+			st.credit(o.portfolio, quote, value-commission)
+		}
+	}
+
+	st.volume += value
+
+	st.refresh(o)
+}
+
+// refresh recomputes the API fields derived from the order's running totals.
+func (st *state) refresh(o *order) {
+
+	o.FilledSize = formatFloat(o.filledSize)
+	o.FilledValue = formatFloat(o.filledValue)
+	o.TotalFees = formatFloat(o.fees)
+	o.NumberOfFills = fmt.Sprint(o.fillCount)
+
+	if o.filledSize > 0 {
+		o.AverageFilledPrice = formatFloat(o.filledValue / o.filledSize)
+	}
+
+	if o.quoteSize > 0 {
+		o.CompletionPercentage = formatFloat(100 * o.filledValue / o.quoteSize)
+	} else if o.baseSize > 0 {
+		o.CompletionPercentage = formatFloat(100 * o.filledSize / o.baseSize)
+	}
+
+	if o.buy() {
+		o.TotalValueAfterFees = formatFloat(o.filledValue + o.fees)
+	} else {
+		o.TotalValueAfterFees = formatFloat(o.filledValue - o.fees)
+	}
+
+	o.Settled = !o.open()
+}
+
+func (s *Server) getOrder(c *call) (interface{}, error) {
+
+	o, ok := s.state.ordersById[c.params[0]]
+	if !ok {
+		return nil, notFound("order %s not found", c.params[0])
+	}
+
+	copied := o.Order
+	return &adv.GetOrderResponse{Order: &copied}, nil
+}
+
+func (s *Server) listOrders(c *call) (interface{}, error) {
+
+	statuses := c.query["order_status"]
+	productId := c.query.Get("product_id")
+	side := c.query.Get("order_side")
+	orderType := c.query.Get("order_type")
+	productType := c.query.Get("product_type")
+	portfolioUuid := c.query.Get("retail_portfolio_id")
+
+	var start, end time.Time
+	if v := c.query.Get("start_date"); len(v) > 0 {
+		start, _ = time.Parse(time.RFC3339, v)
+	}
+	if v := c.query.Get("end_date"); len(v) > 0 {
+		end, _ = time.Parse(time.RFC3339, v)
+	}
+
+	orders := []*adv.Order{}
+	for i := len(s.state.orders) - 1; i >= 0; i-- {
+		o := s.state.orders[i]
+
+		if len(statuses) > 0 && !contains(statuses, o.Status) {
+			continue
+		}
+		if (len(productId) > 0 && o.ProductId != productId) ||
+			(len(side) > 0 && o.Side != side) ||
+			(len(orderType) > 0 && o.OrderType != orderType) ||
+			(len(productType) > 0 && o.ProductType != productType) ||
+			(len(portfolioUuid) > 0 && o.portfolio != portfolioUuid) {
+			continue
+		}
+
+		created, _ := time.Parse(time.RFC3339Nano, o.CreatedTime)
+		if (!start.IsZero() && created.Before(start)) || (!end.IsZero() && created.After(end)) {
+			continue
+		}
+
+		copied := o.Order
+		orders = append(orders, &copied)
+	}
+
+	orders, cursor := page(orders, c.query.Get("cursor"), c.query.Get("limit"))
+
+	return &adv.ListOrdersResponse{Orders: orders, HasNext: len(cursor) > 0, Cursor: cursor}, nil
+}
+
+func (s *Server) listFills(c *call) (interface{}, error) {
+
+	orderId := c.query.Get("order_id")
+	productId := c.query.Get("product_id")
+	portfolioUuid := c.query.Get("retail_portfolio_id")
+
+	var start, end time.Time
+	if v := c.query.Get("start_sequence_timestamp"); len(v) > 0 {
+		start, _ = time.Parse(time.RFC3339Nano, v)
+	}
+	if v := c.query.Get("end_sequence_timestamp"); len(v) > 0 {
+		end, _ = time.Parse(time.RFC3339Nano, v)
+	}
+
+	fills := []*adv.Fill{}
+	for i := len(s.state.fills) - 1; i >= 0; i-- {
+		f := s.state.fills[i]
+
+		if (len(orderId) > 0 && f.OrderId != orderId) || (len(productId) > 0 && f.ProductId != productId) {
+			continue
+		}
+		if o, ok := s.state.ordersById[f.OrderId]; ok && len(portfolioUuid) > 0 && o.portfolio != portfolioUuid {
+			continue
+		}
+		if (!start.IsZero() && f.SequenceTimestamp.Before(start)) || (!end.IsZero() && f.SequenceTimestamp.After(end)) {
+			continue
+		}
+
+		copied := *f
+		fills = append(fills, &copied)
+	}
+
+	fills, cursor := page(fills, c.query.Get("cursor"), c.query.Get("limit"))
+
+	return &adv.ListFillsResponse{Fills: fills, Cursor: cursor}, nil
+}
+
+func (s *Server) cancelOrders(c *call) (interface{}, error) {
+
+	request := &adv.CancelOrdersRequest{}
+	if err := c.decode(request); err != nil {
+		return nil, err
+	}
+
+	results := make([]*adv.CancelResult, 0, len(request.OrderIds))
+	for _, id := range request.OrderIds {
+		result := &adv.CancelResult{OrderId: id}
+
+		o, ok := s.state.ordersById[id]
+		switch {
+		case !ok:
+			result.FailureReason = "UNKNOWN_CANCEL_ORDER"
+		case !o.open():
+			result.FailureReason = "DUPLICATE_CANCEL_REQUEST"
+		default:
+			s.state.cancel(o)
+			result.Success = true
+		}
+
+		results = append(results, result)
+	}
+
+	return &adv.CancelOrdersResponse{Results: results}, nil
+}
+
+func (st *state) cancel(o *order) {
+	st.book(o.ProductId).remove(o)
+	st.finish(o, orderStatusCancelled)
+}
+
+// editFailure returns the reason an edit of the order to price, size and stop price would be
+// refused. Only good-until-cancelled limit and stop limit orders can be edited.
+func (st *state) editFailure(o *order, price, size, stopPrice float64) string {
+
+	if !o.open() {
+		return "ORDER_NOT_OPEN"
+	}
+	if o.TimeInForce != timeInForceGtc || (o.OrderType != orderTypeLimit && o.OrderType != orderTypeStopLimit) {
+		return "INVALID_EDITED_ORDER_TYPE"
+	}
+	if price <= 0 {
+		return "INVALID_EDITED_LIMIT_PRICE"
+	}
+	if size <= o.filledSize {
+		return "CANNOT_EDIT_TO_BELOW_FILLED_SIZE"
+	}
+	if stopPrice > 0 && o.OrderType != orderTypeStopLimit {
+		return "INVALID_EDITED_STOP_PRICE"
+	}
+	return ""
+}
+
+func (s *Server) editOrder(c *call) (interface{}, error) {
+
+	request := &adv.EditOrderRequest{}
+	if err := c.decode(request); err != nil {
+		return nil, err
+	}
+
+	o, ok := s.state.ordersById[request.OrderId]
+	if !ok {
+		return &adv.EditOrderResponse{EditErrors: []*adv.EditError{{EditFailureReason: "ORDER_NOT_FOUND"}}}, nil
+	}
+
+	price, size, stopPrice := parseFloat(request.Price), parseFloat(request.Size), parseFloat(request.StopPrice)
+	if reason := s.state.editFailure(o, price, size, stopPrice); len(reason) > 0 {
+		return &adv.EditOrderResponse{EditErrors: []*adv.EditError{{EditFailureReason: reason}}}, nil
+	}
+
+	s.state.edit(o, request.Price, request.Size, request.StopPrice)
+
+	return &adv.EditOrderResponse{Success: true}, nil
+}
+
+// edit amends an open order in place. Lowering the size keeps the order's place in the queue while
+// a new price or a larger size sends it to the back, as on the exchange.
+func (st *state) edit(o *order, price, size, stopPrice string) {
+
+	newPrice, newSize := parseFloat(price), parseFloat(size)
+	keepPriority := newPrice == o.limitPrice && newSize <= o.baseSize
+
+	o.limitPrice = newPrice
+	o.baseSize = newSize
+
+	if c := o.OrderConfiguration.LimitLimitGtc; c != nil {
+		copied := *c
+		copied.LimitPrice, copied.BaseSize = price, size
+		o.OrderConfiguration.LimitLimitGtc = &copied
+	}
+
+	if c := o.OrderConfiguration.StopLimitStopLimitGtc; c != nil {
+		copied := *c
+		copied.LimitPrice, copied.BaseSize = price, size
+		if len(stopPrice) > 0 {
+			copied.StopPrice = stopPrice
+			o.stopPrice = parseFloat(stopPrice)
+		}
+		o.OrderConfiguration.StopLimitStopLimitGtc = &copied
+	}
+
+	o.EditHistory = append(o.EditHistory, adv.EditHistoryItem{
+		Price:                  price,
+		Size:                   size,
+		ReplaceAcceptTimestamp: timestamp(time.Now()),
+	})
+
+	st.refresh(o)
+
+	b := st.book(o.ProductId)
+
+	if o.TriggerStatus == "STOP_PENDING" {
+		// A pending stop is not in the book yet; check whether the new stop price triggers it.
+		st.triggerStops(b)
+		return
+	}
+
+	if keepPriority {
+		return
+	}
+
+	b.remove(o)
+	st.rest(b, o)
+	st.uncross(b)
+	st.triggerStops(b)
+}
+
+func (s *Server) previewOrder(c *call) (interface{}, error) {
+
+	request := &adv.CreateOrderPreviewRequest{}
+	if err := c.decode(request); err != nil {
+		return nil, err
+	}
+
+	response := &adv.CreateOrderPreviewResponse{Errs: []string{}, Warning: []string{}, Slippage: "0"}
+
+	bid, ask := s.state.bestBidAsk(request.ProductId)
+	response.BestBid, response.BestAsk = formatFloat(bid), formatFloat(ask)
+
+	o, reason := s.state.parseOrder(request.ProductId, request.Side, request.OrderConfiguration, request.RetailPortfolioId)
+	if len(reason) > 0 {
+		response.Errs = append(response.Errs, "PREVIEW_"+reason)
+		return response, nil
+	}
+
+	price := o.limitPrice
+	if o.OrderType == orderTypeMarket {
+		price = bid
+		if o.buy() {
+			price = ask
+		}
+		if price <= 0 {
+			price = s.state.price(o.ProductId)
+		}
+	}
+
+	base, quote := o.baseSize, o.quoteSize
+	if base == 0 && price > 0 {
+		base = quote / price
+	}
+	if quote == 0 {
+		quote = base * price
+	}
+
+	commission := quote * parseFloat(s.state.currentFeeTier().TakerFeeRate)
+
+	total := quote + commission
+	if !o.buy() {
+		total = quote - commission
+	}
+
+	response.BaseSize = formatFloat(base)
+	response.QuoteSize = formatFloat(quote)
+	response.CommissionTotal = formatFloat(commission)
+	response.OrderTotal = formatFloat(total)
+	response.AverageFilledPrice = formatFloat(price)
+
+	return response, nil
+}
+
+func (s *Server) previewEditOrder(c *call) (interface{}, error) {
+
+	request := &adv.PreviewEditOrderRequest{}
+	if err := c.decode(request); err != nil {
+		return nil, err
+	}
+
+	o, ok := s.state.ordersById[request.OrderId]
+	if !ok {
+		return &adv.PreviewEditOrderResponse{EditErrors: []*adv.EditError{{PreviewFailureReason: "ORDER_NOT_FOUND"}}}, nil
+	}
+
+	price, size, stopPrice := parseFloat(request.Price), parseFloat(request.Size), parseFloat(request.StopPrice)
+	if reason := s.state.editFailure(o, price, size, stopPrice); len(reason) > 0 {
+		return &adv.PreviewEditOrderResponse{EditErrors: []*adv.EditError{{PreviewFailureReason: reason}}}, nil
+	}
+
+	bid, ask := s.state.bestBidAsk(o.ProductId)
+	quote := price * size
+	commission := quote * parseFloat(s.state.currentFeeTier().MakerFeeRate)
+
+	return &adv.PreviewEditOrderResponse{
+		Slippage:        "0",
+		OrderTotal:      formatFloat(quote + commission),
+		CommissionTotal: formatFloat(commission),
+		QuoteSize:       formatFloat(quote),
+		BaseSize:        formatFloat(size),
+		BestBid:         formatFloat(bid),
+		BestAsk:         formatFloat(ask),
+	}, nil
+}
+
+func (s *Server) closePosition(c *call) (interface{}, error) {
+
+	request := &adv.ClosePositionRequest{}
+	if err := c.decode(request); err != nil {
+		return nil, err
+	}
+
+	i := -1
+	for j, p := range s.state.futuresPositions {
+		if p.ProductId == request.ProductId {
+			i = j
+			break
+		}
+	}
+
+	if i < 0 {
+		return &adv.ClosePositionResponse{
+			ErrorResponse: &adv.ErrorResponse{Error: "NO_POSITION", Message: fmt.Sprintf("no open position in %s", request.ProductId)},
+		}, nil
+	}
+
+	position := s.state.futuresPositions[i]
+	contracts := parseFloat(position.NumberOfContracts)
+
+	size := contracts
+	if len(request.Size) > 0 {
+		size = parseFloat(request.Size)
+	}
+
+	if size <= 0 || size > contracts {
+		return &adv.ClosePositionResponse{
+			ErrorResponse: &adv.ErrorResponse{Error: "INVALID_SIZE", Message: fmt.Sprintf("invalid size %s", request.Size)},
+		}, nil
+	}
+
+	side := "SELL"
+	if strings.EqualFold(position.Side, "SHORT") {
+		side = "BUY"
+	}
+
+	configuration := adv.OrderConfiguration{MarketMarketIoc: &adv.MarketIoc{BaseSize: formatFloat(size)}}
+
+	o, reason := s.state.parseOrder(request.ProductId, side, configuration, "")
+	if len(reason) > 0 {
+		return &adv.ClosePositionResponse{
+			ErrorResponse: &adv.ErrorResponse{Error: reason, Message: reason, NewOrderFailureReason: reason},
+		}, nil
+	}
+
+	o.ClientOrderId = request.ClientOrderId
+	s.state.submit(o)
+
+	if o.Status == orderStatusFailed {
+		return &adv.ClosePositionResponse{
+			ErrorResponse: &adv.ErrorResponse{Error: o.RejectReason, Message: o.RejectMessage},
+		}, nil
+	}
+
+	if size >= contracts {
+		s.state.futuresPositions = append(s.state.futuresPositions[:i], s.state.futuresPositions[i+1:]...)
+	} else {
+		position.NumberOfContracts = formatFloat(contracts - size)
+	}
+
+	return &adv.ClosePositionResponse{
+		Success: true,
+		SuccessResponse: &adv.SuccessResponse{
+			OrderId:       o.OrderId,
+			ProductId:     o.ProductId,
+			Side:          o.Side,
+			ClientOrderId: o.ClientOrderId,
+		},
+		OrderConfiguration: &configuration,
+	}, nil
+}
@@ -0,0 +1,424 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type RecorderMode int
+
+const (
+	// ModeReplay serves responses from an existing cassette and never touches the network.
+	ModeReplay RecorderMode = iota
+
+	// ModeRecord forwards requests to the real transport and saves every interaction on Stop.
+	ModeRecord
+)
+
+var ErrUnmatchedRequest = errors.New("no recorded interaction matches request")
+
+// redactedFields are the JSON fields whose values identify an account or portfolio. Their values
+// are replaced with stable placeholders wherever they appear in a cassette.
+var redactedFields = map[string]bool{
+	"uuid":                  true,
+	"account_uuid":          true,
+	"account_id":            true,
+	"retail_portfolio_id":   true,
+	"portfolio_uuid":        true,
+	"portfolio_id":          true,
+	"source_portfolio_uuid": true,
+	"target_portfolio_uuid": true,
+	"user_id":               true,
+	"from_account":          true,
+	"to_account":            true,
+	"source_id":             true,
+	"target_id":             true,
+}
+
+// defaultIgnoredBodyFields vary between runs by design and are left out of request matching.
+var defaultIgnoredBodyFields = []string{"client_order_id"}
+
+// Cassette is the fixture file format: the interactions of one recording, in order.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  *CassetteRequest  `json:"request"`
+	Response *CassetteResponse `json:"response"`
+}
+
+// CassetteRequest is a recorded request. Headers are not kept, so credentials never reach disk.
+type CassetteRequest struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+	Body   string `json:"body,omitempty"`
+}
+
+type CassetteResponse struct {
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body"`
+}
+
+type RecorderOptions struct {
+	// Transport carries requests while recording. Defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+
+	// Redact lists further values, such as a portfolio id from the credentials, to replace with
+	// placeholders. They are replaced in the cassette when recording and in incoming requests
+	// before matching when replaying, so pass the same values, in the same order, in both modes.
+	Redact []string
+
+	// IgnoreQueryParams and IgnoreBodyFields are left out when matching requests on replay, for
+	// values that legitimately change between runs such as time windows. client_order_id is
+	// always ignored.
+	IgnoreQueryParams []string
+	IgnoreBodyFields  []string
+}
+
+// Recorder is an http.RoundTripper that records interactions to, or replays them from, a cassette
+// file. Plug it into a client with client.HttpClient = recorder.HttpClient().
+type Recorder struct {
+	mode      RecorderMode
+	path      string
+	transport http.RoundTripper
+	options   RecorderOptions
+
+	mu         sync.Mutex
+	cassette   *Cassette
+	used       []bool
+	unmatched  []string
+	redactions map[string]string
+}
+
+// NewRecorder creates a recorder for the cassette at path. In replay mode the cassette must exist.
+func NewRecorder(path string, mode RecorderMode, options *RecorderOptions) (*Recorder, error) {
+
+	r := &Recorder{
+		mode:       mode,
+		path:       path,
+		transport:  http.DefaultTransport,
+		cassette:   &Cassette{},
+		redactions: make(map[string]string),
+	}
+
+	if options != nil {
+		r.options = *options
+		if options.Transport != nil {
+			r.transport = options.Transport
+		}
+	}
+
+	for _, v := range r.options.Redact {
+		r.redact(v)
+	}
+
+	if mode == ModeRecord {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cassette: %w", err)
+	}
+
+	if err := json.Unmarshal(data, r.cassette); err != nil {
+		return nil, fmt.Errorf("unable to decode cassette %s: %w", path, err)
+	}
+
+	r.used = make([]bool, len(r.cassette.Interactions))
+
+	return r, nil
+}
+
+// HttpClient returns an http.Client that sends every request through the recorder.
+func (r *Recorder) HttpClient() http.Client {
+	return http.Client{Transport: r}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	recorded := &CassetteRequest{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  req.URL.RawQuery,
+		Body:   string(body),
+	}
+
+	if r.mode == ModeReplay {
+		return r.replay(req, recorded)
+	}
+
+	forwarded := req.Clone(req.Context())
+	forwarded.Body = io.NopCloser(bytes.NewReader(body))
+	forwarded.ContentLength = int64(len(body))
+
+	res, err := r.transport.RoundTrip(forwarded)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	responseBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	if query, err := url.ParseQuery(recorded.Query); err == nil {
+		for k, values := range query {
+			if redactedFields[k] {
+				for _, v := range values {
+					r.redact(v)
+				}
+			}
+		}
+	}
+	r.collect(recorded.Body)
+	r.collect(string(responseBody))
+	r.cassette.Interactions = append(r.cassette.Interactions, &Interaction{
+		Request: recorded,
+		Response: &CassetteResponse{
+			StatusCode:  res.StatusCode,
+			ContentType: res.Header.Get("Content-Type"),
+			Body:        string(responseBody),
+		},
+	})
+	r.mu.Unlock()
+
+	res.Body = io.NopCloser(bytes.NewReader(responseBody))
+	return res, nil
+}
+
+func (r *Recorder) replay(req *http.Request, recorded *CassetteRequest) (*http.Response, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The cassette only holds placeholders, so the request's own identifiers must be replaced too.
+	recorded.Path = r.apply(recorded.Path)
+	recorded.Query = r.apply(recorded.Query)
+	recorded.Body = r.apply(recorded.Body)
+
+	key := r.matchKey(recorded)
+
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || r.matchKey(interaction.Request) != key {
+			continue
+		}
+
+		r.used[i] = true
+
+		header := http.Header{}
+		if len(interaction.Response.ContentType) > 0 {
+			header.Set("Content-Type", interaction.Response.ContentType)
+		}
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	description := fmt.Sprintf("%s %s?%s %s", recorded.Method, recorded.Path, recorded.Query, recorded.Body)
+	r.unmatched = append(r.unmatched, description)
+
+	return nil, fmt.Errorf("%w: %s", ErrUnmatchedRequest, description)
+}
+
+// Unmatched returns the requests that found no interaction to replay.
+func (r *Recorder) Unmatched() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.unmatched...)
+}
+
+// Stop finishes the session. When recording it writes the redacted cassette; when replaying it
+// returns an error if any request went unmatched.
+func (r *Recorder) Stop() error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.mode == ModeReplay {
+		if len(r.unmatched) > 0 {
+			return fmt.Errorf("%w: %d requests: %s", ErrUnmatchedRequest, len(r.unmatched), strings.Join(r.unmatched, "; "))
+		}
+		return nil
+	}
+
+	for _, interaction := range r.cassette.Interactions {
+		interaction.Request.Path = r.apply(interaction.Request.Path)
+		interaction.Request.Query = r.apply(interaction.Request.Query)
+		interaction.Request.Body = r.apply(interaction.Request.Body)
+		interaction.Response.Body = r.apply(interaction.Response.Body)
+	}
+
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode cassette: %w", err)
+	}
+
+	if err := os.WriteFile(r.path, data, 0o644); err != nil {
+		return fmt.Errorf("unable to write cassette: %w", err)
+	}
+
+	return nil
+}
+
+// collect registers the identifiers found in a JSON body for redaction.
+func (r *Recorder) collect(body string) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		return
+	}
+	r.walk(v)
+}
+
+func (r *Recorder) walk(v interface{}) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, field := range value {
+			if s, ok := field.(string); ok && redactedFields[k] {
+				r.redact(s)
+				continue
+			}
+			r.walk(field)
+		}
+	case []interface{}:
+		for _, item := range value {
+			r.walk(item)
+		}
+	}
+}
+
+// redact assigns a placeholder shaped like a uuid, so code that parses identifiers keeps working.
+func (r *Recorder) redact(v string) {
+	if len(v) == 0 {
+		return
+	}
+	if _, ok := r.redactions[v]; !ok {
+		r.redactions[v] = fmt.Sprintf("00000000-0000-4000-8000-%012d", len(r.redactions)+1)
+	}
+}
+
+// apply replaces every collected identifier, longest first so that no identifier is left half
+// replaced when one contains another.
+func (r *Recorder) apply(s string) string {
+
+	values := make([]string, 0, len(r.redactions))
+	for v := range r.redactions {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	for _, v := range values {
+		s = replaceToken(s, v, r.redactions[v])
+	}
+
+	return s
+}
+
+// replaceToken replaces old only where it stands as a whole token, so that a short identifier such
+// as a numeric user id is not replaced inside a longer id, a price or a timestamp.
+func replaceToken(s, old, new string) string {
+
+	var b strings.Builder
+	for {
+		i := strings.Index(s, old)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+
+		end := i + len(old)
+		if (i > 0 && isTokenByte(s[i-1])) || (end < len(s) && isTokenByte(s[end])) {
+			b.WriteString(s[:i+1])
+			s = s[i+1:]
+			continue
+		}
+
+		b.WriteString(s[:i])
+		b.WriteString(new)
+		s = s[end:]
+	}
+}
+
+func isTokenByte(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-' || c == '_' || c == '.'
+}
+
+// matchKey normalises a request for comparison: query parameters are sorted and JSON bodies are
+// re-encoded with ignored fields removed.
+func (r *Recorder) matchKey(req *CassetteRequest) string {
+
+	query, err := url.ParseQuery(req.Query)
+	if err == nil {
+		for _, p := range r.options.IgnoreQueryParams {
+			query.Del(p)
+		}
+	}
+
+	normalizedQuery := req.Query
+	if err == nil {
+		normalizedQuery = query.Encode()
+	}
+
+	body := req.Body
+	var v interface{}
+	if len(body) > 0 && json.Unmarshal([]byte(body), &v) == nil {
+		if fields, ok := v.(map[string]interface{}); ok {
+			for _, f := range append(defaultIgnoredBodyFields, r.options.IgnoreBodyFields...) {
+				delete(fields, f)
+			}
+		}
+		if encoded, err := json.Marshal(v); err == nil {
+			body = string(encoded)
+		}
+	}
+
+	return strings.Join([]string{req.Method, req.Path, normalizedQuery, body}, "\n")
+}
@@ -0,0 +1,146 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type handlerFunc func(c *call) (interface{}, error)
+
+type route struct {
+	method   string
+	segments []string
+	handler  handlerFunc
+}
+
+// call carries the parts of a request a handler needs. Path parameters are the segments matched
+// by "*" in the route pattern, in order.
+type call struct {
+	params []string
+	query  url.Values
+	body   []byte
+}
+
+func (c *call) decode(v interface{}) error {
+	if len(c.body) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(c.body, v); err != nil {
+		return invalidArgument("invalid request body: %v", err)
+	}
+	return nil
+}
+
+func (s *Server) handle(method, pattern string, handler handlerFunc) {
+	s.routes = append(s.routes, &route{
+		method:   method,
+		segments: strings.Split(strings.Trim(pattern, "/"), "/"),
+		handler:  handler,
+	})
+}
+
+// match returns the first registered route for the method and path, so literal routes must be
+// registered before wildcard routes that overlap them.
+func (s *Server) match(method, path string) (*route, []string) {
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for _, r := range s.routes {
+		if r.method != method || len(r.segments) != len(segments) {
+			continue
+		}
+
+		var params []string
+		matched := true
+		for i, seg := range r.segments {
+			if seg == "*" {
+				params = append(params, segments[i])
+			} else if seg != segments[i] {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return r, params
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *Server) registerRoutes() {
+
+	s.handle(http.MethodGet, "/brokerage/time", s.getServerTime)
+
+	s.handle(http.MethodGet, "/brokerage/accounts", s.listAccounts)
+	s.handle(http.MethodGet, "/brokerage/accounts/*", s.getAccount)
+
+	s.handle(http.MethodGet, "/brokerage/products", s.listProducts)
+	s.handle(http.MethodGet, "/brokerage/products/*", s.getProduct)
+	s.handle(http.MethodGet, "/brokerage/products/*/candles", s.getProductCandles)
+	s.handle(http.MethodGet, "/brokerage/products/*/ticker", s.getMarketTrades)
+	s.handle(http.MethodGet, "/brokerage/product_book", s.getProductBook)
+	s.handle(http.MethodGet, "/brokerage/best_bid_ask", s.getBestBidAsk)
+	s.handle(http.MethodGet, "/brokerage/transaction_summary", s.getTransactionsSummary)
+
+	s.handle(http.MethodGet, "/brokerage/market/products", s.listProducts)
+	s.handle(http.MethodGet, "/brokerage/market/products/*", s.getProduct)
+	s.handle(http.MethodGet, "/brokerage/market/products/*/candles", s.getProductCandles)
+	s.handle(http.MethodGet, "/brokerage/market/products/*/ticker", s.getMarketTrades)
+	s.handle(http.MethodGet, "/brokerage/market/product_book", s.getProductBook)
+
+	s.handle(http.MethodPost, "/brokerage/orders", s.createOrder)
+	s.handle(http.MethodPost, "/brokerage/orders/preview", s.previewOrder)
+	s.handle(http.MethodPost, "/brokerage/orders/batch_cancel", s.cancelOrders)
+	s.handle(http.MethodPost, "/brokerage/orders/edit", s.editOrder)
+	s.handle(http.MethodPost, "/brokerage/orders/edit_preview", s.previewEditOrder)
+	s.handle(http.MethodPost, "/brokerage/orders/close_position", s.closePosition)
+	s.handle(http.MethodGet, "/brokerage/orders/historical/batch", s.listOrders)
+	s.handle(http.MethodGet, "/brokerage/orders/historical/fills", s.listFills)
+	s.handle(http.MethodGet, "/brokerage/orders/historical/*", s.getOrder)
+
+	s.handle(http.MethodGet, "/brokerage/portfolios", s.listPortfolios)
+	s.handle(http.MethodPost, "/brokerage/portfolios", s.createPortfolio)
+	s.handle(http.MethodPost, "/brokerage/portfolios/move_funds", s.movePortfolioFunds)
+	s.handle(http.MethodGet, "/brokerage/portfolios/*", s.getPortfolioBreakdown)
+	s.handle(http.MethodPut, "/brokerage/portfolios/*", s.editPortfolio)
+	s.handle(http.MethodDelete, "/brokerage/portfolios/*", s.deletePortfolio)
+
+	s.handle(http.MethodGet, "/brokerage/payment_methods", s.listPaymentMethods)
+	s.handle(http.MethodGet, "/brokerage/payment_methods/*", s.getPaymentMethod)
+
+	s.handle(http.MethodPost, "/brokerage/convert/quote", s.createConvertQuote)
+	s.handle(http.MethodPost, "/brokerage/convert/trade/*", s.commitConvertQuote)
+	s.handle(http.MethodGet, "/brokerage/convert/trade/*", s.getConvertTrade)
+
+	s.handle(http.MethodGet, "/brokerage/cfm/balance_summary", s.getFuturesBalanceSummary)
+	s.handle(http.MethodGet, "/brokerage/cfm/positions", s.listFuturesPositions)
+	s.handle(http.MethodGet, "/brokerage/cfm/positions/*", s.getFuturesPosition)
+	s.handle(http.MethodGet, "/brokerage/cfm/sweeps", s.listFuturesSweeps)
+	s.handle(http.MethodPost, "/brokerage/cfm/sweeps/schedule", s.scheduleFuturesSweep)
+	s.handle(http.MethodDelete, "/brokerage/cfm/sweeps", s.cancelPendingFuturesSweeps)
+
+	s.handle(http.MethodPost, "/brokerage/intx/allocate", s.allocatePortfolio)
+	s.handle(http.MethodGet, "/brokerage/intx/portfolio/*", s.getPerpetualsPortfolioSummary)
+	s.handle(http.MethodGet, "/brokerage/intx/positions/*", s.listPerpetualsPositions)
+	s.handle(http.MethodGet, "/brokerage/intx/positions/*/*", s.getPerpetualsPosition)
+}
@@ -0,0 +1,285 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package advtest provides an in-process fake of the Advanced Trade REST API for tests that must
+// run without credentials or network access.
+package advtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/golang-jwt/jwt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	apiPathPrefix = "/api/v3"
+	testKeyName   = "organizations/advtest/apiKeys/advtest"
+)
+
+// Server is a fake Advanced Trade API backed by in-memory state. Point a client at it with
+// client.BaseUrl(server.BaseUrl()) or use Server.Client.
+type Server struct {
+	server      *httptest.Server
+	key         *ecdsa.PrivateKey
+	credentials *adv.Credentials
+	routes      []*route
+
+	mu       sync.Mutex
+	state    *state
+	failures []*Failure
+	requests []*RecordedRequest
+}
+
+// RecordedRequest is a request received by the server, captured after authentication.
+type RecordedRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Body   []byte
+}
+
+// NewServer starts a fake server with a freshly generated signing key and a single default
+// portfolio. Callers must Close it.
+func NewServer() (*Server, error) {
+
+	key, credentials, err := generateCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		key:         key,
+		credentials: credentials,
+		state:       newState(),
+	}
+
+	s.credentials.PortfolioId = s.state.defaultPortfolio
+
+	s.registerRoutes()
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s, nil
+}
+
+// NewTestCredentials returns credentials signed with a throwaway key, for clients whose requests are
+// never verified, such as those replaying a cassette.
+func NewTestCredentials() (*adv.Credentials, error) {
+	_, credentials, err := generateCredentials()
+	return credentials, err
+}
+
+func generateCredentials() (*ecdsa.PrivateKey, *adv.Credentials, error) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to generate test key: %w", err)
+	}
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to marshal test key: %w", err)
+	}
+
+	return key, &adv.Credentials{
+		AccessKey:     testKeyName,
+		PrivatePemKey: string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})),
+	}, nil
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// URL is the root URL of the server.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// BaseUrl is the value to pass to Client.BaseUrl.
+func (s *Server) BaseUrl() string {
+	return s.server.URL + apiPathPrefix
+}
+
+// Credentials returns credentials signed with the server's test key.
+func (s *Server) Credentials() *adv.Credentials {
+	c := *s.credentials
+	return &c
+}
+
+// Client returns an SDK client configured to call the server.
+func (s *Server) Client() *adv.Client {
+	return adv.NewClient(s.Credentials(), http.Client{}).BaseUrl(s.BaseUrl())
+}
+
+// Requests returns the requests received so far, oldest first.
+func (s *Server) Requests() []*RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*RecordedRequest(nil), s.requests...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+
+	i := strings.Index(r.URL.Path, "/brokerage/")
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("unknown path: %s", r.URL.Path))
+		return
+	}
+	path := r.URL.Path[i:]
+
+	public := isPublicPath(path)
+	if err := s.authenticate(r, public); err != nil {
+		writeError(w, http.StatusUnauthorized, "UNAUTHENTICATED", err.Error())
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, &RecordedRequest{Method: r.Method, Path: path, Query: r.URL.RawQuery, Body: body})
+	failure := s.nextFailure(r.Method, path)
+	s.mu.Unlock()
+
+	if failure != nil {
+		if handled := failure.apply(w, r); handled {
+			return
+		}
+	}
+
+	rt, params := s.match(r.Method, path)
+	if rt == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("no route for %s %s", r.Method, path))
+		return
+	}
+
+	if r.Method == http.MethodGet || r.Method == http.MethodDelete {
+		// The SDK sends the marshalled request on every call; only mutating calls carry a real body.
+		body = nil
+	}
+
+	s.mu.Lock()
+	s.state.expireOrders(time.Now())
+	response, err := rt.handler(&call{params: params, query: r.URL.Query(), body: body})
+	s.mu.Unlock()
+
+	if err != nil {
+		var apiErr *apiError
+		if errors.As(err, &apiErr) {
+			writeError(w, apiErr.status, apiErr.code, apiErr.message)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "INTERNAL", err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusOK, response)
+}
+
+func isPublicPath(path string) bool {
+	return strings.HasPrefix(path, "/brokerage/market/") || path == "/brokerage/time"
+}
+
+// authenticate verifies the bearer JWT the SDK signs for every request. Public market data paths
+// accept unauthenticated calls but still reject a token that fails verification.
+func (s *Server) authenticate(r *http.Request, public bool) error {
+
+	header := r.Header.Get("Authorization")
+	if len(header) == 0 {
+		if public {
+			return nil
+		}
+		return errors.New("missing authorization header")
+	}
+
+	tokenString, found := strings.CutPrefix(header, "Bearer ")
+	if !found {
+		return errors.New("authorization header is not a bearer token")
+	}
+
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodECDSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return &s.key.PublicKey, nil
+	})
+	if err != nil {
+		return fmt.Errorf("invalid token: %w", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return errors.New("invalid token claims")
+	}
+
+	if claims["sub"] != testKeyName || token.Header["kid"] != testKeyName {
+		return errors.New("token not issued for the test key")
+	}
+
+	if claims["iss"] != "coinbase-cloud" {
+		return fmt.Errorf("unexpected issuer: %v", claims["iss"])
+	}
+
+	if uri := fmt.Sprintf("%s %s%s", r.Method, r.Host, r.URL.Path); claims["uri"] != uri {
+		return fmt.Errorf("token uri %v does not match %s", claims["uri"], uri)
+	}
+
+	return nil
+}
+
+type apiError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.status, e.code, e.message)
+}
+
+func notFound(format string, a ...interface{}) error {
+	return &apiError{status: http.StatusNotFound, code: "NOT_FOUND", message: fmt.Sprintf(format, a...)}
+}
+
+func invalidArgument(format string, a ...interface{}) error {
+	return &apiError{status: http.StatusBadRequest, code: "INVALID_ARGUMENT", message: fmt.Sprintf(format, a...)}
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJson(w, status, map[string]string{"error": code, "message": message})
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,365 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advtest
+
+import (
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"strings"
+	"time"
+)
+
+type state struct {
+	defaultPortfolio string
+	portfolios       []*adv.Portfolio
+	accounts         []*adv.Account
+	paymentMethods   []*adv.PaymentMethod
+
+	products map[string]*adv.Product
+	books    map[string]*book
+	candles  map[string][]adv.Candle
+	trades   map[string][]*adv.Trade
+	feeTiers []adv.FeeTier
+	volume   float64
+	seq      int64
+
+	orders     []*order
+	ordersById map[string]*order
+	fills      []*adv.Fill
+
+	converts        map[string]*convertTrade
+	convertFeeRate  float64
+	convertWarnings []adv.UserWarning
+
+	futuresBalance   adv.BalanceSummary
+	futuresPositions []*adv.CfmFuturesPosition
+	sweeps           []*adv.Sweep
+
+	perpsPortfolios map[string]*adv.IntxPortfolio
+	perpsPositions  []*adv.IntxPosition
+}
+
+func newState() *state {
+
+	defaultPortfolio := &adv.Portfolio{Name: "Default", Uuid: newId(), Type: "DEFAULT"}
+
+	return &state{
+		defaultPortfolio: defaultPortfolio.Uuid,
+		portfolios:       []*adv.Portfolio{defaultPortfolio},
+		products:         make(map[string]*adv.Product),
+		books:            make(map[string]*book),
+		candles:          make(map[string][]adv.Candle),
+		trades:           make(map[string][]*adv.Trade),
+		ordersById:       make(map[string]*order),
+		converts:         make(map[string]*convertTrade),
+		perpsPortfolios:  make(map[string]*adv.IntxPortfolio),
+		feeTiers: []adv.FeeTier{{
+			PricingTier:  "Advanced 1",
+			UsdFrom:      "0",
+			UsdTo:        "10000",
+			TakerFeeRate: "0.006",
+			MakerFeeRate: "0.004",
+		}},
+	}
+}
+
+// DefaultPortfolio returns the uuid of the portfolio created with the server. Accounts and orders
+// without a portfolio belong to it.
+func (s *Server) DefaultPortfolio() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.defaultPortfolio
+}
+
+// AddAccount adds a spot account. A missing uuid is generated and a missing portfolio defaults to
+// the default portfolio. The stored account is returned.
+func (s *Server) AddAccount(account adv.Account) *adv.Account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(account.Uuid) == 0 {
+		account.Uuid = newId()
+	}
+	if len(account.RetailPortfolioId) == 0 {
+		account.RetailPortfolioId = s.state.defaultPortfolio
+	}
+	if len(account.Name) == 0 {
+		account.Name = account.Currency + " Wallet"
+	}
+	if len(account.Type) == 0 {
+		account.Type = "ACCOUNT_TYPE_CRYPTO"
+	}
+	if len(account.CreatedAt) == 0 {
+		account.CreatedAt = timestamp(time.Now())
+	}
+	account.AvailableBalance.Currency = account.Currency
+	account.Hold.Currency = account.Currency
+	account.Active = true
+	account.Ready = true
+
+	s.state.accounts = append(s.state.accounts, &account)
+
+	c := account
+	return &c
+}
+
+// Account returns a copy of the account with the given uuid.
+func (s *Server) Account(uuid string) (adv.Account, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.state.accounts {
+		if a.Uuid == uuid {
+			return *a, true
+		}
+	}
+	return adv.Account{}, false
+}
+
+// Balance returns the available balance of currency in the portfolio, or the default portfolio
+// when portfolioUuid is empty.
+func (s *Server) Balance(portfolioUuid, currency string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a := s.state.account(portfolioUuid, currency); a != nil {
+		return parseFloat(a.AvailableBalance.Value)
+	}
+	return 0
+}
+
+// AddProduct adds a product. Missing base and quote currencies are derived from the product id.
+func (s *Server) AddProduct(product adv.Product) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	base, quote := splitProductId(product.ProductId)
+	if len(product.BaseCurrencyId) == 0 {
+		product.BaseCurrencyId = base
+	}
+	if len(product.QuoteCurrencyId) == 0 {
+		product.QuoteCurrencyId = quote
+	}
+	if len(product.ProductType) == 0 {
+		product.ProductType = "SPOT"
+	}
+	if len(product.Status) == 0 {
+		product.Status = "online"
+	}
+
+	s.state.products[product.ProductId] = &product
+}
+
+// SetBook replaces the seeded liquidity of a product with the given levels. Client orders resting
+// in the book are kept and trade as makers against any new liquidity that crosses them.
+func (s *Server) SetBook(productId string, bids, asks []adv.Level) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.expireOrders(time.Now())
+	s.state.seed(s.state.book(productId), bids, asks)
+}
+
+// AddCandles appends candles served by the candles endpoints.
+func (s *Server) AddCandles(productId string, candles ...adv.Candle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.candles[productId] = append(s.state.candles[productId], candles...)
+}
+
+// AddMarketTrades appends trades served by the ticker endpoints.
+func (s *Server) AddMarketTrades(productId string, trades ...adv.Trade) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range trades {
+		t := trades[i]
+		t.ProductId = productId
+		s.state.trades[productId] = append(s.state.trades[productId], &t)
+	}
+}
+
+// SetFeeTier sets a single fee tier charged on every fill.
+func (s *Server) SetFeeTier(tier adv.FeeTier) {
+	s.SetFeeTiers(tier)
+}
+
+// SetFeeTiers sets the fee schedule. The tier charged is the one whose USD range contains the
+// volume traded so far, falling back to the last tier once volume exceeds every range.
+func (s *Server) SetFeeTiers(tiers ...adv.FeeTier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.feeTiers = append([]adv.FeeTier(nil), tiers...)
+}
+
+func (s *Server) AddPaymentMethod(method adv.PaymentMethod) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(method.Id) == 0 {
+		method.Id = newId()
+	}
+	s.state.paymentMethods = append(s.state.paymentMethods, &method)
+}
+
+func (s *Server) SetFuturesBalanceSummary(summary adv.BalanceSummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.futuresBalance = summary
+}
+
+func (s *Server) AddFuturesPosition(position adv.CfmFuturesPosition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.futuresPositions = append(s.state.futuresPositions, &position)
+}
+
+func (s *Server) SetPerpetualsPortfolio(portfolio adv.IntxPortfolio) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.perpsPortfolios[portfolio.PortfolioUuid] = &portfolio
+}
+
+func (s *Server) AddPerpetualsPosition(position adv.IntxPosition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.perpsPositions = append(s.state.perpsPositions, &position)
+}
+
+// Orders returns copies of every order received, oldest first.
+func (s *Server) Orders() []adv.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	orders := make([]adv.Order, len(s.state.orders))
+	for i, o := range s.state.orders {
+		orders[i] = o.Order
+	}
+	return orders
+}
+
+// Order returns a copy of the order with the given id.
+func (s *Server) Order(orderId string) (adv.Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if o, ok := s.state.ordersById[orderId]; ok {
+		return o.Order, true
+	}
+	return adv.Order{}, false
+}
+
+// Fills returns copies of every fill generated, oldest first.
+func (s *Server) Fills() []adv.Fill {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fills := make([]adv.Fill, len(s.state.fills))
+	for i, f := range s.state.fills {
+		fills[i] = *f
+	}
+	return fills
+}
+
+func (st *state) portfolio(uuid string) *adv.Portfolio {
+	for _, p := range st.portfolios {
+		if p.Uuid == uuid && !p.Deleted {
+			return p
+		}
+	}
+	return nil
+}
+
+func (st *state) account(portfolioUuid, currency string) *adv.Account {
+	if len(portfolioUuid) == 0 {
+		portfolioUuid = st.defaultPortfolio
+	}
+	for _, a := range st.accounts {
+		if a.RetailPortfolioId == portfolioUuid && strings.EqualFold(a.Currency, currency) {
+			return a
+		}
+	}
+	return nil
+}
+
+// credit adjusts the available balance of currency in the portfolio, creating the account when
+// funds arrive in a currency the portfolio did not hold.
+func (st *state) credit(portfolioUuid, currency string, amount float64) {
+	a := st.account(portfolioUuid, currency)
+	if a == nil {
+		if amount == 0 {
+			return
+		}
+		if len(portfolioUuid) == 0 {
+			portfolioUuid = st.defaultPortfolio
+		}
+		a = &adv.Account{
+			Uuid:              newId(),
+			Name:              currency + " Wallet",
+			Currency:          currency,
+			AvailableBalance:  adv.Amount{Currency: currency},
+			Hold:              adv.Amount{Currency: currency},
+			Active:            true,
+			Ready:             true,
+			Type:              "ACCOUNT_TYPE_CRYPTO",
+			CreatedAt:         timestamp(time.Now()),
+			RetailPortfolioId: portfolioUuid,
+		}
+		st.accounts = append(st.accounts, a)
+	}
+	a.AvailableBalance.Value = formatFloat(parseFloat(a.AvailableBalance.Value) + amount)
+}
+
+func (st *state) currentFeeTier() adv.FeeTier {
+	if len(st.feeTiers) == 0 {
+		return adv.FeeTier{}
+	}
+	for _, tier := range st.feeTiers {
+		to := parseFloat(tier.UsdTo)
+		if st.volume >= parseFloat(tier.UsdFrom) && (to == 0 || st.volume < to) {
+			return tier
+		}
+	}
+	return st.feeTiers[len(st.feeTiers)-1]
+}
+
+func (st *state) bestBidAsk(productId string) (bid, ask float64) {
+	if b, ok := st.books[productId]; ok {
+		return b.bestBidAsk()
+	}
+	return 0, 0
+}
+
+// price is the mid of the book when both sides are quoted and the product price otherwise.
+func (st *state) price(productId string) float64 {
+	if bid, ask := st.bestBidAsk(productId); bid > 0 && ask > 0 {
+		return (bid + ask) / 2
+	}
+	if p, ok := st.products[productId]; ok {
+		return parseFloat(p.Price)
+	}
+	return 0
+}
+
+// rate converts one unit of from into to using any product quoted between them.
+func (st *state) rate(from, to string) float64 {
+	if strings.EqualFold(from, to) || isUsdEquivalent(from) && isUsdEquivalent(to) {
+		return 1
+	}
+	if p := st.price(strings.ToUpper(from + "-" + to)); p > 0 {
+		return p
+	}
+	if p := st.price(strings.ToUpper(to + "-" + from)); p > 0 {
+		return 1 / p
+	}
+	return 0
+}
+
+func isUsdEquivalent(currency string) bool {
+	return strings.EqualFold(currency, "USD") || strings.EqualFold(currency, "USDC")
+}
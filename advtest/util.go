@@ -0,0 +1,68 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package advtest
+
+import (
+	"github.com/google/uuid"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func newId() string {
+	return uuid.New().String()
+}
+
+func parseFloat(v string) float64 {
+	f, _ := strconv.ParseFloat(v, 64)
+	return f
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func splitProductId(productId string) (string, string) {
+	base, quote, _ := strings.Cut(productId, "-")
+	return base, quote
+}
+
+// page slices items using an offset cursor and reports the cursor of the next page, or an empty
+// cursor on the last page.
+func page[T any](items []T, cursor, limit string) ([]T, string) {
+
+	start, _ := strconv.Atoi(cursor)
+	if start < 0 || start > len(items) {
+		start = len(items)
+	}
+
+	end := len(items)
+	if n, err := strconv.Atoi(limit); err == nil && n > 0 && start+n < end {
+		end = start + n
+	}
+
+	next := ""
+	if end < len(items) {
+		next = strconv.Itoa(end)
+	}
+
+	return items[start:end], next
+}
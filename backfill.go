@@ -0,0 +1,369 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// CandleGapFillNone leaves intervals without trades, which the API omits, out of the results.
+	CandleGapFillNone = "NONE"
+
+	// CandleGapFillPreviousClose fills intervals without trades with a flat, zero volume candle at
+	// the previous close. Gaps before the first candle cannot be filled.
+	CandleGapFillPreviousClose = "PREVIOUS_CLOSE"
+
+	defaultBackfillConcurrency = 4
+	defaultBackfillRate        = 10
+)
+
+type CandleBackfillRequest struct {
+	ProductId   string    `json:"product_id"`
+	Granularity string    `json:"granularity"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+
+	// Public fetches from the unauthenticated market endpoint instead of GetProductCandles.
+	Public bool `json:"public,omitempty"`
+
+	// Concurrency is the number of windows fetched at once. Defaults to 4.
+	Concurrency int `json:"concurrency,omitempty"`
+
+	// RateLimiter paces the window requests. Defaults to 10 requests per second, the public
+	// endpoint limit.
+	RateLimiter *RateLimiter `json:"-"`
+
+	// GapFill defaults to CandleGapFillNone. Gaps are reported either way.
+	GapFill string `json:"gap_fill,omitempty"`
+
+	// Stream receives the candles in order as soon as each window is complete, and Writer receives
+	// them in Format. When neither is set the candles are collected in the response.
+	Stream func(candle Candle) error `json:"-"`
+	Writer io.Writer                 `json:"-"`
+	Format string                    `json:"format,omitempty"`
+}
+
+// CandleGap is a run of missing candles, starting at Start and ending before End.
+type CandleGap struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type CandleBackfillResponse struct {
+	Candles  []Candle               `json:"candles,omitempty"`
+	Gaps     []CandleGap            `json:"gaps"`
+	Count    int                    `json:"count"`
+	Requests int                    `json:"requests"`
+	Request  *CandleBackfillRequest `json:"request"`
+}
+
+type candleWindow struct {
+	start   time.Time
+	end     time.Time
+	candles []Candle
+	err     error
+}
+
+// BackfillCandles fetches every candle between Start and End, splitting the range into windows
+// the API accepts and fetching them concurrently under the rate limiter. Candles are returned
+// oldest first with duplicates from overlapping windows removed.
+func BackfillCandles(
+	ctx context.Context,
+	products ProductsService,
+	request *CandleBackfillRequest,
+) (*CandleBackfillResponse, error) {
+
+	step, err := GranularityDuration(request.Granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(request.ProductId) == 0 {
+		return nil, errors.New("product id not set")
+	}
+
+	start := request.Start.Truncate(step)
+	if !request.End.After(start) {
+		return nil, errors.New("end must be after start")
+	}
+
+	switch request.GapFill {
+	case "", CandleGapFillNone, CandleGapFillPreviousClose:
+	default:
+		return nil, fmt.Errorf("unknown gap fill: %s", request.GapFill)
+	}
+
+	var writer *CandleWriter
+	if request.Writer != nil {
+		if writer, err = NewCandleWriter(request.Writer, request.Format); err != nil {
+			return nil, err
+		}
+	}
+
+	var windows []*candleWindow
+	for from := start; from.Before(request.End); {
+		to := from.Add(step * maxCandlesPerWindow)
+		if to.After(request.End) {
+			to = request.End
+		}
+		windows = append(windows, &candleWindow{start: from, end: to})
+		from = to
+	}
+
+	response := &CandleBackfillResponse{Requests: len(windows), Request: request}
+
+	emitter := &candleEmitter{
+		request:  request,
+		response: response,
+		writer:   writer,
+		step:     step,
+		next:     start,
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done, wait := fetchCandleWindows(ctx, products, request, windows)
+	defer func() {
+		cancel()
+		wait()
+	}()
+
+	for _, window := range windows {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case w := <-done[window]:
+			if w.err != nil {
+				return nil, w.err
+			}
+			if err := emitter.window(w); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := emitter.finish(request.End); err != nil {
+		return nil, err
+	}
+
+	if writer != nil {
+		if err := writer.Flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// fetchCandleWindows fetches the windows on a pool of workers. Each window is delivered on its own
+// buffered channel so the caller can consume them in order; wait returns once the workers exit
+// after the context is cancelled.
+func fetchCandleWindows(
+	ctx context.Context,
+	products ProductsService,
+	request *CandleBackfillRequest,
+	windows []*candleWindow,
+) (done map[*candleWindow]chan *candleWindow, wait func()) {
+
+	concurrency := request.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBackfillConcurrency
+	}
+
+	limiter := request.RateLimiter
+	if limiter == nil {
+		limiter = NewRateLimiter(defaultBackfillRate, defaultBackfillRate)
+	}
+
+	done = make(map[*candleWindow]chan *candleWindow, len(windows))
+	for _, w := range windows {
+		done[w] = make(chan *candleWindow, 1)
+	}
+
+	jobs := make(chan *candleWindow)
+	go func() {
+		defer close(jobs)
+		for _, w := range windows {
+			select {
+			case jobs <- w:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for w := range jobs {
+				if w.err = limiter.Wait(ctx); w.err == nil {
+					w.candles, w.err = fetchCandles(ctx, products, request, w.start, w.end)
+				}
+				done[w] <- w
+			}
+		}()
+	}
+
+	return done, wg.Wait
+}
+
+func fetchCandles(ctx context.Context, products ProductsService, request *CandleBackfillRequest, from, to time.Time) ([]Candle, error) {
+
+	var candles *[]Candle
+
+	if request.Public {
+		response, err := products.GetPublicProductCandles(ctx, &GetPublicProductCandlesRequest{
+			ProductId:   request.ProductId,
+			Start:       unixTimestamp(from),
+			End:         unixTimestamp(to),
+			Granularity: request.Granularity,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get candles from %s: %w", formatTimestamp(from), err)
+		}
+		candles = response.Candles
+	} else {
+		response, err := products.GetProductCandles(ctx, &GetProductCandlesRequest{
+			ProductId:   request.ProductId,
+			Start:       unixTimestamp(from),
+			End:         unixTimestamp(to),
+			Granularity: request.Granularity,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get candles from %s: %w", formatTimestamp(from), err)
+		}
+		candles = response.Candles
+	}
+
+	if candles == nil {
+		return nil, nil
+	}
+	return *candles, nil
+}
+
+// candleEmitter delivers candles in order, dropping duplicates and candles outside the range and
+// recording, and optionally filling, the gaps between them.
+type candleEmitter struct {
+	request   *CandleBackfillRequest
+	response  *CandleBackfillResponse
+	writer    *CandleWriter
+	step      time.Duration
+	next      time.Time
+	lastClose string
+}
+
+func (e *candleEmitter) window(w *candleWindow) error {
+
+	type timedCandle struct {
+		start  time.Time
+		candle Candle
+	}
+
+	candles := make([]timedCandle, 0, len(w.candles))
+	for _, c := range w.candles {
+		t, err := c.StartTime()
+		if err != nil {
+			return err
+		}
+		candles = append(candles, timedCandle{start: t, candle: c})
+	}
+
+	sort.SliceStable(candles, func(i, j int) bool { return candles[i].start.Before(candles[j].start) })
+
+	for _, c := range candles {
+		if c.start.Before(e.next) || !c.start.Before(e.request.End) {
+			continue
+		}
+		if err := e.gap(c.start); err != nil {
+			return err
+		}
+		if err := e.emit(c.candle); err != nil {
+			return err
+		}
+		e.next = c.start.Add(e.step)
+		e.lastClose = c.candle.Close
+	}
+
+	return nil
+}
+
+func (e *candleEmitter) finish(end time.Time) error {
+	return e.gap(end)
+}
+
+// gap records the missing candles from the next expected start up to until and, when requested,
+// fills them at the previous close.
+func (e *candleEmitter) gap(until time.Time) error {
+
+	if !e.next.Before(until) {
+		return nil
+	}
+
+	e.response.Gaps = append(e.response.Gaps, CandleGap{Start: e.next, End: until})
+
+	if e.request.GapFill != CandleGapFillPreviousClose || len(e.lastClose) == 0 {
+		e.next = until
+		return nil
+	}
+
+	for ; e.next.Before(until); e.next = e.next.Add(e.step) {
+		err := e.emit(Candle{
+			Start:  unixTimestamp(e.next),
+			Low:    e.lastClose,
+			High:   e.lastClose,
+			Open:   e.lastClose,
+			Close:  e.lastClose,
+			Volume: "0",
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *candleEmitter) emit(c Candle) error {
+
+	e.response.Count++
+
+	if e.request.Stream != nil {
+		if err := e.request.Stream(c); err != nil {
+			return err
+		}
+	}
+
+	if e.writer != nil {
+		if err := e.writer.Write(c); err != nil {
+			return err
+		}
+	}
+
+	if e.request.Stream == nil && e.writer == nil {
+		e.response.Candles = append(e.response.Candles, c)
+	}
+
+	return nil
+}
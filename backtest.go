@@ -0,0 +1,504 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// FillModelClose fills orders placed in a strategy callback at the price of the event that
+	// triggered it: the candle close or the trade price.
+	FillModelClose = "CLOSE"
+
+	// FillModelNextOpen fills orders placed in a strategy callback at the next candle's open or the
+	// next trade's price, as if they reached the exchange after the event.
+	FillModelNextOpen = "NEXT_OPEN"
+
+	// FillModelBookWalk fills orders at the event price against a ladder of fixed-size levels, so
+	// large orders pay for the depth they consume.
+	FillModelBookWalk = "BOOK_WALK"
+
+	defaultBacktestBookLevels  = 10
+	defaultBacktestBookTickBps = 1
+)
+
+// Strategy is driven by a backtest and by live code alike. client is the simulated exchange during
+// a backtest and a Client or PaperClient when trading live.
+type Strategy interface {
+	// OnCandle is called after each candle closes.
+	OnCandle(ctx context.Context, client Service, candle Candle) error
+}
+
+// TradeStrategy is implemented by strategies that can be replayed over a trade tape.
+type TradeStrategy interface {
+	OnTrade(ctx context.Context, client Service, trade Trade) error
+}
+
+type StrategyFunc func(ctx context.Context, client Service, candle Candle) error
+
+func (f StrategyFunc) OnCandle(ctx context.Context, client Service, candle Candle) error {
+	return f(ctx, client, candle)
+}
+
+type BacktestRequest struct {
+	ProductId string `json:"product_id"`
+
+	// Product supplies increments and size limits, e.g. from GetProduct. By default any size is
+	// accepted.
+	Product *Product `json:"product,omitempty"`
+
+	// Candles or Trades is the history to replay, from GetProductCandles, GetMarketTrades or
+	// ReadCandles/ReadTrades. Exactly one must be set; either order is accepted.
+	Candles []Candle `json:"candles,omitempty"`
+	Trades  []Trade  `json:"trades,omitempty"`
+
+	Strategy Strategy `json:"-"`
+
+	// FillModel defaults to FillModelClose.
+	FillModel string `json:"fill_model,omitempty"`
+
+	// FeeTier supplies the maker and taker fee rates, e.g. from GetTransactionsSummary.
+	FeeTier FeeTier `json:"fee_tier"`
+
+	// Balances are the starting balances keyed by currency.
+	Balances map[string]string `json:"balances"`
+
+	SlippageBps float64 `json:"slippage_bps,omitempty"`
+
+	// BookLevelSize is the base size of every level of the book-walk ladder, which has BookLevels
+	// levels each side spaced BookTickBps apart. Defaults to 10 levels 1 bp apart.
+	BookLevelSize string  `json:"book_level_size,omitempty"`
+	BookLevels    int     `json:"book_levels,omitempty"`
+	BookTickBps   float64 `json:"book_tick_bps,omitempty"`
+
+	// PeriodsPerYear annualises the Sharpe ratio. By default it is derived from the median spacing
+	// of the equity curve.
+	PeriodsPerYear float64 `json:"periods_per_year,omitempty"`
+}
+
+type EquityPoint struct {
+	Time     time.Time `json:"time"`
+	Equity   float64   `json:"equity"`
+	Drawdown float64   `json:"drawdown"`
+}
+
+type BacktestResult struct {
+	// Equity is the portfolio value in the quote currency at the start and after every event.
+	Equity         []EquityPoint      `json:"equity"`
+	Trades         []*TradeRecord     `json:"trades"`
+	Orders         []*Order           `json:"orders"`
+	Balances       map[string]float64 `json:"balances"`
+	StartingEquity float64            `json:"starting_equity"`
+	EndingEquity   float64            `json:"ending_equity"`
+	TotalReturn    float64            `json:"total_return"`
+	MaxDrawdown    float64            `json:"max_drawdown"`
+	Sharpe         float64            `json:"sharpe"`
+	TotalFees      float64            `json:"total_fees"`
+	Request        *BacktestRequest   `json:"request"`
+}
+
+// Backtester replays history through a Strategy against a PaperClient whose market data comes
+// from the replay, so the strategy trades through the same Service interface it uses live.
+// Resting orders are evaluated along each candle's path, open to the nearer extreme, the other
+// extreme and close, or at every trade, before the strategy sees the event.
+type Backtester struct {
+	request *BacktestRequest
+	market  *backtestMarket
+	paper   *PaperClient
+	candles []Candle
+	trades  []Trade
+	base    string
+	quote   string
+}
+
+type backtestEvent struct {
+	start  time.Time
+	time   time.Time
+	path   []float64
+	price  float64
+	fill   float64
+	candle Candle
+	trade  Trade
+}
+
+func NewBacktester(request *BacktestRequest) *Backtester {
+	return &Backtester{request: request}
+}
+
+func (b *Backtester) validate() error {
+	r := b.request
+
+	if len(r.ProductId) == 0 {
+		return errors.New("product id not set")
+	}
+
+	if r.Strategy == nil {
+		return errors.New("strategy not set")
+	}
+
+	if (len(r.Candles) > 0) == (len(r.Trades) > 0) {
+		return errors.New("exactly one of candles or trades must be set")
+	}
+
+	if len(r.Trades) > 0 {
+		if _, ok := r.Strategy.(TradeStrategy); !ok {
+			return errors.New("strategy does not implement TradeStrategy")
+		}
+	}
+
+	switch r.FillModel {
+	case "", FillModelClose, FillModelNextOpen:
+	case FillModelBookWalk:
+		if size, err := parseFloat(r.BookLevelSize); err != nil || size <= 0 {
+			return fmt.Errorf("invalid book level size: %s", r.BookLevelSize)
+		}
+	default:
+		return fmt.Errorf("unknown fill model: %s", r.FillModel)
+	}
+
+	if r.BookLevels < 0 || r.BookTickBps < 0 || r.PeriodsPerYear < 0 {
+		return errors.New("book levels, book tick and periods per year must not be negative")
+	}
+
+	return nil
+}
+
+func (b *Backtester) Run(ctx context.Context) (*BacktestResult, error) {
+
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+
+	events, err := b.events()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.setup(); err != nil {
+		return nil, err
+	}
+
+	result := &BacktestResult{Request: b.request}
+
+	first := events[0]
+	b.market.now = first.start
+	b.market.setPrice(first.path[0], first.path[0])
+	result.Equity = append(result.Equity, EquityPoint{Time: first.start, Equity: b.equity(first.path[0])})
+
+	for i, event := range events {
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		b.market.now = event.time
+		for _, price := range event.path {
+			b.market.setPrice(price, price)
+			if err := b.paper.sync(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(b.candles) > 0 {
+			b.market.closed = i + 1
+		} else {
+			b.market.seen = i + 1
+		}
+
+		b.market.setPrice(event.fill, event.fill)
+
+		if len(b.candles) > 0 {
+			err = b.request.Strategy.OnCandle(ctx, b.paper, event.candle)
+		} else {
+			err = b.request.Strategy.(TradeStrategy).OnTrade(ctx, b.paper, event.trade)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("strategy failed at %s: %w", formatTimestamp(event.time), err)
+		}
+
+		b.market.setPrice(event.price, event.price)
+		result.Equity = append(result.Equity, EquityPoint{Time: event.time, Equity: b.equity(event.price)})
+	}
+
+	b.summarize(result)
+
+	return result, nil
+}
+
+// events orders the replayed history and works out, for each event, the prices resting orders are
+// evaluated at, the price it closes at and the price orders placed in its callback fill at.
+func (b *Backtester) events() ([]backtestEvent, error) {
+	r := b.request
+
+	var events []backtestEvent
+
+	if len(r.Candles) > 0 {
+
+		candles := make([]Candle, len(r.Candles))
+		copy(candles, r.Candles)
+
+		starts := make(map[string]time.Time, len(candles))
+		for _, c := range candles {
+			start, err := c.StartTime()
+			if err != nil {
+				return nil, err
+			}
+			starts[c.Start] = start
+		}
+
+		sort.SliceStable(candles, func(i, j int) bool { return starts[candles[i].Start].Before(starts[candles[j].Start]) })
+		b.candles = candles
+
+		interval := medianInterval(len(candles), func(i int) time.Time { return starts[candles[i].Start] })
+
+		for _, c := range candles {
+			open, high, low, close, _, err := c.Ohlcv()
+			if err != nil {
+				return nil, err
+			}
+
+			path := []float64{open, low, high, close}
+			if close < open {
+				path = []float64{open, high, low, close}
+			}
+
+			events = append(events, backtestEvent{
+				start:  starts[c.Start],
+				time:   starts[c.Start].Add(interval),
+				path:   path,
+				price:  close,
+				fill:   close,
+				candle: c,
+			})
+		}
+
+	} else {
+
+		trades := make([]Trade, len(r.Trades))
+		copy(trades, r.Trades)
+		sort.SliceStable(trades, func(i, j int) bool { return trades[i].Time.Before(trades[j].Time) })
+		b.trades = trades
+
+		for _, t := range trades {
+			price, err := parseFloat(t.Price)
+			if err != nil || price <= 0 {
+				return nil, fmt.Errorf("invalid trade price %s", t.Price)
+			}
+
+			events = append(events, backtestEvent{
+				start: t.Time,
+				time:  t.Time,
+				path:  []float64{price},
+				price: price,
+				fill:  price,
+				trade: t,
+			})
+		}
+	}
+
+	if r.FillModel == FillModelNextOpen {
+		for i := 0; i < len(events)-1; i++ {
+			events[i].fill = events[i+1].path[0]
+		}
+	}
+
+	return events, nil
+}
+
+func (b *Backtester) setup() error {
+	r := b.request
+
+	product := GetProductResponse{
+		ProductId:   r.ProductId,
+		ProductType: "SPOT",
+		Status:      "online",
+	}
+
+	if r.Product != nil {
+		product.BaseIncrement = r.Product.BaseIncrement
+		product.QuoteIncrement = r.Product.QuoteIncrement
+		product.PriceIncrement = r.Product.PriceIncrement
+		product.BaseMinSize = r.Product.BaseMinSize
+		product.BaseMaxSize = r.Product.BaseMaxSize
+		product.QuoteMinSize = r.Product.QuoteMinSize
+		product.QuoteMaxSize = r.Product.QuoteMaxSize
+		product.BaseCurrencyId = r.Product.BaseCurrencyId
+		product.QuoteCurrencyId = r.Product.QuoteCurrencyId
+	}
+
+	if len(product.BaseCurrencyId) == 0 || len(product.QuoteCurrencyId) == 0 {
+		product.BaseCurrencyId, product.QuoteCurrencyId, _ = strings.Cut(r.ProductId, "-")
+	}
+
+	b.base, b.quote = product.BaseCurrencyId, product.QuoteCurrencyId
+
+	b.market = &backtestMarket{
+		product: product,
+		candles: b.candles,
+		trades:  b.trades,
+	}
+
+	if r.FillModel == FillModelBookWalk {
+		b.market.levelSize, _ = parseFloat(r.BookLevelSize)
+		b.market.levels = r.BookLevels
+		if b.market.levels == 0 {
+			b.market.levels = defaultBacktestBookLevels
+		}
+		tick := r.BookTickBps
+		if tick == 0 {
+			tick = defaultBacktestBookTickBps
+		}
+		b.market.tick = tick / 10000
+	}
+
+	paper, err := NewPaperClient(b.market, &PaperConfig{
+		Balances:     r.Balances,
+		MakerFeeRate: r.FeeTier.MakerFeeRate,
+		TakerFeeRate: r.FeeTier.TakerFeeRate,
+		SlippageBps:  r.SlippageBps,
+	})
+	if err != nil {
+		return err
+	}
+
+	paper.now = b.market.clock
+	b.paper = paper
+
+	return nil
+}
+
+// equity values the base and quote balances, including holds, in the quote currency.
+func (b *Backtester) equity(price float64) float64 {
+
+	b.paper.mu.Lock()
+	defer b.paper.mu.Unlock()
+
+	var equity float64
+	if a, ok := b.paper.accounts[b.quote]; ok {
+		equity += a.available + a.hold
+	}
+	if a, ok := b.paper.accounts[b.base]; ok {
+		equity += (a.available + a.hold) * price
+	}
+	return equity
+}
+
+func (b *Backtester) summarize(result *BacktestResult) {
+
+	b.paper.mu.Lock()
+	for _, o := range b.paper.orders {
+		order := o.order
+		result.Orders = append(result.Orders, &order)
+	}
+	for _, f := range b.paper.fills {
+		result.Trades = append(result.Trades, FillRecord(f, b.paper.portfolio.Name))
+	}
+	result.Balances = make(map[string]float64, len(b.paper.accounts))
+	for currency, a := range b.paper.accounts {
+		result.Balances[currency] = a.available + a.hold
+	}
+	result.TotalFees = b.paper.fees
+	b.paper.mu.Unlock()
+
+	peak := 0.0
+	for i := range result.Equity {
+		point := &result.Equity[i]
+		peak = math.Max(peak, point.Equity)
+		if peak > 0 {
+			point.Drawdown = (peak - point.Equity) / peak
+		}
+		result.MaxDrawdown = math.Max(result.MaxDrawdown, point.Drawdown)
+	}
+
+	result.StartingEquity = result.Equity[0].Equity
+	result.EndingEquity = result.Equity[len(result.Equity)-1].Equity
+	if result.StartingEquity > 0 {
+		result.TotalReturn = result.EndingEquity/result.StartingEquity - 1
+	}
+
+	periodsPerYear := b.request.PeriodsPerYear
+	if periodsPerYear == 0 {
+		if interval := medianInterval(len(result.Equity), func(i int) time.Time { return result.Equity[i].Time }); interval > 0 {
+			periodsPerYear = float64(365*24*time.Hour) / float64(interval)
+		}
+	}
+
+	result.Sharpe = sharpeRatio(result.Equity, periodsPerYear)
+}
+
+// sharpeRatio annualises the mean over the sample standard deviation of the period returns of the
+// equity curve, with a zero risk-free rate.
+func sharpeRatio(equity []EquityPoint, periodsPerYear float64) float64 {
+
+	var returns []float64
+	for i := 1; i < len(equity); i++ {
+		if equity[i-1].Equity > 0 {
+			returns = append(returns, equity[i].Equity/equity[i-1].Equity-1)
+		}
+	}
+
+	if len(returns) < 2 || periodsPerYear <= 0 {
+		return 0
+	}
+
+	var mean float64
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	variance /= float64(len(returns) - 1)
+
+	if variance == 0 {
+		return 0
+	}
+
+	return mean / math.Sqrt(variance) * math.Sqrt(periodsPerYear)
+}
+
+// medianInterval returns the median spacing of n chronologically ordered times.
+func medianInterval(n int, at func(i int) time.Time) time.Duration {
+
+	if n < 2 {
+		return 0
+	}
+
+	intervals := make([]time.Duration, 0, n-1)
+	for i := 1; i < n; i++ {
+		if d := at(i).Sub(at(i - 1)); d > 0 {
+			intervals = append(intervals, d)
+		}
+	}
+
+	if len(intervals) == 0 {
+		return 0
+	}
+
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+	return intervals[len(intervals)/2]
+}
@@ -0,0 +1,215 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const backtestUnlimitedSize = 1e12
+
+// backtestMarket serves a replayed product to the paper client and the strategy. Only the candles
+// and trades that have closed by the replay clock are visible, and the book is synthesised around
+// the current price: a single unlimited level by default, or a ladder of fixed-size levels for the
+// book-walk fill model.
+type backtestMarket struct {
+	product   GetProductResponse
+	levels    int
+	levelSize float64
+	tick      float64
+
+	now     time.Time
+	bid     float64
+	ask     float64
+	candles []Candle
+	closed  int
+	trades  []Trade
+	seen    int
+}
+
+func (m *backtestMarket) clock() time.Time {
+	return m.now
+}
+
+func (m *backtestMarket) setPrice(bid, ask float64) {
+	m.bid, m.ask = bid, ask
+	m.product.Price = formatFloat((bid + ask) / 2)
+}
+
+func (m *backtestMarket) book(limit int) *PriceBook {
+
+	book := &PriceBook{ProductId: m.product.ProductId, Time: formatTimestamp(m.now)}
+
+	if m.levelSize <= 0 {
+		size := formatFloat(backtestUnlimitedSize)
+		book.Bids = []Level{{Price: formatFloat(m.bid), Size: size}}
+		book.Asks = []Level{{Price: formatFloat(m.ask), Size: size}}
+		return book
+	}
+
+	levels := m.levels
+	if limit > 0 && limit < levels {
+		levels = limit
+	}
+
+	size := formatFloat(m.levelSize)
+	for i := 0; i < levels; i++ {
+		book.Bids = append(book.Bids, Level{Price: formatFloat(m.bid * (1 - float64(i)*m.tick)), Size: size})
+		book.Asks = append(book.Asks, Level{Price: formatFloat(m.ask * (1 + float64(i)*m.tick)), Size: size})
+	}
+
+	return book
+}
+
+func (m *backtestMarket) ListProducts(ctx context.Context, request *ListProductsRequest) (*ListProductsResponse, error) {
+
+	p := m.product
+	product := &Product{
+		ProductId:       p.ProductId,
+		Price:           p.Price,
+		BaseIncrement:   p.BaseIncrement,
+		QuoteIncrement:  p.QuoteIncrement,
+		PriceIncrement:  p.PriceIncrement,
+		BaseMinSize:     p.BaseMinSize,
+		BaseMaxSize:     p.BaseMaxSize,
+		QuoteMinSize:    p.QuoteMinSize,
+		QuoteMaxSize:    p.QuoteMaxSize,
+		BaseCurrencyId:  p.BaseCurrencyId,
+		QuoteCurrencyId: p.QuoteCurrencyId,
+		ProductType:     p.ProductType,
+		Status:          p.Status,
+	}
+
+	return &ListProductsResponse{Products: []*Product{product}, Request: request}, nil
+}
+
+func (m *backtestMarket) GetProduct(ctx context.Context, request *GetProductRequest) (*GetProductResponse, error) {
+
+	if request.ProductId != m.product.ProductId {
+		return nil, errUnknownBacktestProduct(request.ProductId)
+	}
+
+	product := m.product
+	product.Request = request
+	return &product, nil
+}
+
+func (m *backtestMarket) GetProductBook(ctx context.Context, request *GetProductBookRequest) (*GetProductBookResponse, error) {
+
+	if request.ProductId != m.product.ProductId {
+		return nil, errUnknownBacktestProduct(request.ProductId)
+	}
+
+	limit, _ := strconv.Atoi(request.Limit)
+	return &GetProductBookResponse{PriceBook: m.book(limit), Request: request}, nil
+}
+
+func (m *backtestMarket) GetBestBidAsk(ctx context.Context, request *GetBestBidAskRequest) (*GetBestBidAskResponse, error) {
+
+	books := []PriceBook{}
+	for _, productId := range request.ProductIds {
+		if productId == m.product.ProductId {
+			books = append(books, *m.book(1))
+		}
+	}
+
+	return &GetBestBidAskResponse{PriceBooks: &books, Request: request}, nil
+}
+
+// GetProductCandles returns the closed candles within the requested range, newest first as the
+// API does. The granularity is that of the replayed candles.
+func (m *backtestMarket) GetProductCandles(ctx context.Context, request *GetProductCandlesRequest) (*GetProductCandlesResponse, error) {
+
+	if request.ProductId != m.product.ProductId {
+		return nil, errUnknownBacktestProduct(request.ProductId)
+	}
+
+	start, _ := strconv.ParseInt(request.Start, 10, 64)
+	end, _ := strconv.ParseInt(request.End, 10, 64)
+
+	candles := []Candle{}
+	for i := m.closed - 1; i >= 0; i-- {
+		t, err := strconv.ParseInt(m.candles[i].Start, 10, 64)
+		if err != nil {
+			continue
+		}
+		if (start > 0 && t < start) || (end > 0 && t > end) {
+			continue
+		}
+		candles = append(candles, m.candles[i])
+	}
+
+	return &GetProductCandlesResponse{Candles: &candles, Request: request}, nil
+}
+
+func (m *backtestMarket) GetMarketTrades(ctx context.Context, request *GetMarketTradesRequest) (*GetMarketTradesResponse, error) {
+
+	if request.ProductId != m.product.ProductId {
+		return nil, errUnknownBacktestProduct(request.ProductId)
+	}
+
+	limit, _ := strconv.Atoi(request.Limit)
+
+	response := &GetMarketTradesResponse{
+		BestBid: formatFloat(m.bid),
+		BestAsk: formatFloat(m.ask),
+		Request: request,
+	}
+
+	for i := m.seen - 1; i >= 0 && (limit <= 0 || len(response.Trades) < limit); i-- {
+		trade := m.trades[i]
+		response.Trades = append(response.Trades, &trade)
+	}
+
+	return response, nil
+}
+
+func (m *backtestMarket) ListPublicProducts(ctx context.Context, request *ListPublicProductsRequest) (*ListPublicProductsResponse, error) {
+	return nil, paperUnsupported("ListPublicProducts")
+}
+
+func (m *backtestMarket) GetPublicProduct(ctx context.Context, request *GetPublicProductRequest) (*GetPublicProductResponse, error) {
+	return nil, paperUnsupported("GetPublicProduct")
+}
+
+func (m *backtestMarket) GetPublicProductBook(ctx context.Context, request *GetPublicProductBookRequest) (*GetPublicProductBookResponse, error) {
+	return nil, paperUnsupported("GetPublicProductBook")
+}
+
+func (m *backtestMarket) GetPublicProductCandles(ctx context.Context, request *GetPublicProductCandlesRequest) (*GetPublicProductCandlesResponse, error) {
+	return nil, paperUnsupported("GetPublicProductCandles")
+}
+
+func (m *backtestMarket) GetPublicMarketTrades(ctx context.Context, request *GetPublicMarketTradesRequest) (*GetPublicMarketTradesResponse, error) {
+	return nil, paperUnsupported("GetPublicMarketTrades")
+}
+
+func (m *backtestMarket) GetServerTime(ctx context.Context, request *GetServerTimeRequest) (*GetServerTimeResponse, error) {
+	return &GetServerTimeResponse{
+		Iso:          m.now,
+		EpochSeconds: strconv.FormatInt(m.now.Unix(), 10),
+		EpochMillis:  strconv.FormatInt(m.now.UnixMilli(), 10),
+		Request:      request,
+	}, nil
+}
+
+func errUnknownBacktestProduct(productId string) error {
+	return fmt.Errorf("product %s is not part of the backtest", productId)
+}
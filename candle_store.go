@@ -0,0 +1,469 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	candleStoreCoverageFile = "coverage.json"
+	candleStoreSegmentTime  = "2006-01"
+)
+
+var candleStoreKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+// CandleRange is the half-open interval of candle starts from Start up to, but excluding, End.
+type CandleRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type CandleStoreOptions struct {
+	// Public syncs from the unauthenticated market endpoint.
+	Public bool `json:"public,omitempty"`
+
+	// Concurrency and RateLimiter are passed to BackfillCandles.
+	Concurrency int          `json:"concurrency,omitempty"`
+	RateLimiter *RateLimiter `json:"-"`
+}
+
+// CandleStore keeps candles on disk keyed by product and granularity, along with the ranges that
+// have been synced, so repeated loads only fetch what is missing. Each key is a directory holding
+// one CSV file per UTC month and a coverage file. Candles still forming are never stored. A store
+// is safe for concurrent use and only serialises access to the same key, so syncs of different
+// products or granularities run in parallel. A directory must not be shared between processes.
+type CandleStore struct {
+	dir      string
+	products ProductsService
+	opts     CandleStoreOptions
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+type CandleSyncRequest struct {
+	ProductId   string    `json:"product_id"`
+	Granularity string    `json:"granularity"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+}
+
+type CandleSyncResponse struct {
+	// Fetched are the ranges that were missing and have now been synced.
+	Fetched  []CandleRange      `json:"fetched"`
+	Candles  int                `json:"candles"`
+	Requests int                `json:"requests"`
+	Request  *CandleSyncRequest `json:"request"`
+}
+
+type CandleQueryRequest struct {
+	ProductId   string    `json:"product_id"`
+	Granularity string    `json:"granularity"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+
+	// Sync fetches the missing parts of the range before the query is served.
+	Sync bool `json:"sync,omitempty"`
+}
+
+type CandleQueryResponse struct {
+	// Candles are oldest first. Intervals without trades have no candle.
+	Candles []Candle `json:"candles"`
+
+	// Missing are the parts of the range that have not been synced.
+	Missing []CandleRange       `json:"missing"`
+	Request *CandleQueryRequest `json:"request"`
+}
+
+func OpenCandleStore(dir string, products ProductsService, opts *CandleStoreOptions) (*CandleStore, error) {
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create candle store %s: %w", dir, err)
+	}
+
+	s := &CandleStore{dir: dir, products: products, locks: make(map[string]*sync.Mutex)}
+	if opts != nil {
+		s.opts = *opts
+	}
+
+	return s, nil
+}
+
+// Sync fetches the candles in the range that the store does not cover yet.
+func (s *CandleStore) Sync(ctx context.Context, request *CandleSyncRequest) (*CandleSyncResponse, error) {
+
+	if s.products == nil {
+		return nil, errors.New("candle store has no market data source")
+	}
+
+	dir, step, err := s.key(request.ProductId, request.Granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.lock(dir)()
+
+	coverage, err := readCandleCoverage(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &CandleSyncResponse{Request: request}
+
+	complete := time.Now().Truncate(step)
+	for _, missing := range subtractCandleRanges(alignCandleRange(request.Start, request.End, step, complete), coverage) {
+
+		fetched, err := BackfillCandles(ctx, s.products, &CandleBackfillRequest{
+			ProductId:   request.ProductId,
+			Granularity: request.Granularity,
+			Start:       missing.Start,
+			End:         missing.End,
+			Public:      s.opts.Public,
+			Concurrency: s.opts.Concurrency,
+			RateLimiter: s.opts.RateLimiter,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if err := writeCandleSegments(dir, fetched.Candles); err != nil {
+			return nil, err
+		}
+
+		coverage = mergeCandleRanges(append(coverage, missing))
+		if err := writeCandleCoverage(dir, coverage); err != nil {
+			return nil, err
+		}
+
+		response.Fetched = append(response.Fetched, missing)
+		response.Candles += len(fetched.Candles)
+		response.Requests += fetched.Requests
+	}
+
+	return response, nil
+}
+
+// Query returns the stored candles in the range and reports the parts of it that are not covered.
+func (s *CandleStore) Query(ctx context.Context, request *CandleQueryRequest) (*CandleQueryResponse, error) {
+
+	if request.Sync {
+		_, err := s.Sync(ctx, &CandleSyncRequest{
+			ProductId:   request.ProductId,
+			Granularity: request.Granularity,
+			Start:       request.Start,
+			End:         request.End,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dir, step, err := s.key(request.ProductId, request.Granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.lock(dir)()
+
+	coverage, err := readCandleCoverage(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	query := alignCandleRange(request.Start, request.End, step, time.Now().Truncate(step))
+
+	response := &CandleQueryResponse{
+		Candles: []Candle{},
+		Missing: subtractCandleRanges(query, coverage),
+		Request: request,
+	}
+
+	for month := monthStart(query.Start); month.Before(query.End); month = month.AddDate(0, 1, 0) {
+		candles, err := readCandleSegment(dir, month)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range candles {
+			t, err := c.StartTime()
+			if err != nil {
+				return nil, err
+			}
+			if !t.Before(query.Start) && t.Before(query.End) {
+				response.Candles = append(response.Candles, c)
+			}
+		}
+	}
+
+	return response, nil
+}
+
+// Coverage returns the synced ranges for a product and granularity, oldest first.
+func (s *CandleStore) Coverage(productId, granularity string) ([]CandleRange, error) {
+
+	dir, _, err := s.key(productId, granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.lock(dir)()
+
+	return readCandleCoverage(dir)
+}
+
+// lock takes the lock for the key stored in dir and returns the function that releases it.
+func (s *CandleStore) lock(dir string) func() {
+
+	s.mu.Lock()
+	l, ok := s.locks[dir]
+	if !ok {
+		l = &sync.Mutex{}
+		s.locks[dir] = l
+	}
+	s.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+func (s *CandleStore) key(productId, granularity string) (string, time.Duration, error) {
+
+	step, err := GranularityDuration(granularity)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if !candleStoreKeyPattern.MatchString(productId) {
+		return "", 0, fmt.Errorf("invalid product id: %q", productId)
+	}
+
+	return filepath.Join(s.dir, productId, granularity), step, nil
+}
+
+// alignCandleRange widens the range to whole candles and cuts it at the candle still forming.
+func alignCandleRange(start, end time.Time, step time.Duration, complete time.Time) CandleRange {
+
+	r := CandleRange{Start: start.Truncate(step), End: end.Truncate(step)}
+	if r.End.Before(end) {
+		r.End = r.End.Add(step)
+	}
+
+	if r.End.After(complete) {
+		r.End = complete
+	}
+
+	if r.End.Before(r.Start) {
+		r.End = r.Start
+	}
+
+	return r
+}
+
+// mergeCandleRanges sorts the ranges and joins those that overlap or touch.
+func mergeCandleRanges(ranges []CandleRange) []CandleRange {
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start.Before(ranges[j].Start) })
+
+	var merged []CandleRange
+	for _, r := range ranges {
+		if !r.Start.Before(r.End) {
+			continue
+		}
+		if n := len(merged); n > 0 && !r.Start.After(merged[n-1].End) {
+			if r.End.After(merged[n-1].End) {
+				merged[n-1].End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+
+	return merged
+}
+
+// subtractCandleRanges returns the parts of r not covered by the merged coverage.
+func subtractCandleRanges(r CandleRange, coverage []CandleRange) []CandleRange {
+
+	var missing []CandleRange
+
+	next := r.Start
+	for _, c := range coverage {
+		if !next.Before(r.End) {
+			break
+		}
+		if !c.End.After(next) {
+			continue
+		}
+		if c.Start.After(next) {
+			end := c.Start
+			if end.After(r.End) {
+				end = r.End
+			}
+			missing = append(missing, CandleRange{Start: next, End: end})
+		}
+		if c.End.After(next) {
+			next = c.End
+		}
+	}
+
+	if next.Before(r.End) {
+		missing = append(missing, CandleRange{Start: next, End: r.End})
+	}
+
+	return missing
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func readCandleCoverage(dir string) ([]CandleRange, error) {
+
+	data, err := os.ReadFile(filepath.Join(dir, candleStoreCoverageFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var coverage []CandleRange
+	if err := json.Unmarshal(data, &coverage); err != nil {
+		return nil, fmt.Errorf("invalid candle coverage in %s: %w", dir, err)
+	}
+
+	return mergeCandleRanges(coverage), nil
+}
+
+func writeCandleCoverage(dir string, coverage []CandleRange) error {
+
+	data, err := json.MarshalIndent(coverage, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(dir, candleStoreCoverageFile), func(f *os.File) error {
+		_, err := f.Write(data)
+		return err
+	})
+}
+
+func readCandleSegment(dir string, month time.Time) ([]Candle, error) {
+
+	f, err := os.Open(filepath.Join(dir, month.Format(candleStoreSegmentTime)+".csv"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadCandles(f, ExportFormatCsv)
+}
+
+// writeCandleSegments merges the candles into the monthly files, replacing stored candles with the
+// same start.
+func writeCandleSegments(dir string, candles []Candle) error {
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	byMonth := make(map[time.Time][]Candle)
+	for _, c := range candles {
+		t, err := c.StartTime()
+		if err != nil {
+			return err
+		}
+		byMonth[monthStart(t)] = append(byMonth[monthStart(t)], c)
+	}
+
+	for month, fetched := range byMonth {
+
+		stored, err := readCandleSegment(dir, month)
+		if err != nil {
+			return err
+		}
+
+		byStart := make(map[string]Candle, len(stored)+len(fetched))
+		for _, c := range append(stored, fetched...) {
+			byStart[c.Start] = c
+		}
+
+		merged := make([]Candle, 0, len(byStart))
+		for _, c := range byStart {
+			merged = append(merged, c)
+		}
+
+		starts := make(map[string]time.Time, len(merged))
+		for _, c := range merged {
+			if starts[c.Start], err = c.StartTime(); err != nil {
+				return err
+			}
+		}
+		sort.Slice(merged, func(i, j int) bool { return starts[merged[i].Start].Before(starts[merged[j].Start]) })
+
+		path := filepath.Join(dir, month.Format(candleStoreSegmentTime)+".csv")
+		err = writeFileAtomic(path, func(f *os.File) error {
+			return WriteCandles(f, ExportFormatCsv, merged)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFileAtomic writes to a temporary file in the same directory and renames it over path, so a
+// crash never leaves a partially written file behind.
+func writeFileAtomic(path string, write func(f *os.File) error) error {
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
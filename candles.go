@@ -0,0 +1,86 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	GranularityOneMinute     = "ONE_MINUTE"
+	GranularityFiveMinute    = "FIVE_MINUTE"
+	GranularityFifteenMinute = "FIFTEEN_MINUTE"
+	GranularityThirtyMinute  = "THIRTY_MINUTE"
+	GranularityOneHour       = "ONE_HOUR"
+	GranularityTwoHour       = "TWO_HOUR"
+	GranularitySixHour       = "SIX_HOUR"
+	GranularityOneDay        = "ONE_DAY"
+)
+
+var granularityDurations = map[string]time.Duration{
+	GranularityOneMinute:     time.Minute,
+	GranularityFiveMinute:    5 * time.Minute,
+	GranularityFifteenMinute: 15 * time.Minute,
+	GranularityThirtyMinute:  30 * time.Minute,
+	GranularityOneHour:       time.Hour,
+	GranularityTwoHour:       2 * time.Hour,
+	GranularitySixHour:       6 * time.Hour,
+	GranularityOneDay:        24 * time.Hour,
+}
+
+func GranularityDuration(granularity string) (time.Duration, error) {
+	d, ok := granularityDurations[granularity]
+	if !ok {
+		return 0, fmt.Errorf("unknown granularity: %s", granularity)
+	}
+	return d, nil
+}
+
+// StartTime parses the candle start, which the API returns as a UNIX timestamp in seconds.
+func (c Candle) StartTime() (time.Time, error) {
+	sec, err := strconv.ParseInt(c.Start, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid candle start %s: %w", c.Start, err)
+	}
+	return time.Unix(sec, 0).UTC(), nil
+}
+
+// Ohlcv parses the candle's prices and volume.
+func (c Candle) Ohlcv() (open, high, low, close, volume float64, err error) {
+	for _, f := range []struct {
+		name  string
+		value string
+		dest  *float64
+	}{
+		{"open", c.Open, &open},
+		{"high", c.High, &high},
+		{"low", c.Low, &low},
+		{"close", c.Close, &close},
+		{"volume", c.Volume, &volume},
+	} {
+		if *f.dest, err = parseFloat(f.value); err != nil {
+			return 0, 0, 0, 0, 0, fmt.Errorf("invalid candle %s %s: %w", f.name, f.value, err)
+		}
+	}
+	return open, high, low, close, volume, nil
+}
+
+func unixTimestamp(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
@@ -26,6 +26,7 @@ type Client struct {
 	HttpClient  http.Client
 	Credentials *Credentials
 	HttpBaseUrl string
+	killSwitch  *killSwitch
 }
 
 func (c *Client) BaseUrl(u string) *Client {
@@ -38,5 +39,6 @@ func NewClient(credentials *Credentials, httpClient http.Client) *Client {
 		Credentials: credentials,
 		HttpClient:  httpClient,
 		HttpBaseUrl: defaultV3ApiBaseUrl,
+		killSwitch:  &killSwitch{},
 	}
 }
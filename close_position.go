@@ -40,6 +40,18 @@ func (c Client) ClosePosition(
 	request *ClosePositionRequest,
 ) (*ClosePositionResponse, error) {
 
+	if err := c.checkKillSwitch("ClosePosition"); err != nil {
+		return nil, err
+	}
+
+	return c.closePosition(ctx, request)
+}
+
+func (c Client) closePosition(
+	ctx context.Context,
+	request *ClosePositionRequest,
+) (*ClosePositionResponse, error) {
+
 	path := fmt.Sprint("/brokerage/orders/close_position")
 
 	response := &ClosePositionResponse{Request: request}
@@ -37,6 +37,10 @@ func (c Client) CommitConvertQuote(
 	request *CommitConvertQuoteRequest,
 ) (*CommitConvertQuoteResponse, error) {
 
+	if err := c.checkKillSwitch("CommitConvertQuote"); err != nil {
+		return nil, err
+	}
+
 	path := fmt.Sprintf("/brokerage/convert/trade/%s", request.TradeId)
 
 	response := &CommitConvertQuoteResponse{Request: request}
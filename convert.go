@@ -0,0 +1,305 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	ConvertTradeStatusCreated   = "TRADE_STATUS_CREATED"
+	ConvertTradeStatusStarted   = "TRADE_STATUS_STARTED"
+	ConvertTradeStatusCompleted = "TRADE_STATUS_COMPLETED"
+	ConvertTradeStatusCanceled  = "TRADE_STATUS_CANCELED"
+
+	defaultConvertQuoteValidity = 10 * time.Second
+	defaultConvertTimeout       = 2 * time.Minute
+)
+
+var (
+	ErrConvertQuoteRejected = errors.New("convert quote rejected")
+	ErrConvertQuoteExpired  = errors.New("convert quote expired before commit")
+)
+
+type ConvertOptions struct {
+	// PortfolioUuid restricts account resolution to a single portfolio.
+	PortfolioUuid string `json:"portfolio_uuid,omitempty"`
+
+	// MaxFee is the largest total fee accepted, in the fee currency. Zero disables the check.
+	MaxFee float64 `json:"max_fee,omitempty"`
+
+	// MinRate is the lowest exchange rate accepted, in target units per source unit. Zero disables
+	// the check.
+	MinRate float64 `json:"min_rate,omitempty"`
+
+	// AllowWarnings commits quotes that carry user warnings instead of rejecting them.
+	AllowWarnings bool `json:"allow_warnings,omitempty"`
+
+	// QuoteValidity is how long a quote is trusted after it is created. The API does not return an
+	// expiry with the quote, so this is a local estimate. Defaults to 10 seconds.
+	QuoteValidity time.Duration `json:"quote_validity,omitempty"`
+
+	// Timeout bounds the wait for the trade to reach a terminal status. Defaults to 2 minutes.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	PollInterval time.Duration `json:"poll_interval,omitempty"`
+}
+
+type ConvertResult struct {
+	FromAccount string   `json:"from_account"`
+	ToAccount   string   `json:"to_account"`
+	Quote       *Convert `json:"quote"`
+	Trade       *Convert `json:"trade"`
+}
+
+// ConvertFunds quotes a conversion of amount from one currency to another, checks the quote
+// against the fee, rate and warning guards in opts, commits it before it expires and waits for the
+// trade to complete. A trade that ends cancelled is returned along with an error. When the commit
+// fails the trade is looked up, so that a commit accepted by the API is never reported as expired.
+// Once committed, the trade is returned with any error so that it can be reconciled before retrying.
+func ConvertFunds(ctx context.Context, client Service, from, to, amount string, opts *ConvertOptions) (*ConvertResult, error) {
+
+	if opts == nil {
+		opts = &ConvertOptions{}
+	}
+
+	fromAccount, toAccount, err := convertAccounts(ctx, client, from, to, opts.PortfolioUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ConvertResult{FromAccount: fromAccount, ToAccount: toAccount}
+
+	quoted := time.Now()
+	quote, err := client.CreateConvertQuote(ctx, &CreateConvertQuoteRequest{
+		FromAccount: fromAccount,
+		ToAccount:   toAccount,
+		Amount:      amount,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create convert quote: %w", err)
+	}
+
+	if quote.Convert == nil {
+		return nil, errors.New("convert quote not returned")
+	}
+
+	result.Quote = quote.Convert
+
+	if err := checkConvertQuote(quote.Convert, opts); err != nil {
+		return result, err
+	}
+
+	validity := opts.QuoteValidity
+	if validity <= 0 {
+		validity = defaultConvertQuoteValidity
+	}
+
+	if time.Since(quoted) >= validity {
+		return result, ErrConvertQuoteExpired
+	}
+
+	commit, err := client.CommitConvertQuote(ctx, &CommitConvertQuoteRequest{
+		TradeId:     quote.Convert.Id,
+		FromAccount: fromAccount,
+		ToAccount:   toAccount,
+	})
+	if err != nil {
+		trade, lookupErr := lookupConvertTrade(ctx, client, quote.Convert.Id, fromAccount, toAccount)
+		if lookupErr != nil {
+			return result, fmt.Errorf("unable to commit convert quote %s: %w", quote.Convert.Id, errors.Join(err, lookupErr))
+		}
+
+		if trade.Status == ConvertTradeStatusCreated {
+			if time.Since(quoted) >= validity {
+				return result, fmt.Errorf("%w: %v", ErrConvertQuoteExpired, err)
+			}
+			return result, fmt.Errorf("unable to commit convert quote %s: %w", quote.Convert.Id, err)
+		}
+
+		// The commit went through even though its response was lost.
+		commit = &CommitConvertQuoteResponse{Trade: trade}
+	}
+
+	result.Trade = commit.Trade
+
+	result.Trade, err = awaitConvertTrade(ctx, client, commit.Trade, quote.Convert.Id, fromAccount, toAccount, opts)
+
+	return result, err
+}
+
+func checkConvertQuote(quote *Convert, opts *ConvertOptions) error {
+
+	if len(quote.UserWarnings) > 0 && !opts.AllowWarnings {
+		var messages []string
+		for _, w := range quote.UserWarnings {
+			messages = append(messages, w.Message)
+		}
+		return fmt.Errorf("%w: warnings: %s", ErrConvertQuoteRejected, strings.Join(messages, "; "))
+	}
+
+	if opts.MaxFee > 0 {
+		fee, err := parseFloat(quote.TotalFee.Amount.Value)
+		if err != nil {
+			return fmt.Errorf("invalid convert fee %s: %w", quote.TotalFee.Amount.Value, err)
+		}
+		if fee > opts.MaxFee {
+			return fmt.Errorf("%w: fee %v %s exceeds maximum %v", ErrConvertQuoteRejected, fee, quote.TotalFee.Amount.Currency, opts.MaxFee)
+		}
+	}
+
+	if opts.MinRate > 0 {
+		rate, err := parseFloat(quote.ExchangeRate.Value)
+		if err != nil {
+			return fmt.Errorf("invalid convert exchange rate %s: %w", quote.ExchangeRate.Value, err)
+		}
+		if rate < opts.MinRate {
+			return fmt.Errorf("%w: exchange rate %v below minimum %v", ErrConvertQuoteRejected, rate, opts.MinRate)
+		}
+	}
+
+	return nil
+}
+
+func convertAccounts(ctx context.Context, client Service, from, to, portfolioUuid string) (string, string, error) {
+
+	accounts, err := listAllAccounts(ctx, client, portfolioUuid)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to list accounts: %w", err)
+	}
+
+	fromAccount := accountForCurrency(accounts, from)
+	if len(fromAccount) == 0 {
+		return "", "", fmt.Errorf("no active account for %s", from)
+	}
+
+	toAccount := accountForCurrency(accounts, to)
+	if len(toAccount) == 0 {
+		return "", "", fmt.Errorf("no active account for %s", to)
+	}
+
+	return fromAccount, toAccount, nil
+}
+
+// accountForCurrency returns the uuid of the active account holding currency, preferring the
+// default account when there is more than one.
+func accountForCurrency(accounts []*Account, currency string) string {
+	var uuid string
+	for _, a := range accounts {
+		if !a.Active || !strings.EqualFold(a.Currency, currency) {
+			continue
+		}
+		if a.Default {
+			return a.Uuid
+		}
+		if len(uuid) == 0 {
+			uuid = a.Uuid
+		}
+	}
+	return uuid
+}
+
+// lookupConvertTrade fetches a trade on a detached context, as it is called after a failed commit
+// that may have been caused by ctx ending.
+func lookupConvertTrade(ctx context.Context, client Service, tradeId, fromAccount, toAccount string) (*Convert, error) {
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelChildOrderTimeout)
+	defer cancel()
+
+	response, err := client.GetConvertTrade(ctx, &GetConvertTradeRequest{
+		TradeId:     tradeId,
+		FromAccount: fromAccount,
+		ToAccount:   toAccount,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get convert trade %s: %w", tradeId, err)
+	}
+
+	if response.Convert == nil {
+		return nil, fmt.Errorf("convert trade %s not returned", tradeId)
+	}
+
+	return response.Convert, nil
+}
+
+// awaitConvertTrade polls a committed trade until it completes or is cancelled. A committed trade
+// cannot be withdrawn, so errors are retried until maxAwaitOrderFailures in a row or the timeout, and
+// the last known state of the trade, starting with trade, is returned alongside any error so that the
+// caller can reconcile it rather than convert again.
+func awaitConvertTrade(
+	ctx context.Context,
+	client Service,
+	trade *Convert,
+	tradeId,
+	fromAccount,
+	toAccount string,
+	opts *ConvertOptions,
+) (*Convert, error) {
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultConvertTimeout
+	}
+
+	pollInterval := opts.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultExecutionPollInterval
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var failures int
+	for {
+		response, err := client.GetConvertTrade(ctx, &GetConvertTradeRequest{
+			TradeId:     tradeId,
+			FromAccount: fromAccount,
+			ToAccount:   toAccount,
+		})
+		if err == nil && response.Convert == nil {
+			err = fmt.Errorf("convert trade %s not returned", tradeId)
+		}
+
+		switch {
+		case err == nil:
+			failures = 0
+			trade = response.Convert
+			switch trade.Status {
+			case ConvertTradeStatusCompleted:
+				return trade, nil
+			case ConvertTradeStatusCanceled:
+				return trade, fmt.Errorf("convert trade %s cancelled: %s", tradeId, trade.CancellationReason.Message)
+			}
+		case ctx.Err() == nil:
+			if failures++; failures >= maxAwaitOrderFailures {
+				return trade, fmt.Errorf("unable to get convert trade %s: %w", tradeId, err)
+			}
+		}
+
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return trade, fmt.Errorf("convert trade %s not completed: %w", tradeId, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
@@ -50,6 +50,10 @@ func (c Client) CreateOrder(
 	request *CreateOrderRequest,
 ) (*CreateOrderResponse, error) {
 
+	if err := c.checkKillSwitch("CreateOrder"); err != nil {
+		return nil, err
+	}
+
 	path := fmt.Sprint("/brokerage/orders")
 
 	response := &CreateOrderResponse{Request: request}
@@ -22,9 +22,10 @@ import (
 )
 
 type EditOrderRequest struct {
-	OrderId string `json:"order_id"`
-	Price   string `json:"price"`
-	Size    string `json:"size"`
+	OrderId   string `json:"order_id"`
+	Price     string `json:"price"`
+	Size      string `json:"size"`
+	StopPrice string `json:"stop_price,omitempty"`
 }
 
 type EditOrderResponse struct {
@@ -38,6 +39,10 @@ func (c Client) EditOrder(
 	request *EditOrderRequest,
 ) (*EditOrderResponse, error) {
 
+	if err := c.checkKillSwitch("EditOrder"); err != nil {
+		return nil, err
+	}
+
 	path := fmt.Sprint("/brokerage/orders/edit")
 
 	response := &EditOrderResponse{Request: request}
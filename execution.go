@@ -0,0 +1,671 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	ExecutionOrderTypeMarketIoc = "MARKET_IOC"
+	ExecutionOrderTypeLimitGtd  = "LIMIT_GTD"
+
+	ExecutionStatusRunning    = "RUNNING"
+	ExecutionStatusPaused     = "PAUSED"
+	ExecutionStatusCompleted  = "COMPLETED"
+	ExecutionStatusIncomplete = "INCOMPLETE"
+	ExecutionStatusCancelled  = "CANCELLED"
+	ExecutionStatusFailed     = "FAILED"
+
+	defaultExecutionPollInterval = time.Second
+	cancelChildOrderTimeout      = 10 * time.Second
+	maxAwaitOrderFailures        = 3
+)
+
+var (
+	ErrExecutionCancelled = errors.New("execution cancelled")
+	ErrExecutionShortfall = errors.New("execution finished short of the target size")
+)
+
+type ExecutionReport struct {
+	Algorithm    string        `json:"algorithm"`
+	ProductId    string        `json:"product_id"`
+	Side         string        `json:"side"`
+	Status       string        `json:"status"`
+	TargetSize   float64       `json:"target_size"`
+	FilledSize   float64       `json:"filled_size"`
+	FilledValue  float64       `json:"filled_value"`
+	AveragePrice float64       `json:"average_price"`
+	ArrivalPrice float64       `json:"arrival_price"`
+	SlippageBps  float64       `json:"slippage_bps"`
+	Shortfall    float64       `json:"shortfall,omitempty"`
+	TotalFees    float64       `json:"total_fees"`
+	StartTime    time.Time     `json:"start_time"`
+	EndTime      time.Time     `json:"end_time"`
+	ChildOrders  []*ChildOrder `json:"child_orders"`
+	Error        string        `json:"error,omitempty"`
+}
+
+type ChildOrder struct {
+	OrderId            string             `json:"order_id"`
+	ClientOrderId      string             `json:"client_order_id"`
+	OrderConfiguration OrderConfiguration `json:"order_configuration"`
+	Status             string             `json:"status"`
+	Size               float64            `json:"size"`
+	FilledSize         float64            `json:"filled_size"`
+	FilledValue        float64            `json:"filled_value"`
+	Fees               float64            `json:"fees"`
+	PlacedAt           time.Time          `json:"placed_at"`
+	Fills              []*Fill            `json:"fills"`
+}
+
+type executionSlice struct {
+	productId         string
+	side              string
+	orderType         string
+	retailPortfolioId string
+	size              float64
+	deadline          time.Time
+	pollInterval      time.Duration
+}
+
+type productRules struct {
+	baseIncrement  string
+	priceIncrement string
+	baseMinSize    float64
+	baseMaxSize    float64
+}
+
+// execution holds the pause/cancel controls and the running report shared by the execution
+// algorithms.
+type execution struct {
+	mu        sync.Mutex
+	paused    bool
+	resumed   chan struct{}
+	cancelled chan struct{}
+	once      sync.Once
+
+	reportMu sync.Mutex
+	report   ExecutionReport
+	minSize  float64
+}
+
+func newExecution() *execution {
+	return &execution{cancelled: make(chan struct{})}
+}
+
+func (e *execution) Pause() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.paused {
+		e.paused = true
+		e.resumed = make(chan struct{})
+	}
+}
+
+func (e *execution) Resume() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.paused {
+		e.paused = false
+		close(e.resumed)
+	}
+}
+
+func (e *execution) Cancel() {
+	e.once.Do(func() { close(e.cancelled) })
+}
+
+func (e *execution) cancelRequested() bool {
+	select {
+	case <-e.cancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *execution) Paused() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.paused
+}
+
+// withCancel returns a context that is done when either the parent is done or Cancel is called.
+func (e *execution) withCancel(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-e.cancelled:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+func (e *execution) waitIfPaused(ctx context.Context) error {
+	e.mu.Lock()
+	paused, resumed := e.paused, e.resumed
+	e.mu.Unlock()
+
+	if !paused {
+		return nil
+	}
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sleep waits for d and, if the execution is paused when d elapses, until it is resumed.
+func (e *execution) sleep(ctx context.Context, d time.Duration) error {
+	deadline := time.Now().Add(d)
+	for {
+		if err := e.waitIfPaused(ctx); err != nil {
+			return err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(remaining)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+			if !e.Paused() {
+				return nil
+			}
+			deadline = time.Now()
+		}
+	}
+}
+
+func (e *execution) Report() *ExecutionReport {
+	e.reportMu.Lock()
+	defer e.reportMu.Unlock()
+
+	r := e.report
+	if r.Status == ExecutionStatusRunning && e.Paused() {
+		r.Status = ExecutionStatusPaused
+	}
+	r.ChildOrders = append([]*ChildOrder(nil), e.report.ChildOrders...)
+	return &r
+}
+
+func (e *execution) start(report ExecutionReport) {
+	e.reportMu.Lock()
+	defer e.reportMu.Unlock()
+	report.Status = ExecutionStatusRunning
+	report.StartTime = time.Now()
+	e.report = report
+}
+
+func (e *execution) setArrivalPrice(price float64) {
+	e.reportMu.Lock()
+	defer e.reportMu.Unlock()
+	e.report.ArrivalPrice = price
+}
+
+func (e *execution) addChildOrder(child *ChildOrder) {
+	e.reportMu.Lock()
+	defer e.reportMu.Unlock()
+
+	r := &e.report
+	r.ChildOrders = append(r.ChildOrders, child)
+	r.FilledSize += child.FilledSize
+	r.FilledValue += child.FilledValue
+	r.TotalFees += child.Fees
+
+	if r.FilledSize > 0 {
+		r.AveragePrice = r.FilledValue / r.FilledSize
+		r.SlippageBps = slippageBps(r.Side, r.ArrivalPrice, r.AveragePrice)
+	}
+}
+
+func (e *execution) filledSize() float64 {
+	e.reportMu.Lock()
+	defer e.reportMu.Unlock()
+	return e.report.FilledSize
+}
+
+// setMinSize records the smallest tradable size, below which an unfilled remainder is not
+// reported as a shortfall.
+func (e *execution) setMinSize(size float64) {
+	e.reportMu.Lock()
+	defer e.reportMu.Unlock()
+	e.minSize = size
+}
+
+func (e *execution) finish(err error) (*ExecutionReport, error) {
+	if err != nil && e.cancelRequested() {
+		err = ErrExecutionCancelled
+	}
+
+	e.reportMu.Lock()
+	r := &e.report
+	r.EndTime = time.Now()
+
+	// Passive slices that expire unfilled can leave part of the parent order unexecuted.
+	if shortfall := r.TargetSize - r.FilledSize; err == nil && shortfall > 0 && shortfall >= e.minSize {
+		r.Shortfall = shortfall
+		err = fmt.Errorf("%w: filled %s of %s", ErrExecutionShortfall, formatFloat(r.FilledSize), formatFloat(r.TargetSize))
+	}
+
+	switch {
+	case err == nil:
+		r.Status = ExecutionStatusCompleted
+	case errors.Is(err, context.Canceled) || errors.Is(err, ErrExecutionCancelled):
+		r.Status = ExecutionStatusCancelled
+	case errors.Is(err, ErrExecutionShortfall):
+		r.Status = ExecutionStatusIncomplete
+		r.Error = err.Error()
+	default:
+		r.Status = ExecutionStatusFailed
+		r.Error = err.Error()
+	}
+	e.reportMu.Unlock()
+
+	return e.Report(), err
+}
+
+// clampSize bounds a child order size by the product minimum and maximum and the remaining parent
+// size, rounded down to the base increment. A remainder too small to trade is folded into the child
+// unless that would take it past ceiling, the largest size allowed by a participation cap. A zero
+// ceiling means no cap.
+func (r *productRules) clampSize(size, remaining, ceiling float64) float64 {
+
+	size = math.Min(math.Max(size, r.baseMinSize), remaining)
+	if remaining-size < r.baseMinSize {
+		size = remaining
+	}
+
+	if r.baseMaxSize > 0 {
+		size = math.Min(size, r.baseMaxSize)
+	}
+
+	if ceiling > 0 {
+		size = math.Min(size, ceiling)
+	}
+
+	rounded, _ := parseFloat(floorToIncrement(size, r.baseIncrement))
+	if rounded < r.baseMinSize {
+		return 0
+	}
+
+	return rounded
+}
+
+func validateParentOrder(productId, side, totalSize, orderType string) error {
+
+	if len(productId) == 0 {
+		return errors.New("product id not set")
+	}
+
+	if side != "BUY" && side != "SELL" {
+		return fmt.Errorf("invalid side: %s", side)
+	}
+
+	if err := validateExecutionOrderType(orderType); err != nil {
+		return err
+	}
+
+	if total, err := parseFloat(totalSize); err != nil || total <= 0 {
+		return fmt.Errorf("invalid total size: %s", totalSize)
+	}
+
+	return nil
+}
+
+func validateExecutionOrderType(orderType string) error {
+	if orderType != ExecutionOrderTypeMarketIoc && orderType != ExecutionOrderTypeLimitGtd {
+		return fmt.Errorf("invalid order type: %s", orderType)
+	}
+	return nil
+}
+
+func slippageBps(side string, arrivalPrice, averagePrice float64) float64 {
+	if arrivalPrice <= 0 {
+		return 0
+	}
+	if side == "SELL" {
+		return (arrivalPrice - averagePrice) / arrivalPrice * 10000
+	}
+	return (averagePrice - arrivalPrice) / arrivalPrice * 10000
+}
+
+func jitter(r *rand.Rand, v, fraction float64) float64 {
+	if fraction <= 0 {
+		return v
+	}
+	return v * (1 + fraction*(2*r.Float64()-1))
+}
+
+func newRand(r *rand.Rand) *rand.Rand {
+	if r != nil {
+		return r
+	}
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+func isTerminalOrderStatus(status string) bool {
+	switch status {
+	case "FILLED", "CANCELLED", "EXPIRED", "FAILED":
+		return true
+	}
+	return false
+}
+
+func fetchProductRules(ctx context.Context, products ProductsService, productId string) (*productRules, error) {
+
+	product, err := products.GetProduct(ctx, &GetProductRequest{ProductId: productId})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get product %s: %w", productId, err)
+	}
+
+	rules := &productRules{
+		baseIncrement:  product.BaseIncrement,
+		priceIncrement: product.PriceIncrement,
+	}
+
+	if len(rules.priceIncrement) == 0 {
+		rules.priceIncrement = product.QuoteIncrement
+	}
+
+	if rules.baseMinSize, err = parseFloat(product.BaseMinSize); err != nil {
+		return nil, fmt.Errorf("invalid base min size %s: %w", product.BaseMinSize, err)
+	}
+
+	if rules.baseMaxSize, err = parseFloat(product.BaseMaxSize); err != nil {
+		return nil, fmt.Errorf("invalid base max size %s: %w", product.BaseMaxSize, err)
+	}
+
+	return rules, nil
+}
+
+func fetchBestBidAsk(ctx context.Context, products ProductsService, productId string) (bid, ask float64, err error) {
+
+	response, err := products.GetBestBidAsk(ctx, &GetBestBidAskRequest{ProductIds: []string{productId}})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if response.PriceBooks == nil {
+		return 0, 0, fmt.Errorf("no price book returned for %s", productId)
+	}
+
+	for _, book := range *response.PriceBooks {
+		if book.ProductId != productId || len(book.Bids) == 0 || len(book.Asks) == 0 {
+			continue
+		}
+		if bid, err = parseFloat(book.Bids[0].Price); err != nil {
+			return 0, 0, fmt.Errorf("invalid bid price %s: %w", book.Bids[0].Price, err)
+		}
+		if ask, err = parseFloat(book.Asks[0].Price); err != nil {
+			return 0, 0, fmt.Errorf("invalid ask price %s: %w", book.Asks[0].Price, err)
+		}
+		return bid, ask, nil
+	}
+
+	return 0, 0, fmt.Errorf("no best bid/ask returned for %s", productId)
+}
+
+// executeSlice places a single child order for an execution algorithm. Market slices are sent as
+// IOC; limit slices rest passively at the near touch as post-only GTD orders until the slice deadline.
+func executeSlice(ctx context.Context, client Service, slice *executionSlice, rules *productRules) (*ChildOrder, error) {
+
+	baseSize := floorToIncrement(slice.size, rules.baseIncrement)
+
+	request := &CreateOrderRequest{
+		ProductId:         slice.productId,
+		Side:              slice.side,
+		RetailPortfolioId: slice.retailPortfolioId,
+	}
+
+	deadline := slice.deadline
+
+	switch slice.orderType {
+	case ExecutionOrderTypeMarketIoc:
+		request.OrderConfiguration.MarketMarketIoc = &MarketIoc{BaseSize: baseSize}
+		deadline = time.Time{}
+	case ExecutionOrderTypeLimitGtd:
+		bid, ask, err := fetchBestBidAsk(ctx, client, slice.productId)
+		if err != nil {
+			return nil, fmt.Errorf("unable to price slice: %w", err)
+		}
+
+		price := bid
+		if slice.side == "SELL" {
+			price = ask
+		}
+
+		// The end time has whole seconds, so round up rather than expire before the deadline.
+		request.OrderConfiguration.LimitLimitGtd = &LimitGtd{
+			BaseSize:   baseSize,
+			LimitPrice: roundToIncrement(price, rules.priceIncrement),
+			EndTime:    deadline.Add(time.Second - 1).Truncate(time.Second).UTC().Format(time.RFC3339),
+			PostOnly:   true,
+		}
+	default:
+		return nil, validateExecutionOrderType(slice.orderType)
+	}
+
+	return executeChildOrder(ctx, client, request, slice.size, deadline, slice.pollInterval)
+}
+
+// executeChildOrder places an order and polls it until it reaches a terminal status. If the deadline
+// passes or the context is done first, the order is canceled. Fills are collected with ListFills.
+func executeChildOrder(
+	ctx context.Context,
+	orders OrdersService,
+	request *CreateOrderRequest,
+	size float64,
+	deadline time.Time,
+	pollInterval time.Duration,
+) (*ChildOrder, error) {
+
+	if len(request.ClientOrderId) == 0 {
+		request.ClientOrderId = uuid.New().String()
+	}
+
+	response, err := orders.CreateOrder(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if !response.Success {
+		return nil, fmt.Errorf("order rejected: %s", createOrderFailure(response))
+	}
+
+	child := &ChildOrder{
+		OrderId:            response.OrderId,
+		ClientOrderId:      request.ClientOrderId,
+		OrderConfiguration: request.OrderConfiguration,
+		Size:               size,
+		PlacedAt:           time.Now(),
+	}
+
+	if len(child.OrderId) == 0 && response.SuccessResponse != nil {
+		child.OrderId = response.SuccessResponse.OrderId
+	}
+
+	order, waitErr := awaitOrder(ctx, orders, child.OrderId, deadline, pollInterval)
+	if order != nil {
+		child.Status = order.Status
+	}
+
+	if err := collectFills(ctx, orders, child); err != nil && waitErr == nil {
+		waitErr = err
+	}
+
+	return child, waitErr
+}
+
+// awaitOrder polls an order until it reaches a terminal status. Failed polls are retried, and the
+// order is canceled rather than left working untracked when polling keeps failing, the deadline
+// passes or the context is done.
+func awaitOrder(ctx context.Context, orders OrdersService, orderId string, deadline time.Time, pollInterval time.Duration) (*Order, error) {
+
+	if pollInterval <= 0 {
+		pollInterval = defaultExecutionPollInterval
+	}
+
+	var failures int
+	for {
+		response, err := orders.GetOrder(ctx, &GetOrderRequest{OrderId: orderId})
+		if err == nil && response.Order == nil {
+			err = fmt.Errorf("order %s not returned", orderId)
+		}
+
+		switch {
+		case err == nil && isTerminalOrderStatus(response.Order.Status):
+			return response.Order, nil
+		case err == nil:
+			failures = 0
+		case ctx.Err() == nil:
+			if failures++; failures >= maxAwaitOrderFailures {
+				return cancelAndFetch(orders, orderId, fmt.Errorf("unable to get order %s: %w", orderId, err))
+			}
+		}
+
+		if ctx.Err() != nil || (!deadline.IsZero() && time.Now().After(deadline)) {
+			return cancelAndFetch(orders, orderId, ctx.Err())
+		}
+
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+func cancelAndFetch(orders OrdersService, orderId string, cause error) (*Order, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), cancelChildOrderTimeout)
+	defer cancel()
+
+	if _, err := orders.CancelOrders(ctx, &CancelOrdersRequest{OrderIds: []string{orderId}}); err != nil {
+		return nil, fmt.Errorf("unable to cancel order %s: %w", orderId, err)
+	}
+
+	response, err := orders.GetOrder(ctx, &GetOrderRequest{OrderId: orderId})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get order %s: %w", orderId, err)
+	}
+
+	if response.Order == nil {
+		return nil, fmt.Errorf("order %s not returned", orderId)
+	}
+
+	return response.Order, cause
+}
+
+func collectFills(ctx context.Context, orders OrdersService, child *ChildOrder) error {
+
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), cancelChildOrderTimeout)
+		defer cancel()
+	}
+
+	fills, err := listAllFills(ctx, orders, &ListFillsRequest{OrderId: child.OrderId})
+	if err != nil {
+		return fmt.Errorf("unable to list fills for order %s: %w", child.OrderId, err)
+	}
+
+	child.Fills = fills
+	child.FilledSize, child.FilledValue, child.Fees = 0, 0, 0
+
+	for _, f := range fills {
+		size, price, commission, err := fillValues(f)
+		if err != nil {
+			return err
+		}
+		child.FilledSize += size
+		child.FilledValue += size * price
+		child.Fees += commission
+	}
+
+	return nil
+}
+
+func listAllFills(ctx context.Context, orders OrdersService, request *ListFillsRequest) ([]*Fill, error) {
+
+	var fills []*Fill
+	for {
+		response, err := orders.ListFills(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+
+		fills = append(fills, response.Fills...)
+
+		if len(response.Cursor) == 0 || response.Cursor == request.Cursor || len(response.Fills) == 0 {
+			return fills, nil
+		}
+
+		next := *request
+		next.Cursor = response.Cursor
+		request = &next
+	}
+}
+
+// fillValues returns the base size, price and commission of a fill. Fills sized in quote are
+// converted to base size.
+func fillValues(f *Fill) (size, price, commission float64, err error) {
+	if price, err = parseFloat(f.Price); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid fill price %s: %w", f.Price, err)
+	}
+	if size, err = parseFloat(f.Size); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid fill size %s: %w", f.Size, err)
+	}
+	if commission, err = parseFloat(f.Commission); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid fill commission %s: %w", f.Commission, err)
+	}
+	if f.SizeInQuote && price > 0 {
+		size = size / price
+	}
+	return size, price, commission, nil
+}
+
+func createOrderFailure(response *CreateOrderResponse) string {
+	if response.ErrorResponse != nil {
+		if len(response.ErrorResponse.Message) > 0 {
+			return fmt.Sprintf("%s - %s", response.ErrorResponse.Error, response.ErrorResponse.Message)
+		}
+		if len(response.ErrorResponse.Error) > 0 {
+			return response.ErrorResponse.Error
+		}
+	}
+	return response.FailureReason
+}
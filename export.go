@@ -0,0 +1,470 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ExportFormatCsv   = "CSV"
+	ExportFormatJsonl = "JSONL"
+
+	RecordTypeFill    = "FILL"
+	RecordTypeOrder   = "ORDER"
+	RecordTypeConvert = "CONVERT"
+
+	HoldingPeriodShortTerm = "SHORT_TERM"
+	HoldingPeriodLongTerm  = "LONG_TERM"
+
+	maxFillsPageSize = "1000"
+)
+
+var ErrUnmatchedSells = errors.New("sells without an open lot")
+
+var tradeRecordColumns = []string{
+	"record_type",
+	"timestamp",
+	"product_id",
+	"side",
+	"size",
+	"price",
+	"fees",
+	"order_id",
+	"trade_id",
+	"liquidity_indicator",
+	"portfolio",
+}
+
+var disposalRecordColumns = []string{
+	"product_id",
+	"quantity",
+	"open_time",
+	"close_time",
+	"open_trade_id",
+	"close_trade_id",
+	"cost_basis",
+	"proceeds",
+	"gain",
+	"short",
+	"holding_period",
+}
+
+type TradeRecord struct {
+	RecordType         string `json:"record_type"`
+	Timestamp          string `json:"timestamp"`
+	ProductId          string `json:"product_id"`
+	Side               string `json:"side"`
+	Size               string `json:"size"`
+	Price              string `json:"price"`
+	Fees               string `json:"fees"`
+	OrderId            string `json:"order_id"`
+	TradeId            string `json:"trade_id"`
+	LiquidityIndicator string `json:"liquidity_indicator"`
+	Portfolio          string `json:"portfolio"`
+}
+
+type DisposalRecord struct {
+	ProductId     string `json:"product_id"`
+	Quantity      string `json:"quantity"`
+	OpenTime      string `json:"open_time"`
+	CloseTime     string `json:"close_time"`
+	OpenTradeId   string `json:"open_trade_id"`
+	CloseTradeId  string `json:"close_trade_id"`
+	CostBasis     string `json:"cost_basis"`
+	Proceeds      string `json:"proceeds"`
+	Gain          string `json:"gain"`
+	Short         string `json:"short"`
+	HoldingPeriod string `json:"holding_period"`
+}
+
+type ExportTradeHistoryRequest struct {
+	ProductId         string    `json:"product_id,omitempty"`
+	RetailPortfolioId string    `json:"retail_portfolio_id,omitempty"`
+	Start             time.Time `json:"start"`
+	End               time.Time `json:"end"`
+
+	// ConvertTrades identifies the convert trades to include. The API has no endpoint to list them,
+	// so their ids must be supplied, e.g. as recorded when they were committed.
+	ConvertTrades []*ExportConvertTrade `json:"convert_trades,omitempty"`
+
+	Format string `json:"format"`
+
+	// CostBasisMethod selects how fills are matched into tax-lot disposals. Disposals are only
+	// written when Disposals is set.
+	CostBasisMethod string `json:"cost_basis_method,omitempty"`
+
+	// OpeningFills are fills made before Start that opened lots still held at Start. They seed the
+	// lots disposed of by sells in the range and are not written as trades; disposals they close among
+	// themselves are not written either.
+	OpeningFills []*Fill `json:"opening_fills,omitempty"`
+
+	// AllowShortSales writes sells that exceed the open lots as short lots. Otherwise such a sell, e.g.
+	// of an asset bought before Start and missing from OpeningFills, fails the export with
+	// ErrUnmatchedSells before any disposal is written.
+	AllowShortSales bool `json:"allow_short_sales,omitempty"`
+
+	Trades    io.Writer `json:"-"`
+	Disposals io.Writer `json:"-"`
+}
+
+// ExportConvertTrade identifies a convert trade to export. Convert trades do not report when they
+// were made, so Time is the commit time recorded by the caller and is used as the timestamp.
+type ExportConvertTrade struct {
+	GetConvertTradeRequest
+	Time time.Time `json:"time"`
+}
+
+type ExportTradeHistoryResponse struct {
+	Fills         int                        `json:"fills"`
+	Orders        int                        `json:"orders"`
+	ConvertTrades int                        `json:"convert_trades"`
+	Disposals     int                        `json:"disposals"`
+	Request       *ExportTradeHistoryRequest `json:"request"`
+}
+
+// ExportTradeHistory pages through fills and orders, fetches the requested convert trades and
+// writes them as normalized records ordered by timestamp. Tax-lot disposals computed from the
+// fills are written separately.
+func ExportTradeHistory(
+	ctx context.Context,
+	client Service,
+	request *ExportTradeHistoryRequest,
+) (*ExportTradeHistoryResponse, error) {
+
+	if request.Format != ExportFormatCsv && request.Format != ExportFormatJsonl {
+		return nil, fmt.Errorf("unknown export format: %s", request.Format)
+	}
+
+	if request.Trades == nil {
+		return nil, errors.New("trades writer not set")
+	}
+
+	var engine *PnlEngine
+	if request.Disposals != nil {
+		var err error
+		if engine, err = NewPnlEngine(request.CostBasisMethod); err != nil {
+			return nil, err
+		}
+	}
+
+	fills, err := listAllFills(ctx, client, &ListFillsRequest{
+		ProductId:              request.ProductId,
+		StartSequenceTimestamp: formatTimestamp(request.Start),
+		EndSequenceTimestamp:   formatTimestamp(request.End),
+		Limit:                  maxFillsPageSize,
+		RetailPortfolioId:      request.RetailPortfolioId,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list fills: %w", err)
+	}
+
+	orders, err := listAllOrders(ctx, client, &ListOrdersRequest{
+		ProductId:         request.ProductId,
+		StartDate:         formatTimestamp(request.Start),
+		EndDate:           formatTimestamp(request.End),
+		RetailPortfolioId: request.RetailPortfolioId,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list orders: %w", err)
+	}
+
+	response := &ExportTradeHistoryResponse{
+		Fills:   len(fills),
+		Orders:  len(orders),
+		Request: request,
+	}
+
+	portfolio := request.RetailPortfolioId
+
+	type timedRecord struct {
+		time   time.Time
+		record *TradeRecord
+	}
+
+	var timed []timedRecord
+	for _, f := range fills {
+		timed = append(timed, timedRecord{f.TradeTime, FillRecord(f, portfolio)})
+	}
+
+	for _, o := range orders {
+		created, _ := time.Parse(time.RFC3339Nano, o.CreatedTime)
+		timed = append(timed, timedRecord{created, OrderRecord(o, portfolio)})
+	}
+
+	for _, r := range request.ConvertTrades {
+		trade, err := client.GetConvertTrade(ctx, &r.GetConvertTradeRequest)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get convert trade %s: %w", r.TradeId, err)
+		}
+		if trade.Convert != nil {
+			timed = append(timed, timedRecord{r.Time, ConvertRecord(trade.Convert, r.Time, portfolio)})
+			response.ConvertTrades++
+		}
+	}
+
+	sort.SliceStable(timed, func(i, j int) bool { return timed[i].time.Before(timed[j].time) })
+
+	records := make([]*TradeRecord, len(timed))
+	for i, t := range timed {
+		records[i] = t.record
+	}
+
+	if err := WriteTradeRecords(request.Trades, request.Format, records); err != nil {
+		return nil, err
+	}
+
+	if engine == nil {
+		return response, nil
+	}
+
+	if err := engine.AddFills(request.OpeningFills...); err != nil {
+		return nil, fmt.Errorf("invalid opening fills: %w", err)
+	}
+
+	if err := engine.AddFills(fills...); err != nil {
+		return nil, err
+	}
+
+	if !request.AllowShortSales {
+		if unmatched := unmatchedSells(engine); len(unmatched) > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrUnmatchedSells, strings.Join(unmatched, ", "))
+		}
+	}
+
+	var disposals []Disposal
+	for _, d := range engine.Disposals() {
+		if !d.CloseTime.Before(request.Start) {
+			disposals = append(disposals, d)
+		}
+	}
+	response.Disposals = len(disposals)
+
+	if err := WriteDisposals(request.Disposals, request.Format, disposals); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// unmatchedSells returns the trade ids of the sells that opened short lots, whether or not a later
+// buy closed them.
+func unmatchedSells(engine *PnlEngine) []string {
+
+	var tradeIds []string
+	seen := make(map[string]bool)
+	add := func(tradeId string) {
+		if !seen[tradeId] {
+			seen[tradeId] = true
+			tradeIds = append(tradeIds, tradeId)
+		}
+	}
+
+	for _, d := range engine.Disposals() {
+		if d.Short {
+			add(d.OpenTradeId)
+		}
+	}
+
+	for _, p := range engine.Positions() {
+		for _, lot := range p.Lots {
+			if lot.Short {
+				add(lot.TradeId)
+			}
+		}
+	}
+
+	return tradeIds
+}
+
+// FillRecord normalizes a fill. The size is always in the base currency, converting fills sized in
+// quote at the fill price. The portfolio is the fill's own, or portfolio when the fill has none.
+func FillRecord(f *Fill, portfolio string) *TradeRecord {
+
+	size := f.Size
+	if f.SizeInQuote {
+		if base, _, _, err := fillValues(f); err == nil {
+			size = formatFloat(base)
+		}
+	}
+
+	return &TradeRecord{
+		RecordType:         RecordTypeFill,
+		Timestamp:          formatTimestamp(f.TradeTime),
+		ProductId:          f.ProductId,
+		Side:               f.Side,
+		Size:               size,
+		Price:              f.Price,
+		Fees:               f.Commission,
+		OrderId:            f.OrderId,
+		TradeId:            f.TradeId,
+		LiquidityIndicator: f.LiquidityIndicator,
+		Portfolio:          recordPortfolio(f.RetailPortfolioId, portfolio),
+	}
+}
+
+// OrderRecord normalizes an order. The portfolio is the order's own, or portfolio when the order
+// has none.
+func OrderRecord(o *Order, portfolio string) *TradeRecord {
+
+	timestamp := o.CreatedTime
+	if t, err := time.Parse(time.RFC3339Nano, o.CreatedTime); err == nil {
+		timestamp = formatTimestamp(t)
+	}
+
+	return &TradeRecord{
+		RecordType: RecordTypeOrder,
+		Timestamp:  timestamp,
+		ProductId:  o.ProductId,
+		Side:       o.Side,
+		Size:       o.FilledSize,
+		Price:      o.AverageFilledPrice,
+		Fees:       o.TotalFees,
+		OrderId:    o.OrderId,
+		Portfolio:  recordPortfolio(o.RetailPortfolioId, portfolio),
+	}
+}
+
+// ConvertRecord normalizes a convert trade made at t. The size is in the source currency and the
+// price is the exchange rate. Convert trades carry neither a time nor a portfolio, so both are
+// taken from the arguments.
+func ConvertRecord(c *Convert, t time.Time, portfolio string) *TradeRecord {
+	return &TradeRecord{
+		RecordType: RecordTypeConvert,
+		Timestamp:  formatTimestamp(t),
+		ProductId:  fmt.Sprintf("%s-%s", c.SourceCurrency, c.TargetCurrency),
+		Side:       "SELL",
+		Size:       c.UserEnteredAmount.Value,
+		Price:      c.ExchangeRate.Value,
+		Fees:       c.TotalFee.Amount.Value,
+		TradeId:    c.Id,
+		Portfolio:  portfolio,
+	}
+}
+
+func recordPortfolio(own, fallback string) string {
+	if len(own) > 0 {
+		return own
+	}
+	return fallback
+}
+
+func WriteTradeRecords(w io.Writer, format string, records []*TradeRecord) error {
+
+	rows := make([][]string, len(records))
+	for i, r := range records {
+		rows[i] = []string{
+			r.RecordType,
+			r.Timestamp,
+			r.ProductId,
+			r.Side,
+			r.Size,
+			r.Price,
+			r.Fees,
+			r.OrderId,
+			r.TradeId,
+			r.LiquidityIndicator,
+			r.Portfolio,
+		}
+	}
+
+	return writeRecords(w, format, tradeRecordColumns, rows, records)
+}
+
+func WriteDisposals(w io.Writer, format string, disposals []Disposal) error {
+
+	records := make([]*DisposalRecord, len(disposals))
+	rows := make([][]string, len(disposals))
+	for i, d := range disposals {
+		holding := HoldingPeriodShortTerm
+		if !d.OpenTime.IsZero() && !d.CloseTime.Before(d.OpenTime.AddDate(1, 0, 0)) {
+			holding = HoldingPeriodLongTerm
+		}
+
+		r := &DisposalRecord{
+			ProductId:     d.ProductId,
+			Quantity:      formatFloat(d.Quantity),
+			OpenTime:      formatTimestamp(d.OpenTime),
+			CloseTime:     formatTimestamp(d.CloseTime),
+			OpenTradeId:   d.OpenTradeId,
+			CloseTradeId:  d.CloseTradeId,
+			CostBasis:     formatFloat(d.CostBasis),
+			Proceeds:      formatFloat(d.Proceeds),
+			Gain:          formatFloat(d.Gain),
+			Short:         strconv.FormatBool(d.Short),
+			HoldingPeriod: holding,
+		}
+
+		records[i] = r
+		rows[i] = []string{
+			r.ProductId,
+			r.Quantity,
+			r.OpenTime,
+			r.CloseTime,
+			r.OpenTradeId,
+			r.CloseTradeId,
+			r.CostBasis,
+			r.Proceeds,
+			r.Gain,
+			r.Short,
+			r.HoldingPeriod,
+		}
+	}
+
+	return writeRecords(w, format, disposalRecordColumns, rows, records)
+}
+
+func writeRecords[T any](w io.Writer, format string, columns []string, rows [][]string, records []T) error {
+
+	switch format {
+	case ExportFormatCsv:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(columns); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	case ExportFormatJsonl:
+		enc := json.NewEncoder(w)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown export format: %s", format)
+}
+
+func formatTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
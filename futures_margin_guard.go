@@ -0,0 +1,500 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	MarginActionNone           = "NONE"
+	MarginActionAlert          = "ALERT"
+	MarginActionCancelSweeps   = "CANCEL_SWEEPS"
+	MarginActionScheduleSweep  = "SCHEDULE_SWEEP"
+	MarginActionReduceExposure = "REDUCE_EXPOSURE"
+
+	MarginLevelHealthy  = "HEALTHY"
+	MarginLevelWarning  = "WARNING"
+	MarginLevelCritical = "CRITICAL"
+	MarginLevelExcess   = "EXCESS"
+
+	sweepStatusPending = "PENDING"
+
+	defaultReduceExposureCooldown = time.Minute
+)
+
+type FuturesMarginGuardRequest struct {
+	PollInterval time.Duration `json:"poll_interval"`
+
+	// WarningBufferPercentage and CriticalBufferPercentage are compared with
+	// LiquidationBufferPercentage. Below the warning level an alert is raised and pending sweeps are
+	// canceled so that funds stay in the futures account; below the critical level exposure is also
+	// reduced when ReduceExposure is set.
+	WarningBufferPercentage  float64 `json:"warning_buffer_percentage"`
+	CriticalBufferPercentage float64 `json:"critical_buffer_percentage"`
+
+	// ReduceExposure closes ReduceFraction of every open position at the critical level. A position
+	// is not reduced again until its previous close has filled and shows in the position.
+	ReduceExposure bool    `json:"reduce_exposure"`
+	ReduceFraction float64 `json:"reduce_fraction"`
+
+	// SweepAboveBufferPercentage schedules a sweep of SweepAmount to spot when the buffer is above
+	// this level and no sweep is pending. Zero disables sweeping.
+	SweepAboveBufferPercentage float64 `json:"sweep_above_buffer_percentage"`
+	SweepAmount                string  `json:"sweep_amount"`
+
+	// ActionCooldown is the minimum time between two executions of the same action. Reducing
+	// exposure waits at least a minute when it is not set.
+	ActionCooldown time.Duration `json:"action_cooldown"`
+
+	// DryRun records decisions in the audit log without acting on them.
+	DryRun bool `json:"dry_run"`
+
+	// AuditWriter receives each decision as a JSON line, in addition to the in-memory audit log.
+	AuditWriter io.Writer `json:"-"`
+
+	OnDecision func(MarginGuardDecision) `json:"-"`
+}
+
+type MarginGuardDecision struct {
+	Time             time.Time `json:"time"`
+	Level            string    `json:"level"`
+	Action           string    `json:"action"`
+	Reason           string    `json:"reason"`
+	BufferPercentage float64   `json:"buffer_percentage"`
+	BufferAmount     float64   `json:"buffer_amount"`
+	AvailableMargin  float64   `json:"available_margin"`
+	DryRun           bool      `json:"dry_run"`
+	Executed         bool      `json:"executed"`
+	Details          []string  `json:"details,omitempty"`
+	Error            string    `json:"error,omitempty"`
+}
+
+// FuturesMarginGuard watches the CFM liquidation buffer and applies the configured policy, keeping
+// an audit log of every decision it takes.
+type FuturesMarginGuard struct {
+	client  Service
+	request *FuturesMarginGuardRequest
+
+	mu         sync.Mutex
+	audit      []MarginGuardDecision
+	lastAction map[string]time.Time
+	level      string
+	closing    map[string]*marginGuardClose
+}
+
+// marginGuardClose is a close order placed to reduce exposure, along with the size of the position
+// it was placed against.
+type marginGuardClose struct {
+	orderId   string
+	contracts float64
+}
+
+func NewFuturesMarginGuard(client Service, request *FuturesMarginGuardRequest) *FuturesMarginGuard {
+	return &FuturesMarginGuard{
+		client:     client,
+		request:    request,
+		lastAction: make(map[string]time.Time),
+		level:      MarginLevelHealthy,
+		closing:    make(map[string]*marginGuardClose),
+	}
+}
+
+func (g *FuturesMarginGuard) AuditLog() []MarginGuardDecision {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]MarginGuardDecision(nil), g.audit...)
+}
+
+func (g *FuturesMarginGuard) validate() error {
+	r := g.request
+
+	if r.CriticalBufferPercentage > r.WarningBufferPercentage {
+		return errors.New("critical buffer percentage must not exceed the warning level")
+	}
+
+	if r.SweepAboveBufferPercentage != 0 && r.SweepAboveBufferPercentage <= r.WarningBufferPercentage {
+		return errors.New("sweep buffer percentage must be above the warning level")
+	}
+
+	if r.SweepAboveBufferPercentage != 0 {
+		if amount, err := parseFloat(r.SweepAmount); err != nil || amount <= 0 {
+			return fmt.Errorf("invalid sweep amount: %s", r.SweepAmount)
+		}
+	}
+
+	if r.ReduceExposure && (r.ReduceFraction <= 0 || r.ReduceFraction > 1) {
+		return fmt.Errorf("invalid reduce fraction: %v", r.ReduceFraction)
+	}
+
+	if r.ActionCooldown < 0 {
+		return fmt.Errorf("invalid action cooldown: %v", r.ActionCooldown)
+	}
+
+	return nil
+}
+
+func (g *FuturesMarginGuard) Run(ctx context.Context) error {
+
+	pollInterval := g.request.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultRiskPollInterval
+	}
+
+	for {
+		if _, err := g.Check(ctx); err != nil {
+			return err
+		}
+
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Check reads the balance summary once and applies the policy. Failed actions are recorded in the
+// audit log and do not stop the guard; only failing to read the balance summary returns an error.
+func (g *FuturesMarginGuard) Check(ctx context.Context) ([]MarginGuardDecision, error) {
+	r := g.request
+
+	if err := g.validate(); err != nil {
+		return nil, err
+	}
+
+	summary, err := g.client.GetFuturesBalanceSummary(ctx, &GetFuturesBalanceSummaryRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get futures balance summary: %w", err)
+	}
+
+	if summary.BalanceSummary == nil {
+		return nil, errors.New("futures balance summary not returned")
+	}
+
+	base := MarginGuardDecision{Time: time.Now(), DryRun: r.DryRun}
+	balance := summary.BalanceSummary
+
+	if base.BufferPercentage, err = parseFloat(balance.LiquidationBufferPercentage); err != nil {
+		return nil, fmt.Errorf("invalid liquidation buffer percentage %s: %w", balance.LiquidationBufferPercentage, err)
+	}
+
+	if base.BufferAmount, err = parseFloat(balance.LiquidationBufferAmount.Value); err != nil {
+		return nil, fmt.Errorf("invalid liquidation buffer amount %s: %w", balance.LiquidationBufferAmount.Value, err)
+	}
+
+	if base.AvailableMargin, err = parseFloat(balance.AvailableMargin.Value); err != nil {
+		return nil, fmt.Errorf("invalid available margin %s: %w", balance.AvailableMargin.Value, err)
+	}
+
+	pct := base.BufferPercentage
+	switch {
+	case pct < r.CriticalBufferPercentage:
+		base.Level = MarginLevelCritical
+	case pct < r.WarningBufferPercentage:
+		base.Level = MarginLevelWarning
+	case r.SweepAboveBufferPercentage > 0 && pct > r.SweepAboveBufferPercentage:
+		base.Level = MarginLevelExcess
+	default:
+		base.Level = MarginLevelHealthy
+	}
+
+	g.mu.Lock()
+	previous := g.level
+	g.level = base.Level
+	g.mu.Unlock()
+
+	var decisions []MarginGuardDecision
+
+	if base.Level != previous {
+		d := base
+		d.Action = MarginActionAlert
+		d.Reason = fmt.Sprintf("margin level changed from %s to %s", previous, base.Level)
+		d.Executed = true
+		decisions = append(decisions, d)
+	}
+
+	switch base.Level {
+	case MarginLevelCritical:
+		decisions = append(decisions, g.cancelPendingSweeps(ctx, base))
+		if r.ReduceExposure {
+			decisions = append(decisions, g.reduceExposure(ctx, base))
+		}
+	case MarginLevelWarning:
+		decisions = append(decisions, g.cancelPendingSweeps(ctx, base))
+	case MarginLevelExcess:
+		decisions = append(decisions, g.scheduleSweep(ctx, base))
+	}
+
+	var recorded []MarginGuardDecision
+	for _, d := range decisions {
+		if d.Action == MarginActionNone {
+			continue
+		}
+		g.record(d)
+		recorded = append(recorded, d)
+	}
+
+	return recorded, nil
+}
+
+func (g *FuturesMarginGuard) record(d MarginGuardDecision) {
+
+	g.mu.Lock()
+	g.audit = append(g.audit, d)
+	if d.Executed && !d.DryRun {
+		g.lastAction[d.Action] = d.Time
+	}
+	g.mu.Unlock()
+
+	if g.request.AuditWriter != nil {
+		if b, err := json.Marshal(d); err == nil {
+			_, _ = g.request.AuditWriter.Write(append(b, '\n'))
+		}
+	}
+
+	if g.request.OnDecision != nil {
+		g.request.OnDecision(d)
+	}
+}
+
+// coolingDown reports whether action ran too recently to run again.
+func (g *FuturesMarginGuard) coolingDown(action string, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	cooldown := g.request.ActionCooldown
+	if action == MarginActionReduceExposure && cooldown <= 0 {
+		cooldown = defaultReduceExposureCooldown
+	}
+
+	last, ok := g.lastAction[action]
+	return ok && now.Sub(last) < cooldown
+}
+
+func (g *FuturesMarginGuard) pendingSweeps(ctx context.Context) ([]*Sweep, error) {
+
+	response, err := g.client.ListFuturesSweeps(ctx, &ListFuturesSweepsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list futures sweeps: %w", err)
+	}
+
+	var pending []*Sweep
+	for _, s := range response.Sweeps {
+		if s.Status == sweepStatusPending {
+			pending = append(pending, s)
+		}
+	}
+
+	return pending, nil
+}
+
+func (g *FuturesMarginGuard) cancelPendingSweeps(ctx context.Context, d MarginGuardDecision) MarginGuardDecision {
+
+	d.Action = MarginActionCancelSweeps
+
+	pending, err := g.pendingSweeps(ctx)
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+
+	if len(pending) == 0 {
+		d.Action = MarginActionNone
+		return d
+	}
+
+	d.Reason = fmt.Sprintf("buffer %v%% at %s level, keeping funds in the futures account", d.BufferPercentage, d.Level)
+	for _, s := range pending {
+		d.Details = append(d.Details, fmt.Sprintf("sweep %s of %s %s", s.Id, s.RequestedAmount.Value, s.RequestedAmount.Currency))
+	}
+
+	if d.DryRun {
+		return d
+	}
+
+	response, err := g.client.CancelPendingFuturesSweeps(ctx, &CancelPendingFuturesSweepsRequest{})
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+
+	d.Executed = response.Success
+	if !response.Success {
+		d.Error = "cancel pending sweeps was not successful"
+	}
+
+	return d
+}
+
+func (g *FuturesMarginGuard) scheduleSweep(ctx context.Context, d MarginGuardDecision) MarginGuardDecision {
+
+	d.Action = MarginActionScheduleSweep
+
+	if g.coolingDown(d.Action, d.Time) {
+		d.Action = MarginActionNone
+		return d
+	}
+
+	pending, err := g.pendingSweeps(ctx)
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+
+	if len(pending) > 0 {
+		d.Action = MarginActionNone
+		return d
+	}
+
+	d.Reason = fmt.Sprintf("buffer %v%% above %v%%, sweeping excess to spot", d.BufferPercentage, g.request.SweepAboveBufferPercentage)
+	d.Details = []string{fmt.Sprintf("sweep %s USD", g.request.SweepAmount)}
+
+	if d.DryRun {
+		return d
+	}
+
+	response, err := g.client.ScheduleFuturesSweep(ctx, &ScheduleFuturesSweepRequest{UsdAmount: g.request.SweepAmount})
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+
+	d.Executed = response.Success
+	if !response.Success {
+		d.Error = "schedule sweep was not successful"
+	}
+
+	return d
+}
+
+func (g *FuturesMarginGuard) reduceExposure(ctx context.Context, d MarginGuardDecision) MarginGuardDecision {
+
+	d.Action = MarginActionReduceExposure
+
+	if g.coolingDown(d.Action, d.Time) {
+		d.Action = MarginActionNone
+		return d
+	}
+
+	positions, err := g.client.ListFuturesPositions(ctx, &ListFuturesPositionsRequest{})
+	if err != nil {
+		d.Error = fmt.Sprintf("unable to list futures positions: %v", err)
+		return d
+	}
+
+	d.Reason = fmt.Sprintf("buffer %v%% below critical %v%%, closing %v of each position",
+		d.BufferPercentage, g.request.CriticalBufferPercentage, g.request.ReduceFraction)
+
+	var errs []error
+	var closed int
+	for _, p := range positions.FuturesPositions {
+		contracts, err := parseFloat(p.NumberOfContracts)
+		if err != nil || contracts == 0 {
+			continue
+		}
+
+		if pending := g.pendingClose(ctx, p.ProductId, math.Abs(contracts)); len(pending) > 0 {
+			d.Details = append(d.Details, pending)
+			continue
+		}
+
+		size := math.Max(1, math.Floor(math.Abs(contracts)*g.request.ReduceFraction))
+		d.Details = append(d.Details, fmt.Sprintf("close %v of %v %s contracts", size, math.Abs(contracts), p.ProductId))
+
+		if d.DryRun {
+			continue
+		}
+
+		response, err := g.client.ClosePosition(ctx, &ClosePositionRequest{
+			ClientOrderId: uuid.New().String(),
+			ProductId:     p.ProductId,
+			Size:          formatFloat(size),
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.ProductId, err))
+			continue
+		}
+		if !response.Success {
+			errs = append(errs, fmt.Errorf("%s: %s", p.ProductId, errorResponseMessage(response.ErrorResponse)))
+			continue
+		}
+
+		closed++
+		if response.SuccessResponse != nil {
+			g.mu.Lock()
+			g.closing[p.ProductId] = &marginGuardClose{orderId: response.SuccessResponse.OrderId, contracts: math.Abs(contracts)}
+			g.mu.Unlock()
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		d.Error = err.Error()
+	}
+
+	// Positions whose previous close is still pending are not acted on again until it settles.
+	if !d.DryRun && closed == 0 && len(errs) == 0 {
+		d.Action = MarginActionNone
+	}
+
+	d.Executed = closed > 0
+
+	return d
+}
+
+// pendingClose describes the previous close of productId when it has not filled or does not show in
+// the position of contracts yet, and forgets it once it has settled.
+func (g *FuturesMarginGuard) pendingClose(ctx context.Context, productId string, contracts float64) string {
+
+	g.mu.Lock()
+	c, ok := g.closing[productId]
+	g.mu.Unlock()
+
+	if !ok {
+		return ""
+	}
+
+	response, err := g.client.GetOrder(ctx, &GetOrderRequest{OrderId: c.orderId})
+	if err != nil || response.Order == nil {
+		return fmt.Sprintf("skip %s, close order %s status unknown", productId, c.orderId)
+	}
+
+	if !isTerminalOrderStatus(response.Order.Status) {
+		return fmt.Sprintf("skip %s, close order %s still %s", productId, c.orderId, response.Order.Status)
+	}
+
+	filled, _ := parseFloat(response.Order.FilledSize)
+	if filled > 0 && contracts > c.contracts-filled {
+		return fmt.Sprintf("skip %s, close of %v contracts not reflected in the position yet", productId, filled)
+	}
+
+	g.mu.Lock()
+	delete(g.closing, productId)
+	g.mu.Unlock()
+
+	return ""
+}
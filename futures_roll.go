@@ -0,0 +1,391 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ProductTypeFuture            = "FUTURE"
+	ContractExpiryTypeExpiring   = "EXPIRING"
+	ContractExpiryTypePerpetual  = "PERPETUAL"
+	futuresPositionSideLongToken = "LONG"
+	maxRollLegAttempts           = 3
+	rollLegRetryDelay            = 250 * time.Millisecond
+)
+
+type PlanFuturesRollRequest struct {
+	// Window selects positions whose contracts expire within this duration from now.
+	Window     time.Duration `json:"window"`
+	ProductIds []string      `json:"product_ids,omitempty"`
+}
+
+type PlanFuturesRollResponse struct {
+	Rolls   []*FuturesRoll          `json:"rolls"`
+	Request *PlanFuturesRollRequest `json:"request"`
+}
+
+type FuturesRoll struct {
+	Position       *CfmFuturesPosition   `json:"position"`
+	CurrentProduct *GetProductResponse   `json:"current_product"`
+	NextProduct    *Product              `json:"next_product"`
+	Expiry         time.Time             `json:"expiry"`
+	NextExpiry     time.Time             `json:"next_expiry"`
+	CloseRequest   *ClosePositionRequest `json:"close_request"`
+	OpenRequest    *CreateOrderRequest   `json:"open_request"`
+	CloseSide      string                `json:"close_side"`
+}
+
+type ExecuteFuturesRollRequest struct {
+	Rolls []*FuturesRoll `json:"rolls"`
+
+	// DryRun previews both legs with CreateOrderPreview instead of trading.
+	DryRun bool `json:"dry_run"`
+
+	// OpenFirst enters the next contract before closing the expiring one, trading a brief period of
+	// double exposure for never being flat.
+	OpenFirst bool `json:"open_first"`
+
+	// LegDelay is the pause between the two legs of a roll.
+	LegDelay time.Duration `json:"leg_delay"`
+}
+
+type ExecuteFuturesRollResponse struct {
+	Results []*FuturesRollResult       `json:"results"`
+	Request *ExecuteFuturesRollRequest `json:"request"`
+}
+
+type FuturesRollResult struct {
+	Roll         *FuturesRoll                `json:"roll"`
+	ClosePreview *CreateOrderPreviewResponse `json:"close_preview,omitempty"`
+	OpenPreview  *CreateOrderPreviewResponse `json:"open_preview,omitempty"`
+	Close        *ClosePositionResponse      `json:"close,omitempty"`
+	Open         *CreateOrderResponse        `json:"open,omitempty"`
+	Error        string                      `json:"error,omitempty"`
+
+	// Partial is set when the first leg executed but the second did not, leaving the position
+	// flat (close first) or exposed in both contracts (open first). Close and Open show which leg
+	// went through.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// PlanFuturesRoll finds CFM futures positions nearing expiry and pairs each with the next
+// expiring contract of the same underlying, venue and contract size.
+func PlanFuturesRoll(
+	ctx context.Context,
+	client Service,
+	request *PlanFuturesRollRequest,
+) (*PlanFuturesRollResponse, error) {
+
+	positions, err := client.ListFuturesPositions(ctx, &ListFuturesPositionsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list futures positions: %w", err)
+	}
+
+	products, err := client.ListProducts(ctx, &ListProductsRequest{
+		ProductType:        ProductTypeFuture,
+		ContractExpiryType: ContractExpiryTypeExpiring,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list futures products: %w", err)
+	}
+
+	filter := make(map[string]bool)
+	for _, id := range request.ProductIds {
+		filter[id] = true
+	}
+
+	response := &PlanFuturesRollResponse{Request: request}
+	cutoff := time.Now().Add(request.Window)
+
+	for _, position := range positions.FuturesPositions {
+		if len(filter) > 0 && !filter[position.ProductId] {
+			continue
+		}
+
+		current, err := client.GetProduct(ctx, &GetProductRequest{ProductId: position.ProductId})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get product %s: %w", position.ProductId, err)
+		}
+
+		expiry, err := contractExpiry(current.FutureProductDetails.ContractExpiry, position.ExpirationTime)
+		if err != nil {
+			return nil, fmt.Errorf("unable to determine expiry of %s: %w", position.ProductId, err)
+		}
+
+		if expiry.After(cutoff) {
+			continue
+		}
+
+		next, nextExpiry, err := nextContract(current, expiry, products.Products)
+		if err != nil {
+			return nil, err
+		}
+
+		response.Rolls = append(response.Rolls, newFuturesRoll(position, current, next, expiry, nextExpiry))
+	}
+
+	return response, nil
+}
+
+func newFuturesRoll(
+	position *CfmFuturesPosition,
+	current *GetProductResponse,
+	next *Product,
+	expiry,
+	nextExpiry time.Time,
+) *FuturesRoll {
+
+	openSide, closeSide := "SELL", "BUY"
+	if strings.Contains(strings.ToUpper(position.Side), futuresPositionSideLongToken) {
+		openSide, closeSide = "BUY", "SELL"
+	}
+
+	return &FuturesRoll{
+		Position:       position,
+		CurrentProduct: current,
+		NextProduct:    next,
+		Expiry:         expiry,
+		NextExpiry:     nextExpiry,
+		CloseRequest: &ClosePositionRequest{
+			ProductId: position.ProductId,
+			Size:      position.NumberOfContracts,
+		},
+		OpenRequest: &CreateOrderRequest{
+			ProductId: next.ProductId,
+			Side:      openSide,
+			OrderConfiguration: OrderConfiguration{
+				MarketMarketIoc: &MarketIoc{BaseSize: position.NumberOfContracts},
+			},
+		},
+		CloseSide: closeSide,
+	}
+}
+
+func nextContract(current *GetProductResponse, expiry time.Time, products []*Product) (*Product, time.Time, error) {
+
+	details := current.FutureProductDetails
+
+	type candidate struct {
+		product *Product
+		expiry  time.Time
+	}
+
+	var candidates []candidate
+	for _, p := range products {
+		d := p.FutureProductDetails
+		if p.ProductId == current.ProductId ||
+			d.ContractRootUnit != details.ContractRootUnit ||
+			d.ContractSize != details.ContractSize ||
+			d.Venue != details.Venue ||
+			p.TradingDisabled || p.IsDisabled || p.CancelOnly {
+			continue
+		}
+
+		e, err := time.Parse(time.RFC3339, d.ContractExpiry)
+		if err != nil || !e.After(expiry) {
+			continue
+		}
+
+		candidates = append(candidates, candidate{product: p, expiry: e})
+	}
+
+	if len(candidates) == 0 {
+		return nil, time.Time{}, fmt.Errorf("no next contract found for %s", current.ProductId)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].expiry.Before(candidates[j].expiry) })
+
+	return candidates[0].product, candidates[0].expiry, nil
+}
+
+func contractExpiry(values ...string) (time.Time, error) {
+	for _, v := range values {
+		if len(v) == 0 {
+			continue
+		}
+		return time.Parse(time.RFC3339, v)
+	}
+	return time.Time{}, errors.New("contract expiry not set")
+}
+
+// ExecuteFuturesRoll closes each expiring position and opens the same number of contracts in the
+// next expiry. A failure in one roll is recorded in its result and does not stop the others. Once
+// the first leg of a roll has executed the second is retried on transport errors, and the result
+// is marked Partial if it still does not go through.
+func ExecuteFuturesRoll(
+	ctx context.Context,
+	client Service,
+	request *ExecuteFuturesRollRequest,
+) (*ExecuteFuturesRollResponse, error) {
+
+	response := &ExecuteFuturesRollResponse{Request: request}
+
+	var errs []error
+	for _, roll := range request.Rolls {
+		result := &FuturesRollResult{Roll: roll}
+		response.Results = append(response.Results, result)
+
+		var err error
+		if request.DryRun {
+			err = previewFuturesRoll(ctx, client, roll, result)
+		} else {
+			err = executeFuturesRoll(ctx, client, request, roll, result)
+		}
+
+		if err != nil {
+			result.Error = err.Error()
+			errs = append(errs, fmt.Errorf("roll of %s failed: %w", roll.Position.ProductId, err))
+		}
+	}
+
+	return response, errors.Join(errs...)
+}
+
+func previewFuturesRoll(ctx context.Context, client Service, roll *FuturesRoll, result *FuturesRollResult) error {
+
+	closePreview, err := client.CreateOrderPreview(ctx, &CreateOrderPreviewRequest{
+		ProductId: roll.Position.ProductId,
+		Side:      roll.CloseSide,
+		OrderConfiguration: OrderConfiguration{
+			MarketMarketIoc: &MarketIoc{BaseSize: roll.Position.NumberOfContracts},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to preview close: %w", err)
+	}
+	result.ClosePreview = closePreview
+
+	openPreview, err := client.CreateOrderPreview(ctx, &CreateOrderPreviewRequest{
+		ProductId:          roll.OpenRequest.ProductId,
+		Side:               roll.OpenRequest.Side,
+		OrderConfiguration: roll.OpenRequest.OrderConfiguration,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to preview open: %w", err)
+	}
+	result.OpenPreview = openPreview
+
+	if len(closePreview.Errs) > 0 || len(openPreview.Errs) > 0 {
+		return fmt.Errorf("preview errors: %s", strings.Join(append(closePreview.Errs, openPreview.Errs...), ", "))
+	}
+
+	return nil
+}
+
+func executeFuturesRoll(
+	ctx context.Context,
+	client Service,
+	request *ExecuteFuturesRollRequest,
+	roll *FuturesRoll,
+	result *FuturesRollResult,
+) error {
+
+	closeRequest := *roll.CloseRequest
+	closeRequest.ClientOrderId = uuid.New().String()
+
+	openRequest := *roll.OpenRequest
+	openRequest.ClientOrderId = uuid.New().String()
+
+	// Each leg reports whether a response was received, so that only transport failures are retried.
+	closeLeg := func(ctx context.Context) (bool, error) {
+		response, err := client.ClosePosition(ctx, &closeRequest)
+		if err != nil {
+			return false, fmt.Errorf("unable to close %s: %w", closeRequest.ProductId, err)
+		}
+		result.Close = response
+
+		if !response.Success {
+			return true, fmt.Errorf("close of %s rejected: %s", closeRequest.ProductId, errorResponseMessage(response.ErrorResponse))
+		}
+		return true, nil
+	}
+
+	openLeg := func(ctx context.Context) (bool, error) {
+		response, err := client.CreateOrder(ctx, &openRequest)
+		if err != nil {
+			return false, fmt.Errorf("unable to open %s: %w", openRequest.ProductId, err)
+		}
+		result.Open = response
+
+		if !response.Success {
+			return true, fmt.Errorf("open of %s rejected: %s", openRequest.ProductId, createOrderFailure(response))
+		}
+		return true, nil
+	}
+
+	first, second := closeLeg, openLeg
+	if request.OpenFirst {
+		first, second = openLeg, closeLeg
+	}
+
+	if _, err := first(ctx); err != nil {
+		return err
+	}
+
+	// Once the first leg has executed, the second is sent even if ctx is done, as stopping here
+	// would leave only half of the roll.
+	if request.LegDelay > 0 {
+		timer := time.NewTimer(request.LegDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+
+	legCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelChildOrderTimeout)
+	defer cancel()
+
+	var err error
+	for attempt := 0; attempt < maxRollLegAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(rollLegRetryDelay)
+		}
+
+		// The client order id is reused, so a retried request that did reach the exchange is not
+		// executed twice.
+		var responded bool
+		if responded, err = second(legCtx); err == nil || responded {
+			break
+		}
+	}
+
+	if err != nil {
+		result.Partial = true
+		return fmt.Errorf("roll left partial after the first leg: %w", err)
+	}
+
+	return nil
+}
+
+func errorResponseMessage(response *ErrorResponse) string {
+	if response == nil {
+		return "unknown error"
+	}
+	if len(response.Message) > 0 {
+		return fmt.Sprintf("%s - %s", response.Error, response.Message)
+	}
+	return response.Error
+}
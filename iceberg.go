@@ -0,0 +1,272 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const AlgorithmIceberg = "ICEBERG"
+
+type IcebergRequest struct {
+	ProductId         string        `json:"product_id"`
+	Side              string        `json:"side"`
+	TotalSize         string        `json:"total_size"`
+	LimitPrice        string        `json:"limit_price"`
+	DisplaySize       string        `json:"display_size"`
+	DisplayJitter     float64       `json:"display_jitter"`
+	PostOnly          bool          `json:"post_only"`
+	PollInterval      time.Duration `json:"poll_interval"`
+	RetailPortfolioId string        `json:"retail_portfolio_id,omitempty"`
+	Rand              *rand.Rand    `json:"-"`
+}
+
+// IcebergExecutor works a large limit order by only resting a small display size on the book as
+// a GTC limit order, replacing it with a new randomised tranche once it has been filled.
+type IcebergExecutor struct {
+	*execution
+	client  Service
+	request *IcebergRequest
+	rand    *rand.Rand
+
+	priceMu      sync.Mutex
+	limitPrice   string
+	priceUpdated chan struct{}
+}
+
+func NewIcebergExecutor(client Service, request *IcebergRequest) *IcebergExecutor {
+	return &IcebergExecutor{
+		execution:    newExecution(),
+		client:       client,
+		request:      request,
+		rand:         newRand(request.Rand),
+		limitPrice:   request.LimitPrice,
+		priceUpdated: make(chan struct{}, 1),
+	}
+}
+
+// UpdatePrice changes the limit price. The resting tranche is canceled and replaced at the new
+// price with its unfilled size.
+func (e *IcebergExecutor) UpdatePrice(price string) {
+	e.priceMu.Lock()
+	e.limitPrice = price
+	e.priceMu.Unlock()
+
+	select {
+	case e.priceUpdated <- struct{}{}:
+	default:
+	}
+}
+
+func (e *IcebergExecutor) price() string {
+	e.priceMu.Lock()
+	defer e.priceMu.Unlock()
+	return e.limitPrice
+}
+
+func (e *IcebergExecutor) validate() (total, display float64, err error) {
+	r := e.request
+
+	if err := validateParentOrder(r.ProductId, r.Side, r.TotalSize, ExecutionOrderTypeLimitGtd); err != nil {
+		return 0, 0, err
+	}
+
+	if price, err := parseFloat(r.LimitPrice); err != nil || price <= 0 {
+		return 0, 0, fmt.Errorf("invalid limit price: %s", r.LimitPrice)
+	}
+
+	if display, err = parseFloat(r.DisplaySize); err != nil || display <= 0 {
+		return 0, 0, fmt.Errorf("invalid display size: %s", r.DisplaySize)
+	}
+
+	if r.DisplayJitter < 0 || r.DisplayJitter >= 1 {
+		return 0, 0, errors.New("display jitter must be in [0, 1)")
+	}
+
+	total, _ = parseFloat(r.TotalSize)
+	return total, display, nil
+}
+
+func (e *IcebergExecutor) Run(ctx context.Context) (*ExecutionReport, error) {
+
+	total, display, err := e.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := e.withCancel(ctx)
+	defer cancel()
+
+	e.start(ExecutionReport{
+		Algorithm:  AlgorithmIceberg,
+		ProductId:  e.request.ProductId,
+		Side:       e.request.Side,
+		TargetSize: total,
+	})
+
+	return e.finish(e.run(ctx, total, display))
+}
+
+func (e *IcebergExecutor) run(ctx context.Context, total, display float64) error {
+	r := e.request
+
+	rules, err := fetchProductRules(ctx, e.client, r.ProductId)
+	if err != nil {
+		return err
+	}
+	e.setMinSize(rules.baseMinSize)
+
+	bid, ask, err := fetchBestBidAsk(ctx, e.client, r.ProductId)
+	if err != nil {
+		return fmt.Errorf("unable to get arrival price: %w", err)
+	}
+	e.setArrivalPrice((bid + ask) / 2)
+
+	for {
+		if err := e.waitIfPaused(ctx); err != nil {
+			return err
+		}
+
+		remaining := total - e.filledSize()
+		if remaining < rules.baseMinSize || remaining <= 0 {
+			return nil
+		}
+
+		size := rules.clampSize(jitter(e.rand, display, r.DisplayJitter), remaining, 0)
+		if size <= 0 {
+			return nil
+		}
+
+		child, repriced, err := e.placeTranche(ctx, size, rules)
+		if child != nil {
+			e.addChildOrder(child)
+		}
+		if err != nil {
+			return err
+		}
+
+		if child.Status != "FILLED" && !repriced {
+			return fmt.Errorf("tranche %s ended with status %s", child.OrderId, child.Status)
+		}
+	}
+}
+
+// placeTranche rests one display tranche and waits until it is filled, the price is updated or
+// the context is done. It reports whether the tranche was pulled because of a price update.
+func (e *IcebergExecutor) placeTranche(ctx context.Context, size float64, rules *productRules) (*ChildOrder, bool, error) {
+	r := e.request
+
+	// The tranche is priced below, so any pending update has already been applied.
+	select {
+	case <-e.priceUpdated:
+	default:
+	}
+
+	price, _ := parseFloat(e.price())
+
+	request := &CreateOrderRequest{
+		ProductId:         r.ProductId,
+		Side:              r.Side,
+		ClientOrderId:     uuid.New().String(),
+		RetailPortfolioId: r.RetailPortfolioId,
+		OrderConfiguration: OrderConfiguration{
+			LimitLimitGtc: &LimitGtc{
+				BaseSize:   floorToIncrement(size, rules.baseIncrement),
+				LimitPrice: roundToIncrement(price, rules.priceIncrement),
+				PostOnly:   r.PostOnly,
+			},
+		},
+	}
+
+	response, err := e.client.CreateOrder(ctx, request)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !response.Success {
+		return nil, false, fmt.Errorf("order rejected: %s", createOrderFailure(response))
+	}
+
+	child := &ChildOrder{
+		OrderId:            response.OrderId,
+		ClientOrderId:      request.ClientOrderId,
+		OrderConfiguration: request.OrderConfiguration,
+		Size:               size,
+		PlacedAt:           time.Now(),
+	}
+
+	if len(child.OrderId) == 0 && response.SuccessResponse != nil {
+		child.OrderId = response.SuccessResponse.OrderId
+	}
+
+	order, repriced, waitErr := e.awaitTranche(ctx, child.OrderId)
+	if order != nil {
+		child.Status = order.Status
+	}
+
+	if err := collectFills(ctx, e.client, child); err != nil && waitErr == nil {
+		waitErr = err
+	}
+
+	return child, repriced, waitErr
+}
+
+func (e *IcebergExecutor) awaitTranche(ctx context.Context, orderId string) (*Order, bool, error) {
+
+	pollInterval := e.request.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultExecutionPollInterval
+	}
+
+	var failures int
+	for {
+		response, err := e.client.GetOrder(ctx, &GetOrderRequest{OrderId: orderId})
+		switch {
+		case err == nil && isTerminalOrderStatus(response.Order.Status):
+			return response.Order, false, nil
+		case err == nil:
+			failures = 0
+		case ctx.Err() == nil:
+			if failures++; failures >= maxAwaitOrderFailures {
+				order, err := cancelAndFetch(e.client, orderId, fmt.Errorf("unable to get order %s: %w", orderId, err))
+				return order, false, err
+			}
+		}
+
+		if ctx.Err() != nil {
+			order, err := cancelAndFetch(e.client, orderId, ctx.Err())
+			return order, false, err
+		}
+
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-e.priceUpdated:
+			timer.Stop()
+			order, err := cancelAndFetch(e.client, orderId, nil)
+			return order, true, err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,92 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package indicators
+
+import (
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"math"
+)
+
+// Atr is Wilder's average true range. The true range of a candle is its high less its low,
+// widened to reach the previous close across a gap. The first average is the simple average of
+// period true ranges and later ones use Wilder's smoothing.
+type Atr struct {
+	period    int
+	count     int
+	prevClose float64
+	sum       float64
+	value     float64
+	seq       sequence
+}
+
+func NewAtr(period int) (*Atr, error) {
+	if period <= 0 {
+		return nil, ErrInvalidPeriod
+	}
+	return &Atr{period: period}, nil
+}
+
+func (a *Atr) Update(candle adv.Candle) error {
+
+	_, high, low, close, _, err := candle.Ohlcv()
+	if err != nil {
+		return err
+	}
+
+	if err := a.seq.next(candle); err != nil {
+		return err
+	}
+
+	tr := high - low
+	if a.count > 0 {
+		tr = math.Max(tr, math.Max(math.Abs(high-a.prevClose), math.Abs(low-a.prevClose)))
+	}
+	a.prevClose = close
+
+	n := float64(a.period)
+	if a.count < a.period {
+		a.count++
+		a.sum += tr
+		if a.count == a.period {
+			a.value = a.sum / n
+		}
+		return nil
+	}
+
+	a.value = (a.value*(n-1) + tr) / n
+	return nil
+}
+
+func (a *Atr) Ready() bool {
+	return a.count == a.period
+}
+
+func (a *Atr) Value() float64 {
+	if !a.Ready() {
+		return math.NaN()
+	}
+	return a.value
+}
+
+// AtrSeries returns the average true range after each candle, oldest first.
+func AtrSeries(candles []adv.Candle, period int) ([]float64, error) {
+	a, err := NewAtr(period)
+	if err != nil {
+		return nil, err
+	}
+	return series(candles, a, a.Value)
+}
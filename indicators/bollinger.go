@@ -0,0 +1,90 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package indicators
+
+import (
+	"errors"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"math"
+)
+
+type BollingerValue struct {
+	Middle float64 `json:"middle"`
+	Upper  float64 `json:"upper"`
+	Lower  float64 `json:"lower"`
+}
+
+// Bollinger is the Bollinger Bands of closes: the simple moving average over period closes and
+// bands width population standard deviations above and below it. The usual settings are 20 and 2.
+type Bollinger struct {
+	window *window
+	width  float64
+	seq    sequence
+}
+
+func NewBollinger(period int, width float64) (*Bollinger, error) {
+
+	if period <= 0 {
+		return nil, ErrInvalidPeriod
+	}
+
+	if width <= 0 {
+		return nil, errors.New("band width must be positive")
+	}
+
+	return &Bollinger{window: newWindow(period), width: width}, nil
+}
+
+func (b *Bollinger) Update(candle adv.Candle) error {
+	close, err := closePrice(candle)
+	if err != nil {
+		return err
+	}
+	if err := b.seq.next(candle); err != nil {
+		return err
+	}
+	b.Add(close)
+	return nil
+}
+
+// Add updates the bands with a value from any series, such as one derived from closes.
+func (b *Bollinger) Add(value float64) {
+	b.window.push(value)
+}
+
+func (b *Bollinger) Ready() bool {
+	return b.window.full()
+}
+
+func (b *Bollinger) Value() BollingerValue {
+
+	if !b.Ready() {
+		return BollingerValue{Middle: math.NaN(), Upper: math.NaN(), Lower: math.NaN()}
+	}
+
+	middle, band := b.window.mean(), b.width*b.window.stddev()
+	return BollingerValue{Middle: middle, Upper: middle + band, Lower: middle - band}
+}
+
+// BollingerSeries returns the bands after each candle, oldest first.
+func BollingerSeries(candles []adv.Candle, period int, width float64) ([]BollingerValue, error) {
+	b, err := NewBollinger(period, width)
+	if err != nil {
+		return nil, err
+	}
+	return series(candles, b, b.Value)
+}
@@ -0,0 +1,194 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package indicators computes technical indicators over candle series. Each indicator is updated
+// with candles as they close, so strategies can keep one per product, and has a Series function
+// that runs it over a whole history such as GetProductCandles or a candle store query returns.
+package indicators
+
+import (
+	"errors"
+	"fmt"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidPeriod    = errors.New("indicator period must be positive")
+	ErrCandleOutOfOrder = errors.New("candle does not start after the previous candle")
+)
+
+// Indicator is updated with each candle as it closes, oldest first. Value methods return NaN
+// until Ready reports that enough candles have been seen.
+type Indicator interface {
+	Update(candle adv.Candle) error
+	Ready() bool
+}
+
+var (
+	_ Indicator = (*Sma)(nil)
+	_ Indicator = (*Ema)(nil)
+	_ Indicator = (*Rsi)(nil)
+	_ Indicator = (*Macd)(nil)
+	_ Indicator = (*Bollinger)(nil)
+	_ Indicator = (*Atr)(nil)
+	_ Indicator = (*Vwap)(nil)
+)
+
+// Chronological returns a copy of candles sorted oldest first, the order indicators consume them
+// in. The API returns candles newest first.
+func Chronological(candles []adv.Candle) ([]adv.Candle, error) {
+
+	starts := make(map[string]time.Time, len(candles))
+	for _, c := range candles {
+		start, err := c.StartTime()
+		if err != nil {
+			return nil, err
+		}
+		starts[c.Start] = start
+	}
+
+	sorted := make([]adv.Candle, len(candles))
+	copy(sorted, candles)
+	sort.SliceStable(sorted, func(i, j int) bool { return starts[sorted[i].Start].Before(starts[sorted[j].Start]) })
+
+	return sorted, nil
+}
+
+// Closes returns the closing prices of candles oldest first, for feeding price indicators with
+// Add or deriving other series from.
+func Closes(candles []adv.Candle) ([]float64, error) {
+
+	sorted, err := Chronological(candles)
+	if err != nil {
+		return nil, err
+	}
+
+	closes := make([]float64, len(sorted))
+	for i, c := range sorted {
+		if closes[i], err = closePrice(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return closes, nil
+}
+
+func closePrice(candle adv.Candle) (float64, error) {
+	v, err := strconv.ParseFloat(candle.Close, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid candle close %s: %w", candle.Close, err)
+	}
+	return v, nil
+}
+
+// sequence rejects candles that do not start after the previous candle, so that a candle is not
+// counted twice when a stream is replayed or polled.
+type sequence struct {
+	last time.Time
+}
+
+func (s *sequence) next(candle adv.Candle) error {
+
+	start, err := candle.StartTime()
+	if err != nil {
+		return err
+	}
+
+	if !s.last.IsZero() && !start.After(s.last) {
+		return fmt.Errorf("%w: %v", ErrCandleOutOfOrder, start)
+	}
+
+	s.last = start
+	return nil
+}
+
+// series runs an indicator over candles oldest first and collects its value after each candle.
+func series[T any](candles []adv.Candle, indicator Indicator, value func() T) ([]T, error) {
+
+	sorted, err := Chronological(candles)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]T, len(sorted))
+	for i, c := range sorted {
+		if err := indicator.Update(c); err != nil {
+			return nil, err
+		}
+		values[i] = value()
+	}
+
+	return values, nil
+}
+
+// window keeps the last values of a rolling indicator.
+type window struct {
+	values []float64
+	next   int
+	count  int
+}
+
+func newWindow(period int) *window {
+	return &window{values: make([]float64, period)}
+}
+
+func (w *window) push(v float64) {
+	w.values[w.next] = v
+	w.next = (w.next + 1) % len(w.values)
+	if w.count < len(w.values) {
+		w.count++
+	}
+}
+
+func (w *window) full() bool {
+	return w.count == len(w.values)
+}
+
+func (w *window) reset() {
+	w.next = 0
+	w.count = 0
+}
+
+// sum adds the values in the window. It is recomputed rather than kept as a running total so that
+// rounding errors do not accumulate over long streams.
+func (w *window) sum() float64 {
+	var sum float64
+	for _, v := range w.values[:w.count] {
+		sum += v
+	}
+	return sum
+}
+
+func (w *window) mean() float64 {
+	if w.count == 0 {
+		return math.NaN()
+	}
+	return w.sum() / float64(w.count)
+}
+
+// stddev is the population standard deviation of the window.
+func (w *window) stddev() float64 {
+	mean := w.mean()
+	var squares float64
+	for _, v := range w.values[:w.count] {
+		squares += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(squares / float64(w.count))
+}
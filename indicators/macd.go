@@ -0,0 +1,103 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package indicators
+
+import (
+	"errors"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"math"
+)
+
+type MacdValue struct {
+	Macd      float64 `json:"macd"`
+	Signal    float64 `json:"signal"`
+	Histogram float64 `json:"histogram"`
+}
+
+// Macd is the moving average convergence divergence of closes: the fast EMA less the slow EMA,
+// with a signal line that is an EMA of that difference starting once the slow EMA is ready. The
+// usual periods are 12, 26 and 9.
+type Macd struct {
+	fast   *Ema
+	slow   *Ema
+	signal *Ema
+	seq    sequence
+}
+
+func NewMacd(fast, slow, signal int) (*Macd, error) {
+
+	if fast <= 0 || slow <= 0 || signal <= 0 {
+		return nil, ErrInvalidPeriod
+	}
+
+	if fast >= slow {
+		return nil, errors.New("fast period must be shorter than slow period")
+	}
+
+	m := &Macd{}
+	m.fast, _ = NewEma(fast)
+	m.slow, _ = NewEma(slow)
+	m.signal, _ = NewEma(signal)
+	return m, nil
+}
+
+func (m *Macd) Update(candle adv.Candle) error {
+	close, err := closePrice(candle)
+	if err != nil {
+		return err
+	}
+	if err := m.seq.next(candle); err != nil {
+		return err
+	}
+	m.Add(close)
+	return nil
+}
+
+// Add updates the indicator with a value from any series, such as one derived from closes.
+func (m *Macd) Add(value float64) {
+	m.fast.Add(value)
+	m.slow.Add(value)
+	if m.slow.Ready() {
+		m.signal.Add(m.fast.Value() - m.slow.Value())
+	}
+}
+
+// Ready reports whether the signal line is ready. The MACD line is available earlier, once the
+// slow EMA is ready.
+func (m *Macd) Ready() bool {
+	return m.signal.Ready()
+}
+
+func (m *Macd) Value() MacdValue {
+
+	v := MacdValue{Macd: math.NaN(), Signal: m.signal.Value()}
+	if m.slow.Ready() {
+		v.Macd = m.fast.Value() - m.slow.Value()
+	}
+	v.Histogram = v.Macd - v.Signal
+
+	return v
+}
+
+// MacdSeries returns the MACD, signal and histogram after each candle, oldest first.
+func MacdSeries(candles []adv.Candle, fast, slow, signal int) ([]MacdValue, error) {
+	m, err := NewMacd(fast, slow, signal)
+	if err != nil {
+		return nil, err
+	}
+	return series(candles, m, m.Value)
+}
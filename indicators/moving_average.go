@@ -0,0 +1,137 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package indicators
+
+import (
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"math"
+)
+
+// Sma is the simple moving average of the last period closes.
+type Sma struct {
+	window *window
+	seq    sequence
+}
+
+func NewSma(period int) (*Sma, error) {
+	if period <= 0 {
+		return nil, ErrInvalidPeriod
+	}
+	return &Sma{window: newWindow(period)}, nil
+}
+
+func (s *Sma) Update(candle adv.Candle) error {
+	close, err := closePrice(candle)
+	if err != nil {
+		return err
+	}
+	if err := s.seq.next(candle); err != nil {
+		return err
+	}
+	s.Add(close)
+	return nil
+}
+
+// Add updates the average with a value from any series, such as one derived from closes.
+func (s *Sma) Add(value float64) {
+	s.window.push(value)
+}
+
+func (s *Sma) Ready() bool {
+	return s.window.full()
+}
+
+func (s *Sma) Value() float64 {
+	if !s.Ready() {
+		return math.NaN()
+	}
+	return s.window.mean()
+}
+
+// SmaSeries returns the simple moving average after each candle, oldest first.
+func SmaSeries(candles []adv.Candle, period int) ([]float64, error) {
+	s, err := NewSma(period)
+	if err != nil {
+		return nil, err
+	}
+	return series(candles, s, s.Value)
+}
+
+// Ema is the exponential moving average of closes with a smoothing factor of 2 / (period + 1). It
+// is seeded with the simple average of the first period closes.
+type Ema struct {
+	period int
+	alpha  float64
+	count  int
+	sum    float64
+	value  float64
+	seq    sequence
+}
+
+func NewEma(period int) (*Ema, error) {
+	if period <= 0 {
+		return nil, ErrInvalidPeriod
+	}
+	return &Ema{period: period, alpha: 2 / float64(period+1)}, nil
+}
+
+func (e *Ema) Update(candle adv.Candle) error {
+	close, err := closePrice(candle)
+	if err != nil {
+		return err
+	}
+	if err := e.seq.next(candle); err != nil {
+		return err
+	}
+	e.Add(close)
+	return nil
+}
+
+// Add updates the average with a value from any series, such as one derived from closes.
+func (e *Ema) Add(value float64) {
+
+	if e.count < e.period {
+		e.count++
+		e.sum += value
+		if e.count == e.period {
+			e.value = e.sum / float64(e.period)
+		}
+		return
+	}
+
+	e.value += e.alpha * (value - e.value)
+}
+
+func (e *Ema) Ready() bool {
+	return e.count == e.period
+}
+
+func (e *Ema) Value() float64 {
+	if !e.Ready() {
+		return math.NaN()
+	}
+	return e.value
+}
+
+// EmaSeries returns the exponential moving average after each candle, oldest first.
+func EmaSeries(candles []adv.Candle, period int) ([]float64, error) {
+	e, err := NewEma(period)
+	if err != nil {
+		return nil, err
+	}
+	return series(candles, e, e.Value)
+}
@@ -0,0 +1,107 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package indicators
+
+import (
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"math"
+)
+
+// Rsi is Wilder's relative strength index of closes. The first average gain and loss are simple
+// averages over period changes, so it is ready after period + 1 closes, and later ones use
+// Wilder's smoothing. It is 50 when prices have not moved at all.
+type Rsi struct {
+	period  int
+	count   int
+	hasPrev bool
+	prev    float64
+	gain    float64
+	loss    float64
+	seq     sequence
+}
+
+func NewRsi(period int) (*Rsi, error) {
+	if period <= 0 {
+		return nil, ErrInvalidPeriod
+	}
+	return &Rsi{period: period}, nil
+}
+
+func (r *Rsi) Update(candle adv.Candle) error {
+	close, err := closePrice(candle)
+	if err != nil {
+		return err
+	}
+	if err := r.seq.next(candle); err != nil {
+		return err
+	}
+	r.Add(close)
+	return nil
+}
+
+// Add updates the index with a value from any series, such as one derived from closes.
+func (r *Rsi) Add(value float64) {
+
+	if !r.hasPrev {
+		r.prev, r.hasPrev = value, true
+		return
+	}
+
+	change := value - r.prev
+	r.prev = value
+	gain, loss := math.Max(change, 0), math.Max(-change, 0)
+
+	n := float64(r.period)
+	if r.count < r.period {
+		r.count++
+		r.gain += gain
+		r.loss += loss
+		if r.count == r.period {
+			r.gain /= n
+			r.loss /= n
+		}
+		return
+	}
+
+	r.gain = (r.gain*(n-1) + gain) / n
+	r.loss = (r.loss*(n-1) + loss) / n
+}
+
+func (r *Rsi) Ready() bool {
+	return r.count == r.period
+}
+
+func (r *Rsi) Value() float64 {
+	switch {
+	case !r.Ready():
+		return math.NaN()
+	case r.loss == 0 && r.gain == 0:
+		return 50
+	case r.loss == 0:
+		return 100
+	}
+	return 100 - 100/(1+r.gain/r.loss)
+}
+
+// RsiSeries returns the relative strength index after each candle, oldest first.
+func RsiSeries(candles []adv.Candle, period int) ([]float64, error) {
+	r, err := NewRsi(period)
+	if err != nil {
+		return nil, err
+	}
+	return series(candles, r, r.Value)
+}
@@ -0,0 +1,143 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package indicators
+
+import (
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"math"
+	"time"
+)
+
+// Vwap is the volume weighted average of the typical price, (high + low + close) / 3, of each
+// candle. It covers a rolling number of candles, every candle since it was created, or every
+// candle since midnight in a session's time zone.
+type Vwap struct {
+	priceVolume *window
+	volume      *window
+	cumulative  struct{ priceVolume, volume float64 }
+	count       int
+	location    *time.Location
+	session     time.Time
+	seq         sequence
+}
+
+// NewVwap returns a VWAP over the last period candles, or over every candle when period is zero.
+func NewVwap(period int) (*Vwap, error) {
+
+	if period < 0 {
+		return nil, ErrInvalidPeriod
+	}
+
+	v := &Vwap{}
+	if period > 0 {
+		v.priceVolume, v.volume = newWindow(period), newWindow(period)
+	}
+
+	return v, nil
+}
+
+// NewSessionVwap returns a VWAP that restarts at midnight in location each day. Location defaults
+// to UTC.
+func NewSessionVwap(location *time.Location) *Vwap {
+	if location == nil {
+		location = time.UTC
+	}
+	return &Vwap{location: location}
+}
+
+func (v *Vwap) Update(candle adv.Candle) error {
+
+	_, high, low, close, volume, err := candle.Ohlcv()
+	if err != nil {
+		return err
+	}
+
+	if err := v.seq.next(candle); err != nil {
+		return err
+	}
+
+	if v.location != nil {
+		local := v.seq.last.In(v.location)
+		session := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, v.location)
+		if !session.Equal(v.session) {
+			v.Reset()
+			v.session = session
+		}
+	}
+
+	typical := (high + low + close) / 3
+	v.count++
+
+	if v.volume != nil {
+		v.priceVolume.push(typical * volume)
+		v.volume.push(volume)
+		return nil
+	}
+
+	v.cumulative.priceVolume += typical * volume
+	v.cumulative.volume += volume
+	return nil
+}
+
+// Reset starts the average again from the next candle, for sessions the VWAP cannot tell apart
+// by date.
+func (v *Vwap) Reset() {
+	v.count = 0
+	v.cumulative.priceVolume, v.cumulative.volume = 0, 0
+	if v.volume != nil {
+		v.priceVolume.reset()
+		v.volume.reset()
+	}
+}
+
+// Ready reports whether the rolling window is full, or whether a cumulative or session VWAP has
+// seen a candle with volume.
+func (v *Vwap) Ready() bool {
+	if v.volume != nil {
+		return v.volume.full() && v.volume.sum() > 0
+	}
+	return v.count > 0 && v.cumulative.volume > 0
+}
+
+func (v *Vwap) Value() float64 {
+
+	if !v.Ready() {
+		return math.NaN()
+	}
+
+	if v.volume != nil {
+		return v.priceVolume.sum() / v.volume.sum()
+	}
+
+	return v.cumulative.priceVolume / v.cumulative.volume
+}
+
+// VwapSeries returns the VWAP over the last period candles, or every candle when period is zero,
+// after each candle, oldest first.
+func VwapSeries(candles []adv.Candle, period int) ([]float64, error) {
+	v, err := NewVwap(period)
+	if err != nil {
+		return nil, err
+	}
+	return series(candles, v, v.Value)
+}
+
+// SessionVwapSeries returns the VWAP since midnight in location after each candle, oldest first.
+func SessionVwapSeries(candles []adv.Candle, location *time.Location) ([]float64, error) {
+	v := NewSessionVwap(location)
+	return series(candles, v, v.Value)
+}
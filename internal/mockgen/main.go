@@ -0,0 +1,236 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command mockgen generates the advmock package from the service interfaces. Every interface with
+// methods becomes a Mock<Interface> struct with a <Method>Func field per method and a record of
+// the requests it received; interfaces that only embed others become a struct embedding the
+// corresponding mocks.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"log"
+	"os"
+	"strings"
+)
+
+const (
+	sdkImportPath = "github.com/coinbase-samples/advanced-trade-sdk-go"
+	sdkPackage    = "adv"
+)
+
+type method struct {
+	name     string
+	params   string
+	args     string
+	request  string
+	results  string
+	response string
+}
+
+type service struct {
+	name     string
+	methods  []*method
+	embedded []string
+}
+
+func main() {
+
+	source := flag.String("source", "services.go", "file declaring the service interfaces")
+	output := flag.String("output", "advmock/mocks.go", "file to write the mocks to")
+	pkg := flag.String("package", "advmock", "package name of the generated file")
+	flag.Parse()
+
+	services, err := parseServices(*source)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	code, err := generate(*pkg, services)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile(*output, code, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func parseServices(path string) ([]*service, error) {
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []*service
+
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			iface, ok := ts.Type.(*ast.InterfaceType)
+			if !ok {
+				continue
+			}
+
+			s := &service{name: ts.Name.Name}
+
+			for _, field := range iface.Methods.List {
+				ft, ok := field.Type.(*ast.FuncType)
+				if !ok {
+					s.embedded = append(s.embedded, render(fset, field.Type))
+					continue
+				}
+
+				m, err := parseMethod(fset, field.Names[0].Name, ft)
+				if err != nil {
+					return nil, err
+				}
+				s.methods = append(s.methods, m)
+			}
+
+			services = append(services, s)
+		}
+	}
+
+	return services, nil
+}
+
+func parseMethod(fset *token.FileSet, name string, ft *ast.FuncType) (*method, error) {
+
+	if len(ft.Params.List) != 2 || ft.Results == nil || len(ft.Results.List) != 2 {
+		return nil, fmt.Errorf("%s: expected (ctx, request) (response, error)", name)
+	}
+
+	m := &method{name: name}
+
+	var params, args []string
+	for _, p := range ft.Params.List {
+		for _, n := range p.Names {
+			params = append(params, fmt.Sprintf("%s %s", n.Name, qualify(fset, p.Type)))
+			args = append(args, n.Name)
+		}
+	}
+
+	var results []string
+	for _, r := range ft.Results.List {
+		results = append(results, qualify(fset, r.Type))
+	}
+
+	m.params = strings.Join(params, ", ")
+	m.args = strings.Join(args, ", ")
+	m.request = qualify(fset, ft.Params.List[1].Type)
+	m.results = strings.Join(results, ", ")
+	m.response = results[0]
+
+	return m, nil
+}
+
+func render(fset *token.FileSet, expr ast.Expr) string {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, fset, expr)
+	return buf.String()
+}
+
+// qualify renders a type from the SDK package as seen from the generated package.
+func qualify(fset *token.FileSet, expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return "*" + qualify(fset, t.X)
+	case *ast.Ident:
+		if ast.IsExported(t.Name) {
+			return sdkPackage + "." + t.Name
+		}
+	}
+	return render(fset, expr)
+}
+
+func generate(pkg string, services []*service) ([]byte, error) {
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "// Code generated by internal/mockgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	fmt.Fprintf(&b, "import (\n\"context\"\n\"fmt\"\n%s %q\n\"sync\"\n)\n\n", sdkPackage, sdkImportPath)
+
+	for _, s := range services {
+		mock := "Mock" + s.name
+
+		if len(s.methods) == 0 {
+			fmt.Fprintf(&b, "// %s is a mock of %s.%s composed of the mocks of each domain.\n", mock, sdkPackage, s.name)
+			fmt.Fprintf(&b, "type %s struct {\n", mock)
+			for _, e := range s.embedded {
+				fmt.Fprintf(&b, "*Mock%s\n", e)
+			}
+			fmt.Fprintf(&b, "}\n\n")
+
+			fmt.Fprintf(&b, "func New%s() *%s {\nreturn &%s{\n", mock, mock, mock)
+			for _, e := range s.embedded {
+				fmt.Fprintf(&b, "Mock%s: &Mock%s{},\n", e, e)
+			}
+			fmt.Fprintf(&b, "}\n}\n\n")
+
+			fmt.Fprintf(&b, "var _ %s.%s = New%s()\n\n", sdkPackage, s.name, mock)
+			continue
+		}
+
+		fmt.Fprintf(&b, "// %s is a mock of %s.%s. Set the Func field of each method a test expects\n", mock, sdkPackage, s.name)
+		fmt.Fprintf(&b, "// to be called; calling a method without one returns ErrNotStubbed.\n")
+		fmt.Fprintf(&b, "type %s struct {\n", mock)
+		for _, m := range s.methods {
+			fmt.Fprintf(&b, "%sFunc func(%s) (%s)\n", m.name, m.params, m.results)
+		}
+		fmt.Fprintf(&b, "\nmu sync.Mutex\n")
+		for _, m := range s.methods {
+			fmt.Fprintf(&b, "%sCalls []%s\n", lowerFirst(m.name), m.request)
+		}
+		fmt.Fprintf(&b, "}\n\n")
+
+		for _, m := range s.methods {
+			calls := lowerFirst(m.name) + "Calls"
+
+			fmt.Fprintf(&b, "func (m *%s) %s(%s) (%s) {\n", mock, m.name, m.params, m.results)
+			fmt.Fprintf(&b, "m.mu.Lock()\nm.%s = append(m.%s, request)\nfn := m.%sFunc\nm.mu.Unlock()\n\n", calls, calls, m.name)
+			fmt.Fprintf(&b, "if fn == nil {\nreturn nil, fmt.Errorf(\"%%w: %s.%s\", ErrNotStubbed)\n}\n\n", s.name, m.name)
+			fmt.Fprintf(&b, "return fn(%s)\n}\n\n", m.args)
+
+			fmt.Fprintf(&b, "// %sCalls returns the requests passed to %s, oldest first.\n", m.name, m.name)
+			fmt.Fprintf(&b, "func (m *%s) %sCalls() []%s {\n", mock, m.name, m.request)
+			fmt.Fprintf(&b, "m.mu.Lock()\ndefer m.mu.Unlock()\nreturn append([]%s(nil), m.%s...)\n}\n\n", m.request, calls)
+		}
+
+		fmt.Fprintf(&b, "var _ %s.%s = &%s{}\n\n", sdkPackage, s.name, mock)
+	}
+
+	return format.Source(b.Bytes())
+}
+
+func lowerFirst(s string) string {
+	return strings.ToLower(s[:1]) + s[1:]
+}
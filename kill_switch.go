@@ -0,0 +1,224 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+const maxCancelOrderIds = 100
+
+var (
+	ErrKillSwitchEngaged     = errors.New("kill switch engaged")
+	ErrKillSwitchUnavailable = errors.New("kill switch requires a client created with NewClient")
+)
+
+type KillSwitchError struct {
+	Operation string
+	Reason    string
+	EngagedAt time.Time
+}
+
+func (e *KillSwitchError) Error() string {
+	if len(e.Reason) > 0 {
+		return fmt.Sprintf("%s rejected: kill switch engaged at %s - reason: %s", e.Operation, e.EngagedAt.Format(time.RFC3339), e.Reason)
+	}
+	return fmt.Sprintf("%s rejected: kill switch engaged at %s", e.Operation, e.EngagedAt.Format(time.RFC3339))
+}
+
+func (e *KillSwitchError) Unwrap() error {
+	return ErrKillSwitchEngaged
+}
+
+type killSwitch struct {
+	mu        sync.RWMutex
+	engaged   bool
+	reason    string
+	engagedAt time.Time
+}
+
+type EngageKillSwitchRequest struct {
+	Reason           string `json:"reason"`
+	CancelOpenOrders bool   `json:"cancel_open_orders"`
+
+	// ClosePositions closes every CFM futures position. INTX perpetuals positions are not closed
+	// and must be flattened separately.
+	ClosePositions bool `json:"close_positions"`
+}
+
+type EngageKillSwitchResponse struct {
+	EngagedAt       time.Time                `json:"engaged_at"`
+	CancelResults   []*CancelResult          `json:"cancel_results"`
+	ClosedPositions []*ClosePositionResponse `json:"closed_positions"`
+	Errors          []error                  `json:"-"`
+	Request         *EngageKillSwitchRequest `json:"request"`
+}
+
+// EngageKillSwitch blocks every order-mutating call made through this client, and any copy of it,
+// until ReleaseKillSwitch is called. Read endpoints and CancelOrders are unaffected. When requested,
+// open orders are canceled and CFM futures positions are closed after the switch is engaged. Only
+// clients created with NewClient carry a switch; others return ErrKillSwitchUnavailable.
+func (c Client) EngageKillSwitch(
+	ctx context.Context,
+	request *EngageKillSwitchRequest,
+) (*EngageKillSwitchResponse, error) {
+
+	if request == nil {
+		request = &EngageKillSwitchRequest{}
+	}
+
+	ks := c.killSwitch
+	if ks == nil {
+		return nil, ErrKillSwitchUnavailable
+	}
+
+	ks.mu.Lock()
+	if !ks.engaged {
+		ks.engaged = true
+		ks.engagedAt = time.Now()
+	}
+	ks.reason = request.Reason
+	engagedAt := ks.engagedAt
+	ks.mu.Unlock()
+
+	response := &EngageKillSwitchResponse{EngagedAt: engagedAt, Request: request}
+
+	if request.CancelOpenOrders {
+		results, err := c.cancelAllOpenOrders(ctx)
+		response.CancelResults = results
+		if err != nil {
+			response.Errors = append(response.Errors, err)
+		}
+	}
+
+	if request.ClosePositions {
+		closed, errs := c.closeAllFuturesPositions(ctx)
+		response.ClosedPositions = closed
+		response.Errors = append(response.Errors, errs...)
+	}
+
+	if len(response.Errors) > 0 {
+		return response, fmt.Errorf("kill switch engaged but flatten incomplete: %w", errors.Join(response.Errors...))
+	}
+
+	return response, nil
+}
+
+func (c Client) ReleaseKillSwitch() {
+	ks := c.killSwitch
+	if ks == nil {
+		return
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.engaged = false
+	ks.reason = ""
+	ks.engagedAt = time.Time{}
+}
+
+func (c Client) KillSwitchEngaged() bool {
+	ks := c.killSwitch
+	if ks == nil {
+		return false
+	}
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return ks.engaged
+}
+
+func (c Client) checkKillSwitch(operation string) error {
+	ks := c.killSwitch
+	if ks == nil {
+		return nil
+	}
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if !ks.engaged {
+		return nil
+	}
+
+	return &KillSwitchError{
+		Operation: operation,
+		Reason:    ks.reason,
+		EngagedAt: ks.engagedAt,
+	}
+}
+
+func (c Client) cancelAllOpenOrders(ctx context.Context) ([]*CancelResult, error) {
+
+	orders, err := listAllOrders(ctx, c, &ListOrdersRequest{OrderStatus: []string{"OPEN"}})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list open orders: %w", err)
+	}
+
+	var orderIds []string
+	for _, o := range orders {
+		orderIds = append(orderIds, o.OrderId)
+	}
+
+	var results []*CancelResult
+	for start := 0; start < len(orderIds); start += maxCancelOrderIds {
+		end := start + maxCancelOrderIds
+		if end > len(orderIds) {
+			end = len(orderIds)
+		}
+
+		response, err := c.CancelOrders(ctx, &CancelOrdersRequest{OrderIds: orderIds[start:end]})
+		if err != nil {
+			return results, fmt.Errorf("unable to cancel open orders: %w", err)
+		}
+		results = append(results, response.Results...)
+	}
+
+	return results, nil
+}
+
+func (c Client) closeAllFuturesPositions(ctx context.Context) ([]*ClosePositionResponse, []error) {
+
+	positions, err := c.ListFuturesPositions(ctx, &ListFuturesPositionsRequest{})
+	if err != nil {
+		return nil, []error{fmt.Errorf("unable to list futures positions: %w", err)}
+	}
+
+	var closed []*ClosePositionResponse
+	var errs []error
+	for _, p := range positions.FuturesPositions {
+		response, err := c.closePosition(ctx, &ClosePositionRequest{
+			ClientOrderId: uuid.New().String(),
+			ProductId:     p.ProductId,
+			Size:          p.NumberOfContracts,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to close position %s: %w", p.ProductId, err))
+			continue
+		}
+		closed = append(closed, response)
+	}
+
+	return closed, errs
+}
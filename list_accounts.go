@@ -25,6 +25,8 @@ type ListAccountsRequest struct {
 
 type ListAccountsResponse struct {
 	Accounts   []*Account           `json:"accounts"`
+	HasNext    bool                 `json:"has_next"`
+	Cursor     string               `json:"cursor"`
 	Request    *ListAccountsRequest `json:"request"`
 	Pagination *Pagination
 }
@@ -40,6 +42,10 @@ func (c Client) ListAccounts(
 
 	queryParams = appendPaginationParams(queryParams, request.Pagination)
 
+	if len(request.RetailPortfolioId) > 0 {
+		queryParams = appendQueryParam(queryParams, "retail_portfolio_id", request.RetailPortfolioId)
+	}
+
 	response := &ListAccountsResponse{Request: request}
 
 	if err := get(ctx, c, path, queryParams, request, response); err != nil {
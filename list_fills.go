@@ -27,6 +27,7 @@ type ListFillsRequest struct {
 	EndSequenceTimestamp   string `json:"end_sequence_timestamp,omitempty"`
 	Limit                  string `json:"limit,omitempty"`
 	Cursor                 string `json:"cursor,omitempty"`
+	RetailPortfolioId      string `json:"retail_portfolio_id,omitempty"`
 }
 
 type ListFillsResponse struct {
@@ -60,6 +61,12 @@ func (c Client) ListFills(
 	if request.Limit != "" {
 		queryParams = appendQueryParam(queryParams, "limit", request.Limit)
 	}
+	if request.Cursor != "" {
+		queryParams = appendQueryParam(queryParams, "cursor", request.Cursor)
+	}
+	if request.RetailPortfolioId != "" {
+		queryParams = appendQueryParam(queryParams, "retail_portfolio_id", request.RetailPortfolioId)
+	}
 
 	response := &ListFillsResponse{Request: request}
 
@@ -24,7 +24,7 @@ import (
 type ListFuturesSweepsRequest struct{}
 
 type ListFuturesSweepsResponse struct {
-	Sweeps  *Sweep                    `json:"sweeps"`
+	Sweeps  []*Sweep                  `json:"sweeps"`
 	Request *ListFuturesSweepsRequest `json:"request"`
 }
 
@@ -18,6 +18,8 @@ package adv
 
 import "context"
 
+const maxOrdersPageSize = "1000"
+
 type ListOrdersRequest struct {
 	ProductId            string            `json:"product_id,omitempty"`
 	OrderStatus          []string          `json:"order_status,omitempty"`
@@ -36,6 +38,8 @@ type ListOrdersRequest struct {
 type ListOrdersResponse struct {
 	Orders     []*Order `json:"orders"`
 	Sequence   string   `json:"sequence"`
+	HasNext    bool     `json:"has_next"`
+	Cursor     string   `json:"cursor"`
 	Pagination *Pagination
 	Request    *ListOrdersRequest `json:"request"`
 }
@@ -83,6 +87,8 @@ func (c Client) ListOrders(
 		queryParams = appendQueryParam(queryParams, "retail_portfolio_id", request.RetailPortfolioId)
 	}
 
+	queryParams = appendPaginationParams(queryParams, request.Pagination)
+
 	response := &ListOrdersResponse{Request: request}
 
 	if err := get(ctx, c, path, queryParams, request, response); err != nil {
@@ -91,3 +97,26 @@ func (c Client) ListOrders(
 
 	return response, nil
 }
+
+// listAllOrders follows the cursor through every page of orders matching request.
+func listAllOrders(ctx context.Context, orders OrdersService, request *ListOrdersRequest) ([]*Order, error) {
+
+	var all []*Order
+	var cursor string
+	for {
+		page := *request
+		page.Pagination = &PaginationParams{Cursor: cursor, Limit: maxOrdersPageSize}
+
+		response, err := orders.ListOrders(ctx, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, response.Orders...)
+
+		if !response.HasNext || len(response.Cursor) == 0 || response.Cursor == cursor {
+			return all, nil
+		}
+		cursor = response.Cursor
+	}
+}
@@ -0,0 +1,182 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
+
+var candleColumns = []string{
+	"start",
+	"low",
+	"high",
+	"open",
+	"close",
+	"volume",
+}
+
+var tradeColumns = []string{
+	"trade_id",
+	"product_id",
+	"price",
+	"size",
+	"time",
+	"side",
+	"bid",
+	"ask",
+}
+
+// CandleWriter streams candles to w as CSV, with a header row, or as JSON lines in the API's field
+// names. Files written this way can be replayed with ReadCandles.
+type CandleWriter struct {
+	csv  *csv.Writer
+	json *json.Encoder
+}
+
+func NewCandleWriter(w io.Writer, format string) (*CandleWriter, error) {
+
+	switch format {
+	case ExportFormatCsv:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(candleColumns); err != nil {
+			return nil, err
+		}
+		return &CandleWriter{csv: cw}, nil
+	case ExportFormatJsonl:
+		return &CandleWriter{json: json.NewEncoder(w)}, nil
+	}
+
+	return nil, fmt.Errorf("unknown export format: %s", format)
+}
+
+func (w *CandleWriter) Write(c Candle) error {
+	if w.json != nil {
+		return w.json.Encode(c)
+	}
+	return w.csv.Write([]string{c.Start, c.Low, c.High, c.Open, c.Close, c.Volume})
+}
+
+// Flush writes any buffered CSV rows to the underlying writer.
+func (w *CandleWriter) Flush() error {
+	if w.csv == nil {
+		return nil
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func WriteCandles(w io.Writer, format string, candles []Candle) error {
+
+	cw, err := NewCandleWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range candles {
+		if err := cw.Write(c); err != nil {
+			return err
+		}
+	}
+
+	return cw.Flush()
+}
+
+func ReadCandles(r io.Reader, format string) ([]Candle, error) {
+	return readRecords(r, format, candleColumns, func(row []string) (Candle, error) {
+		return Candle{Start: row[0], Low: row[1], High: row[2], Open: row[3], Close: row[4], Volume: row[5]}, nil
+	})
+}
+
+func WriteTrades(w io.Writer, format string, trades []Trade) error {
+
+	rows := make([][]string, len(trades))
+	for i, t := range trades {
+		rows[i] = []string{t.TradeId, t.ProductId, t.Price, t.Size, formatTimestamp(t.Time), t.Side, t.Bid, t.Ask}
+	}
+
+	return writeRecords(w, format, tradeColumns, rows, trades)
+}
+
+func ReadTrades(r io.Reader, format string) ([]Trade, error) {
+	return readRecords(r, format, tradeColumns, func(row []string) (Trade, error) {
+		t, err := time.Parse(time.RFC3339Nano, row[4])
+		if err != nil {
+			return Trade{}, fmt.Errorf("invalid trade time %s: %w", row[4], err)
+		}
+		return Trade{TradeId: row[0], ProductId: row[1], Price: row[2], Size: row[3], Time: t, Side: row[5], Bid: row[6], Ask: row[7]}, nil
+	})
+}
+
+func readRecords[T any](r io.Reader, format string, columns []string, parse func(row []string) (T, error)) ([]T, error) {
+
+	var records []T
+
+	switch format {
+	case ExportFormatCsv:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = len(columns)
+
+		header, err := cr.Read()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Equal(header, columns) {
+			return nil, fmt.Errorf("unexpected columns: %v", header)
+		}
+
+		for {
+			row, err := cr.Read()
+			if err == io.EOF {
+				return records, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			record, err := parse(row)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, record)
+		}
+
+	case ExportFormatJsonl:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			var record T
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				return nil, err
+			}
+			records = append(records, record)
+		}
+		return records, scanner.Err()
+	}
+
+	return nil, fmt.Errorf("unknown export format: %s", format)
+}
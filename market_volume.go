@@ -0,0 +1,221 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	marketTradesLimit   = 1000
+	maxCandlesPerWindow = 300
+)
+
+// marketVolumeTracker accumulates traded volume from GetMarketTrades between polls, skipping
+// trades that were already counted.
+type marketVolumeTracker struct {
+	products  ProductsService
+	productId string
+	since     time.Time
+	seen      map[string]time.Time
+}
+
+func newMarketVolumeTracker(products ProductsService, productId string, since time.Time) *marketVolumeTracker {
+	return &marketVolumeTracker{
+		products:  products,
+		productId: productId,
+		since:     since,
+		seen:      make(map[string]time.Time),
+	}
+}
+
+// poll returns the volume traded since the previous poll. A full page of trades may leave older
+// trades in the window, so pages are fetched backwards from the oldest trade returned until the
+// window is exhausted.
+func (t *marketVolumeTracker) poll(ctx context.Context) (float64, error) {
+
+	var volume float64
+	latest := t.since
+	end := time.Now().Add(time.Second)
+
+	for {
+		response, err := t.products.GetMarketTrades(ctx, &GetMarketTradesRequest{
+			ProductId: t.productId,
+			Limit:     strconv.Itoa(marketTradesLimit),
+			Start:     unixTimestamp(t.since),
+			End:       unixTimestamp(end),
+		})
+		if err != nil {
+			return 0, fmt.Errorf("unable to get market trades: %w", err)
+		}
+
+		var added int
+		oldest := end
+		for _, trade := range response.Trades {
+			if trade.Time.Before(t.since) {
+				continue
+			}
+
+			if trade.Time.Before(oldest) {
+				oldest = trade.Time
+			}
+
+			if _, ok := t.seen[trade.TradeId]; ok {
+				continue
+			}
+
+			size, err := parseFloat(trade.Size)
+			if err != nil {
+				return 0, fmt.Errorf("invalid trade size %s: %w", trade.Size, err)
+			}
+
+			volume += size
+			added++
+			t.seen[trade.TradeId] = trade.Time
+			if trade.Time.After(latest) {
+				latest = trade.Time
+			}
+		}
+
+		// A page without new trades cannot move the window back any further.
+		if len(response.Trades) < marketTradesLimit || added == 0 {
+			break
+		}
+		end = oldest
+	}
+
+	// The API filters on whole seconds, so only trades in the latest second can be returned again.
+	t.since = latest.Truncate(time.Second)
+	for id, tm := range t.seen {
+		if tm.Before(t.since) {
+			delete(t.seen, id)
+		}
+	}
+
+	return volume, nil
+}
+
+// volumeProfile holds the expected share of volume traded in each bucket of an execution window,
+// derived from the same time of day over previous days.
+type volumeProfile struct {
+	start      time.Time
+	bucket     time.Duration
+	cumulative []float64
+}
+
+func buildVolumeProfile(
+	ctx context.Context,
+	products ProductsService,
+	productId,
+	granularity string,
+	days int,
+	start time.Time,
+	duration time.Duration,
+) (*volumeProfile, error) {
+
+	bucket, err := GranularityDuration(granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	if days <= 0 {
+		days = 1
+	}
+
+	byTimeOfDay := make(map[time.Duration]float64)
+
+	_, err = BackfillCandles(ctx, products, &CandleBackfillRequest{
+		ProductId:   productId,
+		Granularity: granularity,
+		Start:       start.Add(-time.Duration(days) * 24 * time.Hour),
+		End:         start,
+		Stream: func(candle Candle) error {
+			t, err := candle.StartTime()
+			if err != nil {
+				return err
+			}
+			volume, err := parseFloat(candle.Volume)
+			if err != nil {
+				return fmt.Errorf("invalid candle volume %s: %w", candle.Volume, err)
+			}
+			byTimeOfDay[timeOfDay(t, bucket)] += volume
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get candles: %w", err)
+	}
+
+	buckets := int(math.Ceil(float64(duration) / float64(bucket)))
+	if buckets < 1 {
+		buckets = 1
+	}
+
+	weights := make([]float64, buckets)
+	var total float64
+	for i := range weights {
+		weights[i] = byTimeOfDay[timeOfDay(start.Add(time.Duration(i)*bucket), bucket)]
+		total += weights[i]
+	}
+
+	profile := &volumeProfile{start: start, bucket: bucket, cumulative: make([]float64, buckets)}
+
+	var sum float64
+	for i, w := range weights {
+		if total > 0 {
+			sum += w / total
+		} else {
+			sum += 1 / float64(buckets)
+		}
+		profile.cumulative[i] = sum
+	}
+
+	return profile, nil
+}
+
+// fraction returns the expected share of the window's volume traded by t, interpolating linearly
+// within a bucket.
+func (p *volumeProfile) fraction(t time.Time) float64 {
+
+	elapsed := t.Sub(p.start)
+	if elapsed <= 0 {
+		return 0
+	}
+
+	i := int(elapsed / p.bucket)
+	if i >= len(p.cumulative) {
+		return 1
+	}
+
+	var previous float64
+	if i > 0 {
+		previous = p.cumulative[i-1]
+	}
+
+	within := float64(elapsed%p.bucket) / float64(p.bucket)
+	return previous + (p.cumulative[i]-previous)*within
+}
+
+func timeOfDay(t time.Time, bucket time.Duration) time.Duration {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return t.Sub(midnight).Truncate(bucket)
+}
@@ -226,6 +226,7 @@ type Order struct {
 	IsLiquidation         bool               `json:"is_liquidation"`
 	LastFillTime          string             `json:"last_fill_time"`
 	EditHistory           []EditHistoryItem  `json:"edit_history"`
+	RetailPortfolioId     string             `json:"retail_portfolio_id"`
 }
 
 type OrderConfiguration struct {
@@ -355,6 +356,7 @@ type Fill struct {
 	SizeInQuote        bool      `json:"size_in_quote"`
 	UserId             string    `json:"user_id"`
 	Side               string    `json:"side"`
+	RetailPortfolioId  string    `json:"retail_portfolio_id"`
 }
 
 type BreakdownResponse struct {
@@ -38,6 +38,10 @@ func (c Client) MovePortfolioFunds(
 	request *MovePortfolioFundsRequest,
 ) (*MovePortfolioFundsResponse, error) {
 
+	if err := c.checkKillSwitch("MovePortfolioFunds"); err != nil {
+		return nil, err
+	}
+
 	path := fmt.Sprint("/brokerage/portfolios/move_funds")
 
 	response := &MovePortfolioFundsResponse{Request: request}
@@ -0,0 +1,282 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+type OcoRequest struct {
+	Legs         [2]*CreateOrderRequest `json:"legs"`
+	PollInterval time.Duration          `json:"poll_interval"`
+}
+
+type OcoLeg struct {
+	Request    *CreateOrderRequest `json:"request"`
+	OrderId    string              `json:"order_id"`
+	Status     string              `json:"status"`
+	FilledSize float64             `json:"filled_size"`
+	Canceled   bool                `json:"canceled"`
+	Order      *Order              `json:"order,omitempty"`
+}
+
+type OcoResult struct {
+	Legs [2]*OcoLeg `json:"legs"`
+
+	// Triggered is the index of the leg whose fill canceled its sibling, or -1 when the group ended
+	// without a fill.
+	Triggered int `json:"triggered"`
+
+	// BothFilled is set when the sibling filled before it could be canceled.
+	BothFilled bool `json:"both_filled"`
+}
+
+// OcoGroup emulates a one-cancels-other pair of orders. Both legs are placed with CreateOrder and
+// polled; as soon as either fills, even partially, the other is canceled. If either leg ends
+// without a fill or the context is done, the remaining leg is canceled as well so that an
+// unsupervised pair is never left on the book.
+type OcoGroup struct {
+	client  Service
+	request *OcoRequest
+
+	mu     sync.Mutex
+	result OcoResult
+}
+
+func NewOcoGroup(client Service, request *OcoRequest) *OcoGroup {
+	return &OcoGroup{
+		client:  client,
+		request: request,
+		result:  OcoResult{Triggered: -1},
+	}
+}
+
+func (g *OcoGroup) Result() OcoResult {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	r := g.result
+	for i, leg := range g.result.Legs {
+		if leg != nil {
+			l := *leg
+			r.Legs[i] = &l
+		}
+	}
+	return r
+}
+
+func (g *OcoGroup) Run(ctx context.Context) (OcoResult, error) {
+
+	for i, leg := range g.request.Legs {
+		if leg == nil {
+			return OcoResult{}, fmt.Errorf("oco leg %d not set", i)
+		}
+	}
+
+	err := g.run(ctx)
+	return g.Result(), err
+}
+
+func (g *OcoGroup) run(ctx context.Context) error {
+
+	for i, request := range g.request.Legs {
+		if len(request.ClientOrderId) == 0 {
+			request.ClientOrderId = uuid.New().String()
+		}
+
+		orderId, err := g.place(ctx, request)
+		if err != nil {
+			err = fmt.Errorf("unable to place oco leg %d: %w", i, err)
+			if i > 0 {
+				return errors.Join(err, g.cancelLegs(0))
+			}
+			return err
+		}
+
+		g.mu.Lock()
+		g.result.Legs[i] = &OcoLeg{Request: request, OrderId: orderId, Status: "OPEN"}
+		g.mu.Unlock()
+
+		if err := ctx.Err(); err != nil {
+			return errors.Join(err, g.cancelLegs(0, 1))
+		}
+	}
+
+	pollInterval := g.request.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultExecutionPollInterval
+	}
+
+	for {
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(ctx.Err(), g.cancelLegs(0, 1))
+		case <-timer.C:
+		}
+
+		done, err := g.poll(ctx)
+		if err != nil {
+			return errors.Join(err, g.cancelLegs(0, 1))
+		}
+
+		if done {
+			return nil
+		}
+	}
+}
+
+// place creates one leg. A leg accepted after ctx is done would rest unsupervised, so the request
+// is not canceled with ctx; the caller checks ctx once the order id is known.
+func (g *OcoGroup) place(ctx context.Context, request *CreateOrderRequest) (string, error) {
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelChildOrderTimeout)
+	defer cancel()
+
+	response, err := g.client.CreateOrder(ctx, request)
+	if err != nil {
+		return "", err
+	}
+
+	if !response.Success {
+		return "", fmt.Errorf("order rejected: %s", createOrderFailure(response))
+	}
+
+	if len(response.OrderId) == 0 && response.SuccessResponse != nil {
+		return response.SuccessResponse.OrderId, nil
+	}
+
+	return response.OrderId, nil
+}
+
+// poll refreshes both legs and reports whether the group has finished.
+func (g *OcoGroup) poll(ctx context.Context) (bool, error) {
+
+	for i := range g.request.Legs {
+		if err := g.refresh(ctx, i); err != nil {
+			return false, err
+		}
+	}
+
+	g.mu.Lock()
+	legs := g.result.Legs
+	triggered := -1
+	for i, leg := range legs {
+		if leg.FilledSize > 0 {
+			triggered = i
+			break
+		}
+	}
+
+	ended := isTerminalOrderStatus(legs[0].Status) || isTerminalOrderStatus(legs[1].Status)
+	g.mu.Unlock()
+
+	if triggered < 0 && !ended {
+		return false, nil
+	}
+
+	var errs []error
+	if triggered < 0 {
+		errs = append(errs, g.cancelLegs(0, 1))
+	} else {
+		errs = append(errs, g.cancelLegs(1-triggered))
+	}
+
+	// Refresh after canceling so that fills that raced with the cancel are reported.
+	for i := range legs {
+		if err := g.refresh(context.Background(), i); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	g.mu.Lock()
+	g.result.Triggered = triggered
+	g.result.BothFilled = legs[0].FilledSize > 0 && legs[1].FilledSize > 0
+	g.mu.Unlock()
+
+	return true, errors.Join(errs...)
+}
+
+func (g *OcoGroup) refresh(ctx context.Context, i int) error {
+
+	g.mu.Lock()
+	orderId := g.result.Legs[i].OrderId
+	g.mu.Unlock()
+
+	response, err := g.client.GetOrder(ctx, &GetOrderRequest{OrderId: orderId})
+	if err != nil {
+		return fmt.Errorf("unable to get oco leg %d: %w", i, err)
+	}
+
+	filled, err := parseFloat(response.Order.FilledSize)
+	if err != nil {
+		return fmt.Errorf("invalid filled size %s: %w", response.Order.FilledSize, err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	leg := g.result.Legs[i]
+	leg.Order = response.Order
+	leg.Status = response.Order.Status
+	leg.FilledSize = filled
+
+	return nil
+}
+
+func (g *OcoGroup) cancelLegs(legs ...int) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), cancelChildOrderTimeout)
+	defer cancel()
+
+	var orderIds []string
+	g.mu.Lock()
+	for _, i := range legs {
+		if leg := g.result.Legs[i]; leg != nil && !isTerminalOrderStatus(leg.Status) {
+			orderIds = append(orderIds, leg.OrderId)
+		}
+	}
+	g.mu.Unlock()
+
+	if len(orderIds) == 0 {
+		return nil
+	}
+
+	response, err := g.client.CancelOrders(ctx, &CancelOrdersRequest{OrderIds: orderIds})
+	if err != nil {
+		return fmt.Errorf("unable to cancel oco legs: %w", err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, result := range response.Results {
+		for _, leg := range g.result.Legs {
+			if leg != nil && leg.OrderId == result.OrderId && result.Success {
+				leg.Canceled = true
+			}
+		}
+	}
+
+	return nil
+}
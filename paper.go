@@ -0,0 +1,466 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const paperPortfolioName = "Paper"
+
+var ErrPaperUnsupported = errors.New("not supported by the paper client")
+
+type PaperConfig struct {
+	// Balances are the starting virtual balances keyed by currency, e.g. {"USD": "10000"}.
+	Balances map[string]string `json:"balances"`
+
+	MakerFeeRate string `json:"maker_fee_rate,omitempty"`
+	TakerFeeRate string `json:"taker_fee_rate,omitempty"`
+
+	// SlippageBps moves every taker fill price against the order by this many basis points, on top
+	// of the price impact of walking the book.
+	SlippageBps float64 `json:"slippage_bps,omitempty"`
+}
+
+// PaperClient implements Service against virtual balances so strategies can be dry-run on live
+// market data. Market data endpoints are served by the wrapped ProductsService, which can be a
+// Client or one replaying a recorded cassette. Orders never leave the process: marketable orders
+// walk the current order book as takers and resting orders fill in full as makers once the top of
+// book trades through their price, which is evaluated whenever orders, fills or balances are read.
+// Only spot products are simulated. Portfolio management, payment method, futures, perpetuals and
+// convert endpoints return ErrPaperUnsupported.
+type PaperClient struct {
+	marketData   ProductsService
+	makerFeeRate float64
+	takerFeeRate float64
+	slippage     float64
+	portfolio    Portfolio
+	now          func() time.Time
+
+	mu         sync.Mutex
+	accounts   map[string]*paperAccount
+	orders     []*paperOrder
+	ordersById map[string]*paperOrder
+	fills      []*Fill
+	products   map[string]*GetProductResponse
+	volume     float64
+	fees       float64
+}
+
+type paperAccount struct {
+	account   Account
+	available float64
+	hold      float64
+}
+
+var _ Service = (*PaperClient)(nil)
+
+func NewPaperClient(marketData ProductsService, config *PaperConfig) (*PaperClient, error) {
+
+	if marketData == nil {
+		return nil, errors.New("market data source not set")
+	}
+
+	if config == nil {
+		config = &PaperConfig{}
+	}
+
+	p := &PaperClient{
+		marketData: marketData,
+		slippage:   config.SlippageBps / 10000,
+		now:        time.Now,
+		portfolio: Portfolio{
+			Name: paperPortfolioName,
+			Uuid: uuid.New().String(),
+			Type: "DEFAULT",
+		},
+		accounts:   make(map[string]*paperAccount),
+		ordersById: make(map[string]*paperOrder),
+		products:   make(map[string]*GetProductResponse),
+	}
+
+	var err error
+	if p.makerFeeRate, err = parseFloat(config.MakerFeeRate); err != nil || p.makerFeeRate < 0 {
+		return nil, fmt.Errorf("invalid maker fee rate: %s", config.MakerFeeRate)
+	}
+
+	if p.takerFeeRate, err = parseFloat(config.TakerFeeRate); err != nil || p.takerFeeRate < 0 {
+		return nil, fmt.Errorf("invalid taker fee rate: %s", config.TakerFeeRate)
+	}
+
+	if config.SlippageBps < 0 {
+		return nil, fmt.Errorf("invalid slippage: %v bps", config.SlippageBps)
+	}
+
+	for currency, balance := range config.Balances {
+		amount, err := parseFloat(balance)
+		if err != nil || amount < 0 {
+			return nil, fmt.Errorf("invalid %s balance: %s", currency, balance)
+		}
+		p.account(currency).available = amount
+	}
+
+	return p, nil
+}
+
+// account returns the virtual account for currency, opening an empty one on first use.
+func (p *PaperClient) account(currency string) *paperAccount {
+
+	currency = strings.ToUpper(currency)
+
+	if a, ok := p.accounts[currency]; ok {
+		return a
+	}
+
+	now := p.now().UTC().Format(time.RFC3339)
+	a := &paperAccount{
+		account: Account{
+			Uuid:              uuid.New().String(),
+			Name:              fmt.Sprintf("%s Wallet", currency),
+			Currency:          currency,
+			Default:           true,
+			Active:            true,
+			CreatedAt:         now,
+			UpdatedAt:         now,
+			Type:              "ACCOUNT_TYPE_CRYPTO",
+			Ready:             true,
+			RetailPortfolioId: p.portfolio.Uuid,
+		},
+	}
+
+	if isCashCurrency(currency) {
+		a.account.Type = "ACCOUNT_TYPE_FIAT"
+	}
+
+	p.accounts[currency] = a
+	return a
+}
+
+func (a *paperAccount) snapshot() *Account {
+	account := a.account
+	account.AvailableBalance = Amount{Value: formatFloat(a.available), Currency: account.Currency}
+	account.Hold = Amount{Value: formatFloat(a.hold), Currency: account.Currency}
+	return &account
+}
+
+func isCashCurrency(currency string) bool {
+	switch currency {
+	case "USD", "USDC":
+		return true
+	}
+	return false
+}
+
+func paperUnsupported(operation string) error {
+	return fmt.Errorf("%s: %w", operation, ErrPaperUnsupported)
+}
+
+// Market data
+
+func (p *PaperClient) ListProducts(ctx context.Context, request *ListProductsRequest) (*ListProductsResponse, error) {
+	return p.marketData.ListProducts(ctx, request)
+}
+
+func (p *PaperClient) GetProduct(ctx context.Context, request *GetProductRequest) (*GetProductResponse, error) {
+	return p.marketData.GetProduct(ctx, request)
+}
+
+func (p *PaperClient) GetProductBook(ctx context.Context, request *GetProductBookRequest) (*GetProductBookResponse, error) {
+	return p.marketData.GetProductBook(ctx, request)
+}
+
+func (p *PaperClient) GetBestBidAsk(ctx context.Context, request *GetBestBidAskRequest) (*GetBestBidAskResponse, error) {
+	return p.marketData.GetBestBidAsk(ctx, request)
+}
+
+func (p *PaperClient) GetProductCandles(ctx context.Context, request *GetProductCandlesRequest) (*GetProductCandlesResponse, error) {
+	return p.marketData.GetProductCandles(ctx, request)
+}
+
+func (p *PaperClient) GetMarketTrades(ctx context.Context, request *GetMarketTradesRequest) (*GetMarketTradesResponse, error) {
+	return p.marketData.GetMarketTrades(ctx, request)
+}
+
+func (p *PaperClient) ListPublicProducts(ctx context.Context, request *ListPublicProductsRequest) (*ListPublicProductsResponse, error) {
+	return p.marketData.ListPublicProducts(ctx, request)
+}
+
+func (p *PaperClient) GetPublicProduct(ctx context.Context, request *GetPublicProductRequest) (*GetPublicProductResponse, error) {
+	return p.marketData.GetPublicProduct(ctx, request)
+}
+
+func (p *PaperClient) GetPublicProductBook(ctx context.Context, request *GetPublicProductBookRequest) (*GetPublicProductBookResponse, error) {
+	return p.marketData.GetPublicProductBook(ctx, request)
+}
+
+func (p *PaperClient) GetPublicProductCandles(ctx context.Context, request *GetPublicProductCandlesRequest) (*GetPublicProductCandlesResponse, error) {
+	return p.marketData.GetPublicProductCandles(ctx, request)
+}
+
+func (p *PaperClient) GetPublicMarketTrades(ctx context.Context, request *GetPublicMarketTradesRequest) (*GetPublicMarketTradesResponse, error) {
+	return p.marketData.GetPublicMarketTrades(ctx, request)
+}
+
+func (p *PaperClient) GetServerTime(ctx context.Context, request *GetServerTimeRequest) (*GetServerTimeResponse, error) {
+	return p.marketData.GetServerTime(ctx, request)
+}
+
+// Accounts
+
+func (p *PaperClient) ListAccounts(ctx context.Context, request *ListAccountsRequest) (*ListAccountsResponse, error) {
+
+	if err := p.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	response := &ListAccountsResponse{Request: request}
+
+	if len(request.RetailPortfolioId) > 0 && request.RetailPortfolioId != p.portfolio.Uuid {
+		return response, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, currency := range p.currencies() {
+		response.Accounts = append(response.Accounts, p.accounts[currency].snapshot())
+	}
+
+	return response, nil
+}
+
+func (p *PaperClient) GetAccount(ctx context.Context, request *GetAccountRequest) (*GetAccountResponse, error) {
+
+	if err := p.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, a := range p.accounts {
+		if a.account.Uuid == request.AccountUuid {
+			return &GetAccountResponse{Accounts: a.snapshot(), Request: request}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("account %s not found", request.AccountUuid)
+}
+
+// GetTransactionsSummary reports the simulated volume and fees under a fee tier built from the
+// configured rates.
+func (p *PaperClient) GetTransactionsSummary(ctx context.Context, request *GetTransactionsSummaryRequest) (*GetTransactionsSummaryResponse, error) {
+
+	if err := p.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return &GetTransactionsSummaryResponse{
+		TotalVolume: int(p.volume),
+		TotalFees:   p.fees,
+		FeeTier: FeeTier{
+			PricingTier:  paperPortfolioName,
+			MakerFeeRate: formatFloat(p.makerFeeRate),
+			TakerFeeRate: formatFloat(p.takerFeeRate),
+		},
+		AdvancedTradeOnlyVolume: int(p.volume),
+		AdvancedTradeOnlyFees:   p.fees,
+		Request:                 request,
+	}, nil
+}
+
+func (p *PaperClient) ListPaymentMethods(ctx context.Context, request *ListPaymentMethodsRequest) (*ListPaymentMethodsResponse, error) {
+	return &ListPaymentMethodsResponse{Request: request}, nil
+}
+
+func (p *PaperClient) GetPaymentMethod(ctx context.Context, request *GetPaymentMethodRequest) (*GetPaymentMethodResponse, error) {
+	return nil, paperUnsupported("GetPaymentMethod")
+}
+
+// currencies returns the currencies of the virtual accounts in alphabetical order.
+func (p *PaperClient) currencies() []string {
+	currencies := make([]string, 0, len(p.accounts))
+	for currency := range p.accounts {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+// Portfolios
+
+func (p *PaperClient) ListPortfolios(ctx context.Context, request *ListPortfoliosRequest) (*ListPortfoliosResponse, error) {
+
+	response := &ListPortfoliosResponse{Request: request}
+
+	if len(request.PortfolioType) == 0 || request.PortfolioType == p.portfolio.Type {
+		portfolio := p.portfolio
+		response.Portfolios = append(response.Portfolios, &portfolio)
+	}
+
+	return response, nil
+}
+
+// GetPortfolioBreakdown values the virtual balances in USD at the mid of each currency's USD
+// product.
+func (p *PaperClient) GetPortfolioBreakdown(ctx context.Context, request *GetPortfolioBreakdownRequest) (*GetPortfolioBreakdownResponse, error) {
+
+	if request.PortfolioUuid != p.portfolio.Uuid {
+		return nil, fmt.Errorf("portfolio %s not found", request.PortfolioUuid)
+	}
+
+	if err := p.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	accounts := make([]*Account, 0, len(p.accounts))
+	for _, currency := range p.currencies() {
+		accounts = append(accounts, p.accounts[currency].snapshot())
+	}
+	p.mu.Unlock()
+
+	breakdown := &Breakdown{Portfolio: p.portfolio}
+
+	var total, cash, crypto float64
+	for _, account := range accounts {
+
+		available, _ := parseFloat(account.AvailableBalance.Value)
+		hold, _ := parseFloat(account.Hold.Value)
+
+		price := 1.0
+		if !isCashCurrency(account.Currency) {
+			bid, ask, err := fetchBestBidAsk(ctx, p.marketData, account.Currency+"-USD")
+			if err != nil {
+				return nil, fmt.Errorf("unable to price %s: %w", account.Currency, err)
+			}
+			price = (bid + ask) / 2
+		}
+
+		position := SpotPosition{
+			Asset:                account.Currency,
+			AccountUuid:          account.Uuid,
+			TotalBalanceCrypto:   available + hold,
+			TotalBalanceFiat:     (available + hold) * price,
+			AvailableToTradeFiat: available * price,
+			IsCash:               isCashCurrency(account.Currency),
+		}
+
+		total += position.TotalBalanceFiat
+		if position.IsCash {
+			cash += position.TotalBalanceFiat
+		} else {
+			crypto += position.TotalBalanceFiat
+		}
+
+		breakdown.SpotPositions = append(breakdown.SpotPositions, position)
+	}
+
+	for i := range breakdown.SpotPositions {
+		if total > 0 {
+			breakdown.SpotPositions[i].Allocation = breakdown.SpotPositions[i].TotalBalanceFiat / total
+		}
+	}
+
+	breakdown.PortfolioBalances = PortfolioBalances{
+		TotalBalance:               Amount{Value: formatFloat(total), Currency: "USD"},
+		TotalCashEquivalentBalance: Amount{Value: formatFloat(cash), Currency: "USD"},
+		TotalCryptoBalance:         Amount{Value: formatFloat(crypto), Currency: "USD"},
+	}
+
+	return &GetPortfolioBreakdownResponse{Breakdown: breakdown, Request: request}, nil
+}
+
+func (p *PaperClient) CreatePortfolio(ctx context.Context, request *CreatePortfolioRequest) (*CreatePortfolioResponse, error) {
+	return nil, paperUnsupported("CreatePortfolio")
+}
+
+func (p *PaperClient) EditPortfolio(ctx context.Context, request *EditPortfolioRequest) (*EditPortfolioResponse, error) {
+	return nil, paperUnsupported("EditPortfolio")
+}
+
+func (p *PaperClient) DeletePortfolio(ctx context.Context, request *DeletePortfolioRequest) (*DeletePortfolioResponse, error) {
+	return nil, paperUnsupported("DeletePortfolio")
+}
+
+func (p *PaperClient) MovePortfolioFunds(ctx context.Context, request *MovePortfolioFundsRequest) (*MovePortfolioFundsResponse, error) {
+	return nil, paperUnsupported("MovePortfolioFunds")
+}
+
+// Futures, perpetuals and convert
+
+func (p *PaperClient) GetFuturesBalanceSummary(ctx context.Context, request *GetFuturesBalanceSummaryRequest) (*GetFuturesBalanceSummaryResponse, error) {
+	return nil, paperUnsupported("GetFuturesBalanceSummary")
+}
+
+func (p *PaperClient) ListFuturesPositions(ctx context.Context, request *ListFuturesPositionsRequest) (*ListFuturesPositionsResponse, error) {
+	return nil, paperUnsupported("ListFuturesPositions")
+}
+
+func (p *PaperClient) GetFuturesPosition(ctx context.Context, request *GetFuturesPositionRequest) (*GetFuturesPositionResponse, error) {
+	return nil, paperUnsupported("GetFuturesPosition")
+}
+
+func (p *PaperClient) ScheduleFuturesSweep(ctx context.Context, request *ScheduleFuturesSweepRequest) (*ScheduleFuturesSweepResponse, error) {
+	return nil, paperUnsupported("ScheduleFuturesSweep")
+}
+
+func (p *PaperClient) ListFuturesSweeps(ctx context.Context, request *ListFuturesSweepsRequest) (*ListFuturesSweepsResponse, error) {
+	return nil, paperUnsupported("ListFuturesSweeps")
+}
+
+func (p *PaperClient) CancelPendingFuturesSweeps(ctx context.Context, request *CancelPendingFuturesSweepsRequest) (*CancelPendingFuturesSweepsResponse, error) {
+	return nil, paperUnsupported("CancelPendingFuturesSweeps")
+}
+
+func (p *PaperClient) AllocatePortfolio(ctx context.Context, request *AllocatePortfolioRequest) (*AllocatePortfolioResponse, error) {
+	return nil, paperUnsupported("AllocatePortfolio")
+}
+
+func (p *PaperClient) GetPerpetualsPortfolioSummary(ctx context.Context, request *GetPerpetualsPortfolioSummaryRequest) (*GetPerpetualsPortfolioSummaryResponse, error) {
+	return nil, paperUnsupported("GetPerpetualsPortfolioSummary")
+}
+
+func (p *PaperClient) ListPerpetualsPositions(ctx context.Context, request *ListPerpetualsPositionsRequest) (*ListPerpetualsPositionsResponse, error) {
+	return nil, paperUnsupported("ListPerpetualsPositions")
+}
+
+func (p *PaperClient) GetPerpetualsPosition(ctx context.Context, request *GetPerpetualsPositionRequest) (*GetPerpetualsPositionResponse, error) {
+	return nil, paperUnsupported("GetPerpetualsPosition")
+}
+
+func (p *PaperClient) CreateConvertQuote(ctx context.Context, request *CreateConvertQuoteRequest) (*CreateConvertQuoteResponse, error) {
+	return nil, paperUnsupported("CreateConvertQuote")
+}
+
+func (p *PaperClient) CommitConvertQuote(ctx context.Context, request *CommitConvertQuoteRequest) (*CommitConvertQuoteResponse, error) {
+	return nil, paperUnsupported("CommitConvertQuote")
+}
+
+func (p *PaperClient) GetConvertTrade(ctx context.Context, request *GetConvertTradeRequest) (*GetConvertTradeResponse, error) {
+	return nil, paperUnsupported("GetConvertTrade")
+}
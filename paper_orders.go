@@ -0,0 +1,1114 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	paperOrderTypeMarket    = "MARKET"
+	paperOrderTypeLimit     = "LIMIT"
+	paperOrderTypeStopLimit = "STOP_LIMIT"
+	paperOrderTypeBracket   = "BRACKET"
+
+	paperTimeInForceIoc = "IMMEDIATE_OR_CANCEL"
+	paperTimeInForceGtc = "GOOD_UNTIL_CANCELLED"
+	paperTimeInForceGtd = "GOOD_UNTIL_DATE_TIME"
+	paperTimeInForceFok = "FILL_OR_KILL"
+
+	paperLiquidityMaker = "MAKER"
+	paperLiquidityTaker = "TAKER"
+
+	paperSizeEpsilon = 1e-12
+)
+
+type paperOrder struct {
+	order         Order
+	base          string
+	quote         string
+	baseIncrement string
+	baseSize      float64
+	quoteSize     float64
+	limitPrice    float64
+	stopPrice     float64
+	stopDirection string
+	postOnly      bool
+	endTime       time.Time
+	hold          float64
+	filledSize    float64
+	filledValue   float64
+	fees          float64
+	fills         int
+}
+
+type paperLevel struct {
+	price float64
+	size  float64
+}
+
+// paperExecution is the immediate part of an order as a taker plus the size left to rest, and the
+// funds the whole order needs: quote currency for buys and base currency for sells.
+type paperExecution struct {
+	size  float64
+	value float64
+	fee   float64
+	rest  float64
+	funds float64
+	bid   float64
+	ask   float64
+}
+
+func (o *paperOrder) buy() bool {
+	return o.order.Side == "BUY"
+}
+
+func (o *paperOrder) open() bool {
+	return o.order.Status == "OPEN"
+}
+
+// holdPrice is the worst price the resting part of the order can fill at.
+func (o *paperOrder) holdPrice() float64 {
+	if o.order.OrderType == paperOrderTypeBracket && o.buy() {
+		return math.Max(o.limitPrice, o.stopPrice)
+	}
+	return o.limitPrice
+}
+
+func (p *PaperClient) CreateOrder(ctx context.Context, request *CreateOrderRequest) (*CreateOrderResponse, error) {
+
+	p.mu.Lock()
+	existing := p.orderByClientId(request.ClientOrderId)
+	p.mu.Unlock()
+
+	if existing != nil {
+		return paperOrderResponse(existing, request), nil
+	}
+
+	o, execution, reason, err := p.plan(ctx, request.ProductId, request.Side, request.OrderConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(reason) > 0 {
+		return paperOrderFailure(reason, request), nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Planning runs unlocked, so a retry with the same client order id may have been placed since.
+	if existing := p.orderByClientId(request.ClientOrderId); existing != nil {
+		return paperOrderResponse(existing, request), nil
+	}
+
+	if reason := p.checkFunds(o, execution); len(reason) > 0 {
+		return paperOrderFailure(reason, request), nil
+	}
+
+	now := p.now()
+
+	o.order.OrderId = uuid.New().String()
+	o.order.ClientOrderId = request.ClientOrderId
+	o.order.CreatedTime = now.UTC().Format(time.RFC3339Nano)
+	o.order.Status = "OPEN"
+
+	p.orders = append(p.orders, o)
+	p.ordersById[o.order.OrderId] = o
+
+	if execution.size > 0 {
+		p.execute(o, execution.size, execution.value, paperLiquidityTaker, now)
+	}
+
+	if execution.rest > paperSizeEpsilon {
+		p.hold(o)
+	} else if execution.size > 0 && (o.quoteSize > 0 || o.filledSize >= o.baseSize-paperSizeEpsilon) {
+		p.finish(o, "FILLED")
+	} else {
+		p.finish(o, "CANCELLED")
+	}
+
+	return paperOrderResponse(o, request), nil
+}
+
+// orderByClientId returns the order placed with clientOrderId, or nil. The caller must hold p.mu.
+func (p *PaperClient) orderByClientId(clientOrderId string) *paperOrder {
+	if len(clientOrderId) == 0 {
+		return nil
+	}
+	for _, o := range p.orders {
+		if o.order.ClientOrderId == clientOrderId {
+			return o
+		}
+	}
+	return nil
+}
+
+func (p *PaperClient) CancelOrders(ctx context.Context, request *CancelOrdersRequest) (*CancelOrdersResponse, error) {
+
+	if err := p.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	response := &CancelOrdersResponse{Request: request}
+
+	for _, orderId := range request.OrderIds {
+		result := &CancelResult{OrderId: orderId}
+		o, ok := p.ordersById[orderId]
+		switch {
+		case !ok:
+			result.FailureReason = "UNKNOWN_CANCEL_ORDER"
+		case !o.open():
+			result.FailureReason = "DUPLICATE_CANCEL_REQUEST"
+		default:
+			p.finish(o, "CANCELLED")
+			result.Success = true
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	return response, nil
+}
+
+// EditOrder amends the price and size of an open good-until-cancelled limit or stop limit order,
+// re-reserving its funds. An edited price that crosses the book fills on the next sync.
+func (p *PaperClient) EditOrder(ctx context.Context, request *EditOrderRequest) (*EditOrderResponse, error) {
+
+	if err := p.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	response := &EditOrderResponse{Request: request}
+
+	o, price, size, stopPrice, reason := p.editTerms(request.OrderId, request.Price, request.Size, request.StopPrice)
+	if len(reason) == 0 {
+		reason = p.edit(o, price, size, stopPrice)
+	}
+
+	if len(reason) > 0 {
+		response.EditErrors = []*EditError{{EditFailureReason: reason}}
+		return response, nil
+	}
+
+	o.order.EditHistory = append(o.order.EditHistory, EditHistoryItem{
+		Price:                  request.Price,
+		Size:                   request.Size,
+		ReplaceAcceptTimestamp: p.now().UTC().Format(time.RFC3339Nano),
+	})
+
+	if c := o.order.OrderConfiguration.LimitLimitGtc; c != nil {
+		copied := *c
+		copied.LimitPrice, copied.BaseSize = request.Price, request.Size
+		o.order.OrderConfiguration.LimitLimitGtc = &copied
+	}
+
+	if c := o.order.OrderConfiguration.StopLimitStopLimitGtc; c != nil {
+		copied := *c
+		copied.LimitPrice, copied.BaseSize = request.Price, request.Size
+		if len(request.StopPrice) > 0 {
+			copied.StopPrice = request.StopPrice
+		}
+		o.order.OrderConfiguration.StopLimitStopLimitGtc = &copied
+	}
+
+	response.Success = true
+	return response, nil
+}
+
+func (p *PaperClient) PreviewEditOrder(ctx context.Context, request *PreviewEditOrderRequest) (*PreviewEditOrderResponse, error) {
+
+	if err := p.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	preview := &PreviewEditOrderResponse{Request: request}
+
+	o, price, size, _, reason := p.editTerms(request.OrderId, request.Price, request.Size, request.StopPrice)
+	if len(reason) > 0 {
+		preview.EditErrors = []*EditError{{PreviewFailureReason: reason}}
+		return preview, nil
+	}
+
+	value := size * price
+	fee := value * p.makerFeeRate
+
+	preview.BaseSize = formatFloat(size)
+	preview.QuoteSize = formatFloat(value)
+	preview.CommissionTotal = formatFloat(fee)
+	if o.buy() {
+		preview.OrderTotal = formatFloat(value + fee)
+	} else {
+		preview.OrderTotal = formatFloat(value - fee)
+	}
+
+	return preview, nil
+}
+
+// editTerms looks up the order and parses an edit of it, returning the reason the exchange would
+// refuse the edit, if any.
+func (p *PaperClient) editTerms(orderId, price, size, stopPrice string) (o *paperOrder, newPrice, newSize, newStop float64, reason string) {
+
+	o, ok := p.ordersById[orderId]
+	if !ok {
+		return nil, 0, 0, 0, "ORDER_NOT_FOUND"
+	}
+
+	if !o.open() {
+		return nil, 0, 0, 0, "ORDER_NOT_OPEN"
+	}
+
+	if o.order.TimeInForce != paperTimeInForceGtc ||
+		(o.order.OrderType != paperOrderTypeLimit && o.order.OrderType != paperOrderTypeStopLimit) {
+		return nil, 0, 0, 0, "INVALID_EDITED_ORDER_TYPE"
+	}
+
+	var err error
+	if newPrice, err = parseFloat(price); err != nil || newPrice <= 0 {
+		return nil, 0, 0, 0, "INVALID_EDITED_LIMIT_PRICE"
+	}
+
+	if newSize, err = parseFloat(size); err != nil || newSize <= o.filledSize {
+		return nil, 0, 0, 0, "CANNOT_EDIT_TO_BELOW_FILLED_SIZE"
+	}
+
+	if newStop, err = parseFloat(stopPrice); err != nil || newStop < 0 ||
+		(newStop > 0 && o.order.OrderType != paperOrderTypeStopLimit) {
+		return nil, 0, 0, 0, "INVALID_EDITED_STOP_PRICE"
+	}
+
+	return o, newPrice, newSize, newStop, ""
+}
+
+// edit re-reserves the order's funds at the new terms, restoring the old terms when the account
+// cannot cover them.
+func (p *PaperClient) edit(o *paperOrder, price, size, stopPrice float64) string {
+
+	previousPrice, previousSize, previousStop := o.limitPrice, o.baseSize, o.stopPrice
+
+	p.release(o)
+	o.limitPrice, o.baseSize = price, size
+	if stopPrice > 0 {
+		o.stopPrice = stopPrice
+	}
+
+	execution := &paperExecution{rest: o.baseSize - o.filledSize}
+	if o.buy() {
+		execution.funds = execution.rest * o.holdPrice() * (1 + p.holdFeeRate())
+	} else {
+		execution.funds = execution.rest
+	}
+
+	reason := p.checkFunds(o, execution)
+	if len(reason) > 0 {
+		o.limitPrice, o.baseSize, o.stopPrice = previousPrice, previousSize, previousStop
+	}
+
+	p.hold(o)
+	return reason
+}
+
+func (p *PaperClient) GetOrder(ctx context.Context, request *GetOrderRequest) (*GetOrderResponse, error) {
+
+	if err := p.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if o, ok := p.ordersById[request.OrderId]; ok {
+		order := o.order
+		return &GetOrderResponse{Order: &order, Request: request}, nil
+	}
+
+	if o := p.orderByClientId(request.ClientOrderId); o != nil {
+		order := o.order
+		return &GetOrderResponse{Order: &order, Request: request}, nil
+	}
+
+	return nil, fmt.Errorf("order %s not found", request.OrderId)
+}
+
+// ListOrders returns the simulated orders matching the request's product, status, side and type
+// filters, newest first, in a single page.
+func (p *PaperClient) ListOrders(ctx context.Context, request *ListOrdersRequest) (*ListOrdersResponse, error) {
+
+	if err := p.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	response := &ListOrdersResponse{Request: request, Pagination: &Pagination{}}
+
+	if len(request.RetailPortfolioId) > 0 && request.RetailPortfolioId != p.portfolio.Uuid {
+		return response, nil
+	}
+
+	for i := len(p.orders) - 1; i >= 0; i-- {
+		o := p.orders[i]
+		if len(request.ProductId) > 0 && o.order.ProductId != request.ProductId {
+			continue
+		}
+		if len(request.OrderSide) > 0 && o.order.Side != request.OrderSide {
+			continue
+		}
+		if len(request.OrderType) > 0 && o.order.OrderType != request.OrderType {
+			continue
+		}
+		if len(request.OrderStatus) > 0 && !slices.Contains(request.OrderStatus, o.order.Status) {
+			continue
+		}
+		order := o.order
+		response.Orders = append(response.Orders, &order)
+	}
+
+	return response, nil
+}
+
+// ListFills returns the simulated fills matching the request's order and product filters, newest
+// first, in a single page.
+func (p *PaperClient) ListFills(ctx context.Context, request *ListFillsRequest) (*ListFillsResponse, error) {
+
+	if err := p.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	response := &ListFillsResponse{Request: request}
+
+	if len(request.RetailPortfolioId) > 0 && request.RetailPortfolioId != p.portfolio.Uuid {
+		return response, nil
+	}
+
+	for i := len(p.fills) - 1; i >= 0; i-- {
+		fill := *p.fills[i]
+		if len(request.OrderId) > 0 && fill.OrderId != request.OrderId {
+			continue
+		}
+		if len(request.ProductId) > 0 && fill.ProductId != request.ProductId {
+			continue
+		}
+		response.Fills = append(response.Fills, &fill)
+	}
+
+	return response, nil
+}
+
+func (p *PaperClient) ClosePosition(ctx context.Context, request *ClosePositionRequest) (*ClosePositionResponse, error) {
+	return nil, paperUnsupported("ClosePosition")
+}
+
+func (p *PaperClient) CreateOrderPreview(ctx context.Context, request *CreateOrderPreviewRequest) (*CreateOrderPreviewResponse, error) {
+
+	o, execution, reason, err := p.plan(ctx, request.ProductId, request.Side, request.OrderConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &CreateOrderPreviewResponse{Request: request}
+
+	if len(reason) > 0 {
+		response.Errs = append(response.Errs, reason)
+		return response, nil
+	}
+
+	p.mu.Lock()
+	if reason := p.checkFunds(o, execution); len(reason) > 0 {
+		response.Errs = append(response.Errs, "PREVIEW_"+reason)
+	}
+	p.mu.Unlock()
+
+	size, value, fee := execution.size, execution.value, execution.fee
+	if execution.rest > 0 {
+		size += execution.rest
+		value += execution.rest * o.limitPrice
+		fee += execution.rest * o.limitPrice * p.makerFeeRate
+	}
+
+	response.BaseSize = formatFloat(size)
+	response.QuoteSize = formatFloat(value)
+	response.CommissionTotal = formatFloat(fee)
+	response.BestBid = formatFloat(execution.bid)
+	response.BestAsk = formatFloat(execution.ask)
+
+	if o.buy() {
+		response.OrderTotal = formatFloat(value + fee)
+	} else {
+		response.OrderTotal = formatFloat(value - fee)
+	}
+
+	if size > 0 {
+		response.AverageFilledPrice = formatFloat(value / size)
+	}
+
+	if execution.size > 0 {
+		touch := execution.ask
+		if !o.buy() {
+			touch = execution.bid
+		}
+		if touch > 0 {
+			response.Slippage = formatFloat(math.Abs(execution.value/execution.size-touch) / touch)
+		}
+	}
+
+	return response, nil
+}
+
+// plan parses an order and works out what it would do against the current book without changing
+// any state. A non-empty reason means the exchange would reject the order.
+func (p *PaperClient) plan(
+	ctx context.Context,
+	productId,
+	side string,
+	configuration OrderConfiguration,
+) (*paperOrder, *paperExecution, string, error) {
+
+	product, err := p.product(ctx, productId)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	if len(product.ProductType) > 0 && product.ProductType != "SPOT" {
+		return nil, nil, "", paperUnsupported(fmt.Sprintf("%s orders", product.ProductType))
+	}
+
+	o, reason := parsePaperOrder(product, side, configuration, p.now())
+	if len(reason) > 0 {
+		return nil, nil, reason, nil
+	}
+
+	book, err := p.marketData.GetProductBook(ctx, &GetProductBookRequest{ProductId: productId})
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("unable to get product book %s: %w", productId, err)
+	}
+
+	if book.PriceBook == nil {
+		return nil, nil, "", fmt.Errorf("no price book returned for %s", productId)
+	}
+
+	bids, err := parsePaperLevels(book.PriceBook.Bids)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	asks, err := parsePaperLevels(book.PriceBook.Asks)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	execution := &paperExecution{}
+	if len(bids) > 0 {
+		execution.bid = bids[0].price
+	}
+	if len(asks) > 0 {
+		execution.ask = asks[0].price
+	}
+
+	levels := asks
+	if !o.buy() {
+		levels = bids
+	}
+
+	switch o.order.OrderType {
+
+	case paperOrderTypeStopLimit, paperOrderTypeBracket:
+		execution.rest = o.baseSize
+
+	case paperOrderTypeMarket:
+		execution.size, execution.value = p.walk(o, levels)
+		if execution.size <= 0 {
+			return nil, nil, "NO_LIQUIDITY", nil
+		}
+
+	case paperOrderTypeLimit:
+		marketable := len(levels) > 0 &&
+			((o.buy() && o.limitPrice >= levels[0].price) || (!o.buy() && o.limitPrice <= levels[0].price))
+
+		if marketable && o.postOnly {
+			return nil, nil, "INVALID_LIMIT_PRICE_POST_ONLY", nil
+		}
+
+		if marketable {
+			execution.size, execution.value = p.walk(o, levels)
+		}
+
+		switch o.order.TimeInForce {
+		case paperTimeInForceFok:
+			if execution.size < o.baseSize-paperSizeEpsilon {
+				execution.size, execution.value = 0, 0
+			}
+		case paperTimeInForceGtc, paperTimeInForceGtd:
+			execution.rest = o.baseSize - execution.size
+		}
+	}
+
+	execution.fee = execution.value * p.takerFeeRate
+
+	if o.buy() {
+		execution.funds = execution.value + execution.fee + execution.rest*o.holdPrice()*(1+p.holdFeeRate())
+	} else {
+		execution.funds = execution.size + execution.rest
+	}
+
+	return o, execution, "", nil
+}
+
+// product returns the product from the market data source, cached for the life of the client.
+func (p *PaperClient) product(ctx context.Context, productId string) (*GetProductResponse, error) {
+
+	p.mu.Lock()
+	product, ok := p.products[productId]
+	p.mu.Unlock()
+
+	if ok {
+		return product, nil
+	}
+
+	product, err := p.marketData.GetProduct(ctx, &GetProductRequest{ProductId: productId})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get product %s: %w", productId, err)
+	}
+
+	p.mu.Lock()
+	p.products[productId] = product
+	p.mu.Unlock()
+
+	return product, nil
+}
+
+func parsePaperOrder(product *GetProductResponse, side string, configuration OrderConfiguration, now time.Time) (*paperOrder, string) {
+
+	if side != "BUY" && side != "SELL" {
+		return nil, "UNKNOWN_SIDE"
+	}
+
+	o := &paperOrder{
+		order: Order{
+			ProductId:            product.ProductId,
+			OrderConfiguration:   configuration,
+			Side:                 side,
+			ProductType:          "SPOT",
+			OrderPlacementSource: "RETAIL_ADVANCED",
+			TriggerStatus:        "INVALID_ORDER_TYPE",
+		},
+		base:          product.BaseCurrencyId,
+		quote:         product.QuoteCurrencyId,
+		baseIncrement: product.BaseIncrement,
+	}
+
+	if len(o.base) == 0 || len(o.quote) == 0 {
+		o.base, o.quote, _ = strings.Cut(product.ProductId, "-")
+	}
+
+	var baseSize, quoteSize, limitPrice, stopPrice, endTime string
+
+	switch c := configuration; {
+	case c.MarketMarketIoc != nil:
+		o.order.OrderType, o.order.TimeInForce = paperOrderTypeMarket, paperTimeInForceIoc
+		baseSize, quoteSize = c.MarketMarketIoc.BaseSize, c.MarketMarketIoc.QuoteSize
+	case c.SorLimitIoc != nil:
+		o.order.OrderType, o.order.TimeInForce = paperOrderTypeLimit, paperTimeInForceIoc
+		baseSize, limitPrice = c.SorLimitIoc.BaseSize, c.SorLimitIoc.LimitPrice
+	case c.LimitLimitGtc != nil:
+		o.order.OrderType, o.order.TimeInForce = paperOrderTypeLimit, paperTimeInForceGtc
+		baseSize, limitPrice, o.postOnly = c.LimitLimitGtc.BaseSize, c.LimitLimitGtc.LimitPrice, c.LimitLimitGtc.PostOnly
+	case c.LimitLimitGtd != nil:
+		o.order.OrderType, o.order.TimeInForce = paperOrderTypeLimit, paperTimeInForceGtd
+		baseSize, limitPrice, o.postOnly = c.LimitLimitGtd.BaseSize, c.LimitLimitGtd.LimitPrice, c.LimitLimitGtd.PostOnly
+		endTime = c.LimitLimitGtd.EndTime
+	case c.LimitLimitFok != nil:
+		o.order.OrderType, o.order.TimeInForce = paperOrderTypeLimit, paperTimeInForceFok
+		baseSize, limitPrice = c.LimitLimitFok.BaseSize, c.LimitLimitFok.LimitPrice
+	case c.StopLimitStopLimitGtc != nil:
+		o.order.OrderType, o.order.TimeInForce = paperOrderTypeStopLimit, paperTimeInForceGtc
+		baseSize, limitPrice, stopPrice = c.StopLimitStopLimitGtc.BaseSize, c.StopLimitStopLimitGtc.LimitPrice, c.StopLimitStopLimitGtc.StopPrice
+		o.stopDirection = c.StopLimitStopLimitGtc.StopDirection
+	case c.StopLimitStopLimitGtd != nil:
+		o.order.OrderType, o.order.TimeInForce = paperOrderTypeStopLimit, paperTimeInForceGtd
+		baseSize, limitPrice, stopPrice = c.StopLimitStopLimitGtd.BaseSize, c.StopLimitStopLimitGtd.LimitPrice, c.StopLimitStopLimitGtd.StopPrice
+		o.stopDirection, endTime = c.StopLimitStopLimitGtd.StopDirection, c.StopLimitStopLimitGtd.EndTime
+	case c.TriggerBracketGtc != nil:
+		o.order.OrderType, o.order.TimeInForce = paperOrderTypeBracket, paperTimeInForceGtc
+		baseSize, limitPrice, stopPrice = c.TriggerBracketGtc.BaseSize, c.TriggerBracketGtc.LimitPrice, c.TriggerBracketGtc.StopTriggerPrice
+	case c.TriggerBracketGtd != nil:
+		o.order.OrderType, o.order.TimeInForce = paperOrderTypeBracket, paperTimeInForceGtd
+		baseSize, limitPrice, stopPrice = c.TriggerBracketGtd.BaseSize, c.TriggerBracketGtd.LimitPrice, c.TriggerBracketGtd.StopTriggerPrice
+		endTime = c.TriggerBracketGtd.EndTime
+	default:
+		return nil, "UNSUPPORTED_ORDER_CONFIGURATION"
+	}
+
+	var err error
+	if o.baseSize, err = parseFloat(baseSize); err != nil || o.baseSize < 0 {
+		return nil, "INVALID_SIZE"
+	}
+	if o.quoteSize, err = parseFloat(quoteSize); err != nil || o.quoteSize < 0 {
+		return nil, "INVALID_SIZE"
+	}
+	if o.limitPrice, err = parseFloat(limitPrice); err != nil || o.limitPrice < 0 {
+		return nil, "INVALID_LIMIT_PRICE"
+	}
+	if o.stopPrice, err = parseFloat(stopPrice); err != nil || o.stopPrice < 0 {
+		return nil, "INVALID_STOP_PRICE"
+	}
+
+	if (o.baseSize > 0) == (o.quoteSize > 0) {
+		return nil, "INVALID_SIZE"
+	}
+
+	if o.order.OrderType != paperOrderTypeMarket {
+		if o.limitPrice <= 0 {
+			return nil, "INVALID_LIMIT_PRICE"
+		}
+		if o.order.OrderType != paperOrderTypeLimit && o.stopPrice <= 0 {
+			return nil, "INVALID_STOP_PRICE"
+		}
+	}
+
+	if min, _ := parseFloat(product.BaseMinSize); o.baseSize > 0 && o.baseSize < min {
+		return nil, "INVALID_BASE_SIZE_TOO_SMALL"
+	}
+
+	if max, _ := parseFloat(product.BaseMaxSize); max > 0 && o.baseSize > max {
+		return nil, "INVALID_BASE_SIZE_TOO_LARGE"
+	}
+
+	if o.order.TimeInForce == paperTimeInForceGtd {
+		if o.endTime, err = time.Parse(time.RFC3339, endTime); err != nil || !o.endTime.After(now) {
+			return nil, "INVALID_END_TIME"
+		}
+	}
+
+	switch o.stopDirection {
+	case "", StopDirectionStopUp, StopDirectionStopDown:
+	default:
+		return nil, "INVALID_STOP_DIRECTION"
+	}
+
+	if o.stopPrice > 0 {
+		o.order.TriggerStatus = "STOP_PENDING"
+	}
+
+	o.order.SizeInQuote = o.quoteSize > 0
+
+	return o, ""
+}
+
+func parsePaperLevels(levels []Level) ([]paperLevel, error) {
+
+	parsed := make([]paperLevel, 0, len(levels))
+
+	for _, level := range levels {
+		price, err := parseFloat(level.Price)
+		if err != nil {
+			return nil, fmt.Errorf("invalid level price %s: %w", level.Price, err)
+		}
+		size, err := parseFloat(level.Size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid level size %s: %w", level.Size, err)
+		}
+		parsed = append(parsed, paperLevel{price: price, size: size})
+	}
+
+	return parsed, nil
+}
+
+// walk takes liquidity from the levels, best first, until the order is filled or its limit price
+// is reached. Every level's price is worsened by the configured slippage, but never past the limit.
+func (p *PaperClient) walk(o *paperOrder, levels []paperLevel) (size, value float64) {
+
+	remainingBase, remainingQuote := o.baseSize-o.filledSize, o.quoteSize
+
+	for _, level := range levels {
+
+		if o.limitPrice > 0 && ((o.buy() && level.price > o.limitPrice) || (!o.buy() && level.price < o.limitPrice)) {
+			break
+		}
+
+		price := p.slipped(o, level.price)
+
+		take := level.size
+		if o.quoteSize > 0 {
+			take = math.Min(take, remainingQuote/price)
+			remainingQuote -= take * price
+		} else {
+			take = math.Min(take, remainingBase)
+			remainingBase -= take
+		}
+
+		size += take
+		value += take * price
+
+		if remainingBase <= paperSizeEpsilon && remainingQuote <= paperSizeEpsilon {
+			break
+		}
+	}
+
+	if o.quoteSize > 0 && size > 0 {
+		rounded, _ := parseFloat(floorToIncrement(size, o.baseIncrement))
+		value *= rounded / size
+		size = rounded
+	}
+
+	return size, value
+}
+
+func (p *PaperClient) slipped(o *paperOrder, price float64) float64 {
+	if o.buy() {
+		price *= 1 + p.slippage
+		if o.limitPrice > 0 {
+			price = math.Min(price, o.limitPrice)
+		}
+		return price
+	}
+	price *= 1 - p.slippage
+	if o.limitPrice > 0 {
+		price = math.Max(price, o.limitPrice)
+	}
+	return price
+}
+
+func (p *PaperClient) holdFeeRate() float64 {
+	return math.Max(p.makerFeeRate, p.takerFeeRate)
+}
+
+func (p *PaperClient) checkFunds(o *paperOrder, execution *paperExecution) string {
+
+	currency := o.base
+	if o.buy() {
+		currency = o.quote
+	}
+
+	var available float64
+	if a, ok := p.accounts[currency]; ok {
+		available = a.available
+	}
+
+	if execution.funds > available+paperSizeEpsilon {
+		return "INSUFFICIENT_FUND"
+	}
+
+	return ""
+}
+
+// hold reserves the funds the resting part of the order can consume.
+func (p *PaperClient) hold(o *paperOrder) {
+
+	remaining := o.baseSize - o.filledSize
+
+	if o.buy() {
+		o.hold = remaining * o.holdPrice() * (1 + p.holdFeeRate())
+		a := p.account(o.quote)
+		a.available -= o.hold
+		a.hold += o.hold
+	} else {
+		o.hold = remaining
+		a := p.account(o.base)
+		a.available -= o.hold
+		a.hold += o.hold
+	}
+
+	p.refresh(o)
+}
+
+func (p *PaperClient) release(o *paperOrder) {
+
+	if o.hold <= 0 {
+		return
+	}
+
+	a := p.account(o.base)
+	if o.buy() {
+		a = p.account(o.quote)
+	}
+
+	a.available += o.hold
+	a.hold -= o.hold
+	o.hold = 0
+}
+
+// execute records a fill of size for value against the order and settles it against the virtual
+// balances. Any hold is released first, since resting orders always fill in full.
+func (p *PaperClient) execute(o *paperOrder, size, value float64, liquidity string, now time.Time) {
+
+	p.release(o)
+
+	rate := p.takerFeeRate
+	if liquidity == paperLiquidityMaker {
+		rate = p.makerFeeRate
+	}
+	fee := value * rate
+
+	base, quote := p.account(o.base), p.account(o.quote)
+	if o.buy() {
+		base.available += size
+		quote.available -= value + fee
+	} else {
+		base.available -= size
+		quote.available += value - fee
+	}
+
+	o.filledSize += size
+	o.filledValue += value
+	o.fees += fee
+	o.fills++
+	o.order.LastFillTime = now.UTC().Format(time.RFC3339Nano)
+
+	p.volume += value
+	p.fees += fee
+
+	p.fills = append(p.fills, &Fill{
+		EntryId:            uuid.New().String(),
+		TradeId:            uuid.New().String(),
+		OrderId:            o.order.OrderId,
+		TradeTime:          now,
+		TradeType:          "FILL",
+		Price:              formatFloat(value / size),
+		Size:               formatFloat(size),
+		Commission:         formatFloat(fee),
+		ProductId:          o.order.ProductId,
+		SequenceTimestamp:  now,
+		LiquidityIndicator: liquidity,
+		SizeInQuote:        o.order.SizeInQuote,
+		Side:               o.order.Side,
+	})
+
+	p.refresh(o)
+}
+
+func (p *PaperClient) finish(o *paperOrder, status string) {
+	p.release(o)
+	o.order.Status = status
+	o.order.Settled = true
+	p.refresh(o)
+}
+
+// refresh derives the order's reported fill fields from its running totals.
+func (p *PaperClient) refresh(o *paperOrder) {
+
+	o.order.FilledSize = formatFloat(o.filledSize)
+	o.order.FilledValue = formatFloat(o.filledValue)
+	o.order.NumberOfFills = fmt.Sprintf("%d", o.fills)
+	o.order.TotalFees = formatFloat(o.fees)
+	o.order.OutstandingHoldAmount = formatFloat(o.hold)
+
+	if o.filledSize > 0 {
+		o.order.AverageFilledPrice = formatFloat(o.filledValue / o.filledSize)
+	} else {
+		o.order.AverageFilledPrice = "0"
+	}
+
+	if o.buy() {
+		o.order.TotalValueAfterFees = formatFloat(o.filledValue + o.fees)
+	} else {
+		o.order.TotalValueAfterFees = formatFloat(o.filledValue - o.fees)
+	}
+
+	completion := 0.0
+	if o.quoteSize > 0 {
+		completion = o.filledValue / o.quoteSize * 100
+	} else if o.baseSize > 0 {
+		completion = o.filledSize / o.baseSize * 100
+	}
+	if o.order.Status == "FILLED" {
+		completion = 100
+	}
+	o.order.CompletionPercentage = formatFloat(math.Min(completion, 100))
+}
+
+// sync expires good-until-date orders, triggers stops and fills resting orders whose price the
+// top of book has reached. Resting orders fill as makers at their limit price; stop limit orders
+// that are marketable when triggered and bracket stop legs fill as takers at the touch.
+func (p *PaperClient) sync(ctx context.Context) error {
+
+	now := p.now()
+
+	p.mu.Lock()
+	var productIds []string
+	seen := make(map[string]bool)
+	for _, o := range p.orders {
+		if !o.open() {
+			continue
+		}
+		if !o.endTime.IsZero() && !now.Before(o.endTime) {
+			p.finish(o, "EXPIRED")
+			continue
+		}
+		if !seen[o.order.ProductId] {
+			seen[o.order.ProductId] = true
+			productIds = append(productIds, o.order.ProductId)
+		}
+	}
+	p.mu.Unlock()
+
+	if len(productIds) == 0 {
+		return nil
+	}
+
+	response, err := p.marketData.GetBestBidAsk(ctx, &GetBestBidAskRequest{ProductIds: productIds})
+	if err != nil {
+		return fmt.Errorf("unable to get best bid/ask: %w", err)
+	}
+
+	tops := make(map[string]paperExecution)
+	if response.PriceBooks != nil {
+		for _, book := range *response.PriceBooks {
+			if len(book.Bids) == 0 || len(book.Asks) == 0 {
+				continue
+			}
+			bid, err := parseFloat(book.Bids[0].Price)
+			if err != nil {
+				return fmt.Errorf("invalid bid price %s: %w", book.Bids[0].Price, err)
+			}
+			ask, err := parseFloat(book.Asks[0].Price)
+			if err != nil {
+				return fmt.Errorf("invalid ask price %s: %w", book.Asks[0].Price, err)
+			}
+			tops[book.ProductId] = paperExecution{bid: bid, ask: ask}
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, o := range p.orders {
+		if top, ok := tops[o.order.ProductId]; ok && o.open() {
+			p.match(o, top.bid, top.ask, now)
+		}
+	}
+
+	return nil
+}
+
+func (p *PaperClient) match(o *paperOrder, bid, ask float64, now time.Time) {
+
+	remaining := o.baseSize - o.filledSize
+	touch := ask
+	if !o.buy() {
+		touch = bid
+	}
+
+	reached := func(price float64) bool {
+		if o.buy() {
+			return touch <= price
+		}
+		return touch >= price
+	}
+
+	switch o.order.OrderType {
+
+	case paperOrderTypeLimit:
+		if reached(o.limitPrice) {
+			p.execute(o, remaining, remaining*o.limitPrice, paperLiquidityMaker, now)
+		}
+
+	case paperOrderTypeStopLimit:
+		if o.order.TriggerStatus == "STOP_PENDING" {
+			if !paperStopTriggered(o, (bid+ask)/2) {
+				return
+			}
+			o.order.TriggerStatus = "STOP_TRIGGERED"
+			if reached(o.limitPrice) {
+				p.execute(o, remaining, remaining*p.slipped(o, touch), paperLiquidityTaker, now)
+			}
+		} else if reached(o.limitPrice) {
+			p.execute(o, remaining, remaining*o.limitPrice, paperLiquidityMaker, now)
+		}
+
+	case paperOrderTypeBracket:
+		if reached(o.limitPrice) {
+			p.execute(o, remaining, remaining*o.limitPrice, paperLiquidityMaker, now)
+		} else if (o.buy() && touch >= o.stopPrice) || (!o.buy() && touch <= o.stopPrice) {
+			o.order.TriggerStatus = "STOP_TRIGGERED"
+			price := touch * (1 + p.slippage)
+			if !o.buy() {
+				price = touch * (1 - p.slippage)
+			}
+			p.execute(o, remaining, remaining*price, paperLiquidityTaker, now)
+		}
+	}
+
+	if o.filledSize >= o.baseSize-paperSizeEpsilon {
+		p.finish(o, "FILLED")
+	}
+}
+
+// paperStopTriggered reports whether the reference price has reached the stop. Orders without a
+// direction trigger upwards for buys and downwards for sells.
+func paperStopTriggered(o *paperOrder, reference float64) bool {
+
+	direction := o.stopDirection
+	if len(direction) == 0 {
+		direction = StopDirectionStopDown
+		if o.buy() {
+			direction = StopDirectionStopUp
+		}
+	}
+
+	if direction == StopDirectionStopUp {
+		return reference >= o.stopPrice
+	}
+	return reference <= o.stopPrice
+}
+
+func paperOrderResponse(o *paperOrder, request *CreateOrderRequest) *CreateOrderResponse {
+	return &CreateOrderResponse{
+		Success: true,
+		OrderId: o.order.OrderId,
+		SuccessResponse: &SuccessResponse{
+			OrderId:       o.order.OrderId,
+			ProductId:     o.order.ProductId,
+			Side:          o.order.Side,
+			ClientOrderId: o.order.ClientOrderId,
+		},
+		OrderConfiguration: o.order.OrderConfiguration,
+		Request:            request,
+	}
+}
+
+func paperOrderFailure(reason string, request *CreateOrderRequest) *CreateOrderResponse {
+	return &CreateOrderResponse{
+		Success:       false,
+		FailureReason: reason,
+		ErrorResponse: &ErrorResponse{
+			Error:                 reason,
+			Message:               reason,
+			NewOrderFailureReason: reason,
+		},
+		OrderConfiguration: request.OrderConfiguration,
+		Request:            request,
+	}
+}
@@ -0,0 +1,396 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	PegReferenceBest = "BEST"
+	PegReferenceMid  = "MID"
+)
+
+type PegRequest struct {
+	ProductId          string        `json:"product_id"`
+	Side               string        `json:"side"`
+	BaseSize           string        `json:"base_size"`
+	Reference          string        `json:"reference"`
+	Offset             string        `json:"offset,omitempty"`
+	MinMove            string        `json:"min_move,omitempty"`
+	MinRepriceInterval time.Duration `json:"min_reprice_interval"`
+	PollInterval       time.Duration `json:"poll_interval"`
+	RetailPortfolioId  string        `json:"retail_portfolio_id,omitempty"`
+
+	// BookSource overrides the top of book, e.g. with a level 2 stream. By default GetBestBidAsk is
+	// polled.
+	BookSource func(ctx context.Context) (bid, ask float64, err error) `json:"-"`
+}
+
+type PegState struct {
+	OrderId      string  `json:"order_id"`
+	Status       string  `json:"status"`
+	Price        float64 `json:"price"`
+	Size         float64 `json:"size"`
+	FilledSize   float64 `json:"filled_size"`
+	Edits        int     `json:"edits"`
+	Replacements int     `json:"replacements"`
+	Order        *Order  `json:"order,omitempty"`
+}
+
+// Pegger keeps a post-only GTC limit order at the best price on its side of the book, optionally
+// offset away from it, or at the mid. Reprices are previewed and applied with EditOrder, falling
+// back to cancel/replace when the edit is rejected. The order is canceled if the context is done
+// before it fills.
+type Pegger struct {
+	client  Service
+	request *PegRequest
+
+	offset      float64
+	minMove     float64
+	rules       *productRules
+	lastReprice time.Time
+	filledPrior float64
+
+	mu    sync.Mutex
+	state PegState
+}
+
+func NewPegger(client Service, request *PegRequest) *Pegger {
+	return &Pegger{client: client, request: request}
+}
+
+func (p *Pegger) State() PegState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+func (p *Pegger) validate() error {
+	r := p.request
+
+	if len(r.ProductId) == 0 {
+		return errors.New("product id not set")
+	}
+
+	if r.Side != "BUY" && r.Side != "SELL" {
+		return fmt.Errorf("invalid side: %s", r.Side)
+	}
+
+	if r.Reference != PegReferenceBest && r.Reference != PegReferenceMid {
+		return fmt.Errorf("invalid reference: %s", r.Reference)
+	}
+
+	size, err := parseFloat(r.BaseSize)
+	if err != nil || size <= 0 {
+		return fmt.Errorf("invalid base size: %s", r.BaseSize)
+	}
+	p.state.Size = size
+
+	if p.offset, err = parseFloat(r.Offset); err != nil || p.offset < 0 {
+		return fmt.Errorf("invalid offset: %s", r.Offset)
+	}
+
+	if p.minMove, err = parseFloat(r.MinMove); err != nil || p.minMove < 0 {
+		return fmt.Errorf("invalid min move: %s", r.MinMove)
+	}
+
+	return nil
+}
+
+func (p *Pegger) Run(ctx context.Context) (PegState, error) {
+
+	if err := p.validate(); err != nil {
+		return PegState{}, err
+	}
+
+	err := p.run(ctx)
+	if err != nil && len(p.State().OrderId) > 0 && !isTerminalOrderStatus(p.State().Status) {
+		err = errors.Join(err, p.cancel())
+	}
+
+	return p.State(), err
+}
+
+func (p *Pegger) run(ctx context.Context) error {
+	r := p.request
+
+	rules, err := fetchProductRules(ctx, p.client, r.ProductId)
+	if err != nil {
+		return err
+	}
+	p.rules = rules
+
+	if p.minMove == 0 {
+		p.minMove, _ = parseFloat(rules.priceIncrement)
+	}
+
+	price, err := p.target(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := p.place(ctx, price, p.State().Size); err != nil {
+		return err
+	}
+
+	pollInterval := r.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultExecutionPollInterval
+	}
+
+	for {
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		done, err := p.refresh(ctx)
+		if err != nil || done {
+			return err
+		}
+
+		price, err := p.target(ctx)
+		if err != nil {
+			return err
+		}
+
+		state := p.State()
+		if math.Abs(price-state.Price) < p.minMove || time.Since(p.lastReprice) < r.MinRepriceInterval {
+			continue
+		}
+
+		if done, err := p.reprice(ctx, price); err != nil || done {
+			return err
+		}
+	}
+}
+
+// target returns the peg price for the current top of book. The price never crosses the spread,
+// so the post-only order is not rejected for taking liquidity.
+func (p *Pegger) target(ctx context.Context) (float64, error) {
+
+	var bid, ask float64
+	var err error
+	if p.request.BookSource != nil {
+		bid, ask, err = p.request.BookSource(ctx)
+	} else {
+		bid, ask, err = fetchBestBidAsk(ctx, p.client, p.request.ProductId)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	increment, _ := parseFloat(p.rules.priceIncrement)
+
+	var price float64
+	switch {
+	case p.request.Reference == PegReferenceMid && p.request.Side == "BUY":
+		price = (bid+ask)/2 - p.offset
+	case p.request.Reference == PegReferenceMid:
+		price = (bid+ask)/2 + p.offset
+	case p.request.Side == "BUY":
+		price = bid - p.offset
+	default:
+		price = ask + p.offset
+	}
+
+	if p.request.Side == "BUY" {
+		price, _ = parseFloat(floorToIncrement(price, p.rules.priceIncrement))
+		if price >= ask {
+			price = ask - increment
+		}
+	} else {
+		price, _ = parseFloat(ceilToIncrement(price, p.rules.priceIncrement))
+		if price <= bid {
+			price = bid + increment
+		}
+	}
+
+	return price, nil
+}
+
+func (p *Pegger) place(ctx context.Context, price, size float64) error {
+	r := p.request
+
+	response, err := p.client.CreateOrder(ctx, &CreateOrderRequest{
+		ProductId:     r.ProductId,
+		Side:          r.Side,
+		ClientOrderId: uuid.New().String(),
+		OrderConfiguration: OrderConfiguration{LimitLimitGtc: &LimitGtc{
+			BaseSize:   floorToIncrement(size, p.rules.baseIncrement),
+			LimitPrice: roundToIncrement(price, p.rules.priceIncrement),
+			PostOnly:   true,
+		}},
+		RetailPortfolioId: r.RetailPortfolioId,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to place pegged order: %w", err)
+	}
+
+	if !response.Success {
+		return fmt.Errorf("pegged order rejected: %s", createOrderFailure(response))
+	}
+
+	orderId := response.OrderId
+	if len(orderId) == 0 && response.SuccessResponse != nil {
+		orderId = response.SuccessResponse.OrderId
+	}
+
+	p.lastReprice = time.Now()
+
+	p.mu.Lock()
+	p.state.OrderId = orderId
+	p.state.Status = "OPEN"
+	p.state.Price = price
+	p.mu.Unlock()
+
+	return nil
+}
+
+// refresh polls the resting order and reports whether pegging has finished.
+func (p *Pegger) refresh(ctx context.Context) (bool, error) {
+
+	response, err := p.client.GetOrder(ctx, &GetOrderRequest{OrderId: p.State().OrderId})
+	if err != nil {
+		return false, fmt.Errorf("unable to get pegged order: %w", err)
+	}
+
+	filled, err := parseFloat(response.Order.FilledSize)
+	if err != nil {
+		return false, fmt.Errorf("invalid filled size %s: %w", response.Order.FilledSize, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.state.Order = response.Order
+	p.state.Status = response.Order.Status
+	p.state.FilledSize = p.filledPrior + filled
+
+	return isTerminalOrderStatus(response.Order.Status), nil
+}
+
+// reprice moves the order to price and reports whether pegging has finished because the order
+// could not be replaced.
+func (p *Pegger) reprice(ctx context.Context, price float64) (bool, error) {
+
+	state := p.State()
+	size := floorToIncrement(state.Size-p.filledPrior, p.rules.baseIncrement)
+	limitPrice := roundToIncrement(price, p.rules.priceIncrement)
+
+	preview, err := p.client.PreviewEditOrder(ctx, &PreviewEditOrderRequest{
+		OrderId: state.OrderId,
+		Price:   limitPrice,
+		Size:    size,
+	})
+	if err == nil && len(preview.EditErrors) == 0 {
+		edit, err := p.client.EditOrder(ctx, &EditOrderRequest{
+			OrderId: state.OrderId,
+			Price:   limitPrice,
+			Size:    size,
+		})
+		if errors.Is(err, ErrKillSwitchEngaged) {
+			return false, err
+		}
+		if err == nil && edit.Success && len(edit.EditErrors) == 0 {
+			p.lastReprice = time.Now()
+			p.mu.Lock()
+			p.state.Price = price
+			p.state.Edits++
+			p.mu.Unlock()
+			return false, nil
+		}
+	}
+
+	return p.replace(ctx, price)
+}
+
+func (p *Pegger) replace(ctx context.Context, price float64) (bool, error) {
+
+	state := p.State()
+
+	response, err := p.client.CancelOrders(ctx, &CancelOrdersRequest{OrderIds: []string{state.OrderId}})
+	if err != nil {
+		return false, fmt.Errorf("unable to cancel pegged order for replacement: %w", err)
+	}
+
+	for _, result := range response.Results {
+		if result.OrderId == state.OrderId && !result.Success {
+			// Most likely filled in the meantime; the next refresh picks up its status.
+			return false, nil
+		}
+	}
+
+	canceled, err := p.client.GetOrder(ctx, &GetOrderRequest{OrderId: state.OrderId})
+	if err != nil {
+		return false, fmt.Errorf("unable to get canceled pegged order: %w", err)
+	}
+
+	filled, err := parseFloat(canceled.Order.FilledSize)
+	if err != nil {
+		return false, fmt.Errorf("invalid filled size %s: %w", canceled.Order.FilledSize, err)
+	}
+
+	p.filledPrior += filled
+
+	p.mu.Lock()
+	p.state.FilledSize = p.filledPrior
+	p.state.Status = canceled.Order.Status
+	p.state.Order = canceled.Order
+	p.mu.Unlock()
+
+	remaining := state.Size - p.filledPrior
+	if remaining < p.rules.baseMinSize {
+		return true, nil
+	}
+
+	if err := p.place(ctx, price, remaining); err != nil {
+		return false, err
+	}
+
+	p.mu.Lock()
+	p.state.Replacements++
+	p.mu.Unlock()
+
+	return false, nil
+}
+
+func (p *Pegger) cancel() error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), cancelChildOrderTimeout)
+	defer cancel()
+
+	if _, err := p.client.CancelOrders(ctx, &CancelOrdersRequest{OrderIds: []string{p.State().OrderId}}); err != nil {
+		return fmt.Errorf("unable to cancel pegged order: %w", err)
+	}
+
+	p.mu.Lock()
+	p.state.Status = "CANCELLED"
+	p.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,279 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RiskKindLiquidationDistance   = "LIQUIDATION_DISTANCE"
+	RiskKindLiquidationBuffer     = "LIQUIDATION_BUFFER"
+	RiskKindLiquidationPercentage = "LIQUIDATION_PERCENTAGE"
+
+	defaultRiskPollInterval = 30 * time.Second
+)
+
+type PerpetualsMonitorRequest struct {
+	PortfolioUuid string        `json:"portfolio_uuid"`
+	PollInterval  time.Duration `json:"poll_interval"`
+
+	// LiquidationDistance alerts when a position's mark price is within this fraction of its
+	// liquidation price.
+	LiquidationDistance float64 `json:"liquidation_distance,omitempty"`
+
+	// LiquidationBuffer alerts when the portfolio liquidation buffer drops below this amount.
+	LiquidationBuffer float64 `json:"liquidation_buffer,omitempty"`
+
+	// LiquidationPercentage alerts when the portfolio liquidation percentage rises above this level.
+	LiquidationPercentage float64 `json:"liquidation_percentage,omitempty"`
+
+	OnThreshold func(RiskEvent) `json:"-"`
+}
+
+// RiskEvent is raised when a monitored value crosses its threshold, with Breached set when it
+// enters the alert zone and cleared when it recovers.
+type RiskEvent struct {
+	Kind      string    `json:"kind"`
+	ProductId string    `json:"product_id,omitempty"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Breached  bool      `json:"breached"`
+	Time      time.Time `json:"time"`
+}
+
+type PerpetualsRiskSnapshot struct {
+	Time                  time.Time                 `json:"time"`
+	Portfolio             *IntxPortfolio            `json:"portfolio"`
+	LiquidationBuffer     float64                   `json:"liquidation_buffer"`
+	LiquidationPercentage float64                   `json:"liquidation_percentage"`
+	ProjectedFunding      float64                   `json:"projected_funding"`
+	Positions             []*PerpetualsPositionRisk `json:"positions"`
+}
+
+type PerpetualsPositionRisk struct {
+	Position    *IntxPosition `json:"position"`
+	FundingRate float64       `json:"funding_rate"`
+	FundingTime string        `json:"funding_time"`
+	Notional    float64       `json:"notional"`
+
+	// ProjectedFunding is the payment for the next funding period; negative values are paid.
+	ProjectedFunding float64 `json:"projected_funding"`
+
+	// LiquidationDistance is the distance from mark to liquidation price as a fraction of mark, or 1
+	// when no liquidation price is reported.
+	LiquidationDistance float64 `json:"liquidation_distance"`
+}
+
+// PerpetualsMonitor polls an INTX portfolio, projects funding for each open position and raises
+// RiskEvents as liquidation thresholds are crossed.
+type PerpetualsMonitor struct {
+	client  Service
+	request *PerpetualsMonitorRequest
+
+	mu       sync.Mutex
+	snapshot *PerpetualsRiskSnapshot
+	breached map[string]bool
+}
+
+func NewPerpetualsMonitor(client Service, request *PerpetualsMonitorRequest) *PerpetualsMonitor {
+	return &PerpetualsMonitor{
+		client:   client,
+		request:  request,
+		breached: make(map[string]bool),
+	}
+}
+
+func (m *PerpetualsMonitor) Snapshot() *PerpetualsRiskSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.snapshot
+}
+
+// Run polls until the context is done. Poll errors are returned immediately.
+func (m *PerpetualsMonitor) Run(ctx context.Context) error {
+
+	pollInterval := m.request.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultRiskPollInterval
+	}
+
+	for {
+		if _, err := m.Check(ctx); err != nil {
+			return err
+		}
+
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Check takes a single snapshot and evaluates the thresholds against it.
+func (m *PerpetualsMonitor) Check(ctx context.Context) (*PerpetualsRiskSnapshot, error) {
+
+	snapshot, err := perpetualsRiskSnapshot(ctx, m.client, m.request.PortfolioUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.snapshot = snapshot
+	m.mu.Unlock()
+
+	r := m.request
+
+	if r.LiquidationBuffer > 0 {
+		m.evaluate(RiskKindLiquidationBuffer, "", snapshot.LiquidationBuffer, r.LiquidationBuffer,
+			snapshot.LiquidationBuffer < r.LiquidationBuffer, snapshot.Time)
+	}
+
+	if r.LiquidationPercentage > 0 {
+		m.evaluate(RiskKindLiquidationPercentage, "", snapshot.LiquidationPercentage, r.LiquidationPercentage,
+			snapshot.LiquidationPercentage > r.LiquidationPercentage, snapshot.Time)
+	}
+
+	if r.LiquidationDistance > 0 {
+		for _, p := range snapshot.Positions {
+			m.evaluate(RiskKindLiquidationDistance, p.Position.ProductId, p.LiquidationDistance, r.LiquidationDistance,
+				p.LiquidationDistance < r.LiquidationDistance, snapshot.Time)
+		}
+	}
+
+	return snapshot, nil
+}
+
+func (m *PerpetualsMonitor) evaluate(kind, productId string, value, threshold float64, breached bool, t time.Time) {
+
+	key := kind + "|" + productId
+
+	m.mu.Lock()
+	changed := m.breached[key] != breached
+	m.breached[key] = breached
+	m.mu.Unlock()
+
+	if changed && m.request.OnThreshold != nil {
+		m.request.OnThreshold(RiskEvent{
+			Kind:      kind,
+			ProductId: productId,
+			Value:     value,
+			Threshold: threshold,
+			Breached:  breached,
+			Time:      t,
+		})
+	}
+}
+
+func perpetualsRiskSnapshot(ctx context.Context, client Service, portfolioUuid string) (*PerpetualsRiskSnapshot, error) {
+
+	summary, err := client.GetPerpetualsPortfolioSummary(ctx, &GetPerpetualsPortfolioSummaryRequest{PortfolioUuid: portfolioUuid})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get perpetuals portfolio summary: %w", err)
+	}
+
+	if summary.Portfolios == nil {
+		return nil, errors.New("perpetuals portfolio summary not returned")
+	}
+
+	positions, err := client.ListPerpetualsPositions(ctx, &ListPerpetualsPositionsRequest{PortfolioUuid: portfolioUuid})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list perpetuals positions: %w", err)
+	}
+
+	snapshot := &PerpetualsRiskSnapshot{Time: time.Now(), Portfolio: summary.Portfolios}
+
+	if snapshot.LiquidationBuffer, err = parseFloat(summary.Portfolios.LiquidationBuffer); err != nil {
+		return nil, fmt.Errorf("invalid liquidation buffer %s: %w", summary.Portfolios.LiquidationBuffer, err)
+	}
+
+	if snapshot.LiquidationPercentage, err = parseFloat(summary.Portfolios.LiquidationPercentage); err != nil {
+		return nil, fmt.Errorf("invalid liquidation percentage %s: %w", summary.Portfolios.LiquidationPercentage, err)
+	}
+
+	for _, position := range positions.Positions {
+		product, err := client.GetProduct(ctx, &GetProductRequest{ProductId: position.ProductId})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get product %s: %w", position.ProductId, err)
+		}
+
+		risk, err := perpetualsPositionRisk(position, product.FutureProductDetails.PerpetualDetails)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshot.Positions = append(snapshot.Positions, risk)
+		snapshot.ProjectedFunding += risk.ProjectedFunding
+	}
+
+	return snapshot, nil
+}
+
+func perpetualsPositionRisk(position *IntxPosition, details PerpetualDetails) (*PerpetualsPositionRisk, error) {
+
+	rate, err := parseFloat(details.FundingRate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid funding rate %s: %w", details.FundingRate, err)
+	}
+
+	mark, err := parseFloat(position.MarkPrice.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mark price %s: %w", position.MarkPrice.Value, err)
+	}
+
+	liquidation, err := parseFloat(position.LiquidationPrice.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid liquidation price %s: %w", position.LiquidationPrice.Value, err)
+	}
+
+	size, err := parseFloat(position.NetSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid net size %s: %w", position.NetSize, err)
+	}
+
+	notional := math.Abs(size) * mark
+
+	// Longs pay shorts when the funding rate is positive.
+	direction := 1.0
+	if size < 0 || strings.Contains(strings.ToUpper(position.PositionSide), "SHORT") {
+		direction = -1.0
+	}
+
+	risk := &PerpetualsPositionRisk{
+		Position:         position,
+		FundingRate:      rate,
+		FundingTime:      details.FundingTime,
+		Notional:         notional,
+		ProjectedFunding: -direction * notional * rate,
+	}
+
+	risk.LiquidationDistance = 1
+	if mark > 0 && liquidation > 0 {
+		risk.LiquidationDistance = math.Abs(mark-liquidation) / mark
+	}
+
+	return risk, nil
+}
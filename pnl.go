@@ -0,0 +1,375 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	CostBasisFifo    = "FIFO"
+	CostBasisLifo    = "LIFO"
+	CostBasisHifo    = "HIFO"
+	CostBasisAverage = "AVERAGE"
+
+	quantityEpsilon = 1e-12
+)
+
+// TaxLot is an open position acquired by a single fill. UnitCost includes the fee paid when the
+// lot was opened; for short lots it is the unit proceeds net of the fee.
+type TaxLot struct {
+	ProductId string    `json:"product_id"`
+	TradeId   string    `json:"trade_id"`
+	Time      time.Time `json:"time"`
+	Quantity  float64   `json:"quantity"`
+	UnitCost  float64   `json:"unit_cost"`
+	Short     bool      `json:"short"`
+}
+
+// Disposal is the part of a lot closed by a later fill. Fees on both sides are included in
+// CostBasis and Proceeds.
+type Disposal struct {
+	ProductId    string    `json:"product_id"`
+	OpenTradeId  string    `json:"open_trade_id"`
+	CloseTradeId string    `json:"close_trade_id"`
+	OpenTime     time.Time `json:"open_time"`
+	CloseTime    time.Time `json:"close_time"`
+	Quantity     float64   `json:"quantity"`
+	CostBasis    float64   `json:"cost_basis"`
+	Proceeds     float64   `json:"proceeds"`
+	Gain         float64   `json:"gain"`
+	Short        bool      `json:"short"`
+}
+
+type ProductPnl struct {
+	ProductId     string   `json:"product_id"`
+	Quantity      float64  `json:"quantity"`
+	CostBasis     float64  `json:"cost_basis"`
+	AverageCost   float64  `json:"average_cost"`
+	RealizedPnl   float64  `json:"realized_pnl"`
+	UnrealizedPnl float64  `json:"unrealized_pnl"`
+	MarkPrice     float64  `json:"mark_price"`
+	Fees          float64  `json:"fees"`
+	BuyVolume     float64  `json:"buy_volume"`
+	SellVolume    float64  `json:"sell_volume"`
+	Lots          []TaxLot `json:"lots"`
+}
+
+// PnlEngine computes realized and unrealized PnL per product from fills using the selected
+// cost-basis method. Fills can be added incrementally and in any order; duplicates are ignored and
+// a fill older than the latest applied one causes the history to be replayed.
+type PnlEngine struct {
+	method string
+
+	mu        sync.Mutex
+	fills     []*Fill
+	seen      map[string]bool
+	latest    time.Time
+	products  map[string]*pnlBook
+	disposals []Disposal
+	marks     map[string]float64
+}
+
+type pnlBook struct {
+	lots       []*TaxLot
+	realized   float64
+	fees       float64
+	buyVolume  float64
+	sellVolume float64
+}
+
+func NewPnlEngine(method string) (*PnlEngine, error) {
+
+	switch method {
+	case CostBasisFifo, CostBasisLifo, CostBasisHifo, CostBasisAverage:
+	default:
+		return nil, fmt.Errorf("unknown cost basis method: %s", method)
+	}
+
+	return &PnlEngine{
+		method:   method,
+		seen:     make(map[string]bool),
+		products: make(map[string]*pnlBook),
+		marks:    make(map[string]float64),
+	}, nil
+}
+
+func (e *PnlEngine) AddFills(fills ...*Fill) error {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// The whole batch is validated before any fill is marked as seen, so that a rejected batch can be
+	// retried in full.
+	var added []*Fill
+	batch := make(map[string]bool)
+	for _, f := range fills {
+		key := fillKey(f)
+		if e.seen[key] || batch[key] {
+			continue
+		}
+		if _, _, _, err := fillValues(f); err != nil {
+			return err
+		}
+		if f.Side != "BUY" && f.Side != "SELL" {
+			return fmt.Errorf("invalid side %s for fill %s", f.Side, key)
+		}
+		batch[key] = true
+		added = append(added, f)
+	}
+
+	if len(added) == 0 {
+		return nil
+	}
+
+	for key := range batch {
+		e.seen[key] = true
+	}
+
+	sortFills(added)
+	replay := added[0].TradeTime.Before(e.latest)
+
+	e.fills = append(e.fills, added...)
+
+	if replay {
+		sortFills(e.fills)
+		e.products = make(map[string]*pnlBook)
+		e.disposals = nil
+		added = e.fills
+	}
+
+	for _, f := range added {
+		e.apply(f)
+	}
+
+	e.latest = e.fills[len(e.fills)-1].TradeTime
+
+	return nil
+}
+
+// Mark sets the price used to value the open lots of a product.
+func (e *PnlEngine) Mark(productId string, price float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.marks[productId] = price
+}
+
+func (e *PnlEngine) Position(productId string) *ProductPnl {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	book, ok := e.products[productId]
+	if !ok {
+		return nil
+	}
+	return e.summarize(productId, book)
+}
+
+func (e *PnlEngine) Positions() []*ProductPnl {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var positions []*ProductPnl
+	for productId, book := range e.products {
+		positions = append(positions, e.summarize(productId, book))
+	}
+
+	sort.Slice(positions, func(i, j int) bool { return positions[i].ProductId < positions[j].ProductId })
+
+	return positions
+}
+
+func (e *PnlEngine) Disposals() []Disposal {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Disposal(nil), e.disposals...)
+}
+
+func (e *PnlEngine) summarize(productId string, book *pnlBook) *ProductPnl {
+
+	p := &ProductPnl{
+		ProductId:   productId,
+		RealizedPnl: book.realized,
+		Fees:        book.fees,
+		BuyVolume:   book.buyVolume,
+		SellVolume:  book.sellVolume,
+	}
+
+	mark, marked := e.marks[productId]
+	if marked {
+		p.MarkPrice = mark
+	}
+
+	for _, lot := range book.lots {
+		p.Lots = append(p.Lots, *lot)
+		if lot.Short {
+			p.Quantity -= lot.Quantity
+			p.CostBasis -= lot.Quantity * lot.UnitCost
+			if marked {
+				p.UnrealizedPnl += lot.Quantity * (lot.UnitCost - mark)
+			}
+		} else {
+			p.Quantity += lot.Quantity
+			p.CostBasis += lot.Quantity * lot.UnitCost
+			if marked {
+				p.UnrealizedPnl += lot.Quantity * (mark - lot.UnitCost)
+			}
+		}
+	}
+
+	if p.Quantity > quantityEpsilon || p.Quantity < -quantityEpsilon {
+		p.AverageCost = p.CostBasis / p.Quantity
+	}
+
+	return p
+}
+
+func (e *PnlEngine) apply(f *Fill) {
+
+	size, price, commission, _ := fillValues(f)
+
+	book, ok := e.products[f.ProductId]
+	if !ok {
+		book = &pnlBook{}
+		e.products[f.ProductId] = book
+	}
+
+	book.fees += commission
+
+	short := f.Side == "SELL"
+	if short {
+		book.sellVolume += size
+	} else {
+		book.buyVolume += size
+	}
+
+	feePerUnit := 0.0
+	if size > 0 {
+		feePerUnit = commission / size
+	}
+
+	remaining := size
+	for remaining > quantityEpsilon {
+		i := e.selectLot(book.lots, !short)
+		if i < 0 {
+			break
+		}
+
+		lot := book.lots[i]
+		q := lot.Quantity
+		if remaining < q {
+			q = remaining
+		}
+
+		d := Disposal{
+			ProductId:    f.ProductId,
+			OpenTradeId:  lot.TradeId,
+			CloseTradeId: f.TradeId,
+			OpenTime:     lot.Time,
+			CloseTime:    f.TradeTime,
+			Quantity:     q,
+			Short:        lot.Short,
+		}
+
+		if lot.Short {
+			d.Proceeds = q * lot.UnitCost
+			d.CostBasis = q * (price + feePerUnit)
+		} else {
+			d.CostBasis = q * lot.UnitCost
+			d.Proceeds = q * (price - feePerUnit)
+		}
+		d.Gain = d.Proceeds - d.CostBasis
+
+		book.realized += d.Gain
+		e.disposals = append(e.disposals, d)
+
+		lot.Quantity -= q
+		remaining -= q
+		if lot.Quantity <= quantityEpsilon {
+			book.lots = append(book.lots[:i], book.lots[i+1:]...)
+		}
+	}
+
+	if remaining <= quantityEpsilon {
+		return
+	}
+
+	unitCost := price + feePerUnit
+	if short {
+		unitCost = price - feePerUnit
+	}
+
+	lot := &TaxLot{
+		ProductId: f.ProductId,
+		TradeId:   f.TradeId,
+		Time:      f.TradeTime,
+		Quantity:  remaining,
+		UnitCost:  unitCost,
+		Short:     short,
+	}
+
+	if e.method == CostBasisAverage && len(book.lots) > 0 {
+		pooled := book.lots[0]
+		total := pooled.Quantity + lot.Quantity
+		pooled.UnitCost = (pooled.Quantity*pooled.UnitCost + lot.Quantity*lot.UnitCost) / total
+		pooled.Quantity = total
+		return
+	}
+
+	book.lots = append(book.lots, lot)
+}
+
+// selectLot returns the index of the next short or long lot to close, or -1 if no lot of that side
+// is open. Only lots of one side can be open at a time.
+func (e *PnlEngine) selectLot(lots []*TaxLot, short bool) int {
+
+	if len(lots) == 0 || lots[0].Short != short {
+		return -1
+	}
+
+	switch e.method {
+	case CostBasisLifo:
+		return len(lots) - 1
+	case CostBasisHifo:
+		// Highest cost for long lots and lowest proceeds for short lots minimise the realized gain.
+		best := 0
+		for i, lot := range lots {
+			if (!short && lot.UnitCost > lots[best].UnitCost) || (short && lot.UnitCost < lots[best].UnitCost) {
+				best = i
+			}
+		}
+		return best
+	}
+
+	return 0
+}
+
+func fillKey(f *Fill) string {
+	if len(f.EntryId) > 0 {
+		return f.EntryId
+	}
+	return f.TradeId + "|" + f.OrderId
+}
+
+func sortFills(fills []*Fill) {
+	sort.SliceStable(fills, func(i, j int) bool {
+		return fills[i].TradeTime.Before(fills[j].TradeTime)
+	})
+}
@@ -0,0 +1,352 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxAccountsPageSize = "250"
+
+type PortfolioSnapshot struct {
+	PortfolioUuid string    `json:"portfolio_uuid"`
+	Time          time.Time `json:"time"`
+
+	TotalUsdValue        float64 `json:"total_usd_value"`
+	SpotUsdValue         float64 `json:"spot_usd_value"`
+	CashUsd              float64 `json:"cash_usd"`
+	FuturesCashUsd       float64 `json:"futures_cash_usd"`
+	PerpsCollateralUsd   float64 `json:"perps_collateral_usd"`
+	FuturesUnrealizedPnl float64 `json:"futures_unrealized_pnl"`
+	PerpsUnrealizedPnl   float64 `json:"perps_unrealized_pnl"`
+
+	Assets []*AssetExposure `json:"assets"`
+
+	FuturesBalance *BalanceSummary `json:"futures_balance,omitempty"`
+	PerpsPortfolio *IntxPortfolio  `json:"perps_portfolio,omitempty"`
+
+	// Warnings lists the CFM and INTX sources that could not be read, e.g. because the portfolio
+	// has no access to them. Their figures are left at zero.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// AssetExposure nets spot balances with CFM futures and INTX perpetual positions on the same
+// underlying asset. Sizes are in units of the asset; values are in USD.
+type AssetExposure struct {
+	Asset            string  `json:"asset"`
+	IsCash           bool    `json:"is_cash"`
+	SpotBalance      float64 `json:"spot_balance"`
+	Available        float64 `json:"available"`
+	Hold             float64 `json:"hold"`
+	FuturesExposure  float64 `json:"futures_exposure"`
+	PerpsExposure    float64 `json:"perps_exposure"`
+	NetExposure      float64 `json:"net_exposure"`
+	UsdPrice         float64 `json:"usd_price"`
+	UsdValue         float64 `json:"usd_value"`
+	UnrealizedPnlUsd float64 `json:"unrealized_pnl_usd"`
+}
+
+// SnapshotPortfolio fetches accounts, the portfolio breakdown, the CFM balance summary and the INTX
+// portfolio summary concurrently and merges them into per-asset net exposure. Futures products are
+// looked up for their underlying asset and contract size when the breakdown leaves them out.
+func SnapshotPortfolio(ctx context.Context, client Service, portfolioUuid string) (*PortfolioSnapshot, error) {
+
+	var (
+		wg                    sync.WaitGroup
+		accounts              []*Account
+		breakdown             *GetPortfolioBreakdownResponse
+		futures               *GetFuturesBalanceSummaryResponse
+		perps                 *GetPerpetualsPortfolioSummaryResponse
+		accountsErr, breakErr error
+		futuresErr, perpsErr  error
+	)
+
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		accounts, accountsErr = listAllAccounts(ctx, client, portfolioUuid)
+	}()
+	go func() {
+		defer wg.Done()
+		breakdown, breakErr = client.GetPortfolioBreakdown(ctx, &GetPortfolioBreakdownRequest{PortfolioUuid: portfolioUuid})
+	}()
+	go func() {
+		defer wg.Done()
+		futures, futuresErr = client.GetFuturesBalanceSummary(ctx, &GetFuturesBalanceSummaryRequest{})
+	}()
+	go func() {
+		defer wg.Done()
+		perps, perpsErr = client.GetPerpetualsPortfolioSummary(ctx, &GetPerpetualsPortfolioSummaryRequest{PortfolioUuid: portfolioUuid})
+	}()
+	wg.Wait()
+
+	if accountsErr != nil {
+		return nil, fmt.Errorf("unable to list accounts: %w", accountsErr)
+	}
+
+	if breakErr != nil {
+		return nil, fmt.Errorf("unable to get portfolio breakdown: %w", breakErr)
+	}
+
+	if breakdown.Breakdown == nil {
+		return nil, fmt.Errorf("portfolio breakdown not returned for %s", portfolioUuid)
+	}
+
+	s := &snapshotBuilder{
+		snapshot: &PortfolioSnapshot{PortfolioUuid: portfolioUuid, Time: time.Now()},
+		assets:   make(map[string]*AssetExposure),
+	}
+
+	var contractsErr error
+	if s.contracts, contractsErr = futuresContracts(ctx, client, breakdown.Breakdown.FuturesPositions); contractsErr != nil {
+		s.warn("futures product details unavailable: %v", contractsErr)
+	}
+
+	s.addAccounts(accounts)
+	s.addBreakdown(breakdown.Breakdown)
+
+	if futuresErr != nil {
+		s.warn("futures balance summary unavailable: %v", futuresErr)
+	} else if futures.BalanceSummary != nil {
+		s.addFuturesBalance(futures.BalanceSummary)
+	}
+
+	if perpsErr != nil {
+		s.warn("perpetuals portfolio summary unavailable: %v", perpsErr)
+	} else if perps.Portfolios != nil {
+		s.addPerpsPortfolio(perps.Portfolios)
+	}
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return s.build(), nil
+}
+
+func listAllAccounts(ctx context.Context, client Service, portfolioUuid string) ([]*Account, error) {
+
+	request := &ListAccountsRequest{
+		RetailPortfolioId: portfolioUuid,
+		Pagination:        &PaginationParams{Limit: maxAccountsPageSize},
+	}
+
+	var accounts []*Account
+	for {
+		response, err := client.ListAccounts(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, response.Accounts...)
+
+		if !response.HasNext || len(response.Cursor) == 0 || response.Cursor == request.Pagination.Cursor {
+			return accounts, nil
+		}
+
+		request = &ListAccountsRequest{
+			RetailPortfolioId: portfolioUuid,
+			Pagination:        &PaginationParams{Cursor: response.Cursor, Limit: maxAccountsPageSize},
+		}
+	}
+}
+
+// futuresContract holds the product details needed to net a CFM futures position with spot.
+type futuresContract struct {
+	underlying   string
+	contractSize string
+}
+
+// futuresContracts looks up the futures products whose underlying asset or contract size the
+// breakdown leaves out, as product ids such as BIT-28JUN24-CDE do not name the asset. Products that
+// cannot be fetched are left out of the map and reported in the error.
+func futuresContracts(ctx context.Context, products ProductsService, positions []FuturesPosition) (map[string]*futuresContract, error) {
+
+	contracts := make(map[string]*futuresContract)
+
+	var errs []error
+	for _, p := range positions {
+		if _, ok := contracts[p.ProductId]; ok || (len(p.UnderlyingAsset) > 0 && len(p.ContractSize) > 0) {
+			continue
+		}
+
+		product, err := products.GetProduct(ctx, &GetProductRequest{ProductId: p.ProductId})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to get product %s: %w", p.ProductId, err))
+			continue
+		}
+
+		contracts[p.ProductId] = &futuresContract{
+			underlying:   product.FutureProductDetails.ContractRootUnit,
+			contractSize: product.FutureProductDetails.ContractSize,
+		}
+	}
+
+	return contracts, errors.Join(errs...)
+}
+
+type snapshotBuilder struct {
+	snapshot  *PortfolioSnapshot
+	assets    map[string]*AssetExposure
+	contracts map[string]*futuresContract
+	err       error
+}
+
+func (s *snapshotBuilder) asset(name string) *AssetExposure {
+	name = strings.ToUpper(name)
+	a, ok := s.assets[name]
+	if !ok {
+		a = &AssetExposure{Asset: name}
+		s.assets[name] = a
+	}
+	return a
+}
+
+func (s *snapshotBuilder) warn(format string, args ...interface{}) {
+	s.snapshot.Warnings = append(s.snapshot.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (s *snapshotBuilder) parse(field, v string) float64 {
+	f, err := parseFloat(v)
+	if err != nil && s.err == nil {
+		s.err = fmt.Errorf("invalid %s %s: %w", field, v, err)
+	}
+	return f
+}
+
+func (s *snapshotBuilder) addAccounts(accounts []*Account) {
+	for _, account := range accounts {
+		a := s.asset(account.Currency)
+		a.Available += s.parse("available balance", account.AvailableBalance.Value)
+		a.Hold += s.parse("hold", account.Hold.Value)
+	}
+}
+
+func (s *snapshotBuilder) addBreakdown(breakdown *Breakdown) {
+
+	s.snapshot.TotalUsdValue = s.parse("total balance", breakdown.PortfolioBalances.TotalBalance.Value)
+
+	for _, p := range breakdown.SpotPositions {
+		a := s.asset(p.Asset)
+		a.IsCash = p.IsCash
+		if p.TotalBalanceCrypto != 0 {
+			a.UsdPrice = p.TotalBalanceFiat / p.TotalBalanceCrypto
+		}
+
+		s.snapshot.SpotUsdValue += p.TotalBalanceFiat
+		if p.IsCash {
+			s.snapshot.CashUsd += p.TotalBalanceFiat
+		}
+	}
+
+	for _, p := range breakdown.FuturesPositions {
+		name, size := p.UnderlyingAsset, p.ContractSize
+		if c, ok := s.contracts[p.ProductId]; ok {
+			if len(name) == 0 {
+				name = c.underlying
+			}
+			if len(size) == 0 {
+				size = c.contractSize
+			}
+		}
+		if len(name) == 0 {
+			name = baseAsset(p.ProductId)
+		}
+
+		a := s.asset(name)
+		contracts := s.parse("futures amount", p.Amount)
+		contractSize := s.parse("contract size", size)
+		if contractSize == 0 {
+			contractSize = 1
+		}
+
+		a.FuturesExposure += positionSign(p.Side, contracts) * math.Abs(contracts) * contractSize
+		a.UnrealizedPnlUsd += s.parse("futures unrealized pnl", p.UnrealizedPnl)
+		if a.UsdPrice == 0 {
+			a.UsdPrice = s.parse("futures current price", p.CurrentPrice)
+		}
+	}
+
+	for _, p := range breakdown.PerpPositions {
+		a := s.asset(baseAsset(p.Symbol))
+		size := s.parse("perp net size", p.NetSize)
+
+		a.PerpsExposure += positionSign(p.PositionSide, size) * math.Abs(size)
+		a.UnrealizedPnlUsd += s.parse("perp unrealized pnl", p.UnrealizedPnl.UserNativeCurrency.Value)
+		if a.UsdPrice == 0 {
+			a.UsdPrice = s.parse("perp mark price", p.MarkPrice.UserNativeCurrency.Value)
+		}
+	}
+}
+
+func (s *snapshotBuilder) addFuturesBalance(balance *BalanceSummary) {
+	s.snapshot.FuturesBalance = balance
+	s.snapshot.FuturesCashUsd = s.parse("cfm usd balance", balance.CfmUsdBalance.Value)
+	s.snapshot.FuturesUnrealizedPnl = s.parse("futures unrealized pnl", balance.UnrealizedPnl.Value)
+}
+
+func (s *snapshotBuilder) addPerpsPortfolio(portfolio *IntxPortfolio) {
+	s.snapshot.PerpsPortfolio = portfolio
+	s.snapshot.PerpsCollateralUsd = s.parse("perps collateral", portfolio.Collateral)
+	s.snapshot.PerpsUnrealizedPnl = s.parse("perps unrealized pnl", portfolio.UnrealizedPnl.Value)
+}
+
+func (s *snapshotBuilder) build() *PortfolioSnapshot {
+
+	for _, a := range s.assets {
+		a.SpotBalance = a.Available + a.Hold
+		a.NetExposure = a.SpotBalance + a.FuturesExposure + a.PerpsExposure
+		a.UsdValue = a.NetExposure * a.UsdPrice
+		s.snapshot.Assets = append(s.snapshot.Assets, a)
+	}
+
+	sort.Slice(s.snapshot.Assets, func(i, j int) bool {
+		return s.snapshot.Assets[i].Asset < s.snapshot.Assets[j].Asset
+	})
+
+	return s.snapshot
+}
+
+// baseAsset returns the base currency of a product id such as BTC-USD or BTC-PERP-INTX.
+func baseAsset(productId string) string {
+	if i := strings.Index(productId, "-"); i > 0 {
+		return productId[:i]
+	}
+	return productId
+}
+
+// positionSign returns -1 for short positions, judged by the side when set and by the size
+// otherwise.
+func positionSign(side string, size float64) float64 {
+	side = strings.ToUpper(side)
+	switch {
+	case strings.Contains(side, "SHORT"):
+		return -1
+	case strings.Contains(side, "LONG"):
+		return 1
+	case size < 0:
+		return -1
+	}
+	return 1
+}
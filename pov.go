@@ -0,0 +1,186 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const AlgorithmPov = "POV"
+
+var errPovMaxDuration = errors.New("max duration reached")
+
+type PovRequest struct {
+	ProductId            string        `json:"product_id"`
+	Side                 string        `json:"side"`
+	TotalSize            string        `json:"total_size"`
+	ParticipationRate    float64       `json:"participation_rate"`
+	MaxParticipationRate float64       `json:"max_participation_rate"`
+	Interval             time.Duration `json:"interval"`
+	MaxDuration          time.Duration `json:"max_duration"`
+	OrderType            string        `json:"order_type"`
+	PollInterval         time.Duration `json:"poll_interval"`
+	RetailPortfolioId    string        `json:"retail_portfolio_id,omitempty"`
+}
+
+// PovExecutor trades a fixed share of the market volume printed since it started, not counting its
+// own fills. Volume that is too small to trade is carried forward, and no single child exceeds the
+// maximum participation of the volume seen in its interval.
+type PovExecutor struct {
+	*execution
+	client  Service
+	request *PovRequest
+}
+
+func NewPovExecutor(client Service, request *PovRequest) *PovExecutor {
+	return &PovExecutor{
+		execution: newExecution(),
+		client:    client,
+		request:   request,
+	}
+}
+
+func (e *PovExecutor) validate() (float64, error) {
+	r := e.request
+
+	if err := validateParentOrder(r.ProductId, r.Side, r.TotalSize, r.OrderType); err != nil {
+		return 0, err
+	}
+
+	if r.ParticipationRate <= 0 || r.ParticipationRate > 1 {
+		return 0, fmt.Errorf("invalid participation rate: %v", r.ParticipationRate)
+	}
+
+	if r.MaxParticipationRate != 0 && (r.MaxParticipationRate < r.ParticipationRate || r.MaxParticipationRate > 1) {
+		return 0, fmt.Errorf("invalid max participation rate: %v", r.MaxParticipationRate)
+	}
+
+	if r.Interval <= 0 {
+		return 0, errors.New("interval must be positive")
+	}
+
+	total, _ := parseFloat(r.TotalSize)
+	return total, nil
+}
+
+func (e *PovExecutor) Run(ctx context.Context) (*ExecutionReport, error) {
+
+	total, err := e.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := e.withCancel(ctx)
+	defer cancel()
+
+	if e.request.MaxDuration > 0 {
+		ctx, cancel = context.WithTimeoutCause(ctx, e.request.MaxDuration, errPovMaxDuration)
+		defer cancel()
+	}
+
+	e.start(ExecutionReport{
+		Algorithm:  AlgorithmPov,
+		ProductId:  e.request.ProductId,
+		Side:       e.request.Side,
+		TargetSize: total,
+	})
+
+	// Reaching MaxDuration ends the execution normally, but a deadline set by the caller does not.
+	err = e.run(ctx, total)
+	if errors.Is(err, context.DeadlineExceeded) && errors.Is(context.Cause(ctx), errPovMaxDuration) {
+		err = nil
+	}
+
+	return e.finish(err)
+}
+
+func (e *PovExecutor) run(ctx context.Context, total float64) error {
+	r := e.request
+
+	rules, err := fetchProductRules(ctx, e.client, r.ProductId)
+	if err != nil {
+		return err
+	}
+	e.setMinSize(rules.baseMinSize)
+
+	bid, ask, err := fetchBestBidAsk(ctx, e.client, r.ProductId)
+	if err != nil {
+		return fmt.Errorf("unable to get arrival price: %w", err)
+	}
+	e.setArrivalPrice((bid + ask) / 2)
+
+	maxRate := r.MaxParticipationRate
+	if maxRate == 0 {
+		maxRate = r.ParticipationRate
+	}
+
+	tracker := newMarketVolumeTracker(e.client, r.ProductId, time.Now())
+
+	var marketVolume, countedFills float64
+	for {
+		if err := e.sleep(ctx, r.Interval); err != nil {
+			return err
+		}
+
+		filled := e.filledSize()
+		remaining := total - filled
+		if remaining < rules.baseMinSize || remaining <= 0 {
+			return nil
+		}
+
+		volume, err := tracker.poll(ctx)
+		if err != nil {
+			return err
+		}
+
+		// The tape includes this executor's own fills since the previous poll.
+		volume = math.Max(0, volume-(filled-countedFills))
+		countedFills = filled
+		marketVolume += volume
+
+		ceiling := volume * maxRate
+		size := math.Min(marketVolume*r.ParticipationRate-filled, ceiling)
+		if size < rules.baseMinSize {
+			continue
+		}
+
+		if size = rules.clampSize(size, remaining, ceiling); size <= 0 {
+			continue
+		}
+
+		now := time.Now()
+		child, err := executeSlice(ctx, e.client, &executionSlice{
+			productId:         r.ProductId,
+			side:              r.Side,
+			orderType:         r.OrderType,
+			retailPortfolioId: r.RetailPortfolioId,
+			size:              size,
+			deadline:          now.Add(r.Interval),
+			pollInterval:      r.PollInterval,
+		}, rules)
+		if child != nil {
+			e.addChildOrder(child)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -22,9 +22,10 @@ import (
 )
 
 type PreviewEditOrderRequest struct {
-	OrderId string `json:"order_id"`
-	Price   string `json:"price"`
-	Size    string `json:"size"`
+	OrderId   string `json:"order_id"`
+	Price     string `json:"price"`
+	Size      string `json:"size"`
+	StopPrice string `json:"stop_price,omitempty"`
 }
 
 type PreviewEditOrderResponse struct {
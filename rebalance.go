@@ -0,0 +1,368 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultQuoteCurrency = "USD"
+
+type RebalanceRequest struct {
+	PortfolioUuid string `json:"portfolio_uuid"`
+	QuoteCurrency string `json:"quote_currency"`
+
+	// TargetWeights maps assets to their share of the managed value. Whatever is left of 1 is held
+	// in the quote currency. Assets without a target are left untouched and are not part of the
+	// managed value.
+	TargetWeights map[string]float64 `json:"target_weights"`
+
+	// Tolerance is the absolute drift from the target weight tolerated before trading.
+	Tolerance float64 `json:"tolerance"`
+
+	DryRun       bool          `json:"dry_run"`
+	PollInterval time.Duration `json:"poll_interval"`
+}
+
+type RebalanceResponse struct {
+	TotalValue float64             `json:"total_value"`
+	Holdings   []*RebalanceHolding `json:"holdings"`
+	Trades     []*RebalanceTrade   `json:"trades"`
+	Request    *RebalanceRequest   `json:"request"`
+}
+
+type RebalanceHolding struct {
+	Asset        string  `json:"asset"`
+	Quantity     float64 `json:"quantity"`
+	Available    float64 `json:"available"`
+	Price        float64 `json:"price"`
+	Value        float64 `json:"value"`
+	Weight       float64 `json:"weight"`
+	TargetWeight float64 `json:"target_weight"`
+	Drift        float64 `json:"drift"`
+}
+
+type RebalanceTrade struct {
+	ProductId  string                      `json:"product_id"`
+	Side       string                      `json:"side"`
+	BaseSize   string                      `json:"base_size"`
+	QuoteValue float64                     `json:"quote_value"`
+	Preview    *CreateOrderPreviewResponse `json:"preview,omitempty"`
+	Order      *Order                      `json:"order,omitempty"`
+	Skipped    string                      `json:"skipped,omitempty"`
+	Error      string                      `json:"error,omitempty"`
+}
+
+// Rebalance computes the spot trades needed to bring holdings back within tolerance of their target
+// weights, previews every trade and, unless DryRun is set, executes them as market orders with all
+// sells completing before any buy is placed. Sells are sized from the available balance, as held
+// funds cannot be sold, and no buy is placed unless every planned sell executed. Buys are previewed
+// again once the sells have filled, and an error is returned for every planned trade that was not
+// executed.
+func Rebalance(ctx context.Context, client Service, request *RebalanceRequest) (*RebalanceResponse, error) {
+
+	quote := strings.ToUpper(request.QuoteCurrency)
+	if len(quote) == 0 {
+		quote = defaultQuoteCurrency
+	}
+
+	targets := make(map[string]float64)
+	var targetSum float64
+	for asset, w := range request.TargetWeights {
+		if w < 0 {
+			return nil, fmt.Errorf("invalid target weight %v for %s", w, asset)
+		}
+		targets[strings.ToUpper(asset)] = w
+		targetSum += w
+	}
+
+	if targetSum > 1+1e-9 {
+		return nil, fmt.Errorf("target weights sum to %v, more than 1", targetSum)
+	}
+
+	if _, ok := targets[quote]; ok {
+		return nil, errors.New("the quote currency cannot have a target weight")
+	}
+	targets[quote] = 1 - targetSum
+
+	breakdown, err := client.GetPortfolioBreakdown(ctx, &GetPortfolioBreakdownRequest{PortfolioUuid: request.PortfolioUuid})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get portfolio breakdown: %w", err)
+	}
+
+	if breakdown.Breakdown == nil {
+		return nil, fmt.Errorf("portfolio breakdown not returned for %s", request.PortfolioUuid)
+	}
+
+	quantities := make(map[string]float64)
+	for _, p := range breakdown.Breakdown.SpotPositions {
+		quantities[strings.ToUpper(p.Asset)] += p.TotalBalanceCrypto
+	}
+
+	accounts, err := listAllAccounts(ctx, client, request.PortfolioUuid)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list accounts: %w", err)
+	}
+
+	available := make(map[string]float64)
+	for _, a := range accounts {
+		balance, err := parseFloat(a.AvailableBalance.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid available balance %s: %w", a.AvailableBalance.Value, err)
+		}
+		available[strings.ToUpper(a.Currency)] += balance
+	}
+
+	prices, err := rebalancePrices(ctx, client, targets, quote)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &RebalanceResponse{Request: request}
+
+	for asset, target := range targets {
+		h := &RebalanceHolding{
+			Asset:        asset,
+			Quantity:     quantities[asset],
+			Available:    available[asset],
+			Price:        prices[asset],
+			TargetWeight: target,
+		}
+		h.Value = h.Quantity * h.Price
+		response.TotalValue += h.Value
+		response.Holdings = append(response.Holdings, h)
+	}
+
+	sort.Slice(response.Holdings, func(i, j int) bool { return response.Holdings[i].Asset < response.Holdings[j].Asset })
+
+	if response.TotalValue <= 0 {
+		return nil, errors.New("nothing to rebalance, managed value is zero")
+	}
+
+	for _, h := range response.Holdings {
+		h.Weight = h.Value / response.TotalValue
+		h.Drift = h.Weight - h.TargetWeight
+
+		if h.Asset == quote || math.Abs(h.Drift) <= request.Tolerance {
+			continue
+		}
+
+		trade, err := rebalanceTrade(ctx, client, h, quote, response.TotalValue, request.PortfolioUuid)
+		if err != nil {
+			return nil, err
+		}
+		response.Trades = append(response.Trades, trade)
+	}
+
+	// Sells first so that their proceeds fund the buys.
+	sort.SliceStable(response.Trades, func(i, j int) bool {
+		return response.Trades[i].Side == "SELL" && response.Trades[j].Side == "BUY"
+	})
+
+	if request.DryRun {
+		return response, nil
+	}
+
+	// Buys may rely on the proceeds of every sell, so they are only placed once all sells executed and
+	// are previewed again against the balances the sells left. Every trade that was planned but not
+	// executed is reported as an error.
+	var errs []error
+	var sellFailed bool
+	for _, trade := range response.Trades {
+		if trade.Side == "BUY" && sellFailed {
+			trade.Skipped = "not placed because a sell did not execute"
+			errs = append(errs, fmt.Errorf("%s %s: %s", trade.Side, trade.ProductId, trade.Skipped))
+			continue
+		}
+
+		if len(trade.Skipped) > 0 {
+			continue
+		}
+
+		if trade.Side == "BUY" {
+			previewRebalanceTrade(ctx, client, trade, request.PortfolioUuid)
+		}
+
+		if len(trade.Error) > 0 {
+			errs = append(errs, fmt.Errorf("%s %s: %s", trade.Side, trade.ProductId, trade.Error))
+			if trade.Side == "SELL" {
+				sellFailed = true
+			}
+			continue
+		}
+
+		if err := executeRebalanceTrade(ctx, client, trade, request); err != nil {
+			trade.Error = err.Error()
+			errs = append(errs, fmt.Errorf("%s %s: %w", trade.Side, trade.ProductId, err))
+			if trade.Side == "SELL" {
+				sellFailed = true
+			}
+		}
+	}
+
+	return response, errors.Join(errs...)
+}
+
+func rebalancePrices(ctx context.Context, client Service, targets map[string]float64, quote string) (map[string]float64, error) {
+
+	prices := map[string]float64{quote: 1}
+
+	var productIds []string
+	for asset := range targets {
+		if asset != quote {
+			productIds = append(productIds, fmt.Sprintf("%s-%s", asset, quote))
+		}
+	}
+
+	if len(productIds) == 0 {
+		return prices, nil
+	}
+
+	response, err := client.GetBestBidAsk(ctx, &GetBestBidAskRequest{ProductIds: productIds})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get best bid/ask: %w", err)
+	}
+
+	if response.PriceBooks != nil {
+		for _, book := range *response.PriceBooks {
+			if len(book.Bids) == 0 || len(book.Asks) == 0 {
+				continue
+			}
+			bid, err := parseFloat(book.Bids[0].Price)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bid price %s: %w", book.Bids[0].Price, err)
+			}
+			ask, err := parseFloat(book.Asks[0].Price)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ask price %s: %w", book.Asks[0].Price, err)
+			}
+			prices[baseAsset(book.ProductId)] = (bid + ask) / 2
+		}
+	}
+
+	for asset := range targets {
+		if _, ok := prices[asset]; !ok {
+			return nil, fmt.Errorf("no price for %s-%s", asset, quote)
+		}
+	}
+
+	return prices, nil
+}
+
+func rebalanceTrade(
+	ctx context.Context,
+	client Service,
+	h *RebalanceHolding,
+	quote string,
+	total float64,
+	portfolioUuid string,
+) (*RebalanceTrade, error) {
+
+	productId := fmt.Sprintf("%s-%s", h.Asset, quote)
+
+	rules, err := fetchProductRules(ctx, client, productId)
+	if err != nil {
+		return nil, err
+	}
+
+	value := (h.TargetWeight - h.Weight) * total
+
+	trade := &RebalanceTrade{ProductId: productId, Side: "BUY", QuoteValue: math.Abs(value)}
+	if value < 0 {
+		trade.Side = "SELL"
+	}
+
+	size := math.Abs(value) / h.Price
+	if trade.Side == "SELL" {
+		size = math.Min(size, h.Available)
+	}
+
+	trade.BaseSize = floorToIncrement(size, rules.baseIncrement)
+	if rounded, _ := parseFloat(trade.BaseSize); rounded < rules.baseMinSize || rounded <= 0 {
+		trade.Skipped = fmt.Sprintf("size %s below minimum %v", trade.BaseSize, rules.baseMinSize)
+		return trade, nil
+	}
+
+	previewRebalanceTrade(ctx, client, trade, portfolioUuid)
+
+	return trade, nil
+}
+
+// previewRebalanceTrade previews trade against the portfolio, replacing any earlier preview and
+// recording a failed or rejected preview as the trade's error.
+func previewRebalanceTrade(ctx context.Context, client Service, trade *RebalanceTrade, portfolioUuid string) {
+
+	trade.Preview = nil
+	trade.Error = ""
+
+	preview, err := client.CreateOrderPreview(ctx, &CreateOrderPreviewRequest{
+		ProductId:          trade.ProductId,
+		Side:               trade.Side,
+		OrderConfiguration: OrderConfiguration{MarketMarketIoc: &MarketIoc{BaseSize: trade.BaseSize}},
+		RetailPortfolioId:  portfolioUuid,
+	})
+	if err != nil {
+		trade.Error = fmt.Sprintf("preview failed: %v", err)
+		return
+	}
+
+	trade.Preview = preview
+	if len(preview.Errs) > 0 {
+		trade.Error = fmt.Sprintf("preview errors: %s", strings.Join(preview.Errs, ", "))
+	}
+}
+
+func executeRebalanceTrade(ctx context.Context, client Service, trade *RebalanceTrade, request *RebalanceRequest) error {
+
+	response, err := client.CreateOrder(ctx, &CreateOrderRequest{
+		ProductId:          trade.ProductId,
+		Side:               trade.Side,
+		ClientOrderId:      uuid.New().String(),
+		OrderConfiguration: OrderConfiguration{MarketMarketIoc: &MarketIoc{BaseSize: trade.BaseSize}},
+		RetailPortfolioId:  request.PortfolioUuid,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !response.Success {
+		return fmt.Errorf("order rejected: %s", createOrderFailure(response))
+	}
+
+	orderId := response.OrderId
+	if len(orderId) == 0 && response.SuccessResponse != nil {
+		orderId = response.SuccessResponse.OrderId
+	}
+
+	order, err := awaitOrder(ctx, client, orderId, time.Time{}, request.PollInterval)
+	if order != nil {
+		trade.Order = order
+	}
+
+	if err == nil && order.Status != "FILLED" {
+		return fmt.Errorf("order %s ended with status %s", orderId, order.Status)
+	}
+
+	return err
+}
@@ -0,0 +1,270 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const calendarDay = 24 * time.Hour
+
+// defaultResampleOrigin is a Monday, so that day multiples of seven give weeks starting on Monday.
+// Month bars default to starting on the first of the month.
+var (
+	defaultResampleOrigin      = time.Date(1970, 1, 5, 0, 0, 0, 0, time.UTC)
+	defaultMonthResampleOrigin = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+type ResampleRequest struct {
+	// Interval is the bar length, e.g. 3*time.Minute, 4*time.Hour or 7*24*time.Hour. Multiples of a
+	// day are counted in calendar days in Location, so daily and weekly bars stay aligned to local
+	// midnight across daylight saving changes. Shorter bars have a fixed length.
+	Interval time.Duration `json:"interval,omitempty"`
+
+	// Months builds calendar month bars instead of Interval bars: 1 for monthly, 3 for quarterly
+	// bars starting in January, April, July and October.
+	Months int `json:"months,omitempty"`
+
+	// Location is the time zone bars are aligned in. Defaults to UTC.
+	Location *time.Location `json:"-"`
+
+	// Origin is the wall clock start of one bar, taken in Location. It defaults to midnight on a
+	// Monday, so weekly bars start on Mondays, or midnight on the first for month bars. A Sunday
+	// 17:00 origin gives weekly bars opening then.
+	Origin time.Time `json:"origin,omitempty"`
+}
+
+// barClock assigns times to the bars of a resample request.
+type barClock struct {
+	interval time.Duration
+	days     int
+	months   int
+	location *time.Location
+	origin   time.Time
+}
+
+func newBarClock(request *ResampleRequest) (*barClock, error) {
+
+	if request == nil {
+		return nil, errors.New("resample request not set")
+	}
+
+	if (request.Interval > 0) == (request.Months > 0) {
+		return nil, errors.New("exactly one of interval or months must be positive")
+	}
+
+	if request.Interval < 0 || request.Months < 0 {
+		return nil, errors.New("interval and months must not be negative")
+	}
+
+	c := &barClock{interval: request.Interval, months: request.Months, location: request.Location}
+	if c.location == nil {
+		c.location = time.UTC
+	}
+
+	origin := request.Origin
+	if origin.IsZero() {
+		origin = defaultResampleOrigin
+		if c.months > 0 {
+			origin = defaultMonthResampleOrigin
+		}
+	}
+	c.origin = time.Date(origin.Year(), origin.Month(), origin.Day(), origin.Hour(), origin.Minute(), origin.Second(), origin.Nanosecond(), c.location)
+
+	if c.interval > 0 && c.interval%calendarDay == 0 {
+		c.days = int(c.interval / calendarDay)
+	}
+
+	return c, nil
+}
+
+// start returns the start of the bar containing t.
+func (c *barClock) start(t time.Time) time.Time {
+
+	local := t.In(c.location)
+	clock := c.origin.Sub(time.Date(c.origin.Year(), c.origin.Month(), c.origin.Day(), 0, 0, 0, 0, c.location))
+
+	switch {
+	case c.months > 0:
+		shifted := local.Add(-clock)
+		if shifted.Day() < c.origin.Day() {
+			shifted = shifted.AddDate(0, -1, 0)
+		}
+		months := floorDiv(shifted.Year()*12+int(shifted.Month())-1-(c.origin.Year()*12+int(c.origin.Month())-1), c.months) * c.months
+		return time.Date(c.origin.Year(), c.origin.Month()+time.Month(months), c.origin.Day(), c.origin.Hour(), c.origin.Minute(), c.origin.Second(), c.origin.Nanosecond(), c.location)
+
+	case c.days > 0:
+		shifted := local.Add(-clock)
+		days := floorDiv(civilDays(shifted)-civilDays(c.origin), c.days) * c.days
+		return time.Date(c.origin.Year(), c.origin.Month(), c.origin.Day()+days, c.origin.Hour(), c.origin.Minute(), c.origin.Second(), c.origin.Nanosecond(), c.location)
+	}
+
+	n := int64(math.Floor(float64(t.Sub(c.origin)) / float64(c.interval)))
+	return c.origin.Add(time.Duration(n) * c.interval)
+}
+
+// civilDays counts calendar days since the UNIX epoch for the date of t in its own location.
+func civilDays(t time.Time) int {
+	return int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / int64(calendarDay/time.Second))
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+type bar struct {
+	start  time.Time
+	open   float64
+	high   float64
+	low    float64
+	close  float64
+	volume float64
+}
+
+func (b *bar) candle() Candle {
+	return Candle{
+		Start:  unixTimestamp(b.start),
+		Low:    formatFloat(b.low),
+		High:   formatFloat(b.high),
+		Open:   formatFloat(b.open),
+		Close:  formatFloat(b.close),
+		Volume: formatFloat(b.volume),
+	}
+}
+
+// ResampleCandles aggregates candles into bars of the requested interval, which should be a
+// multiple of the source granularity. Bars are returned oldest first; bars without source candles
+// are omitted, as the API omits candles without trades, and the newest bar may be incomplete.
+func ResampleCandles(candles []Candle, request *ResampleRequest) ([]Candle, error) {
+
+	clock, err := newBarClock(request)
+	if err != nil {
+		return nil, err
+	}
+
+	type source struct {
+		start                          time.Time
+		open, high, low, close, volume float64
+	}
+
+	sources := make([]source, 0, len(candles))
+	for _, c := range candles {
+		start, err := c.StartTime()
+		if err != nil {
+			return nil, err
+		}
+		s := source{start: start}
+		if s.open, s.high, s.low, s.close, s.volume, err = c.Ohlcv(); err != nil {
+			return nil, err
+		}
+		sources = append(sources, s)
+	}
+
+	sort.SliceStable(sources, func(i, j int) bool { return sources[i].start.Before(sources[j].start) })
+
+	var bars []Candle
+	var current *bar
+
+	for _, s := range sources {
+		start := clock.start(s.start)
+		if current == nil || !start.Equal(current.start) {
+			if current != nil {
+				bars = append(bars, current.candle())
+			}
+			current = &bar{start: start, open: s.open, high: s.high, low: s.low}
+		}
+		current.high = math.Max(current.high, s.high)
+		current.low = math.Min(current.low, s.low)
+		current.close = s.close
+		current.volume += s.volume
+	}
+
+	if current != nil {
+		bars = append(bars, current.candle())
+	}
+
+	return bars, nil
+}
+
+// CandlesFromTrades builds bars from a trade tape such as GetMarketTrades returns, newest or oldest
+// first. Bars are returned oldest first and bars without trades are omitted.
+func CandlesFromTrades(trades []Trade, request *ResampleRequest) ([]Candle, error) {
+
+	clock, err := newBarClock(request)
+	if err != nil {
+		return nil, err
+	}
+
+	type print struct {
+		time  time.Time
+		price float64
+		size  float64
+	}
+
+	// Keep tape order for trades sharing a timestamp once the tape runs oldest first.
+	newestFirst := len(trades) > 1 && trades[0].Time.After(trades[len(trades)-1].Time)
+
+	prints := make([]print, 0, len(trades))
+	for i := range trades {
+		t := trades[i]
+		if newestFirst {
+			t = trades[len(trades)-1-i]
+		}
+		price, err := parseFloat(t.Price)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trade price %s: %w", t.Price, err)
+		}
+		size, err := parseFloat(t.Size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trade size %s: %w", t.Size, err)
+		}
+		prints = append(prints, print{time: t.Time, price: price, size: size})
+	}
+
+	sort.SliceStable(prints, func(i, j int) bool { return prints[i].time.Before(prints[j].time) })
+
+	var bars []Candle
+	var current *bar
+
+	for _, p := range prints {
+		start := clock.start(p.time)
+		if current == nil || !start.Equal(current.start) {
+			if current != nil {
+				bars = append(bars, current.candle())
+			}
+			current = &bar{start: start, open: p.price, high: p.price, low: p.price}
+		}
+		current.high = math.Max(current.high, p.price)
+		current.low = math.Min(current.low, p.price)
+		current.close = p.price
+		current.volume += p.size
+	}
+
+	if current != nil {
+		bars = append(bars, current.candle())
+	}
+
+	return bars, nil
+}
@@ -35,6 +35,10 @@ func (c Client) ScheduleFuturesSweep(
 	request *ScheduleFuturesSweepRequest,
 ) (*ScheduleFuturesSweepResponse, error) {
 
+	if err := c.checkKillSwitch("ScheduleFuturesSweep"); err != nil {
+		return nil, err
+	}
+
 	path := fmt.Sprint("/brokerage/cfm/sweeps/schedule")
 
 	response := &ScheduleFuturesSweepResponse{Request: request}
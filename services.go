@@ -0,0 +1,102 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import "context"
+
+//go:generate go run ./internal/mockgen -source services.go -output advmock/mocks.go
+
+// Service is the complete Advanced Trade API grouped by domain. Client implements it; code that
+// depends on the interfaces instead of Client can be given a mock, a paper-trading backend or a
+// decorator.
+type Service interface {
+	OrdersService
+	AccountsService
+	ProductsService
+	PortfoliosService
+	FuturesService
+	PerpetualsService
+	ConvertService
+}
+
+type OrdersService interface {
+	CreateOrder(ctx context.Context, request *CreateOrderRequest) (*CreateOrderResponse, error)
+	CreateOrderPreview(ctx context.Context, request *CreateOrderPreviewRequest) (*CreateOrderPreviewResponse, error)
+	EditOrder(ctx context.Context, request *EditOrderRequest) (*EditOrderResponse, error)
+	PreviewEditOrder(ctx context.Context, request *PreviewEditOrderRequest) (*PreviewEditOrderResponse, error)
+	CancelOrders(ctx context.Context, request *CancelOrdersRequest) (*CancelOrdersResponse, error)
+	GetOrder(ctx context.Context, request *GetOrderRequest) (*GetOrderResponse, error)
+	ListOrders(ctx context.Context, request *ListOrdersRequest) (*ListOrdersResponse, error)
+	ListFills(ctx context.Context, request *ListFillsRequest) (*ListFillsResponse, error)
+	ClosePosition(ctx context.Context, request *ClosePositionRequest) (*ClosePositionResponse, error)
+}
+
+type AccountsService interface {
+	ListAccounts(ctx context.Context, request *ListAccountsRequest) (*ListAccountsResponse, error)
+	GetAccount(ctx context.Context, request *GetAccountRequest) (*GetAccountResponse, error)
+	GetTransactionsSummary(ctx context.Context, request *GetTransactionsSummaryRequest) (*GetTransactionsSummaryResponse, error)
+	ListPaymentMethods(ctx context.Context, request *ListPaymentMethodsRequest) (*ListPaymentMethodsResponse, error)
+	GetPaymentMethod(ctx context.Context, request *GetPaymentMethodRequest) (*GetPaymentMethodResponse, error)
+}
+
+type ProductsService interface {
+	ListProducts(ctx context.Context, request *ListProductsRequest) (*ListProductsResponse, error)
+	GetProduct(ctx context.Context, request *GetProductRequest) (*GetProductResponse, error)
+	GetProductBook(ctx context.Context, request *GetProductBookRequest) (*GetProductBookResponse, error)
+	GetBestBidAsk(ctx context.Context, request *GetBestBidAskRequest) (*GetBestBidAskResponse, error)
+	GetProductCandles(ctx context.Context, request *GetProductCandlesRequest) (*GetProductCandlesResponse, error)
+	GetMarketTrades(ctx context.Context, request *GetMarketTradesRequest) (*GetMarketTradesResponse, error)
+	ListPublicProducts(ctx context.Context, request *ListPublicProductsRequest) (*ListPublicProductsResponse, error)
+	GetPublicProduct(ctx context.Context, request *GetPublicProductRequest) (*GetPublicProductResponse, error)
+	GetPublicProductBook(ctx context.Context, request *GetPublicProductBookRequest) (*GetPublicProductBookResponse, error)
+	GetPublicProductCandles(ctx context.Context, request *GetPublicProductCandlesRequest) (*GetPublicProductCandlesResponse, error)
+	GetPublicMarketTrades(ctx context.Context, request *GetPublicMarketTradesRequest) (*GetPublicMarketTradesResponse, error)
+	GetServerTime(ctx context.Context, request *GetServerTimeRequest) (*GetServerTimeResponse, error)
+}
+
+type PortfoliosService interface {
+	ListPortfolios(ctx context.Context, request *ListPortfoliosRequest) (*ListPortfoliosResponse, error)
+	CreatePortfolio(ctx context.Context, request *CreatePortfolioRequest) (*CreatePortfolioResponse, error)
+	EditPortfolio(ctx context.Context, request *EditPortfolioRequest) (*EditPortfolioResponse, error)
+	DeletePortfolio(ctx context.Context, request *DeletePortfolioRequest) (*DeletePortfolioResponse, error)
+	GetPortfolioBreakdown(ctx context.Context, request *GetPortfolioBreakdownRequest) (*GetPortfolioBreakdownResponse, error)
+	MovePortfolioFunds(ctx context.Context, request *MovePortfolioFundsRequest) (*MovePortfolioFundsResponse, error)
+}
+
+type FuturesService interface {
+	GetFuturesBalanceSummary(ctx context.Context, request *GetFuturesBalanceSummaryRequest) (*GetFuturesBalanceSummaryResponse, error)
+	ListFuturesPositions(ctx context.Context, request *ListFuturesPositionsRequest) (*ListFuturesPositionsResponse, error)
+	GetFuturesPosition(ctx context.Context, request *GetFuturesPositionRequest) (*GetFuturesPositionResponse, error)
+	ScheduleFuturesSweep(ctx context.Context, request *ScheduleFuturesSweepRequest) (*ScheduleFuturesSweepResponse, error)
+	ListFuturesSweeps(ctx context.Context, request *ListFuturesSweepsRequest) (*ListFuturesSweepsResponse, error)
+	CancelPendingFuturesSweeps(ctx context.Context, request *CancelPendingFuturesSweepsRequest) (*CancelPendingFuturesSweepsResponse, error)
+}
+
+type PerpetualsService interface {
+	AllocatePortfolio(ctx context.Context, request *AllocatePortfolioRequest) (*AllocatePortfolioResponse, error)
+	GetPerpetualsPortfolioSummary(ctx context.Context, request *GetPerpetualsPortfolioSummaryRequest) (*GetPerpetualsPortfolioSummaryResponse, error)
+	ListPerpetualsPositions(ctx context.Context, request *ListPerpetualsPositionsRequest) (*ListPerpetualsPositionsResponse, error)
+	GetPerpetualsPosition(ctx context.Context, request *GetPerpetualsPositionRequest) (*GetPerpetualsPositionResponse, error)
+}
+
+type ConvertService interface {
+	CreateConvertQuote(ctx context.Context, request *CreateConvertQuoteRequest) (*CreateConvertQuoteResponse, error)
+	CommitConvertQuote(ctx context.Context, request *CommitConvertQuoteRequest) (*CommitConvertQuoteResponse, error)
+	GetConvertTrade(ctx context.Context, request *GetConvertTradeRequest) (*GetConvertTradeResponse, error)
+}
+
+var _ Service = Client{}
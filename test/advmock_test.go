@@ -0,0 +1,57 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advmock"
+	"testing"
+)
+
+func bestAsk(ctx context.Context, products adv.ProductsService, productId string) (string, error) {
+	response, err := products.GetBestBidAsk(ctx, &adv.GetBestBidAskRequest{ProductIds: []string{productId}})
+	if err != nil {
+		return "", err
+	}
+	return (*response.PriceBooks)[0].Asks[0].Price, nil
+}
+
+func TestMockService(t *testing.T) {
+	mock := advmock.NewMockService()
+	mock.GetBestBidAskFunc = func(ctx context.Context, request *adv.GetBestBidAskRequest) (*adv.GetBestBidAskResponse, error) {
+		books := []adv.PriceBook{{ProductId: request.ProductIds[0], Asks: []adv.Level{{Price: "101", Size: "1"}}}}
+		return &adv.GetBestBidAskResponse{PriceBooks: &books, Request: request}, nil
+	}
+
+	var service adv.Service = mock
+
+	ask, err := bestAsk(context.Background(), service, "BTC-USD")
+	if err != nil || ask != "101" {
+		t.Fatalf("unexpected ask %s: %v", ask, err)
+	}
+
+	calls := mock.GetBestBidAskCalls()
+	if len(calls) != 1 || calls[0].ProductIds[0] != "BTC-USD" {
+		t.Fatalf("unexpected calls: %+v", calls)
+	}
+
+	if _, err := service.CreateOrder(context.Background(), &adv.CreateOrderRequest{}); !errors.Is(err, advmock.ErrNotStubbed) {
+		t.Fatalf("expected unstubbed call to fail, got: %v", err)
+	}
+}
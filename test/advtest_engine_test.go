@@ -0,0 +1,201 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"github.com/google/uuid"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func parseTestFloat(v string) float64 {
+	f, _ := strconv.ParseFloat(v, 64)
+	return f
+}
+
+func placeOrder(t *testing.T, client *adv.Client, side string, configuration adv.OrderConfiguration) *adv.CreateOrderResponse {
+	t.Helper()
+	response, err := client.CreateOrder(context.Background(), &adv.CreateOrderRequest{
+		ProductId:          "BTC-USD",
+		Side:               side,
+		ClientOrderId:      uuid.New().String(),
+		OrderConfiguration: configuration,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func orderStatus(t *testing.T, server *advtest.Server, orderId string) adv.Order {
+	t.Helper()
+	order, ok := server.Order(orderId)
+	if !ok {
+		t.Fatalf("order %s not found", orderId)
+	}
+	return order
+}
+
+func TestMatchingEngineMarketSweep(t *testing.T) {
+	server := setupFakeServer(t)
+	server.SetBook("BTC-USD", nil, []adv.Level{{Price: "101", Size: "1"}, {Price: "102", Size: "1"}})
+
+	response := placeOrder(t, server.Client(), "BUY", adv.OrderConfiguration{MarketMarketIoc: &adv.MarketIoc{BaseSize: "1.5"}})
+
+	order := orderStatus(t, server, response.OrderId)
+	if order.Status != "FILLED" || order.NumberOfFills != "2" {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+	assertFloat(t, "average price", parseTestFloat(order.AverageFilledPrice), (101+0.5*102)/1.5)
+
+	book, err := server.Client().GetProductBook(context.Background(), &adv.GetProductBookRequest{ProductId: "BTC-USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(book.PriceBook.Asks) != 1 || book.PriceBook.Asks[0].Size != "0.5" {
+		t.Fatalf("unexpected asks: %+v", book.PriceBook.Asks)
+	}
+}
+
+func TestMatchingEnginePriceTimePriority(t *testing.T) {
+	server := setupFakeServer(t)
+	client := server.Client()
+
+	first := placeOrder(t, client, "BUY", adv.OrderConfiguration{LimitLimitGtc: &adv.LimitGtc{BaseSize: "1", LimitPrice: "100"}})
+	second := placeOrder(t, client, "BUY", adv.OrderConfiguration{LimitLimitGtc: &adv.LimitGtc{BaseSize: "1", LimitPrice: "100"}})
+
+	server.Trade("BTC-USD", "SELL", 100, 1.5)
+
+	if o := orderStatus(t, server, first.OrderId); o.Status != "FILLED" {
+		t.Fatalf("expected first order filled: %+v", o)
+	}
+	if o := orderStatus(t, server, second.OrderId); o.Status != "OPEN" || o.FilledSize != "0.5" {
+		t.Fatalf("expected second order partially filled: %+v", o)
+	}
+
+	for _, f := range server.Fills() {
+		if f.LiquidityIndicator != "MAKER" {
+			t.Fatalf("unexpected taker fill: %+v", f)
+		}
+		assertFloat(t, "maker commission", parseTestFloat(f.Commission), parseTestFloat(f.Size)*100*0.004)
+	}
+
+	// Raising the size loses priority while lowering it does not.
+	ctx := context.Background()
+	third := placeOrder(t, client, "BUY", adv.OrderConfiguration{LimitLimitGtc: &adv.LimitGtc{BaseSize: "1", LimitPrice: "100"}})
+	if _, err := client.EditOrder(ctx, &adv.EditOrderRequest{OrderId: second.OrderId, Price: "100", Size: "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Trade("BTC-USD", "SELL", 100, 1)
+
+	if o := orderStatus(t, server, third.OrderId); o.Status != "FILLED" {
+		t.Fatalf("expected third order to gain priority: %+v", o)
+	}
+}
+
+func TestMatchingEngineTimeInForce(t *testing.T) {
+	server := setupFakeServer(t)
+	client := server.Client()
+
+	fok := placeOrder(t, client, "BUY", adv.OrderConfiguration{LimitLimitFok: &adv.LimitFok{BaseSize: "20", LimitPrice: "101"}})
+	if o := orderStatus(t, server, fok.OrderId); o.Status != "CANCELLED" || o.FilledSize != "0" {
+		t.Fatalf("expected fill or kill to be cancelled: %+v", o)
+	}
+
+	ioc := placeOrder(t, client, "BUY", adv.OrderConfiguration{SorLimitIoc: &adv.SorLimitIoc{BaseSize: "20", LimitPrice: "101"}})
+	if o := orderStatus(t, server, ioc.OrderId); o.Status != "CANCELLED" || o.FilledSize != "10" {
+		t.Fatalf("expected immediate or cancel to partially fill: %+v", o)
+	}
+
+	postOnly := placeOrder(t, client, "SELL", adv.OrderConfiguration{LimitLimitGtc: &adv.LimitGtc{BaseSize: "0.1", LimitPrice: "98", PostOnly: true}})
+	if postOnly.Success {
+		t.Fatalf("expected crossing post only order to be rejected: %+v", postOnly)
+	}
+
+	gtd := placeOrder(t, client, "SELL", adv.OrderConfiguration{LimitLimitGtd: &adv.LimitGtd{
+		BaseSize:   "0.1",
+		LimitPrice: "120",
+		EndTime:    time.Now().Add(time.Second).UTC().Format(time.RFC3339),
+	}})
+	if !gtd.Success {
+		t.Fatalf("good until date order failed: %+v", gtd)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	server.Trade("BTC-USD", "BUY", 99, 0.1)
+
+	if o := orderStatus(t, server, gtd.OrderId); o.Status != "EXPIRED" {
+		t.Fatalf("expected good until date order to expire: %+v", o)
+	}
+}
+
+func TestMatchingEngineStops(t *testing.T) {
+	server := setupFakeServer(t)
+	client := server.Client()
+
+	stop := placeOrder(t, client, "SELL", adv.OrderConfiguration{StopLimitStopLimitGtc: &adv.StopLimitGtc{
+		BaseSize:      "0.5",
+		LimitPrice:    "94",
+		StopPrice:     "95",
+		StopDirection: "STOP_DIRECTION_STOP_DOWN",
+	}})
+
+	bracket := placeOrder(t, client, "SELL", adv.OrderConfiguration{TriggerBracketGtc: &adv.TriggerGtc{
+		BaseSize:         "0.25",
+		LimitPrice:       "110",
+		StopTriggerPrice: "96",
+	}})
+
+	if o := orderStatus(t, server, stop.OrderId); o.Status != "OPEN" || o.TriggerStatus != "STOP_PENDING" {
+		t.Fatalf("expected pending stop: %+v", o)
+	}
+
+	server.SetBook("BTC-USD", []adv.Level{{Price: "95", Size: "10"}}, []adv.Level{{Price: "101", Size: "10"}})
+	server.Trade("BTC-USD", "SELL", 95, 1)
+
+	if o := orderStatus(t, server, bracket.OrderId); o.Status != "FILLED" || o.AverageFilledPrice != "95" {
+		t.Fatalf("expected bracket stop to trade at market: %+v", o)
+	}
+
+	if o := orderStatus(t, server, stop.OrderId); o.Status != "FILLED" || o.TriggerStatus != "STOP_TRIGGERED" {
+		t.Fatalf("expected stop to trigger and fill: %+v", o)
+	}
+}
+
+func TestMatchingEngineSeedBookJson(t *testing.T) {
+	server := setupFakeServer(t)
+
+	snapshot := []byte(`{"pricebook":{"product_id":"BTC-USD","bids":[{"price":"50","size":"2"}],"asks":[{"price":"51","size":"3"}]}}`)
+	if err := server.SeedBookJson(snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := server.Client().GetBestBidAsk(context.Background(), &adv.GetBestBidAskRequest{ProductIds: []string{"BTC-USD"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	book := (*response.PriceBooks)[0]
+	if book.Bids[0].Price != "50" || book.Asks[0].Size != "3" {
+		t.Fatalf("unexpected book: %+v", book)
+	}
+}
@@ -0,0 +1,196 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setupFakeServer(t *testing.T) *advtest.Server {
+	t.Helper()
+
+	server, err := advtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	server.AddProduct(adv.Product{
+		ProductId:      "BTC-USD",
+		Price:          "100",
+		BaseIncrement:  "0.0001",
+		PriceIncrement: "0.01",
+		BaseMinSize:    "0.0001",
+		BaseMaxSize:    "1000",
+	})
+	server.SetBook("BTC-USD", []adv.Level{{Price: "99", Size: "10"}}, []adv.Level{{Price: "101", Size: "10"}})
+
+	server.AddAccount(adv.Account{Currency: "USD", AvailableBalance: adv.Amount{Value: "10000"}})
+	server.AddAccount(adv.Account{Currency: "USDC", AvailableBalance: adv.Amount{Value: "0"}})
+	server.AddAccount(adv.Account{Currency: "BTC", AvailableBalance: adv.Amount{Value: "1"}})
+
+	return server
+}
+
+func TestFakeServerAuthentication(t *testing.T) {
+	server := setupFakeServer(t)
+	ctx := context.Background()
+
+	if _, err := server.Client().ListPortfolios(ctx, &adv.ListPortfoliosRequest{}); err != nil {
+		t.Fatalf("expected signed request to succeed: %v", err)
+	}
+
+	other, err := advtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	client := adv.NewClient(other.Credentials(), http.Client{}).BaseUrl(server.BaseUrl())
+	_, err = client.ListPortfolios(ctx, &adv.ListPortfoliosRequest{})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected a foreign key to be rejected, got: %v", err)
+	}
+}
+
+func TestFakeServerOrders(t *testing.T) {
+	server := setupFakeServer(t)
+	client := server.Client()
+	ctx := context.Background()
+
+	market, err := client.CreateOrder(ctx, &adv.CreateOrderRequest{
+		ProductId:          "BTC-USD",
+		Side:               "BUY",
+		ClientOrderId:      "market-1",
+		OrderConfiguration: adv.OrderConfiguration{MarketMarketIoc: &adv.MarketIoc{BaseSize: "0.5"}},
+	})
+	if err != nil || !market.Success {
+		t.Fatalf("market order failed: %v %+v", err, market)
+	}
+
+	order, err := client.GetOrder(ctx, &adv.GetOrderRequest{OrderId: market.OrderId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Order.Status != "FILLED" || order.Order.AverageFilledPrice != "101" {
+		t.Fatalf("unexpected market order: %+v", order.Order)
+	}
+
+	assertFloat(t, "BTC balance", server.Balance("", "BTC"), 1.5)
+	assertFloat(t, "USD balance", server.Balance("", "USD"), 10000-50.5*1.006)
+
+	limit, err := client.CreateOrder(ctx, &adv.CreateOrderRequest{
+		ProductId:          "BTC-USD",
+		Side:               "SELL",
+		ClientOrderId:      "limit-1",
+		OrderConfiguration: adv.OrderConfiguration{LimitLimitGtc: &adv.LimitGtc{BaseSize: "0.25", LimitPrice: "105"}},
+	})
+	if err != nil || !limit.Success {
+		t.Fatalf("limit order failed: %v %+v", err, limit)
+	}
+
+	edit, err := client.EditOrder(ctx, &adv.EditOrderRequest{OrderId: limit.OrderId, Price: "104", Size: "0.3"})
+	if err != nil || !edit.Success {
+		t.Fatalf("edit failed: %v %+v", err, edit)
+	}
+
+	open, err := client.ListOrders(ctx, &adv.ListOrdersRequest{OrderStatus: []string{"OPEN"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(open.Orders) != 1 || open.Orders[0].OrderConfiguration.LimitLimitGtc.LimitPrice != "104" {
+		t.Fatalf("unexpected open orders: %+v", open.Orders)
+	}
+
+	cancel, err := client.CancelOrders(ctx, &adv.CancelOrdersRequest{OrderIds: []string{limit.OrderId, "missing"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cancel.Results[0].Success || cancel.Results[1].Success {
+		t.Fatalf("unexpected cancel results: %+v", cancel.Results)
+	}
+
+	fills, err := client.ListFills(ctx, &adv.ListFillsRequest{ProductId: "BTC-USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fills.Fills) != 1 || fills.Fills[0].LiquidityIndicator != "TAKER" {
+		t.Fatalf("unexpected fills: %+v", fills.Fills)
+	}
+}
+
+func TestFakeServerFailureInjection(t *testing.T) {
+	server := setupFakeServer(t)
+	client := server.Client()
+	ctx := context.Background()
+
+	server.Inject(advtest.Failure{Path: "/brokerage/products/*", StatusCode: http.StatusTooManyRequests, Times: 1})
+
+	if _, err := client.GetProduct(ctx, &adv.GetProductRequest{ProductId: "BTC-USD"}); err == nil || !strings.Contains(err.Error(), "429") {
+		t.Fatalf("expected injected 429, got: %v", err)
+	}
+
+	if _, err := client.GetProduct(ctx, &adv.GetProductRequest{ProductId: "BTC-USD"}); err != nil {
+		t.Fatalf("expected failure to expire: %v", err)
+	}
+
+	server.Inject(advtest.Failure{Method: http.MethodGet, Path: "/brokerage/accounts", Body: "{not json", Times: 1})
+
+	if _, err := client.ListAccounts(ctx, &adv.ListAccountsRequest{}); err == nil {
+		t.Fatal("expected malformed body to fail decoding")
+	}
+
+	server.Inject(advtest.Failure{Path: "/brokerage/time", Latency: time.Second})
+
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := client.GetServerTime(timeout, &adv.GetServerTimeRequest{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected latency to exceed the deadline, got: %v", err)
+	}
+}
+
+func TestFakeServerConvert(t *testing.T) {
+	server := setupFakeServer(t)
+	client := server.Client()
+	ctx := context.Background()
+
+	result, err := adv.ConvertFunds(ctx, client, "USD", "USDC", "100", &adv.ConvertOptions{MinRate: 0.99, PollInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Trade.Status != adv.ConvertTradeStatusCompleted {
+		t.Fatalf("unexpected convert trade: %+v", result.Trade)
+	}
+
+	assertFloat(t, "USDC balance", server.Balance("", "USDC"), 100)
+
+	server.SetConvertTerms(0.01)
+
+	_, err = adv.ConvertFunds(ctx, client, "USD", "USDC", "100", &adv.ConvertOptions{MaxFee: 0.5})
+	if !errors.Is(err, adv.ErrConvertQuoteRejected) {
+		t.Fatalf("expected fee guard to reject the quote, got: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupBackfillServer seeds 1000 one-minute candles closing at their index, with the five minutes
+// from 500 missing.
+func setupBackfillServer(t *testing.T) *advtest.Server {
+	t.Helper()
+
+	server := setupFakeServer(t)
+
+	var candles []adv.Candle
+	for i := 0; i < 1000; i++ {
+		if i >= 500 && i < 505 {
+			continue
+		}
+		price := fmt.Sprintf("%d", i)
+		candles = append(candles, adv.Candle{
+			Start:  fmt.Sprintf("%d", backtestStart.Add(time.Duration(i)*time.Minute).Unix()),
+			Open:   price,
+			High:   price,
+			Low:    price,
+			Close:  price,
+			Volume: "1",
+		})
+	}
+	server.AddCandles("BTC-USD", candles...)
+
+	return server
+}
+
+func backfillRequest() *adv.CandleBackfillRequest {
+	return &adv.CandleBackfillRequest{
+		ProductId:   "BTC-USD",
+		Granularity: adv.GranularityOneMinute,
+		Start:       backtestStart,
+		End:         backtestStart.Add(1000 * time.Minute),
+		RateLimiter: adv.NewRateLimiter(1000, 10),
+	}
+}
+
+func candleRequests(server *advtest.Server) int {
+	var n int
+	for _, r := range server.Requests() {
+		if strings.HasSuffix(r.Path, "/candles") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestBackfillCandlesChunksAndDeduplicates(t *testing.T) {
+	server := setupBackfillServer(t)
+
+	response, err := adv.BackfillCandles(context.Background(), server.Client(), backfillRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Requests != 4 || candleRequests(server) != 4 {
+		t.Fatalf("expected 4 window requests, got %d and %d on the server", response.Requests, candleRequests(server))
+	}
+
+	if response.Count != 995 || len(response.Candles) != 995 {
+		t.Fatalf("expected 995 candles, got %d", len(response.Candles))
+	}
+
+	for i := 1; i < len(response.Candles); i++ {
+		if parseTestFloat(response.Candles[i].Start) <= parseTestFloat(response.Candles[i-1].Start) {
+			t.Fatalf("candles out of order or duplicated at %d", i)
+		}
+	}
+
+	gap := adv.CandleGap{Start: backtestStart.Add(500 * time.Minute), End: backtestStart.Add(505 * time.Minute)}
+	if len(response.Gaps) != 1 || response.Gaps[0] != gap {
+		t.Fatalf("expected gap %+v, got %+v", gap, response.Gaps)
+	}
+}
+
+func TestBackfillCandlesFillsGaps(t *testing.T) {
+	server := setupBackfillServer(t)
+
+	request := backfillRequest()
+	request.GapFill = adv.CandleGapFillPreviousClose
+
+	var candles []adv.Candle
+	request.Stream = func(candle adv.Candle) error {
+		candles = append(candles, candle)
+		return nil
+	}
+
+	response, err := adv.BackfillCandles(context.Background(), server.Client(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Count != 1000 || len(candles) != 1000 || len(response.Candles) != 0 {
+		t.Fatalf("expected 1000 streamed candles, got %d", len(candles))
+	}
+
+	filled := candles[502]
+	if filled.Close != "499" || filled.Open != "499" || filled.Volume != "0" {
+		t.Fatalf("expected a flat candle at the previous close, got %+v", filled)
+	}
+	if filled.Start != fmt.Sprintf("%d", backtestStart.Add(502*time.Minute).Unix()) {
+		t.Fatalf("unexpected filled candle start %s", filled.Start)
+	}
+}
+
+func TestBackfillCandlesWritesToDisk(t *testing.T) {
+	server := setupBackfillServer(t)
+
+	var buf bytes.Buffer
+	request := backfillRequest()
+	request.Writer = &buf
+	request.Format = adv.ExportFormatCsv
+
+	if _, err := adv.BackfillCandles(context.Background(), server.Client(), request); err != nil {
+		t.Fatal(err)
+	}
+
+	candles, err := adv.ReadCandles(&buf, adv.ExportFormatCsv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 995 || candles[0].Close != "0" || candles[994].Close != "999" {
+		t.Fatalf("unexpected candles read back: %d", len(candles))
+	}
+}
+
+func TestBackfillCandlesFailure(t *testing.T) {
+	server := setupBackfillServer(t)
+	server.Inject(advtest.Failure{Path: "/brokerage/products/BTC-USD/candles", Times: 1, StatusCode: http.StatusInternalServerError})
+
+	if _, err := adv.BackfillCandles(context.Background(), server.Client(), backfillRequest()); err == nil {
+		t.Fatal("expected the failed window to fail the backfill")
+	}
+}
+
+func TestRateLimiterPacesRequests(t *testing.T) {
+	limiter := adv.NewRateLimiter(100, 1)
+	ctx := context.Background()
+
+	began := time.Now()
+	for i := 0; i < 6; i++ {
+		if err := limiter.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if elapsed := time.Since(began); elapsed < 45*time.Millisecond {
+		t.Fatalf("expected 5 paced requests to take at least 50ms, took %v", elapsed)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := adv.NewRateLimiter(0.001, 1).Wait(cancelled); err != nil {
+		t.Fatalf("expected the initial burst token to be available, got %v", err)
+	}
+	limiter = adv.NewRateLimiter(0.001, 1)
+	_ = limiter.Wait(ctx)
+	if err := limiter.Wait(cancelled); err == nil {
+		t.Fatal("expected a cancelled wait to fail")
+	}
+}
+
+func TestRateLimiterWithoutRateIsUnlimited(t *testing.T) {
+	for _, rate := range []float64{0, -1} {
+		limiter := adv.NewRateLimiter(rate, 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		for i := 0; i < 100; i++ {
+			if err := limiter.Wait(ctx); err != nil {
+				t.Fatalf("rate %v: expected an unlimited wait, got %v", rate, err)
+			}
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,275 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"math"
+	"testing"
+	"time"
+)
+
+var backtestStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// backtestCandles returns daily candles from open, high, low, close quadruples.
+func backtestCandles(ohlc ...[4]float64) []adv.Candle {
+	candles := make([]adv.Candle, len(ohlc))
+	for i, v := range ohlc {
+		candles[i] = adv.Candle{
+			Start:  fmt.Sprintf("%d", backtestStart.AddDate(0, 0, i).Unix()),
+			Open:   fmt.Sprintf("%v", v[0]),
+			High:   fmt.Sprintf("%v", v[1]),
+			Low:    fmt.Sprintf("%v", v[2]),
+			Close:  fmt.Sprintf("%v", v[3]),
+			Volume: "1",
+		}
+	}
+	// The API returns candles newest first.
+	for i, j := 0, len(candles)-1; i < j; i, j = i+1, j-1 {
+		candles[i], candles[j] = candles[j], candles[i]
+	}
+	return candles
+}
+
+var testBacktestCandles = backtestCandles(
+	[4]float64{100, 101, 99, 100},
+	[4]float64{102, 112, 101, 110},
+	[4]float64{111, 121, 109, 120},
+	[4]float64{119, 120, 108, 110},
+	[4]float64{111, 131, 110, 130},
+)
+
+// roundTrip buys one unit on the first candle and sells everything on the fourth.
+func roundTrip() adv.Strategy {
+	bar := 0
+	return adv.StrategyFunc(func(ctx context.Context, client adv.Service, candle adv.Candle) error {
+		bar++
+		switch bar {
+		case 1:
+			return marketOrder(ctx, client, "BUY", "1")
+		case 4:
+			accounts, err := client.ListAccounts(ctx, &adv.ListAccountsRequest{})
+			if err != nil {
+				return err
+			}
+			for _, a := range accounts.Accounts {
+				if a.Currency == "BTC" {
+					return marketOrder(ctx, client, "SELL", a.AvailableBalance.Value)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func marketOrder(ctx context.Context, client adv.Service, side, size string) error {
+	response, err := client.CreateOrder(ctx, &adv.CreateOrderRequest{
+		ProductId:          "BTC-USD",
+		Side:               side,
+		OrderConfiguration: adv.OrderConfiguration{MarketMarketIoc: &adv.MarketIoc{BaseSize: size}},
+	})
+	if err != nil {
+		return err
+	}
+	if !response.Success {
+		return fmt.Errorf("order rejected: %s", response.FailureReason)
+	}
+	return nil
+}
+
+func runBacktest(t *testing.T, request *adv.BacktestRequest) *adv.BacktestResult {
+	t.Helper()
+
+	request.ProductId = "BTC-USD"
+	request.Balances = map[string]string{"USD": "1000"}
+
+	result, err := adv.NewBacktester(request).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestBacktestCloseFillModel(t *testing.T) {
+	result := runBacktest(t, &adv.BacktestRequest{
+		Candles:        testBacktestCandles,
+		Strategy:       roundTrip(),
+		FeeTier:        adv.FeeTier{MakerFeeRate: "0.005", TakerFeeRate: "0.01"},
+		PeriodsPerYear: 365,
+	})
+
+	if len(result.Trades) != 2 {
+		t.Fatalf("expected 2 trades, got %d", len(result.Trades))
+	}
+	if result.Trades[0].Price != "100" || result.Trades[1].Price != "110" {
+		t.Fatalf("expected fills at the closes, got %s and %s", result.Trades[0].Price, result.Trades[1].Price)
+	}
+
+	want := []float64{1000, 999, 1009, 1019, 1007.9, 1007.9}
+	if len(result.Equity) != len(want) {
+		t.Fatalf("expected %d equity points, got %d", len(want), len(result.Equity))
+	}
+	for i, w := range want {
+		assertFloat(t, fmt.Sprintf("equity[%d]", i), result.Equity[i].Equity, w)
+	}
+
+	if !result.Equity[0].Time.Equal(backtestStart) || !result.Equity[1].Time.Equal(backtestStart.AddDate(0, 0, 1)) {
+		t.Fatalf("unexpected equity times: %v, %v", result.Equity[0].Time, result.Equity[1].Time)
+	}
+
+	assertFloat(t, "total fees", result.TotalFees, 2.1)
+	assertFloat(t, "total return", result.TotalReturn, 0.0079)
+	assertFloat(t, "max drawdown", result.MaxDrawdown, (1019-1007.9)/1019)
+
+	var returns []float64
+	for i := 1; i < len(want); i++ {
+		returns = append(returns, want[i]/want[i-1]-1)
+	}
+	var mean, variance float64
+	for _, r := range returns {
+		mean += r / float64(len(returns))
+	}
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean) / float64(len(returns)-1)
+	}
+	assertFloat(t, "sharpe", result.Sharpe, mean/math.Sqrt(variance)*math.Sqrt(365))
+}
+
+func TestBacktestNextOpenFillModel(t *testing.T) {
+	result := runBacktest(t, &adv.BacktestRequest{
+		Candles:   testBacktestCandles,
+		Strategy:  roundTrip(),
+		FillModel: adv.FillModelNextOpen,
+	})
+
+	if len(result.Trades) != 2 || result.Trades[0].Price != "102" || result.Trades[1].Price != "111" {
+		t.Fatalf("expected fills at the next opens, got %+v", result.Trades)
+	}
+	assertFloat(t, "ending equity", result.EndingEquity, 1009)
+}
+
+func TestBacktestBookWalkFillModel(t *testing.T) {
+	bar := 0
+	result := runBacktest(t, &adv.BacktestRequest{
+		Candles: testBacktestCandles,
+		Strategy: adv.StrategyFunc(func(ctx context.Context, client adv.Service, candle adv.Candle) error {
+			if bar++; bar == 1 {
+				return marketOrder(ctx, client, "BUY", "3")
+			}
+			return nil
+		}),
+		FillModel:     adv.FillModelBookWalk,
+		BookLevelSize: "1",
+		BookTickBps:   100,
+	})
+
+	if len(result.Trades) != 1 {
+		t.Fatalf("expected 1 trade, got %d", len(result.Trades))
+	}
+	assertFloat(t, "average price", parseTestFloat(result.Trades[0].Price), 101)
+	assertFloat(t, "USD", result.Balances["USD"], 1000-303)
+}
+
+func TestBacktestRestingOrdersFillIntrabar(t *testing.T) {
+	bar := 0
+	result := runBacktest(t, &adv.BacktestRequest{
+		Candles: testBacktestCandles,
+		Strategy: adv.StrategyFunc(func(ctx context.Context, client adv.Service, candle adv.Candle) error {
+			bar++
+			switch bar {
+			case 1:
+				return marketOrder(ctx, client, "BUY", "1")
+			case 2:
+				_, err := client.CreateOrder(ctx, &adv.CreateOrderRequest{
+					ProductId:          "BTC-USD",
+					Side:               "SELL",
+					OrderConfiguration: adv.OrderConfiguration{LimitLimitGtc: &adv.LimitGtc{BaseSize: "1", LimitPrice: "125"}},
+				})
+				return err
+			}
+			return nil
+		}),
+		FeeTier: adv.FeeTier{MakerFeeRate: "0.001", TakerFeeRate: "0.002"},
+	})
+
+	if len(result.Trades) != 2 {
+		t.Fatalf("expected 2 trades, got %d", len(result.Trades))
+	}
+
+	sell := result.Trades[1]
+	if sell.Price != "125" || sell.LiquidityIndicator != "MAKER" {
+		t.Fatalf("expected a maker fill at 125, got %s %s", sell.Price, sell.LiquidityIndicator)
+	}
+	if sell.Timestamp != backtestStart.AddDate(0, 0, 5).Format(time.RFC3339Nano) {
+		t.Fatalf("expected the fill during the last candle, got %s", sell.Timestamp)
+	}
+	assertFloat(t, "USD", result.Balances["USD"], 1000-100.2+125*0.999)
+}
+
+func TestBacktestFromStoredCandles(t *testing.T) {
+	for _, format := range []string{adv.ExportFormatCsv, adv.ExportFormatJsonl} {
+		var buf bytes.Buffer
+		if err := adv.WriteCandles(&buf, format, testBacktestCandles); err != nil {
+			t.Fatal(err)
+		}
+
+		candles, err := adv.ReadCandles(&buf, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := runBacktest(t, &adv.BacktestRequest{Candles: candles, Strategy: roundTrip()})
+		assertFloat(t, format+" ending equity", result.EndingEquity, 1010)
+	}
+}
+
+type tradeCounter struct {
+	trades int
+}
+
+func (s *tradeCounter) OnCandle(ctx context.Context, client adv.Service, candle adv.Candle) error {
+	return nil
+}
+
+func (s *tradeCounter) OnTrade(ctx context.Context, client adv.Service, trade adv.Trade) error {
+	if s.trades++; s.trades == 1 {
+		return marketOrder(ctx, client, "BUY", "1")
+	}
+	return nil
+}
+
+func TestBacktestTradeTape(t *testing.T) {
+	trades := []adv.Trade{
+		{TradeId: "3", ProductId: "BTC-USD", Price: "103", Size: "1", Time: backtestStart.Add(3 * time.Second)},
+		{TradeId: "2", ProductId: "BTC-USD", Price: "102", Size: "1", Time: backtestStart.Add(2 * time.Second)},
+		{TradeId: "1", ProductId: "BTC-USD", Price: "101", Size: "1", Time: backtestStart.Add(time.Second)},
+	}
+
+	strategy := &tradeCounter{}
+	result := runBacktest(t, &adv.BacktestRequest{Trades: trades, Strategy: strategy, FillModel: adv.FillModelNextOpen})
+
+	if strategy.trades != 3 {
+		t.Fatalf("expected 3 trades replayed, got %d", strategy.trades)
+	}
+	if len(result.Trades) != 1 || result.Trades[0].Price != "102" {
+		t.Fatalf("expected a fill at the next trade price, got %+v", result.Trades)
+	}
+	assertFloat(t, "ending equity", result.EndingEquity, 1001)
+}
@@ -0,0 +1,202 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"fmt"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func minutes(n int) time.Time {
+	return backtestStart.Add(time.Duration(n) * time.Minute)
+}
+
+func TestCandleStoreSyncsOnlyMissingRanges(t *testing.T) {
+	server := setupBackfillServer(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := adv.OpenCandleStore(dir, server.Client(), &adv.CandleStoreOptions{RateLimiter: adv.NewRateLimiter(1000, 10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	synced, err := store.Sync(ctx, &adv.CandleSyncRequest{
+		ProductId:   "BTC-USD",
+		Granularity: adv.GranularityOneMinute,
+		Start:       minutes(0),
+		End:         minutes(400),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if synced.Candles != 400 || synced.Requests != 2 {
+		t.Fatalf("expected 400 candles in 2 requests, got %d in %d", synced.Candles, synced.Requests)
+	}
+
+	synced, err = store.Sync(ctx, &adv.CandleSyncRequest{
+		ProductId:   "BTC-USD",
+		Granularity: adv.GranularityOneMinute,
+		Start:       minutes(200),
+		End:         minutes(600),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []adv.CandleRange{{Start: minutes(400), End: minutes(600)}}
+	if len(synced.Fetched) != 1 || synced.Fetched[0] != want[0] || synced.Requests != 1 {
+		t.Fatalf("expected only %+v to be fetched, got %+v in %d requests", want, synced.Fetched, synced.Requests)
+	}
+
+	requests := candleRequests(server)
+
+	reopened, err := adv.OpenCandleStore(dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query, err := reopened.Query(ctx, &adv.CandleQueryRequest{
+		ProductId:   "BTC-USD",
+		Granularity: adv.GranularityOneMinute,
+		Start:       minutes(100),
+		End:         minutes(700),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(query.Candles) != 495 || query.Candles[0].Close != "100" || query.Candles[494].Close != "599" {
+		t.Fatalf("unexpected candles served: %d", len(query.Candles))
+	}
+	missing := adv.CandleRange{Start: minutes(600), End: minutes(700)}
+	if len(query.Missing) != 1 || query.Missing[0] != missing {
+		t.Fatalf("expected %+v to be missing, got %+v", missing, query.Missing)
+	}
+	if candleRequests(server) != requests {
+		t.Fatal("expected the query to be served from disk")
+	}
+
+	coverage, err := reopened.Coverage("BTC-USD", adv.GranularityOneMinute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coverage) != 1 || coverage[0] != (adv.CandleRange{Start: minutes(0), End: minutes(600)}) {
+		t.Fatalf("unexpected coverage %+v", coverage)
+	}
+}
+
+func TestCandleStoreMonthlySegments(t *testing.T) {
+	server := setupFakeServer(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	for i := 0; i < 60; i++ {
+		price := fmt.Sprintf("%d", 100+i)
+		server.AddCandles("BTC-USD", adv.Candle{
+			Start:  fmt.Sprintf("%d", backtestStart.AddDate(0, 0, i).Unix()),
+			Open:   price,
+			High:   price,
+			Low:    price,
+			Close:  price,
+			Volume: "1",
+		})
+	}
+
+	store, err := adv.OpenCandleStore(dir, server.Client(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query, err := store.Query(ctx, &adv.CandleQueryRequest{
+		ProductId:   "BTC-USD",
+		Granularity: adv.GranularityOneDay,
+		Start:       backtestStart.Add(12 * time.Hour),
+		End:         backtestStart.AddDate(0, 0, 60),
+		Sync:        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(query.Candles) != 60 || len(query.Missing) != 0 {
+		t.Fatalf("expected 60 candles and nothing missing, got %d and %+v", len(query.Candles), query.Missing)
+	}
+
+	for _, segment := range []string{"2024-01.csv", "2024-02.csv"} {
+		if _, err := os.Stat(filepath.Join(dir, "BTC-USD", adv.GranularityOneDay, segment)); err != nil {
+			t.Fatalf("expected segment %s: %v", segment, err)
+		}
+	}
+
+	if _, err := store.Query(ctx, &adv.CandleQueryRequest{ProductId: "../BTC-USD", Granularity: adv.GranularityOneDay}); err == nil {
+		t.Fatal("expected a product id escaping the store to be rejected")
+	}
+}
+
+func TestCandleStoreSyncsKeysInParallel(t *testing.T) {
+	server := setupBackfillServer(t)
+	ctx := context.Background()
+
+	store, err := adv.OpenCandleStore(t.TempDir(), server.Client(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hold the one-minute sync in its backfill while the one-hour sync runs.
+	server.Inject(advtest.Failure{Path: "/brokerage/products/BTC-USD/candles", Times: 1, Latency: time.Second})
+
+	slow := make(chan error, 1)
+	go func() {
+		_, err := store.Sync(ctx, &adv.CandleSyncRequest{
+			ProductId:   "BTC-USD",
+			Granularity: adv.GranularityOneMinute,
+			Start:       minutes(0),
+			End:         minutes(100),
+		})
+		slow <- err
+	}()
+
+	for candleRequests(server) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+
+	if _, err := store.Sync(timeout, &adv.CandleSyncRequest{
+		ProductId:   "BTC-USD",
+		Granularity: adv.GranularityOneHour,
+		Start:       minutes(0),
+		End:         minutes(600),
+	}); err != nil {
+		t.Fatalf("expected another granularity to sync during the backfill, got %v", err)
+	}
+
+	if _, err := store.Coverage("BTC-USD", adv.GranularityOneHour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-slow; err != nil {
+		t.Fatal(err)
+	}
+}
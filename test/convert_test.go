@@ -0,0 +1,139 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lostCommit delivers convert commits to the server but fails them on the way back, as when the
+// connection drops after the API has accepted the request.
+type lostCommit struct{}
+
+func (lostCommit) RoundTrip(r *http.Request) (*http.Response, error) {
+	response, err := http.DefaultTransport.RoundTrip(r)
+	if err != nil || !strings.HasSuffix(r.URL.Path, "/commit") {
+		return response, err
+	}
+	response.Body.Close()
+	return nil, errors.New("connection reset by peer")
+}
+
+func TestConvertFundsFollowsCommitWithLostResponse(t *testing.T) {
+	server := setupFakeServer(t)
+	client := server.Client()
+	client.HttpClient = http.Client{Transport: lostCommit{}}
+
+	result, err := adv.ConvertFunds(context.Background(), client, "USD", "USDC", "100", &adv.ConvertOptions{PollInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("expected the accepted commit to be followed, got: %v", err)
+	}
+
+	if result.Trade == nil || result.Trade.Status != adv.ConvertTradeStatusCompleted {
+		t.Fatalf("unexpected convert trade: %+v", result.Trade)
+	}
+
+	assertFloat(t, "USDC balance", server.Balance("", "USDC"), 100)
+}
+
+func TestConvertFundsReportsExpiryOfUncommittedQuote(t *testing.T) {
+	server := setupFakeServer(t)
+
+	server.Inject(advtest.Failure{
+		Method:     http.MethodPost,
+		Path:       "/brokerage/convert/trade/*",
+		Times:      1,
+		Latency:    100 * time.Millisecond,
+		StatusCode: http.StatusServiceUnavailable,
+	})
+
+	opts := &adv.ConvertOptions{QuoteValidity: 50 * time.Millisecond, PollInterval: time.Millisecond}
+
+	result, err := adv.ConvertFunds(context.Background(), server.Client(), "USD", "USDC", "100", opts)
+	if !errors.Is(err, adv.ErrConvertQuoteExpired) {
+		t.Fatalf("expected the quote to be reported expired, got: %v", err)
+	}
+
+	if result == nil || result.Quote == nil || result.Trade != nil {
+		t.Fatalf("expected only the quote in the result, got %+v", result)
+	}
+
+	assertFloat(t, "USDC balance", server.Balance("", "USDC"), 0)
+}
+
+func TestConvertFundsReportsFailedCommitWithinValidity(t *testing.T) {
+	server := setupFakeServer(t)
+
+	server.Inject(advtest.Failure{
+		Method:     http.MethodPost,
+		Path:       "/brokerage/convert/trade/*",
+		Times:      1,
+		StatusCode: http.StatusServiceUnavailable,
+	})
+
+	_, err := adv.ConvertFunds(context.Background(), server.Client(), "USD", "USDC", "100", nil)
+	if err == nil || errors.Is(err, adv.ErrConvertQuoteExpired) {
+		t.Fatalf("expected a commit error other than expiry, got: %v", err)
+	}
+}
+
+func TestConvertFundsRetriesTradeLookupAfterCommit(t *testing.T) {
+	server := setupFakeServer(t)
+
+	server.Inject(advtest.Failure{
+		Method:     http.MethodGet,
+		Path:       "/brokerage/convert/trade/*",
+		Times:      2,
+		StatusCode: http.StatusServiceUnavailable,
+	})
+
+	result, err := adv.ConvertFunds(context.Background(), server.Client(), "USD", "USDC", "100", &adv.ConvertOptions{PollInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("expected transient lookup errors to be retried, got: %v", err)
+	}
+
+	if result.Trade == nil || result.Trade.Status != adv.ConvertTradeStatusCompleted {
+		t.Fatalf("unexpected convert trade: %+v", result.Trade)
+	}
+}
+
+func TestConvertFundsReturnsCommittedTradeWithLookupError(t *testing.T) {
+	server := setupFakeServer(t)
+
+	server.Inject(advtest.Failure{
+		Method:     http.MethodGet,
+		Path:       "/brokerage/convert/trade/*",
+		Times:      3,
+		StatusCode: http.StatusServiceUnavailable,
+	})
+
+	result, err := adv.ConvertFunds(context.Background(), server.Client(), "USD", "USDC", "100", &adv.ConvertOptions{PollInterval: time.Millisecond})
+	if err == nil {
+		t.Fatal("expected the failed lookups to be reported")
+	}
+
+	if result == nil || result.Trade == nil || result.Trade.Id != result.Quote.Id {
+		t.Fatalf("expected the committed trade in the result, got %+v", result)
+	}
+}
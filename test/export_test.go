@@ -0,0 +1,278 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteTradeRecords(t *testing.T) {
+	fill := &adv.Fill{
+		TradeId:            "t1",
+		OrderId:            "o1",
+		ProductId:          "BTC-USD",
+		Side:               "BUY",
+		Price:              "100",
+		Size:               "0.5",
+		Commission:         "0.1",
+		LiquidityIndicator: "MAKER",
+		TradeTime:          time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	records := []*adv.TradeRecord{adv.FillRecord(fill, "portfolio-1")}
+
+	var csv bytes.Buffer
+	if err := adv.WriteTradeRecords(&csv, adv.ExportFormatCsv, records); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "record_type,timestamp,product_id,side,size,price,fees,order_id,trade_id,liquidity_indicator,portfolio\n" +
+		"FILL,2024-03-01T12:00:00Z,BTC-USD,BUY,0.5,100,0.1,o1,t1,MAKER,portfolio-1\n"
+	if csv.String() != want {
+		t.Fatalf("unexpected csv:\n%s", csv.String())
+	}
+
+	var jsonl bytes.Buffer
+	if err := adv.WriteTradeRecords(&jsonl, adv.ExportFormatJsonl, records); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded adv.TradeRecord
+	if err := json.Unmarshal(jsonl.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != *records[0] {
+		t.Fatalf("unexpected jsonl record: %+v", decoded)
+	}
+}
+
+func TestWriteDisposals(t *testing.T) {
+	engine, _ := adv.NewPnlEngine(adv.CostBasisFifo)
+
+	open := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := engine.AddFills(
+		&adv.Fill{EntryId: "1", TradeId: "1", ProductId: "BTC-USD", Side: "BUY", Price: "100", Size: "1", TradeTime: open},
+		&adv.Fill{EntryId: "2", TradeId: "2", ProductId: "BTC-USD", Side: "SELL", Price: "150", Size: "1", TradeTime: open.AddDate(1, 1, 0)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := adv.WriteDisposals(&out, adv.ExportFormatCsv, engine.Disposals()); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected header and one disposal, got %d lines", len(lines))
+	}
+
+	if lines[1] != "BTC-USD,1,2023-01-01T00:00:00Z,2024-02-01T00:00:00Z,1,2,100,150,50,false,LONG_TERM" {
+		t.Fatalf("unexpected disposal: %s", lines[1])
+	}
+}
+
+func TestFillRecordConvertsQuoteSizeToBase(t *testing.T) {
+	fill := &adv.Fill{
+		ProductId:         "BTC-USD",
+		Side:              "BUY",
+		Price:             "100",
+		Size:              "50",
+		SizeInQuote:       true,
+		RetailPortfolioId: "portfolio-2",
+	}
+
+	record := adv.FillRecord(fill, "portfolio-1")
+	if record.Size != "0.5" || record.Portfolio != "portfolio-2" {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+}
+
+func TestExportTradeHistoryPagesThroughResults(t *testing.T) {
+	server := setupFakeServer(t)
+	client := server.Client()
+	ctx := context.Background()
+
+	converted, err := adv.ConvertFunds(ctx, client, "USD", "USDC", "100", &adv.ConvertOptions{PollInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	convertedAt := time.Now()
+
+	// More orders and fills than one page of ListOrders and ListFills holds.
+	const orders = 1001
+	for i := 0; i < orders; i++ {
+		response, err := client.CreateOrder(ctx, &adv.CreateOrderRequest{
+			ProductId:          "BTC-USD",
+			Side:               "BUY",
+			ClientOrderId:      fmt.Sprintf("export-%d", i),
+			OrderConfiguration: adv.OrderConfiguration{MarketMarketIoc: &adv.MarketIoc{BaseSize: "0.0001"}},
+		})
+		if err != nil || !response.Success {
+			t.Fatalf("order failed: %v %+v", err, response)
+		}
+	}
+
+	var out bytes.Buffer
+	response, err := adv.ExportTradeHistory(ctx, client, &adv.ExportTradeHistoryRequest{
+		ConvertTrades: []*adv.ExportConvertTrade{{
+			GetConvertTradeRequest: adv.GetConvertTradeRequest{
+				TradeId:     converted.Trade.Id,
+				FromAccount: converted.FromAccount,
+				ToAccount:   converted.ToAccount,
+			},
+			Time: convertedAt,
+		}},
+		Format: adv.ExportFormatCsv,
+		Trades: &out,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Fills != orders || response.Orders != orders || response.ConvertTrades != 1 {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1+2*orders+1 {
+		t.Fatalf("expected a header and %d records, got %d rows", 2*orders+1, len(rows))
+	}
+
+	// The convert trade came first and every record carries its portfolio and time in order.
+	if rows[1][0] != adv.RecordTypeConvert {
+		t.Fatalf("expected the convert trade first, got %v", rows[1])
+	}
+
+	var previous time.Time
+	for _, row := range rows[1:] {
+		at, err := time.Parse(time.RFC3339Nano, row[1])
+		if err != nil {
+			t.Fatalf("invalid timestamp in %v: %v", row, err)
+		}
+		if at.Before(previous) {
+			t.Fatalf("records out of order at %v", row)
+		}
+		previous = at
+
+		if row[0] != adv.RecordTypeConvert && row[10] != server.DefaultPortfolio() {
+			t.Fatalf("expected the record's own portfolio, got %v", row)
+		}
+	}
+}
+
+func TestExportTradeHistoryRejectsSellsWithoutLots(t *testing.T) {
+	server := setupFakeServer(t)
+	client := server.Client()
+	ctx := context.Background()
+
+	start := time.Now()
+	sellBtc(t, client, "0.5")
+
+	var trades, disposals bytes.Buffer
+	_, err := adv.ExportTradeHistory(ctx, client, &adv.ExportTradeHistoryRequest{
+		Start:           start.Add(-time.Second),
+		Format:          adv.ExportFormatCsv,
+		CostBasisMethod: adv.CostBasisFifo,
+		Trades:          &trades,
+		Disposals:       &disposals,
+	})
+	if !errors.Is(err, adv.ErrUnmatchedSells) {
+		t.Fatalf("expected ErrUnmatchedSells, got %v", err)
+	}
+	if disposals.Len() != 0 {
+		t.Fatalf("expected no disposals, got %s", disposals.String())
+	}
+}
+
+func TestExportTradeHistorySeedsLotsFromOpeningFills(t *testing.T) {
+	server := setupFakeServer(t)
+	client := server.Client()
+	ctx := context.Background()
+
+	start := time.Now().Add(-time.Second)
+	sellBtc(t, client, "0.5")
+
+	// The BTC sold was bought before Start, and a round trip before Start is not reported.
+	opened := start.AddDate(-2, 0, 0)
+	opening := []*adv.Fill{
+		{EntryId: "o1", TradeId: "o1", ProductId: "BTC-USD", Side: "BUY", Price: "40", Size: "2", Commission: "0", TradeTime: opened},
+		{EntryId: "o2", TradeId: "o2", ProductId: "BTC-USD", Side: "SELL", Price: "50", Size: "1", Commission: "0", TradeTime: opened.Add(time.Hour)},
+	}
+
+	var trades, disposals bytes.Buffer
+	response, err := adv.ExportTradeHistory(ctx, client, &adv.ExportTradeHistoryRequest{
+		Start:           start,
+		Format:          adv.ExportFormatCsv,
+		CostBasisMethod: adv.CostBasisFifo,
+		OpeningFills:    opening,
+		Trades:          &trades,
+		Disposals:       &disposals,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Fills != 1 || response.Disposals != 1 {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+
+	rows, err := csv.NewReader(&disposals).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected a header and one disposal, got %v", rows)
+	}
+
+	if rows[1][4] != "o1" || rows[1][10] != adv.HoldingPeriodLongTerm {
+		t.Fatalf("expected the opening lot to be disposed of long term, got %v", rows[1])
+	}
+	assertFloat(t, "quantity", parseTestFloat(rows[1][1]), 0.5)
+	assertFloat(t, "cost basis", parseTestFloat(rows[1][6]), 20)
+
+	if strings.Contains(trades.String(), "o1") {
+		t.Fatalf("opening fills were written as trades: %s", trades.String())
+	}
+}
+
+func sellBtc(t *testing.T, client *adv.Client, size string) {
+	t.Helper()
+
+	response, err := client.CreateOrder(context.Background(), &adv.CreateOrderRequest{
+		ProductId:          "BTC-USD",
+		Side:               "SELL",
+		ClientOrderId:      "export-sell",
+		OrderConfiguration: adv.OrderConfiguration{MarketMarketIoc: &adv.MarketIoc{BaseSize: size}},
+	})
+	if err != nil || !response.Success {
+		t.Fatalf("sell failed: %v %+v", err, response)
+	}
+}
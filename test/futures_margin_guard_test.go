@@ -0,0 +1,242 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"bytes"
+	"context"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupGuardServer holds a long position of 4 contracts in a listed futures product.
+func setupGuardServer(t *testing.T) *advtest.Server {
+	server := setupFakeServer(t)
+	server.AddProduct(adv.Product{
+		ProductId:      expiringContract,
+		ProductType:    adv.ProductTypeFuture,
+		Price:          "60000",
+		BaseIncrement:  "1",
+		PriceIncrement: "5",
+		BaseMinSize:    "1",
+		BaseMaxSize:    "1000",
+	})
+	server.SetBook(expiringContract, []adv.Level{{Price: "59995", Size: "100"}}, []adv.Level{{Price: "60005", Size: "100"}})
+	server.AddFuturesPosition(adv.CfmFuturesPosition{ProductId: expiringContract, Side: "LONG", NumberOfContracts: "4"})
+	return server
+}
+
+func setMarginBuffer(server *advtest.Server, percentage string) {
+	server.SetFuturesBalanceSummary(adv.BalanceSummary{
+		AvailableMargin:             adv.Amount{Value: "1000", Currency: "USD"},
+		LiquidationBufferAmount:     adv.Amount{Value: "1000", Currency: "USD"},
+		LiquidationBufferPercentage: percentage,
+	})
+}
+
+func guardRequest() *adv.FuturesMarginGuardRequest {
+	return &adv.FuturesMarginGuardRequest{
+		WarningBufferPercentage:    50,
+		CriticalBufferPercentage:   20,
+		ReduceExposure:             true,
+		ReduceFraction:             0.5,
+		SweepAboveBufferPercentage: 200,
+		SweepAmount:                "100",
+		ActionCooldown:             time.Hour,
+	}
+}
+
+func checkGuard(t *testing.T, guard *adv.FuturesMarginGuard, want ...string) []adv.MarginGuardDecision {
+	t.Helper()
+
+	decisions, err := guard.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actions []string
+	for _, d := range decisions {
+		actions = append(actions, d.Action)
+		if len(d.Error) > 0 {
+			t.Fatalf("unexpected failed decision: %+v", d)
+		}
+	}
+
+	if strings.Join(actions, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected actions %v, got %v", want, actions)
+	}
+	return decisions
+}
+
+func pendingSweeps(t *testing.T, server *advtest.Server) int {
+	t.Helper()
+	response, err := server.Client().ListFuturesSweeps(context.Background(), &adv.ListFuturesSweepsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(response.Sweeps)
+}
+
+func contracts(t *testing.T, server *advtest.Server) string {
+	t.Helper()
+	response, err := server.Client().ListFuturesPositions(context.Background(), &adv.ListFuturesPositionsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.FuturesPositions[0].NumberOfContracts
+}
+
+func TestFuturesMarginGuardAppliesPolicyPerLevel(t *testing.T) {
+	server := setupGuardServer(t)
+
+	var audit bytes.Buffer
+	request := guardRequest()
+	request.AuditWriter = &audit
+
+	guard := adv.NewFuturesMarginGuard(server.Client(), request)
+
+	setMarginBuffer(server, "300")
+	decisions := checkGuard(t, guard, adv.MarginActionAlert, adv.MarginActionScheduleSweep)
+	if decisions[0].Level != adv.MarginLevelExcess || !decisions[1].Executed {
+		t.Fatalf("unexpected decisions: %+v", decisions)
+	}
+	if n := pendingSweeps(t, server); n != 1 {
+		t.Fatalf("expected one pending sweep, got %d", n)
+	}
+
+	// The level is unchanged and the sweep is cooling down.
+	checkGuard(t, guard)
+
+	setMarginBuffer(server, "40")
+	checkGuard(t, guard, adv.MarginActionAlert, adv.MarginActionCancelSweeps)
+	if n := pendingSweeps(t, server); n != 0 {
+		t.Fatalf("expected the pending sweep to be canceled, got %d", n)
+	}
+
+	setMarginBuffer(server, "10")
+	decisions = checkGuard(t, guard, adv.MarginActionAlert, adv.MarginActionReduceExposure)
+	if decisions[0].Level != adv.MarginLevelCritical || !decisions[1].Executed {
+		t.Fatalf("unexpected decisions: %+v", decisions)
+	}
+	if n := contracts(t, server); n != "2" {
+		t.Fatalf("expected half of the position to be closed, %s contracts left", n)
+	}
+
+	// Exposure is only reduced once per cooldown.
+	checkGuard(t, guard)
+	if n := contracts(t, server); n != "2" {
+		t.Fatalf("expected the position to be left alone, %s contracts left", n)
+	}
+
+	if n := len(guard.AuditLog()); n != 6 || strings.Count(audit.String(), "\n") != n {
+		t.Fatalf("expected 6 decisions in both audit logs, got %d and:\n%s", n, audit.String())
+	}
+}
+
+func TestFuturesMarginGuardDryRun(t *testing.T) {
+	server := setupGuardServer(t)
+
+	request := guardRequest()
+	request.DryRun = true
+
+	setMarginBuffer(server, "10")
+	decisions := checkGuard(t, adv.NewFuturesMarginGuard(server.Client(), request), adv.MarginActionAlert, adv.MarginActionReduceExposure)
+
+	if d := decisions[1]; !d.DryRun || d.Executed || len(d.Details) != 1 {
+		t.Fatalf("unexpected decision: %+v", d)
+	}
+	if n := contracts(t, server); n != "4" {
+		t.Fatalf("expected a dry run not to trade, %s contracts left", n)
+	}
+}
+
+func TestFuturesMarginGuardRecordsFailedActions(t *testing.T) {
+	server := setupGuardServer(t)
+
+	server.Inject(advtest.Failure{
+		Method:     http.MethodPost,
+		Path:       "/brokerage/cfm/sweeps/schedule",
+		StatusCode: http.StatusInternalServerError,
+	})
+
+	guard := adv.NewFuturesMarginGuard(server.Client(), guardRequest())
+
+	setMarginBuffer(server, "300")
+	decisions, err := guard.Check(context.Background())
+	if err != nil {
+		t.Fatalf("expected a failed action not to fail the check, got: %v", err)
+	}
+
+	if len(decisions) != 2 || decisions[1].Executed || len(decisions[1].Error) == 0 {
+		t.Fatalf("unexpected decisions: %+v", decisions)
+	}
+
+	// A failed action does not start the cooldown.
+	server.ClearFailures()
+	checkGuard(t, guard, adv.MarginActionScheduleSweep)
+}
+
+func TestFuturesMarginGuardDefaultsReduceCooldown(t *testing.T) {
+	server := setupGuardServer(t)
+
+	request := guardRequest()
+	request.ActionCooldown = 0
+
+	guard := adv.NewFuturesMarginGuard(server.Client(), request)
+
+	setMarginBuffer(server, "10")
+	checkGuard(t, guard, adv.MarginActionAlert, adv.MarginActionReduceExposure)
+
+	// The buffer has not caught up with the close yet; without a cooldown every poll would close more.
+	checkGuard(t, guard)
+	if n := contracts(t, server); n != "2" {
+		t.Fatalf("expected a single close, %s contracts left", n)
+	}
+}
+
+func TestFuturesMarginGuardWaitsForCloseToShow(t *testing.T) {
+	server := setupGuardServer(t)
+
+	request := guardRequest()
+	request.ActionCooldown = time.Nanosecond
+
+	guard := adv.NewFuturesMarginGuard(server.Client(), request)
+
+	setMarginBuffer(server, "10")
+	checkGuard(t, guard, adv.MarginActionAlert, adv.MarginActionReduceExposure)
+
+	// The position listing still shows the size from before the close.
+	server.Inject(advtest.Failure{
+		Method: http.MethodGet,
+		Path:   "/brokerage/cfm/positions",
+		Times:  1,
+		Body:   `{"positions": [{"product_id": "` + expiringContract + `", "side": "LONG", "number_of_contracts": "4"}]}`,
+	})
+	checkGuard(t, guard)
+	if n := contracts(t, server); n != "2" {
+		t.Fatalf("expected the position to be left alone, %s contracts left", n)
+	}
+
+	checkGuard(t, guard, adv.MarginActionReduceExposure)
+	if n := contracts(t, server); n != "1" {
+		t.Fatalf("expected the settled position to be reduced again, %s contracts left", n)
+	}
+}
@@ -0,0 +1,154 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const (
+	expiringContract = "BIT-28JUN24-CDE"
+	nextContract     = "BIT-26JUL24-CDE"
+)
+
+// setupRollServer lists both contracts and holds a long position of 2 in the expiring one.
+func setupRollServer(t *testing.T) *advtest.Server {
+	server := setupFakeServer(t)
+	for _, id := range []string{expiringContract, nextContract} {
+		server.AddProduct(adv.Product{
+			ProductId:      id,
+			Price:          "60000",
+			BaseIncrement:  "1",
+			PriceIncrement: "5",
+			BaseMinSize:    "1",
+			BaseMaxSize:    "1000",
+		})
+		server.SetBook(id, []adv.Level{{Price: "59995", Size: "100"}}, []adv.Level{{Price: "60005", Size: "100"}})
+	}
+	server.AddFuturesPosition(adv.CfmFuturesPosition{ProductId: expiringContract, Side: "LONG", NumberOfContracts: "2"})
+	return server
+}
+
+func rollRequest(openProductId string) *adv.ExecuteFuturesRollRequest {
+	return &adv.ExecuteFuturesRollRequest{
+		Rolls: []*adv.FuturesRoll{{
+			Position:     &adv.CfmFuturesPosition{ProductId: expiringContract, Side: "LONG", NumberOfContracts: "2"},
+			CloseRequest: &adv.ClosePositionRequest{ProductId: expiringContract, Size: "2"},
+			OpenRequest: &adv.CreateOrderRequest{
+				ProductId:          openProductId,
+				Side:               "BUY",
+				OrderConfiguration: adv.OrderConfiguration{MarketMarketIoc: &adv.MarketIoc{BaseSize: "2"}},
+			},
+			CloseSide: "SELL",
+		}},
+	}
+}
+
+func ordersFor(server *advtest.Server, productId string) int {
+	var n int
+	for _, o := range server.Orders() {
+		if o.ProductId == productId {
+			n++
+		}
+	}
+	return n
+}
+
+func TestFuturesRollRetriesSecondLeg(t *testing.T) {
+	server := setupRollServer(t)
+
+	server.Inject(advtest.Failure{
+		Method:     http.MethodPost,
+		Path:       "/brokerage/orders",
+		Times:      2,
+		StatusCode: http.StatusServiceUnavailable,
+	})
+
+	response, err := adv.ExecuteFuturesRoll(context.Background(), server.Client(), rollRequest(nextContract))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := response.Results[0]
+	if result.Partial || !result.Close.Success || !result.Open.Success {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if n := ordersFor(server, nextContract); n != 1 {
+		t.Fatalf("expected one order in the next contract, got %d", n)
+	}
+}
+
+func TestFuturesRollReportsPartialRoll(t *testing.T) {
+	server := setupRollServer(t)
+
+	// The next contract is not listed, so the open leg is rejected after the close went through.
+	response, err := adv.ExecuteFuturesRoll(context.Background(), server.Client(), rollRequest("BIT-30AUG24-CDE"))
+	if err == nil {
+		t.Fatal("expected the roll to fail")
+	}
+
+	result := response.Results[0]
+	if !result.Partial || !result.Close.Success || result.Open == nil || result.Open.Success || len(result.Error) == 0 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestFuturesRollFinishesRollWhenContextIsDoneBetweenLegs(t *testing.T) {
+	server := setupRollServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	request := rollRequest(nextContract)
+	request.LegDelay = 5 * time.Second
+
+	response, err := adv.ExecuteFuturesRoll(ctx, server.Client(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := response.Results[0]
+	if result.Partial || !result.Close.Success || !result.Open.Success {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestFuturesRollFailedFirstLegIsNotPartial(t *testing.T) {
+	server := setupRollServer(t)
+
+	request := rollRequest(nextContract)
+	request.Rolls[0].CloseRequest.ProductId = nextContract
+
+	response, err := adv.ExecuteFuturesRoll(context.Background(), server.Client(), request)
+	if err == nil {
+		t.Fatal("expected the roll to fail")
+	}
+
+	result := response.Results[0]
+	if result.Partial || result.Open != nil {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if n := ordersFor(server, nextContract); n != 0 {
+		t.Fatalf("expected no order in the next contract, got %d", n)
+	}
+}
@@ -0,0 +1,115 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func icebergRequest(totalSize string) *adv.IcebergRequest {
+	return &adv.IcebergRequest{
+		ProductId:    "BTC-USD",
+		Side:         "BUY",
+		TotalSize:    totalSize,
+		LimitPrice:   "100",
+		DisplaySize:  "0.1",
+		PollInterval: 5 * time.Millisecond,
+	}
+}
+
+func TestIcebergReplenishesFilledTranches(t *testing.T) {
+	server := setupFakeServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// A seller keeps lifting whatever tranche is resting at the limit price.
+	go func() {
+		for ctx.Err() == nil {
+			server.Trade("BTC-USD", "SELL", 100, 0.1)
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	report, err := adv.NewIcebergExecutor(server.Client(), icebergRequest("0.3")).Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Status != adv.ExecutionStatusCompleted || len(report.ChildOrders) != 3 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	assertFloat(t, "filled", report.FilledSize, 0.3)
+	assertFloat(t, "average price", report.AveragePrice, 100)
+}
+
+func TestIcebergCancelsTrancheWhenOrderPollingFails(t *testing.T) {
+	server := setupFakeServer(t)
+
+	server.Inject(advtest.Failure{
+		Method:     http.MethodGet,
+		Path:       "/brokerage/orders/historical/*",
+		Times:      3,
+		StatusCode: http.StatusInternalServerError,
+	})
+
+	report, err := adv.NewIcebergExecutor(server.Client(), icebergRequest("0.3")).Run(context.Background())
+	if err == nil || errors.Is(err, adv.ErrExecutionShortfall) {
+		t.Fatalf("expected the run to fail, got: %v", err)
+	}
+
+	if report.Status != adv.ExecutionStatusFailed || len(report.ChildOrders) != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.ChildOrders[0].Status != "CANCELLED" {
+		t.Fatalf("expected the tranche to be canceled, got %s", report.ChildOrders[0].Status)
+	}
+	assertNoOpenOrders(t, server)
+}
+
+func TestIcebergRetriesTransientOrderErrors(t *testing.T) {
+	server := setupFakeServer(t)
+
+	server.Inject(advtest.Failure{
+		Method:     http.MethodGet,
+		Path:       "/brokerage/orders/historical/*",
+		Times:      2,
+		StatusCode: http.StatusServiceUnavailable,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go func() {
+		for ctx.Err() == nil {
+			server.Trade("BTC-USD", "SELL", 100, 0.1)
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	report, err := adv.NewIcebergExecutor(server.Client(), icebergRequest("0.2")).Run(ctx)
+	if err != nil {
+		t.Fatalf("expected transient errors to be retried, got: %v", err)
+	}
+	assertFloat(t, "filled", report.FilledSize, 0.2)
+}
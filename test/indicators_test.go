@@ -0,0 +1,241 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"errors"
+	"fmt"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/indicators"
+	"math"
+	"testing"
+	"time"
+)
+
+// indicatorCloses is Wilder's relative strength index example series.
+var indicatorCloses = []float64{
+	44.34, 44.09, 44.15, 43.61, 44.33, 44.83, 45.10, 45.42, 45.84, 46.08, 45.89,
+	46.03, 45.61, 46.28, 46.28, 46.00, 46.03, 46.41, 46.22, 45.64, 46.21, 46.25,
+	45.71, 46.45, 45.78, 45.35, 44.03, 44.18, 44.22, 44.57, 43.42, 42.66, 43.13,
+}
+
+// indicatorCandles returns daily candles around indicatorCloses, newest first as the API returns
+// them.
+func indicatorCandles() []adv.Candle {
+	n := len(indicatorCloses)
+	candles := make([]adv.Candle, n)
+	for i, c := range indicatorCloses {
+		candles[n-1-i] = adv.Candle{
+			Start:  fmt.Sprintf("%d", backtestStart.AddDate(0, 0, i).Unix()),
+			Open:   fmt.Sprintf("%v", c),
+			High:   fmt.Sprintf("%v", c+0.25+0.05*float64(i%4)),
+			Low:    fmt.Sprintf("%v", c-0.2-0.05*float64(i%3)),
+			Close:  fmt.Sprintf("%v", c),
+			Volume: fmt.Sprintf("%d", 1000+100*(i%7)),
+		}
+	}
+	return candles
+}
+
+func assertWarmup(t *testing.T, name string, values []float64, ready int) {
+	t.Helper()
+	for i, v := range values[:ready] {
+		if !math.IsNaN(v) {
+			t.Errorf("%s: expected NaN before candle %d, got %v at %d", name, ready, v, i)
+		}
+	}
+}
+
+func TestIndicatorReferenceValues(t *testing.T) {
+	candles := indicatorCandles()
+
+	sma, err := indicators.SmaSeries(candles, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertWarmup(t, "sma", sma, 9)
+	assertFloat(t, "sma", sma[9], 44.779)
+	assertFloat(t, "sma", sma[32], 44.379)
+
+	ema, err := indicators.EmaSeries(candles, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertWarmup(t, "ema", ema, 9)
+	assertFloat(t, "ema", ema[9], 44.779)
+	assertFloat(t, "ema", ema[10], 44.981)
+	assertFloat(t, "ema", ema[32], 44.11929901522182)
+
+	rsi, err := indicators.RsiSeries(candles, 14)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertWarmup(t, "rsi", rsi, 14)
+	assertFloat(t, "rsi", rsi[14], 70.46413502109705)
+	assertFloat(t, "rsi", rsi[32], 37.788771982057824)
+
+	macd, err := indicators.MacdSeries(candles, 5, 12, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(macd[10].Macd) || !math.IsNaN(macd[11].Signal) {
+		t.Errorf("expected MACD to warm up, got %+v and %+v", macd[10], macd[11])
+	}
+	assertFloat(t, "macd", macd[11].Macd, 0.7828689986282598)
+	assertFloat(t, "macd", macd[14].Macd, 0.6101810496899276)
+	assertFloat(t, "signal", macd[14].Signal, 0.6672955398598059)
+	assertFloat(t, "histogram", macd[14].Histogram, 0.6101810496899276-0.6672955398598059)
+	assertFloat(t, "macd", macd[32].Macd, -0.7731261972756798)
+	assertFloat(t, "signal", macd[32].Signal, -0.6731642057830303)
+
+	bands, err := indicators.BollingerSeries(candles, 20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(bands[18].Middle) {
+		t.Errorf("expected bands to warm up, got %+v", bands[18])
+	}
+	assertFloat(t, "middle", bands[19].Middle, 45.409)
+	assertFloat(t, "upper", bands[19].Upper, 47.115328221650216)
+	assertFloat(t, "lower", bands[19].Lower, 43.70267177834978)
+	assertFloat(t, "upper", bands[32].Upper, 47.62015026847822)
+	assertFloat(t, "lower", bands[32].Lower, 42.86184973152178)
+
+	atr, err := indicators.AtrSeries(candles, 14)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertWarmup(t, "atr", atr, 13)
+	assertFloat(t, "atr", atr[13], 0.6857142857142863)
+	assertFloat(t, "atr", atr[14], 0.6831632653061234)
+	assertFloat(t, "atr", atr[32], 0.7964440961160558)
+
+	vwap, err := indicators.VwapSeries(candles, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFloat(t, "vwap", vwap[0], 44.35666666666666)
+	assertFloat(t, "vwap", vwap[32], 45.1760534591195)
+
+	vwap, err = indicators.VwapSeries(candles, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertWarmup(t, "rolling vwap", vwap, 4)
+	assertFloat(t, "rolling vwap", vwap[4], 44.11927777777778)
+	assertFloat(t, "rolling vwap", vwap[32], 43.54877777777778)
+}
+
+func TestIndicatorStreamingMatchesSeries(t *testing.T) {
+	candles, err := indicators.Chronological(indicatorCandles())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsi, _ := indicators.NewRsi(14)
+	macd, _ := indicators.NewMacd(5, 12, 4)
+	atr, _ := indicators.NewAtr(14)
+	for _, candle := range candles {
+		for _, indicator := range []indicators.Indicator{rsi, macd, atr} {
+			if err := indicator.Update(candle); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	rsiSeries, _ := indicators.RsiSeries(candles, 14)
+	macdSeries, _ := indicators.MacdSeries(candles, 5, 12, 4)
+	atrSeries, _ := indicators.AtrSeries(candles, 14)
+	last := len(candles) - 1
+
+	assertFloat(t, "rsi", rsi.Value(), rsiSeries[last])
+	assertFloat(t, "macd", macd.Value().Signal, macdSeries[last].Signal)
+	assertFloat(t, "atr", atr.Value(), atrSeries[last])
+	if !rsi.Ready() || !macd.Ready() || !atr.Ready() {
+		t.Error("expected indicators to be ready")
+	}
+
+	// Feeding the closes directly gives the same result as the candles.
+	ema, _ := indicators.NewEma(10)
+	closes, err := indicators.Closes(indicatorCandles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range closes {
+		ema.Add(c)
+	}
+	assertFloat(t, "ema", ema.Value(), 44.11929901522182)
+
+	if err := rsi.Update(candles[last]); !errors.Is(err, indicators.ErrCandleOutOfOrder) {
+		t.Errorf("expected a repeated candle to be rejected, got %v", err)
+	}
+}
+
+func TestSessionVwapResetsAtMidnight(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	candle := func(at time.Time, price, volume string) adv.Candle {
+		return adv.Candle{Start: fmt.Sprintf("%d", at.Unix()), Open: price, High: price, Low: price, Close: price, Volume: volume}
+	}
+
+	// 2024-01-02 04:00 UTC is 23:00 in New York, still the first session.
+	candles := []adv.Candle{
+		candle(time.Date(2024, 1, 1, 15, 0, 0, 0, time.UTC), "100", "1"),
+		candle(time.Date(2024, 1, 2, 4, 0, 0, 0, time.UTC), "110", "3"),
+		candle(time.Date(2024, 1, 2, 6, 0, 0, 0, time.UTC), "120", "2"),
+	}
+
+	vwap, err := indicators.SessionVwapSeries(candles, newYork)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFloat(t, "vwap", vwap[1], 107.5)
+	assertFloat(t, "vwap", vwap[2], 120)
+
+	vwap, err = indicators.SessionVwapSeries(candles, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFloat(t, "vwap", vwap[1], 110)
+	assertFloat(t, "vwap", vwap[2], 114)
+}
+
+func TestIndicatorInvalidArguments(t *testing.T) {
+	if _, err := indicators.NewSma(0); !errors.Is(err, indicators.ErrInvalidPeriod) {
+		t.Errorf("expected an invalid period error, got %v", err)
+	}
+	if _, err := indicators.NewMacd(26, 12, 9); err == nil {
+		t.Error("expected an error for a fast period longer than the slow period")
+	}
+	if _, err := indicators.NewBollinger(20, 0); err == nil {
+		t.Error("expected an error for a zero band width")
+	}
+	if _, err := indicators.NewVwap(-1); !errors.Is(err, indicators.ErrInvalidPeriod) {
+		t.Errorf("expected an invalid period error, got %v", err)
+	}
+
+	sma, _ := indicators.NewSma(3)
+	if err := sma.Update(adv.Candle{Start: "1704067200", Close: "x"}); err == nil {
+		t.Error("expected an error for an invalid close")
+	}
+	if sma.Ready() || !math.IsNaN(sma.Value()) {
+		t.Error("expected an invalid candle not to be counted")
+	}
+}
@@ -0,0 +1,238 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"net/http"
+	"testing"
+)
+
+func TestKillSwitch(t *testing.T) {
+	client := adv.NewClient(&adv.Credentials{}, http.Client{})
+
+	ctx := context.Background()
+	if _, err := client.EngageKillSwitch(ctx, &adv.EngageKillSwitchRequest{Reason: "incident"}); err != nil {
+		t.Fatalf("failed to engage kill switch: %v", err)
+	}
+
+	if !client.KillSwitchEngaged() {
+		t.Fatal("expected kill switch to be engaged")
+	}
+
+	copied := *client
+
+	_, err := copied.CreateOrder(ctx, &adv.CreateOrderRequest{ProductId: "BTC-USD", Side: "BUY"})
+	if !errors.Is(err, adv.ErrKillSwitchEngaged) {
+		t.Fatalf("expected kill switch error, got: %v", err)
+	}
+
+	var ksErr *adv.KillSwitchError
+	if !errors.As(err, &ksErr) || ksErr.Operation != "CreateOrder" || ksErr.Reason != "incident" {
+		t.Fatalf("unexpected kill switch error: %v", err)
+	}
+
+	if _, err := client.ClosePosition(ctx, &adv.ClosePositionRequest{ProductId: "BIT-28JUN24-CDE"}); !errors.Is(err, adv.ErrKillSwitchEngaged) {
+		t.Fatalf("expected kill switch error, got: %v", err)
+	}
+
+	client.ReleaseKillSwitch()
+
+	if copied.KillSwitchEngaged() {
+		t.Fatal("expected kill switch to be released")
+	}
+}
+
+func restingBuy(t *testing.T, client *adv.Client, clientOrderId string) string {
+	t.Helper()
+	response, err := client.CreateOrder(context.Background(), &adv.CreateOrderRequest{
+		ProductId:          "BTC-USD",
+		Side:               "BUY",
+		ClientOrderId:      clientOrderId,
+		OrderConfiguration: adv.OrderConfiguration{LimitLimitGtc: &adv.LimitGtc{BaseSize: "0.0001", LimitPrice: "50"}},
+	})
+	if err != nil || !response.Success {
+		t.Fatalf("order failed: %v %+v", err, response)
+	}
+	return response.OrderId
+}
+
+func postCount(server *advtest.Server) int {
+	var n int
+	for _, r := range server.Requests() {
+		if r.Method == http.MethodPost {
+			n++
+		}
+	}
+	return n
+}
+
+func TestKillSwitchBlocksEveryMutatingEndpoint(t *testing.T) {
+	server := setupFakeServer(t)
+	client := server.Client()
+	ctx := context.Background()
+
+	orderId := restingBuy(t, client, "resting-1")
+
+	if _, err := client.EngageKillSwitch(ctx, &adv.EngageKillSwitchRequest{Reason: "incident"}); err != nil {
+		t.Fatal(err)
+	}
+	posts := postCount(server)
+
+	blocked := map[string]func() error{
+		"CreateOrder": func() error {
+			_, err := client.CreateOrder(ctx, &adv.CreateOrderRequest{ProductId: "BTC-USD", Side: "BUY"})
+			return err
+		},
+		"EditOrder": func() error {
+			_, err := client.EditOrder(ctx, &adv.EditOrderRequest{OrderId: orderId, Price: "51", Size: "0.0001"})
+			return err
+		},
+		"ClosePosition": func() error {
+			_, err := client.ClosePosition(ctx, &adv.ClosePositionRequest{ProductId: "BIT-28JUN24-CDE"})
+			return err
+		},
+		"CommitConvertQuote": func() error {
+			_, err := client.CommitConvertQuote(ctx, &adv.CommitConvertQuoteRequest{TradeId: "trade", FromAccount: "USD", ToAccount: "USDC"})
+			return err
+		},
+		"MovePortfolioFunds": func() error {
+			_, err := client.MovePortfolioFunds(ctx, &adv.MovePortfolioFundsRequest{SourcePortfolioUuid: server.DefaultPortfolio()})
+			return err
+		},
+		"ScheduleFuturesSweep": func() error {
+			_, err := client.ScheduleFuturesSweep(ctx, &adv.ScheduleFuturesSweepRequest{UsdAmount: "1"})
+			return err
+		},
+	}
+
+	for operation, call := range blocked {
+		err := call()
+		var ksErr *adv.KillSwitchError
+		if !errors.As(err, &ksErr) || ksErr.Operation != operation || ksErr.Reason != "incident" {
+			t.Errorf("%s: expected kill switch error, got: %v", operation, err)
+		}
+	}
+
+	if n := postCount(server); n != posts {
+		t.Fatalf("expected blocked calls not to reach the server, got %d new requests", n-posts)
+	}
+
+	if _, err := client.ListAccounts(ctx, &adv.ListAccountsRequest{}); err != nil {
+		t.Errorf("expected reads to work while engaged: %v", err)
+	}
+	if _, err := client.GetOrder(ctx, &adv.GetOrderRequest{OrderId: orderId}); err != nil {
+		t.Errorf("expected reads to work while engaged: %v", err)
+	}
+
+	canceled, err := client.CancelOrders(ctx, &adv.CancelOrdersRequest{OrderIds: []string{orderId}})
+	if err != nil || !canceled.Results[0].Success {
+		t.Fatalf("expected cancels to work while engaged: %v %+v", err, canceled)
+	}
+
+	client.ReleaseKillSwitch()
+	restingBuy(t, client, "resting-2")
+}
+
+func TestKillSwitchSharedByClientCopies(t *testing.T) {
+	server := setupFakeServer(t)
+	ctx := context.Background()
+
+	client := server.Client()
+	copied := *client
+
+	if _, err := client.EngageKillSwitch(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.ReleaseKillSwitch)
+
+	if _, err := copied.CreateOrder(ctx, &adv.CreateOrderRequest{ProductId: "BTC-USD", Side: "BUY"}); !errors.Is(err, adv.ErrKillSwitchEngaged) {
+		t.Fatalf("expected a copy made before engaging to be blocked, got: %v", err)
+	}
+
+	// Clients created with NewClient keep their own switch.
+	if server.Client().KillSwitchEngaged() {
+		t.Fatal("expected a separately constructed client to be unaffected")
+	}
+}
+
+func TestKillSwitchRequiresNewClient(t *testing.T) {
+	server := setupFakeServer(t)
+	ctx := context.Background()
+
+	literal := adv.Client{Credentials: server.Credentials(), HttpBaseUrl: server.BaseUrl()}
+	other := adv.Client{Credentials: server.Credentials(), HttpBaseUrl: server.BaseUrl()}
+
+	if _, err := literal.EngageKillSwitch(ctx, nil); !errors.Is(err, adv.ErrKillSwitchUnavailable) {
+		t.Fatalf("expected a struct literal client to have no kill switch, got: %v", err)
+	}
+
+	if literal.KillSwitchEngaged() || other.KillSwitchEngaged() {
+		t.Fatal("expected struct literal clients not to share a switch")
+	}
+
+	restingBuy(t, &other, "literal-client")
+}
+
+func TestKillSwitchFlattenOnEngage(t *testing.T) {
+	server := setupFakeServer(t)
+	client := server.Client()
+	ctx := context.Background()
+
+	server.AddProduct(adv.Product{
+		ProductId:      "BIT-28JUN24-CDE",
+		Price:          "60000",
+		BaseIncrement:  "1",
+		PriceIncrement: "5",
+		BaseMinSize:    "1",
+		BaseMaxSize:    "1000",
+	})
+	server.SetBook("BIT-28JUN24-CDE", []adv.Level{{Price: "59995", Size: "100"}}, []adv.Level{{Price: "60005", Size: "100"}})
+	server.AddFuturesPosition(adv.CfmFuturesPosition{ProductId: "BIT-28JUN24-CDE", Side: "LONG", NumberOfContracts: "2"})
+
+	// More open orders than fit in one page of ListOrders.
+	const orders = 1005
+	for i := 0; i < orders; i++ {
+		restingBuy(t, client, fmt.Sprintf("resting-%d", i))
+	}
+
+	response, err := client.EngageKillSwitch(ctx, &adv.EngageKillSwitchRequest{CancelOpenOrders: true, ClosePositions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.CancelResults) != orders {
+		t.Fatalf("expected %d cancel results, got %d", orders, len(response.CancelResults))
+	}
+	for _, o := range server.Orders() {
+		if o.Status == "OPEN" {
+			t.Fatalf("expected every open order to be canceled, found %s", o.OrderId)
+		}
+	}
+
+	if len(response.ClosedPositions) != 1 || !response.ClosedPositions[0].Success {
+		t.Fatalf("expected the futures position to be closed, got %+v", response.ClosedPositions)
+	}
+
+	if !client.KillSwitchEngaged() {
+		t.Fatal("expected kill switch to stay engaged after flattening")
+	}
+}
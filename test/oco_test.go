@@ -0,0 +1,176 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"testing"
+	"time"
+)
+
+func limitSell(productId, size, price string) *adv.CreateOrderRequest {
+	return &adv.CreateOrderRequest{
+		ProductId: productId,
+		Side:      "SELL",
+		OrderConfiguration: adv.OrderConfiguration{
+			LimitLimitGtc: &adv.LimitGtc{BaseSize: size, LimitPrice: price},
+		},
+	}
+}
+
+// setupOcoServer moves the offer above both take-profit legs so that buyers trade with them.
+func setupOcoServer(t *testing.T) *advtest.Server {
+	server := setupFakeServer(t)
+	server.SetBook("BTC-USD", []adv.Level{{Price: "99", Size: "10"}}, []adv.Level{{Price: "120", Size: "10"}})
+	return server
+}
+
+func ocoRequest() *adv.OcoRequest {
+	return &adv.OcoRequest{
+		Legs: [2]*adv.CreateOrderRequest{
+			limitSell("BTC-USD", "0.5", "105"),
+			limitSell("BTC-USD", "0.5", "110"),
+		},
+		PollInterval: 20 * time.Millisecond,
+	}
+}
+
+// onceResting runs f as soon as n client orders rest on the book.
+func onceResting(ctx context.Context, server *advtest.Server, n int, f func()) {
+	go func() {
+		for ctx.Err() == nil {
+			var open int
+			for _, o := range server.Orders() {
+				if o.Status == "OPEN" {
+					open++
+				}
+			}
+			if open >= n {
+				f()
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+}
+
+func TestOcoFillCancelsSibling(t *testing.T) {
+	server := setupOcoServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	onceResting(ctx, server, 2, func() { server.Trade("BTC-USD", "BUY", 105, 0.5) })
+
+	result, err := adv.NewOcoGroup(server.Client(), ocoRequest()).Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Triggered != 0 || result.BothFilled {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.Legs[0].Status != "FILLED" || !result.Legs[1].Canceled || result.Legs[1].Status != "CANCELLED" {
+		t.Fatalf("unexpected legs: %+v, %+v", result.Legs[0], result.Legs[1])
+	}
+	assertFloat(t, "BTC balance", server.Balance("", "BTC"), 0.5)
+	assertNoOpenOrders(t, server)
+}
+
+func TestOcoReportsBothLegsFillingBeforeCancel(t *testing.T) {
+	server := setupOcoServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// One buyer sweeps both legs between two polls.
+	onceResting(ctx, server, 2, func() { server.Trade("BTC-USD", "BUY", 110, 1) })
+
+	result, err := adv.NewOcoGroup(server.Client(), ocoRequest()).Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Triggered != 0 || !result.BothFilled || result.Legs[1].Canceled {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	assertFloat(t, "leg 1 filled", result.Legs[1].FilledSize, 0.5)
+	assertFloat(t, "BTC balance", server.Balance("", "BTC"), 0)
+}
+
+func TestOcoCancelsSiblingOfCanceledLeg(t *testing.T) {
+	server := setupOcoServer(t)
+	client := server.Client()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	onceResting(ctx, server, 2, func() {
+		for _, o := range server.Orders() {
+			if o.Status == "OPEN" && o.OrderConfiguration.LimitLimitGtc.LimitPrice == "105" {
+				client.CancelOrders(ctx, &adv.CancelOrdersRequest{OrderIds: []string{o.OrderId}})
+			}
+		}
+	})
+
+	result, err := adv.NewOcoGroup(client, ocoRequest()).Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Triggered != -1 || result.Legs[0].Canceled || !result.Legs[1].Canceled {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	assertNoOpenOrders(t, server)
+}
+
+func TestOcoCancelsLegsWhenContextIsDone(t *testing.T) {
+	server := setupOcoServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	onceResting(ctx, server, 2, cancel)
+
+	result, err := adv.NewOcoGroup(server.Client(), ocoRequest()).Run(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the context error, got: %v", err)
+	}
+
+	if !result.Legs[0].Canceled || !result.Legs[1].Canceled {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	assertNoOpenOrders(t, server)
+}
+
+func TestOcoCancelsFirstLegWhenSecondIsRejected(t *testing.T) {
+	server := setupOcoServer(t)
+
+	request := ocoRequest()
+	request.Legs[1] = limitSell("ETH-USD", "0.5", "110")
+
+	result, err := adv.NewOcoGroup(server.Client(), request).Run(context.Background())
+	if err == nil {
+		t.Fatal("expected the rejected leg to fail the group")
+	}
+
+	if result.Legs[0] == nil || !result.Legs[0].Canceled || result.Legs[1] != nil {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	assertNoOpenOrders(t, server)
+}
@@ -0,0 +1,318 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setupPaperClient(t *testing.T) (*adv.PaperClient, func(bids, asks []adv.Level)) {
+	t.Helper()
+
+	server := setupFakeServer(t)
+
+	paper, err := adv.NewPaperClient(server.Client(), &adv.PaperConfig{
+		Balances:     map[string]string{"USD": "10000"},
+		MakerFeeRate: "0.004",
+		TakerFeeRate: "0.006",
+		SlippageBps:  10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		for _, r := range server.Requests() {
+			if r.Method != "GET" {
+				t.Errorf("paper client sent %s %s to the exchange", r.Method, r.Path)
+			}
+		}
+	})
+
+	return paper, func(bids, asks []adv.Level) { server.SetBook("BTC-USD", bids, asks) }
+}
+
+func paperBalance(t *testing.T, paper *adv.PaperClient, currency string) (available, hold float64) {
+	t.Helper()
+
+	response, err := paper.ListAccounts(context.Background(), &adv.ListAccountsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, account := range response.Accounts {
+		if account.Currency == currency {
+			return parseTestFloat(account.AvailableBalance.Value), parseTestFloat(account.Hold.Value)
+		}
+	}
+	return 0, 0
+}
+
+func TestPaperMarketOrderWalksBook(t *testing.T) {
+	paper, setBook := setupPaperClient(t)
+	ctx := context.Background()
+
+	setBook([]adv.Level{{Price: "99", Size: "10"}}, []adv.Level{{Price: "101", Size: "1"}, {Price: "102", Size: "5"}})
+
+	response, err := paper.CreateOrder(ctx, &adv.CreateOrderRequest{
+		ProductId:          "BTC-USD",
+		Side:               "BUY",
+		OrderConfiguration: adv.OrderConfiguration{MarketMarketIoc: &adv.MarketIoc{BaseSize: "2"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !response.Success {
+		t.Fatalf("order rejected: %s", response.FailureReason)
+	}
+
+	order, err := paper.GetOrder(ctx, &adv.GetOrderRequest{OrderId: response.OrderId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Order.Status != "FILLED" {
+		t.Fatalf("expected FILLED, got %s", order.Order.Status)
+	}
+
+	value := 101*1.001 + 102*1.001
+	assertFloat(t, "filled value", parseTestFloat(order.Order.FilledValue), value)
+	assertFloat(t, "total fees", parseTestFloat(order.Order.TotalFees), value*0.006)
+
+	fills, err := paper.ListFills(ctx, &adv.ListFillsRequest{OrderId: response.OrderId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fills.Fills) != 1 || fills.Fills[0].LiquidityIndicator != "TAKER" {
+		t.Fatalf("expected a single taker fill, got %+v", fills.Fills)
+	}
+
+	usd, _ := paperBalance(t, paper, "USD")
+	btc, _ := paperBalance(t, paper, "BTC")
+	assertFloat(t, "USD", usd, 10000-value*1.006)
+	assertFloat(t, "BTC", btc, 2)
+}
+
+func TestPaperRestingLimitFillsAsMaker(t *testing.T) {
+	paper, setBook := setupPaperClient(t)
+	ctx := context.Background()
+
+	response, err := paper.CreateOrder(ctx, &adv.CreateOrderRequest{
+		ProductId:          "BTC-USD",
+		Side:               "BUY",
+		OrderConfiguration: adv.OrderConfiguration{LimitLimitGtc: &adv.LimitGtc{BaseSize: "1", LimitPrice: "95"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !response.Success {
+		t.Fatalf("order rejected: %s", response.FailureReason)
+	}
+
+	usd, hold := paperBalance(t, paper, "USD")
+	assertFloat(t, "USD hold", hold, 95*1.006)
+	assertFloat(t, "USD available", usd, 10000-95*1.006)
+
+	setBook([]adv.Level{{Price: "93", Size: "10"}}, []adv.Level{{Price: "94", Size: "10"}})
+
+	open, err := paper.ListOrders(ctx, &adv.ListOrdersRequest{OrderStatus: []string{"OPEN"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(open.Orders) != 0 {
+		t.Fatalf("expected no open orders, got %d", len(open.Orders))
+	}
+
+	fills, err := paper.ListFills(ctx, &adv.ListFillsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fills.Fills) != 1 || fills.Fills[0].LiquidityIndicator != "MAKER" || fills.Fills[0].Price != "95" {
+		t.Fatalf("expected a maker fill at the limit price, got %+v", fills.Fills)
+	}
+
+	usd, hold = paperBalance(t, paper, "USD")
+	assertFloat(t, "USD hold", hold, 0)
+	assertFloat(t, "USD available", usd, 10000-95*1.004)
+}
+
+func TestPaperCancelAndEditReserveFunds(t *testing.T) {
+	paper, _ := setupPaperClient(t)
+	ctx := context.Background()
+
+	response, err := paper.CreateOrder(ctx, &adv.CreateOrderRequest{
+		ProductId:          "BTC-USD",
+		Side:               "BUY",
+		OrderConfiguration: adv.OrderConfiguration{LimitLimitGtc: &adv.LimitGtc{BaseSize: "10", LimitPrice: "90"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edit, err := paper.EditOrder(ctx, &adv.EditOrderRequest{OrderId: response.OrderId, Price: "90", Size: "200"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edit.Success || len(edit.EditErrors) != 1 || edit.EditErrors[0].EditFailureReason != "INSUFFICIENT_FUND" {
+		t.Fatalf("expected the oversized edit to be refused, got %+v", edit)
+	}
+
+	edit, err = paper.EditOrder(ctx, &adv.EditOrderRequest{OrderId: response.OrderId, Price: "80", Size: "5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !edit.Success {
+		t.Fatalf("edit refused: %+v", edit.EditErrors)
+	}
+
+	_, hold := paperBalance(t, paper, "USD")
+	assertFloat(t, "USD hold", hold, 5*80*1.006)
+
+	cancel, err := paper.CancelOrders(ctx, &adv.CancelOrdersRequest{OrderIds: []string{response.OrderId, response.OrderId}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cancel.Results[0].Success || cancel.Results[1].FailureReason != "DUPLICATE_CANCEL_REQUEST" {
+		t.Fatalf("unexpected cancel results: %+v %+v", cancel.Results[0], cancel.Results[1])
+	}
+
+	usd, hold := paperBalance(t, paper, "USD")
+	assertFloat(t, "USD hold", hold, 0)
+	assertFloat(t, "USD available", usd, 10000)
+}
+
+func TestPaperRejections(t *testing.T) {
+	paper, _ := setupPaperClient(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		side          string
+		configuration adv.OrderConfiguration
+		reason        string
+	}{
+		{"no base balance", "SELL", adv.OrderConfiguration{MarketMarketIoc: &adv.MarketIoc{BaseSize: "1"}}, "INSUFFICIENT_FUND"},
+		{"market buy", "BUY", adv.OrderConfiguration{MarketMarketIoc: &adv.MarketIoc{BaseSize: "5"}}, ""},
+		{"sell above balance", "SELL", adv.OrderConfiguration{MarketMarketIoc: &adv.MarketIoc{BaseSize: "6"}}, "INSUFFICIENT_FUND"},
+		{"post only crosses", "BUY", adv.OrderConfiguration{LimitLimitGtc: &adv.LimitGtc{BaseSize: "1", LimitPrice: "102", PostOnly: true}}, "INVALID_LIMIT_PRICE_POST_ONLY"},
+		{"below min size", "BUY", adv.OrderConfiguration{LimitLimitGtc: &adv.LimitGtc{BaseSize: "0.00001", LimitPrice: "90"}}, "INVALID_BASE_SIZE_TOO_SMALL"},
+		{"buying power", "BUY", adv.OrderConfiguration{LimitLimitGtc: &adv.LimitGtc{BaseSize: "200", LimitPrice: "90"}}, "INSUFFICIENT_FUND"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := paper.CreateOrder(ctx, &adv.CreateOrderRequest{
+				ProductId:          "BTC-USD",
+				Side:               tt.side,
+				OrderConfiguration: tt.configuration,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tt.reason) == 0 {
+				if !response.Success {
+					t.Fatalf("order rejected: %s", response.FailureReason)
+				}
+				return
+			}
+			if response.Success || response.FailureReason != tt.reason {
+				t.Fatalf("expected %s, got success=%v reason=%s", tt.reason, response.Success, response.FailureReason)
+			}
+		})
+	}
+
+	if _, err := paper.CreateConvertQuote(ctx, &adv.CreateConvertQuoteRequest{}); !errors.Is(err, adv.ErrPaperUnsupported) {
+		t.Fatalf("expected ErrPaperUnsupported, got %v", err)
+	}
+}
+
+func TestPaperRunsTwapExecutor(t *testing.T) {
+	paper, _ := setupPaperClient(t)
+
+	report, err := adv.NewTwapExecutor(paper, &adv.TwapRequest{
+		ProductId: "BTC-USD",
+		Side:      "BUY",
+		TotalSize: "0.3",
+		Duration:  30 * time.Millisecond,
+		Slices:    3,
+		OrderType: adv.ExecutionOrderTypeMarketIoc,
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Status != adv.ExecutionStatusCompleted || len(report.ChildOrders) != 3 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	assertFloat(t, "filled", report.FilledSize, 0.3)
+
+	available, _ := paperBalance(t, paper, "BTC")
+	assertFloat(t, "BTC balance", available, 0.3)
+}
+
+func TestPaperDeduplicatesConcurrentClientOrderIds(t *testing.T) {
+	paper, _ := setupPaperClient(t)
+	ctx := context.Background()
+
+	request := &adv.CreateOrderRequest{
+		ProductId:          "BTC-USD",
+		Side:               "BUY",
+		ClientOrderId:      "retried-order",
+		OrderConfiguration: adv.OrderConfiguration{LimitLimitGtc: &adv.LimitGtc{BaseSize: "0.1", LimitPrice: "95"}},
+	}
+
+	const attempts = 20
+
+	orderIds := make(chan string, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := paper.CreateOrder(ctx, request)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			orderIds <- response.OrderId
+		}()
+	}
+	wg.Wait()
+	close(orderIds)
+
+	first := <-orderIds
+	for id := range orderIds {
+		if id != first {
+			t.Fatalf("expected every attempt to return order %s, got %s", first, id)
+		}
+	}
+
+	open, err := paper.ListOrders(ctx, &adv.ListOrdersRequest{OrderStatus: []string{"OPEN"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(open.Orders) != 1 {
+		t.Fatalf("expected a single open order, got %d", len(open.Orders))
+	}
+
+	_, hold := paperBalance(t, paper, "USD")
+	assertFloat(t, "USD hold", hold, 0.1*95*1.006)
+}
@@ -0,0 +1,163 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// pegBid pegs a bid for 1 BTC to a scripted top of book. Each poll calls book with its number,
+// starting at 1 for the initial placement; returning a zero bid ends the run.
+func pegBid(ctx context.Context, server *advtest.Server, book func(call int) (bid, ask float64)) (adv.PegState, error) {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var calls int
+	return adv.NewPegger(server.Client(), &adv.PegRequest{
+		ProductId:    "BTC-USD",
+		Side:         "BUY",
+		BaseSize:     "1",
+		Reference:    adv.PegReferenceBest,
+		PollInterval: 5 * time.Millisecond,
+		BookSource: func(ctx context.Context) (float64, float64, error) {
+			calls++
+			bid, ask := book(calls)
+			if bid == 0 {
+				cancel()
+				return 0, 0, ctx.Err()
+			}
+			return bid, ask, nil
+		},
+	}).Run(ctx)
+}
+
+// setupPegServer lowers the book bid below the peg so that sellers trade with the pegged order.
+func setupPegServer(t *testing.T) *advtest.Server {
+	server := setupFakeServer(t)
+	server.SetBook("BTC-USD", []adv.Level{{Price: "98", Size: "10"}}, []adv.Level{{Price: "101", Size: "10"}})
+	return server
+}
+
+func TestPegEditsOrderWhenBidMoves(t *testing.T) {
+	server := setupPegServer(t)
+
+	state, err := pegBid(context.Background(), server, func(call int) (float64, float64) {
+		switch {
+		case call == 1:
+			return 99, 101
+		case call < 4:
+			return 100, 101
+		}
+		return 0, 0
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the run to end with its context, got: %v", err)
+	}
+
+	if state.Edits != 1 || state.Replacements != 0 || state.Status != "CANCELLED" {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+	assertFloat(t, "price", state.Price, 100)
+
+	order, _ := server.Order(state.OrderId)
+	if order.OrderConfiguration.LimitLimitGtc.LimitPrice != "100.00" {
+		t.Fatalf("expected the order to be edited to 100, got %s", order.OrderConfiguration.LimitLimitGtc.LimitPrice)
+	}
+	assertNoOpenOrders(t, server)
+}
+
+func TestPegReplacesRemainderWhenEditFails(t *testing.T) {
+	server := setupPegServer(t)
+
+	state, err := pegBid(context.Background(), server, func(call int) (float64, float64) {
+		switch call {
+		case 1:
+			return 99, 101
+		case 2:
+			server.Trade("BTC-USD", "SELL", 99, 0.4)
+			server.Inject(advtest.Failure{
+				Method:     http.MethodPost,
+				Path:       "/brokerage/orders/edit",
+				StatusCode: http.StatusBadRequest,
+			})
+			return 100, 101
+		case 3:
+			return 100, 101
+		}
+		return 0, 0
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the run to end with its context, got: %v", err)
+	}
+
+	if state.Edits != 0 || state.Replacements != 1 {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+	assertFloat(t, "filled", state.FilledSize, 0.4)
+
+	order, _ := server.Order(state.OrderId)
+	if c := order.OrderConfiguration.LimitLimitGtc; c.LimitPrice != "100.00" || c.BaseSize != "0.6000" || !c.PostOnly {
+		t.Fatalf("expected the remainder to be replaced at 100, got %+v", c)
+	}
+	assertFloat(t, "BTC balance", server.Balance("", "BTC"), 1.4)
+	assertNoOpenOrders(t, server)
+}
+
+func TestPegFinishesWhenFilled(t *testing.T) {
+	server := setupPegServer(t)
+
+	state, err := pegBid(context.Background(), server, func(call int) (float64, float64) {
+		if call == 2 {
+			server.Trade("BTC-USD", "SELL", 99, 1)
+		}
+		if call > 3 {
+			return 0, 0
+		}
+		return 99, 101
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state.Status != "FILLED" || state.Edits != 0 || state.Replacements != 0 {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+	assertFloat(t, "filled", state.FilledSize, 1)
+}
+
+func TestPegNeverCrossesTheSpread(t *testing.T) {
+	server := setupPegServer(t)
+
+	// On a locked book the best bid is the ask, which a post-only bid cannot take.
+	state, err := pegBid(context.Background(), server, func(call int) (float64, float64) {
+		if call > 1 {
+			return 0, 0
+		}
+		return 100.01, 100.01
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the run to end with its context, got: %v", err)
+	}
+	assertFloat(t, "price", state.Price, 100)
+}
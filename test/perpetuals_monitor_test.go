@@ -0,0 +1,181 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const perpsPortfolio = "perps-portfolio"
+
+func addPerpetual(server *advtest.Server, productId, fundingRate string) {
+	server.AddProduct(adv.Product{
+		ProductId:      productId,
+		ProductType:    adv.ProductTypeFuture,
+		BaseIncrement:  "0.0001",
+		PriceIncrement: "0.1",
+		BaseMinSize:    "0.0001",
+		BaseMaxSize:    "1000",
+		FutureProductDetails: adv.FutureProductDetails{
+			ContractExpiryType: adv.ContractExpiryTypePerpetual,
+			PerpetualDetails:   adv.PerpetualDetails{FundingRate: fundingRate, FundingTime: "2024-06-01T16:00:00Z"},
+		},
+	})
+}
+
+func setPerpsMargin(server *advtest.Server, buffer, percentage string) {
+	server.SetPerpetualsPortfolio(adv.IntxPortfolio{
+		PortfolioUuid:         perpsPortfolio,
+		LiquidationBuffer:     buffer,
+		LiquidationPercentage: percentage,
+	})
+}
+
+// setupPerpsServer holds a long BTC position far from liquidation and a short ETH position 5%
+// from it.
+func setupPerpsServer(t *testing.T) *advtest.Server {
+	server := setupFakeServer(t)
+
+	addPerpetual(server, "BTC-PERP-INTX", "0.0001")
+	addPerpetual(server, "ETH-PERP-INTX", "0.0002")
+	setPerpsMargin(server, "5000", "10")
+
+	server.AddPerpetualsPosition(adv.IntxPosition{
+		ProductId:        "BTC-PERP-INTX",
+		PortfolioUuid:    perpsPortfolio,
+		Symbol:           "BTC-PERP-INTX",
+		PositionSide:     "POSITION_SIDE_LONG",
+		NetSize:          "0.5",
+		MarkPrice:        adv.Amount{Value: "60000", Currency: "USDC"},
+		LiquidationPrice: adv.Amount{Value: "50000", Currency: "USDC"},
+	})
+	server.AddPerpetualsPosition(adv.IntxPosition{
+		ProductId:        "ETH-PERP-INTX",
+		PortfolioUuid:    perpsPortfolio,
+		Symbol:           "ETH-PERP-INTX",
+		PositionSide:     "POSITION_SIDE_SHORT",
+		NetSize:          "-2",
+		MarkPrice:        adv.Amount{Value: "3000", Currency: "USDC"},
+		LiquidationPrice: adv.Amount{Value: "3150", Currency: "USDC"},
+	})
+
+	return server
+}
+
+func TestPerpetualsMonitorProjectsFunding(t *testing.T) {
+	server := setupPerpsServer(t)
+
+	monitor := adv.NewPerpetualsMonitor(server.Client(), &adv.PerpetualsMonitorRequest{PortfolioUuid: perpsPortfolio})
+
+	snapshot, err := monitor.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(snapshot.Positions) != 2 {
+		t.Fatalf("unexpected positions: %+v", snapshot.Positions)
+	}
+
+	// The long pays 0.01% of 30000 and the short receives 0.02% of 6000.
+	btc, eth := snapshot.Positions[0], snapshot.Positions[1]
+	assertFloat(t, "BTC notional", btc.Notional, 30000)
+	assertFloat(t, "BTC funding", btc.ProjectedFunding, -3)
+	assertFloat(t, "BTC distance", btc.LiquidationDistance, 10000.0/60000)
+	assertFloat(t, "ETH notional", eth.Notional, 6000)
+	assertFloat(t, "ETH funding", eth.ProjectedFunding, 1.2)
+	assertFloat(t, "ETH distance", eth.LiquidationDistance, 0.05)
+	assertFloat(t, "projected funding", snapshot.ProjectedFunding, -1.8)
+	assertFloat(t, "liquidation buffer", snapshot.LiquidationBuffer, 5000)
+
+	if monitor.Snapshot() != snapshot {
+		t.Fatal("expected the latest snapshot to be kept")
+	}
+}
+
+func TestPerpetualsMonitorRaisesEventsOnCrossings(t *testing.T) {
+	server := setupPerpsServer(t)
+
+	var events []adv.RiskEvent
+	monitor := adv.NewPerpetualsMonitor(server.Client(), &adv.PerpetualsMonitorRequest{
+		PortfolioUuid:         perpsPortfolio,
+		LiquidationDistance:   0.1,
+		LiquidationBuffer:     4000,
+		LiquidationPercentage: 50,
+		OnThreshold:           func(e adv.RiskEvent) { events = append(events, e) },
+	})
+
+	check := func(want ...adv.RiskEvent) {
+		t.Helper()
+		events = nil
+		if _, err := monitor.Check(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != len(want) {
+			t.Fatalf("expected %d events, got %+v", len(want), events)
+		}
+		for i, w := range want {
+			if events[i].Kind != w.Kind || events[i].ProductId != w.ProductId || events[i].Breached != w.Breached {
+				t.Fatalf("event %d: expected %+v, got %+v", i, w, events[i])
+			}
+		}
+	}
+
+	// Only the ETH position starts inside its threshold.
+	check(adv.RiskEvent{Kind: adv.RiskKindLiquidationDistance, ProductId: "ETH-PERP-INTX", Breached: true})
+
+	// Staying in the alert zone does not repeat the event.
+	check()
+
+	setPerpsMargin(server, "3000", "60")
+	check(
+		adv.RiskEvent{Kind: adv.RiskKindLiquidationBuffer, Breached: true},
+		adv.RiskEvent{Kind: adv.RiskKindLiquidationPercentage, Breached: true},
+	)
+
+	// The threshold itself is not a breach.
+	setPerpsMargin(server, "4000", "50")
+	check(
+		adv.RiskEvent{Kind: adv.RiskKindLiquidationBuffer, Breached: false},
+		adv.RiskEvent{Kind: adv.RiskKindLiquidationPercentage, Breached: false},
+	)
+}
+
+func TestPerpetualsMonitorReturnsPollErrors(t *testing.T) {
+	server := setupPerpsServer(t)
+
+	server.Inject(advtest.Failure{
+		Method:     http.MethodGet,
+		Path:       "/brokerage/intx/positions/*",
+		StatusCode: http.StatusInternalServerError,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := adv.NewPerpetualsMonitor(server.Client(), &adv.PerpetualsMonitorRequest{
+		PortfolioUuid: perpsPortfolio,
+		PollInterval:  5 * time.Millisecond,
+	}).Run(ctx)
+	if err == nil || ctx.Err() != nil {
+		t.Fatalf("expected the poll error to end the run, got: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"math"
+	"testing"
+	"time"
+)
+
+func testFills() []*adv.Fill {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fill := func(id, side, price, size, commission string, minutes int) *adv.Fill {
+		return &adv.Fill{
+			EntryId:    id,
+			TradeId:    id,
+			ProductId:  "BTC-USD",
+			Side:       side,
+			Price:      price,
+			Size:       size,
+			Commission: commission,
+			TradeTime:  start.Add(time.Duration(minutes) * time.Minute),
+		}
+	}
+
+	return []*adv.Fill{
+		fill("1", "BUY", "100", "1", "1", 0),
+		fill("2", "BUY", "120", "1", "1", 1),
+		fill("3", "BUY", "110", "1", "1", 2),
+		fill("4", "SELL", "130", "2", "2", 3),
+	}
+}
+
+func TestPnlCostBasisMethods(t *testing.T) {
+	tests := []struct {
+		method   string
+		realized float64
+		quantity float64
+		cost     float64
+	}{
+		// Sell 2 @ 130 with 1 fee: proceeds 258.
+		{adv.CostBasisFifo, 258 - 101 - 121, 1, 111},
+		{adv.CostBasisLifo, 258 - 111 - 121, 1, 101},
+		{adv.CostBasisHifo, 258 - 121 - 111, 1, 101},
+		{adv.CostBasisAverage, 258 - 2*111, 1, 111},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			engine, err := adv.NewPnlEngine(tt.method)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := engine.AddFills(testFills()...); err != nil {
+				t.Fatal(err)
+			}
+
+			p := engine.Position("BTC-USD")
+			if p == nil {
+				t.Fatal("expected position")
+			}
+
+			assertFloat(t, "realized", p.RealizedPnl, tt.realized)
+			assertFloat(t, "quantity", p.Quantity, tt.quantity)
+			assertFloat(t, "cost basis", p.CostBasis, tt.cost)
+			assertFloat(t, "fees", p.Fees, 5)
+
+			engine.Mark("BTC-USD", 150)
+			assertFloat(t, "unrealized", engine.Position("BTC-USD").UnrealizedPnl, 150-tt.cost)
+		})
+	}
+}
+
+func TestPnlIncrementalAndOutOfOrder(t *testing.T) {
+	fills := testFills()
+
+	engine, _ := adv.NewPnlEngine(adv.CostBasisFifo)
+
+	// Newest first, as returned by ListFills, and with a duplicate.
+	if err := engine.AddFills(fills[3], fills[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.AddFills(fills[0], fills[2], fills[3]); err != nil {
+		t.Fatal(err)
+	}
+
+	p := engine.Position("BTC-USD")
+	assertFloat(t, "realized", p.RealizedPnl, 258-101-121)
+	assertFloat(t, "quantity", p.Quantity, 1)
+
+	if n := len(engine.Disposals()); n != 2 {
+		t.Fatalf("expected 2 disposals, got %d", n)
+	}
+}
+
+func TestPnlRetriesRejectedBatch(t *testing.T) {
+	engine, err := adv.NewPnlEngine(adv.CostBasisFifo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fills := testFills()
+	invalid := *fills[3]
+	invalid.Side = "UNKNOWN"
+
+	if err := engine.AddFills(fills[0], fills[1], fills[2], &invalid); err == nil {
+		t.Fatal("expected a fill with an invalid side to reject the batch")
+	}
+	if p := engine.Position("BTC-USD"); p != nil {
+		t.Fatalf("expected nothing applied from the rejected batch, got %+v", p)
+	}
+
+	if err := engine.AddFills(fills...); err != nil {
+		t.Fatal(err)
+	}
+
+	p := engine.Position("BTC-USD")
+	if p == nil {
+		t.Fatal("expected the retried batch to be applied")
+	}
+	assertFloat(t, "quantity", p.Quantity, 1)
+	assertFloat(t, "realized", p.RealizedPnl, 258-101-121)
+}
+
+func TestPnlShort(t *testing.T) {
+	engine, _ := adv.NewPnlEngine(adv.CostBasisFifo)
+
+	now := time.Now()
+	err := engine.AddFills(
+		&adv.Fill{EntryId: "1", ProductId: "ETH-USD", Side: "SELL", Price: "200", Size: "2", TradeTime: now},
+		&adv.Fill{EntryId: "2", ProductId: "ETH-USD", Side: "BUY", Price: "150", Size: "1", TradeTime: now.Add(time.Second)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := engine.Position("ETH-USD")
+	assertFloat(t, "realized", p.RealizedPnl, 50)
+	assertFloat(t, "quantity", p.Quantity, -1)
+}
+
+func assertFloat(t *testing.T, name string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("%s: got %v, want %v", name, got, want)
+	}
+}
@@ -0,0 +1,150 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"net/http"
+	"testing"
+)
+
+// setupSnapshotServer adds a short of 50 BIT contracts of 0.01 BTC and a long BTC perpetual of 0.2
+// to the seeded balances of 10000 USD and 1 BTC.
+func setupSnapshotServer(t *testing.T) *advtest.Server {
+	server := setupFakeServer(t)
+	portfolio := server.DefaultPortfolio()
+
+	server.AddProduct(adv.Product{
+		ProductId:      expiringContract,
+		ProductType:    adv.ProductTypeFuture,
+		BaseIncrement:  "1",
+		PriceIncrement: "5",
+		BaseMinSize:    "1",
+		BaseMaxSize:    "1000",
+		FutureProductDetails: adv.FutureProductDetails{
+			ContractRootUnit:   "BTC",
+			ContractSize:       "0.01",
+			ContractExpiryType: adv.ContractExpiryTypeExpiring,
+		},
+	})
+	server.AddFuturesPosition(adv.CfmFuturesPosition{
+		ProductId:         expiringContract,
+		Side:              "SHORT",
+		NumberOfContracts: "50",
+		CurrentPrice:      "100",
+		UnrealizedPnl:     "-5",
+	})
+	server.SetFuturesBalanceSummary(adv.BalanceSummary{
+		CfmUsdBalance: adv.Amount{Value: "2000", Currency: "USD"},
+		UnrealizedPnl: adv.Amount{Value: "-5", Currency: "USD"},
+	})
+
+	server.SetPerpetualsPortfolio(adv.IntxPortfolio{
+		PortfolioUuid: portfolio,
+		Collateral:    "500",
+		UnrealizedPnl: adv.Amount{Value: "3", Currency: "USDC"},
+	})
+	server.AddPerpetualsPosition(adv.IntxPosition{
+		ProductId:     "BTC-PERP-INTX",
+		PortfolioUuid: portfolio,
+		Symbol:        "BTC-PERP-INTX",
+		PositionSide:  "POSITION_SIDE_LONG",
+		NetSize:       "0.2",
+		MarkPrice:     adv.Amount{Value: "100", Currency: "USDC"},
+		UnrealizedPnl: adv.Amount{Value: "3", Currency: "USDC"},
+	})
+
+	return server
+}
+
+func snapshotAsset(t *testing.T, snapshot *adv.PortfolioSnapshot, asset string) *adv.AssetExposure {
+	t.Helper()
+	for _, a := range snapshot.Assets {
+		if a.Asset == asset {
+			return a
+		}
+	}
+	t.Fatalf("asset %s not in snapshot: %+v", asset, snapshot.Assets)
+	return nil
+}
+
+func TestSnapshotPortfolioNetsDerivativesWithSpot(t *testing.T) {
+	server := setupSnapshotServer(t)
+
+	snapshot, err := adv.SnapshotPortfolio(context.Background(), server.Client(), server.DefaultPortfolio())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(snapshot.Warnings) > 0 {
+		t.Fatalf("unexpected warnings: %v", snapshot.Warnings)
+	}
+
+	// The futures position nets under BTC rather than under the BIT product code.
+	for _, a := range snapshot.Assets {
+		if a.Asset == "BIT" {
+			t.Fatalf("expected no exposure under the product code, got %+v", a)
+		}
+	}
+
+	btc := snapshotAsset(t, snapshot, "BTC")
+	assertFloat(t, "spot", btc.SpotBalance, 1)
+	assertFloat(t, "futures exposure", btc.FuturesExposure, -0.5)
+	assertFloat(t, "perps exposure", btc.PerpsExposure, 0.2)
+	assertFloat(t, "net exposure", btc.NetExposure, 0.7)
+	assertFloat(t, "unrealized pnl", btc.UnrealizedPnlUsd, -2)
+	assertFloat(t, "usd value", btc.UsdValue, 0.7*btc.UsdPrice)
+
+	usd := snapshotAsset(t, snapshot, "USD")
+	if !usd.IsCash {
+		t.Fatalf("expected USD to be cash: %+v", usd)
+	}
+	assertFloat(t, "cash", snapshot.CashUsd, 10000)
+	assertFloat(t, "futures cash", snapshot.FuturesCashUsd, 2000)
+	assertFloat(t, "perps collateral", snapshot.PerpsCollateralUsd, 500)
+	assertFloat(t, "perps pnl", snapshot.PerpsUnrealizedPnl, 3)
+}
+
+func TestSnapshotPortfolioWarnsAboutUnavailableSources(t *testing.T) {
+	server := setupSnapshotServer(t)
+
+	server.Inject(advtest.Failure{
+		Method:     http.MethodGet,
+		Path:       "/brokerage/intx/portfolio/*",
+		StatusCode: http.StatusForbidden,
+	})
+	server.Inject(advtest.Failure{
+		Method:     http.MethodGet,
+		Path:       "/brokerage/products/" + expiringContract,
+		StatusCode: http.StatusInternalServerError,
+	})
+
+	snapshot, err := adv.SnapshotPortfolio(context.Background(), server.Client(), server.DefaultPortfolio())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(snapshot.Warnings) != 2 || snapshot.PerpsPortfolio != nil {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+
+	// Without product details the position falls back to the product code, one unit per contract.
+	assertFloat(t, "BIT exposure", snapshotAsset(t, snapshot, "BIT").FuturesExposure, -50)
+	assertFloat(t, "BTC exposure", snapshotAsset(t, snapshot, "BTC").NetExposure, 1.2)
+}
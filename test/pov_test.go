@@ -0,0 +1,135 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"testing"
+	"time"
+)
+
+// printTrades adds n trades of size each to the tape, spaced apart and stamped ahead of now so that
+// an executor starting now counts them once its first interval has passed.
+func printTrades(server *advtest.Server, productId string, n int, size string, spacing time.Duration) {
+	at := time.Now().Add(50 * time.Millisecond)
+	trades := make([]adv.Trade, n)
+	for i := range trades {
+		trades[i] = adv.Trade{
+			TradeId: fmt.Sprintf("print-%d", i),
+			Price:   "100",
+			Size:    size,
+			Time:    at.Add(time.Duration(i) * spacing),
+			Side:    "BUY",
+		}
+	}
+	server.AddMarketTrades(productId, trades...)
+}
+
+func povRequest(productId, totalSize string, rate float64) *adv.PovRequest {
+	return &adv.PovRequest{
+		ProductId:         productId,
+		Side:              "BUY",
+		TotalSize:         totalSize,
+		ParticipationRate: rate,
+		Interval:          10 * time.Millisecond,
+		MaxDuration:       100 * time.Millisecond,
+		OrderType:         adv.ExecutionOrderTypeMarketIoc,
+		PollInterval:      5 * time.Millisecond,
+	}
+}
+
+func TestPovCountsEveryTradeInBusyIntervals(t *testing.T) {
+	server := setupFakeServer(t)
+
+	// More trades than one GetMarketTrades page holds, spread over several seconds because the API
+	// pages on whole seconds.
+	printTrades(server, "BTC-USD", 1500, "0.001", 1600*time.Microsecond)
+
+	request := povRequest("BTC-USD", "1", 0.2)
+	request.Interval = 2500 * time.Millisecond
+	request.MaxDuration = 3500 * time.Millisecond
+
+	report, _ := adv.NewPovExecutor(server.Client(), request).Run(context.Background())
+
+	if len(report.ChildOrders) == 0 {
+		t.Fatalf("expected a child order, got %+v", report)
+	}
+	assertFloat(t, "first child", report.ChildOrders[0].Size, 0.3)
+}
+
+func TestPovCapsFoldedRemainder(t *testing.T) {
+	server := setupFakeServer(t)
+	server.AddProduct(adv.Product{
+		ProductId:      "ETH-USD",
+		Price:          "100",
+		BaseIncrement:  "0.01",
+		PriceIncrement: "0.01",
+		BaseMinSize:    "0.1",
+		BaseMaxSize:    "1000",
+	})
+	server.SetBook("ETH-USD", []adv.Level{{Price: "99", Size: "10"}}, []adv.Level{{Price: "101", Size: "10"}})
+
+	printTrades(server, "ETH-USD", 19, "0.1", time.Microsecond)
+
+	// Half of the 1.9 printed is 0.95, leaving 0.05: too small to trade, but folding it in would
+	// take the child past the participation cap.
+	report, _ := adv.NewPovExecutor(server.Client(), povRequest("ETH-USD", "1", 0.5)).Run(context.Background())
+
+	if len(report.ChildOrders) == 0 {
+		t.Fatalf("expected a child order, got %+v", report)
+	}
+	assertFloat(t, "first child", report.ChildOrders[0].Size, 0.95)
+	if report.Status != adv.ExecutionStatusCompleted {
+		t.Fatalf("expected an untradable remainder not to count as a shortfall, got %+v", report)
+	}
+}
+
+func TestPovExcludesOwnFillsFromVolume(t *testing.T) {
+	server := setupFakeServer(t)
+
+	printTrades(server, "BTC-USD", 10, "0.1", time.Microsecond)
+
+	// Half of the 1.0 printed is 0.5. The child's own 0.5 on the tape must not raise the base.
+	report, _ := adv.NewPovExecutor(server.Client(), povRequest("BTC-USD", "2", 0.5)).Run(context.Background())
+
+	if len(report.ChildOrders) != 1 {
+		t.Fatalf("expected a single child order, got %+v", report.ChildOrders)
+	}
+	assertFloat(t, "filled", report.FilledSize, 0.5)
+}
+
+func TestPovReportsCallerDeadline(t *testing.T) {
+	server := setupFakeServer(t)
+
+	request := povRequest("BTC-USD", "1", 0.5)
+	request.MaxDuration = time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	report, err := adv.NewPovExecutor(server.Client(), request).Run(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the caller's deadline to be reported, got: %v", err)
+	}
+	if report.Status != adv.ExecutionStatusFailed {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
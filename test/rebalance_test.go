@@ -0,0 +1,237 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupRebalanceServer adds an empty ETH-USD market next to the seeded BTC-USD one, so that a
+// rebalance out of BTC into ETH plans a sell and a buy.
+func setupRebalanceServer(t *testing.T) *advtest.Server {
+	server := setupFakeServer(t)
+
+	server.AddProduct(adv.Product{
+		ProductId:      "ETH-USD",
+		Price:          "10",
+		BaseIncrement:  "0.001",
+		PriceIncrement: "0.01",
+		BaseMinSize:    "0.001",
+		BaseMaxSize:    "1000",
+	})
+	server.SetBook("ETH-USD", []adv.Level{{Price: "9.99", Size: "1000"}}, []adv.Level{{Price: "10.01", Size: "1000"}})
+	server.AddAccount(adv.Account{Currency: "ETH", AvailableBalance: adv.Amount{Value: "0"}})
+
+	return server
+}
+
+func rebalanceRequest(server *advtest.Server, targets map[string]float64) *adv.RebalanceRequest {
+	return &adv.RebalanceRequest{
+		PortfolioUuid: server.DefaultPortfolio(),
+		QuoteCurrency: "USD",
+		TargetWeights: targets,
+		Tolerance:     0.001,
+		PollInterval:  10 * time.Millisecond,
+	}
+}
+
+func TestRebalancePreviewsAgainstPortfolio(t *testing.T) {
+	server := setupFakeServer(t)
+
+	request := rebalanceRequest(server, map[string]float64{"BTC": 0})
+	request.DryRun = true
+
+	response, err := adv.Rebalance(context.Background(), server.Client(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.Trades) != 1 || response.Trades[0].Preview == nil {
+		t.Fatalf("expected one previewed trade, got %+v", response.Trades)
+	}
+
+	var previews int
+	for _, r := range server.Requests() {
+		if r.Method != http.MethodPost || r.Path != "/brokerage/orders/preview" {
+			continue
+		}
+		previews++
+
+		preview := &adv.CreateOrderPreviewRequest{}
+		if err := json.Unmarshal(r.Body, preview); err != nil {
+			t.Fatal(err)
+		}
+		if preview.RetailPortfolioId != request.PortfolioUuid {
+			t.Errorf("expected preview in portfolio %s, got %q", request.PortfolioUuid, preview.RetailPortfolioId)
+		}
+	}
+
+	if previews != 1 {
+		t.Errorf("expected 1 preview request, got %d", previews)
+	}
+}
+
+func TestRebalanceSellsOnlyAvailableBalance(t *testing.T) {
+	server, err := advtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	server.AddProduct(adv.Product{
+		ProductId:      "BTC-USD",
+		Price:          "100",
+		BaseIncrement:  "0.0001",
+		PriceIncrement: "0.01",
+		BaseMinSize:    "0.0001",
+		BaseMaxSize:    "1000",
+	})
+	server.SetBook("BTC-USD", []adv.Level{{Price: "99", Size: "10"}}, []adv.Level{{Price: "101", Size: "10"}})
+	server.AddAccount(adv.Account{Currency: "USD", AvailableBalance: adv.Amount{Value: "10000"}})
+
+	// 0.4 of the BTC is held by an order the rebalance knows nothing about.
+	server.AddAccount(adv.Account{
+		Currency:         "BTC",
+		AvailableBalance: adv.Amount{Value: "0.6"},
+		Hold:             adv.Amount{Value: "0.4"},
+	})
+
+	request := rebalanceRequest(server, map[string]float64{"BTC": 0})
+	request.DryRun = true
+
+	response, err := adv.Rebalance(context.Background(), server.Client(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, h := range response.Holdings {
+		if h.Asset == "BTC" {
+			assertFloat(t, "quantity", h.Quantity, 1)
+			assertFloat(t, "available", h.Available, 0.6)
+		}
+	}
+
+	if len(response.Trades) != 1 {
+		t.Fatalf("expected one trade, got %d", len(response.Trades))
+	}
+
+	trade := response.Trades[0]
+	if trade.Side != "SELL" || trade.BaseSize != "0.6000" {
+		t.Errorf("expected SELL 0.6000, got %s %s", trade.Side, trade.BaseSize)
+	}
+	if len(trade.Error) > 0 {
+		t.Errorf("expected the sell to preview cleanly, got %s", trade.Error)
+	}
+}
+
+func TestRebalanceSkipsBuysWhenSellPreviewFails(t *testing.T) {
+	server := setupRebalanceServer(t)
+
+	server.Inject(advtest.Failure{
+		Method:     http.MethodPost,
+		Path:       "/brokerage/orders/preview",
+		Times:      1,
+		StatusCode: http.StatusInternalServerError,
+	})
+
+	response, err := adv.Rebalance(context.Background(), server.Client(), rebalanceRequest(server, map[string]float64{"BTC": 0, "ETH": 0.1}))
+	if err == nil {
+		t.Fatal("expected the failed sell to be reported")
+	}
+	if !strings.Contains(err.Error(), "BUY ETH-USD") {
+		t.Errorf("expected the skipped buy to be reported, got %v", err)
+	}
+
+	var sells, buys int
+	for _, trade := range response.Trades {
+		switch trade.Side {
+		case "SELL":
+			sells++
+			if len(trade.Error) == 0 {
+				t.Error("expected the sell to carry its preview error")
+			}
+		case "BUY":
+			buys++
+			if len(trade.Skipped) == 0 || trade.Order != nil {
+				t.Errorf("expected the buy to be skipped, got %+v", trade)
+			}
+		}
+	}
+
+	if sells != 1 || buys != 1 {
+		t.Fatalf("expected a sell and a buy, got %d and %d", sells, buys)
+	}
+
+	if orders := server.Orders(); len(orders) != 0 {
+		t.Errorf("expected no orders, got %d", len(orders))
+	}
+}
+
+func TestRebalanceSellsBeforeBuying(t *testing.T) {
+	server := setupRebalanceServer(t)
+
+	response, err := adv.Rebalance(context.Background(), server.Client(), rebalanceRequest(server, map[string]float64{"BTC": 0, "ETH": 0.1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.Trades) != 2 || response.Trades[0].Side != "SELL" || response.Trades[1].Side != "BUY" {
+		t.Fatalf("expected a sell then a buy, got %+v", response.Trades)
+	}
+
+	for _, trade := range response.Trades {
+		if trade.Order == nil || trade.Order.Status != "FILLED" {
+			t.Errorf("expected %s %s to fill, got %+v", trade.Side, trade.ProductId, trade.Order)
+		}
+	}
+
+	assertFloat(t, "BTC balance", server.Balance(server.DefaultPortfolio(), "BTC"), 0)
+}
+
+func TestRebalancePreviewsBuysAgainstSellProceeds(t *testing.T) {
+	server := setupRebalanceServer(t)
+	client := server.Client()
+	ctx := context.Background()
+
+	// Move every USD out of the managed value, so the buy can only be funded by the sell.
+	if _, err := adv.ConvertFunds(ctx, client, "USD", "USDC", "10000", &adv.ConvertOptions{PollInterval: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := adv.Rebalance(ctx, client, rebalanceRequest(server, map[string]float64{"BTC": 0, "ETH": 0.5}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.Trades) != 2 || response.Trades[1].Side != "BUY" {
+		t.Fatalf("expected a sell then a buy, got %+v", response.Trades)
+	}
+
+	buy := response.Trades[1]
+	if len(buy.Error) > 0 || buy.Order == nil || buy.Order.Status != "FILLED" {
+		t.Fatalf("expected the buy to fill from the sell proceeds, got %+v", buy)
+	}
+
+	assertFloat(t, "ETH balance", server.Balance(server.DefaultPortfolio(), "ETH"), 5)
+}
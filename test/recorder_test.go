@@ -0,0 +1,160 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorderRoundTrip(t *testing.T) {
+	server := setupFakeServer(t)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	recorder, err := advtest.NewRecorder(path, advtest.ModeRecord, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := server.Client()
+	client.HttpClient = recorder.HttpClient()
+
+	recorded, err := client.ListAccounts(ctx, &adv.ListAccountsRequest{RetailPortfolioId: server.DefaultPortfolio()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.GetProduct(ctx, &adv.GetProductRequest{ProductId: "BTC-USD"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range []string{recorded.Accounts[0].Uuid, server.DefaultPortfolio(), "Bearer"} {
+		if strings.Contains(string(data), secret) {
+			t.Fatalf("cassette leaks %s", secret)
+		}
+	}
+
+	server.Close()
+
+	replayer, err := advtest.NewRecorder(path, advtest.ModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentials, err := advtest.NewTestCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offline := adv.NewClient(credentials, replayer.HttpClient()).BaseUrl(server.BaseUrl())
+
+	product, err := offline.GetProduct(ctx, &adv.GetProductRequest{ProductId: "BTC-USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.ProductId != "BTC-USD" || product.BaseIncrement != "0.0001" {
+		t.Fatalf("unexpected replayed product: %+v", product)
+	}
+
+	_, err = offline.GetProduct(ctx, &adv.GetProductRequest{ProductId: "ETH-USD"})
+	if !errors.Is(err, advtest.ErrUnmatchedRequest) {
+		t.Fatalf("expected unmatched request error, got: %v", err)
+	}
+
+	if err := replayer.Stop(); !errors.Is(err, advtest.ErrUnmatchedRequest) {
+		t.Fatalf("expected stop to report unmatched requests, got: %v", err)
+	}
+
+	if _, err := advtest.NewRecorder(filepath.Join(t.TempDir(), "missing.json"), advtest.ModeReplay, nil); err == nil {
+		t.Fatal("expected missing cassette to fail")
+	}
+}
+
+func TestRecorderRedactsReplayedRequests(t *testing.T) {
+	server := setupFakeServer(t)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	portfolio := server.DefaultPortfolio()
+
+	// "10" stands in for a short identifier such as a numeric user id.
+	options := &advtest.RecorderOptions{Redact: []string{portfolio, "10"}}
+
+	recorder, err := advtest.NewRecorder(path, advtest.ModeRecord, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := server.Client()
+	client.HttpClient = recorder.HttpClient()
+
+	if _, err := client.ListAccounts(ctx, &adv.ListAccountsRequest{RetailPortfolioId: portfolio}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.GetProduct(ctx, &adv.GetProductRequest{ProductId: "BTC-USD"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Close()
+
+	replayer, err := advtest.NewRecorder(path, advtest.ModeReplay, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offline := adv.NewClient(server.Credentials(), replayer.HttpClient()).BaseUrl(server.BaseUrl())
+
+	accounts, err := offline.ListAccounts(ctx, &adv.ListAccountsRequest{RetailPortfolioId: portfolio})
+	if err != nil {
+		t.Fatalf("expected the request carrying the real portfolio to match: %v", err)
+	}
+	if len(accounts.Accounts) == 0 || accounts.Accounts[0].RetailPortfolioId == portfolio {
+		t.Fatalf("expected redacted accounts, got %+v", accounts.Accounts)
+	}
+
+	product, err := offline.GetProduct(ctx, &adv.GetProductRequest{ProductId: "BTC-USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.Price != "100" || product.BaseMaxSize != "1000" {
+		t.Fatalf("expected values containing a redacted value to survive, got price %s and max size %s", product.Price, product.BaseMaxSize)
+	}
+
+	if err := replayer.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
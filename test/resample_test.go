@@ -0,0 +1,230 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"fmt"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// intervalCandles returns oldest first candles every interval from start, with open, high, low and
+// close of i, i+2, i-1 and i+1 and a volume of 1 for the ith candle.
+func intervalCandles(start time.Time, interval time.Duration, n int) []adv.Candle {
+	candles := make([]adv.Candle, n)
+	for i := range candles {
+		candles[i] = adv.Candle{
+			Start:  fmt.Sprintf("%d", start.Add(time.Duration(i)*interval).Unix()),
+			Open:   fmt.Sprintf("%d", i),
+			High:   fmt.Sprintf("%d", i+2),
+			Low:    fmt.Sprintf("%d", i-1),
+			Close:  fmt.Sprintf("%d", i+1),
+			Volume: "1",
+		}
+	}
+	return candles
+}
+
+func assertCandle(t *testing.T, candle adv.Candle, start time.Time, open, high, low, close, volume float64) {
+	t.Helper()
+	got, err := candle.StartTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(start) {
+		t.Errorf("expected candle at %v, got %v", start, got)
+	}
+	o, h, l, c, v, err := candle.Ohlcv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFloat(t, "open", o, open)
+	assertFloat(t, "high", h, high)
+	assertFloat(t, "low", l, low)
+	assertFloat(t, "close", c, close)
+	assertFloat(t, "volume", v, volume)
+}
+
+func TestResampleFixedIntervals(t *testing.T) {
+	source := intervalCandles(backtestStart, time.Minute, 10)
+	// Resampling must not depend on the newest first order the API returns.
+	for i, j := 0, len(source)-1; i < j; i, j = i+1, j-1 {
+		source[i], source[j] = source[j], source[i]
+	}
+
+	bars, err := adv.ResampleCandles(source, &adv.ResampleRequest{Interval: 3 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bars) != 4 {
+		t.Fatalf("expected 4 three minute bars, got %d", len(bars))
+	}
+	assertCandle(t, bars[0], minutes(0), 0, 4, -1, 3, 3)
+	assertCandle(t, bars[1], minutes(3), 3, 7, 2, 6, 3)
+	assertCandle(t, bars[3], minutes(9), 9, 11, 8, 10, 1)
+
+	hourly := intervalCandles(backtestStart, time.Hour, 24)
+	bars, err = adv.ResampleCandles(hourly, &adv.ResampleRequest{Interval: 4 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bars) != 6 {
+		t.Fatalf("expected 6 four hour bars, got %d", len(bars))
+	}
+	assertCandle(t, bars[5], backtestStart.Add(20*time.Hour), 20, 25, 19, 24, 4)
+}
+
+func TestResampleCalendarIntervals(t *testing.T) {
+	// 2023-12-25 is a Monday; 50 daily candles run to 2024-02-12.
+	daily := intervalCandles(time.Date(2023, 12, 25, 0, 0, 0, 0, time.UTC), 24*time.Hour, 50)
+
+	weekly, err := adv.ResampleCandles(daily, &adv.ResampleRequest{Interval: 7 * 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(weekly) != 8 {
+		t.Fatalf("expected 8 weekly bars, got %d", len(weekly))
+	}
+	assertCandle(t, weekly[1], backtestStart, 7, 15, 6, 14, 7)
+
+	sunday := time.Date(2023, 12, 31, 17, 0, 0, 0, time.UTC)
+	weekly, err = adv.ResampleCandles(daily, &adv.ResampleRequest{Interval: 7 * 24 * time.Hour, Origin: sunday})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertCandle(t, weekly[1], sunday, 7, 15, 6, 14, 7)
+
+	monthly, err := adv.ResampleCandles(daily, &adv.ResampleRequest{Months: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(monthly) != 3 {
+		t.Fatalf("expected 3 monthly bars, got %d", len(monthly))
+	}
+	assertCandle(t, monthly[0], time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC), 0, 8, -1, 7, 7)
+	assertCandle(t, monthly[1], backtestStart, 7, 39, 6, 38, 31)
+	assertCandle(t, monthly[2], time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), 38, 51, 37, 50, 12)
+
+	quarterly, err := adv.ResampleCandles(daily, &adv.ResampleRequest{Months: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quarterly) != 2 {
+		t.Fatalf("expected 2 quarterly bars, got %d", len(quarterly))
+	}
+	assertCandle(t, quarterly[0], time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC), 0, 8, -1, 7, 7)
+}
+
+func TestResampleTimezoneAlignment(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hourly candles across the start of daylight saving time on 2024-03-10.
+	start := time.Date(2024, 3, 9, 0, 0, 0, 0, newYork)
+	hourly := intervalCandles(start, time.Hour, 71)
+
+	daily, err := adv.ResampleCandles(hourly, &adv.ResampleRequest{Interval: 24 * time.Hour, Location: newYork})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(daily) != 3 {
+		t.Fatalf("expected 3 daily bars, got %d", len(daily))
+	}
+	assertCandle(t, daily[0], start, 0, 25, -1, 24, 24)
+	// The day daylight saving time starts is only 23 hours long.
+	assertCandle(t, daily[1], time.Date(2024, 3, 10, 0, 0, 0, 0, newYork), 24, 48, 23, 47, 23)
+	assertCandle(t, daily[2], time.Date(2024, 3, 11, 0, 0, 0, 0, newYork), 47, 72, 46, 71, 24)
+
+	if _, err := adv.ResampleCandles(hourly, &adv.ResampleRequest{}); err == nil {
+		t.Error("expected an error without an interval")
+	}
+	if _, err := adv.ResampleCandles(hourly, &adv.ResampleRequest{Interval: time.Hour, Months: 1}); err == nil {
+		t.Error("expected an error with both an interval and months")
+	}
+}
+
+func TestCandlesFromTrades(t *testing.T) {
+	trade := func(id string, at time.Time, price, size string) adv.Trade {
+		return adv.Trade{TradeId: id, ProductId: "BTC-USD", Price: price, Size: size, Time: at}
+	}
+
+	// Newest first, as GetMarketTrades returns them, with two trades sharing a timestamp.
+	tape := []adv.Trade{
+		trade("6", minutes(3).Add(10*time.Second), "98", "1"),
+		trade("5", minutes(1).Add(30*time.Second), "104", "0.5"),
+		trade("4", minutes(1).Add(30*time.Second), "103", "0.5"),
+		trade("3", minutes(1), "105", "2"),
+		trade("2", minutes(0).Add(40*time.Second), "99", "1.5"),
+		trade("1", minutes(0).Add(10*time.Second), "100", "1"),
+	}
+
+	bars, err := adv.CandlesFromTrades(tape, &adv.ResampleRequest{Interval: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bars) != 3 {
+		t.Fatalf("expected 3 one minute bars, got %d", len(bars))
+	}
+	assertCandle(t, bars[0], minutes(0), 100, 100, 99, 99, 2.5)
+	assertCandle(t, bars[1], minutes(1), 105, 105, 103, 104, 3)
+	assertCandle(t, bars[2], minutes(3), 98, 98, 98, 98, 1)
+
+	if _, err := adv.CandlesFromTrades([]adv.Trade{trade("1", minutes(0), "x", "1")}, &adv.ResampleRequest{Interval: time.Minute}); err == nil {
+		t.Error("expected an error for an invalid trade price")
+	}
+}
+
+func TestCandlesFromMarketTrades(t *testing.T) {
+	server := setupFakeServer(t)
+	ctx := context.Background()
+
+	server.Trade("BTC-USD", "BUY", 101, 2)
+	server.Trade("BTC-USD", "SELL", 99, 3)
+
+	response, err := server.Client().GetMarketTrades(ctx, &adv.GetMarketTradesRequest{ProductId: "BTC-USD", Limit: "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tape := make([]adv.Trade, len(response.Trades))
+	for i, trade := range response.Trades {
+		tape[i] = *trade
+	}
+
+	bars, err := adv.CandlesFromTrades(tape, &adv.ResampleRequest{Interval: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var volume float64
+	for _, bar := range bars {
+		_, high, low, _, v, err := bar.Ohlcv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if high > 101 || low < 99 {
+			t.Errorf("expected prices within the book, got high %v low %v", high, low)
+		}
+		volume += v
+	}
+	assertFloat(t, "volume", volume, 5)
+}
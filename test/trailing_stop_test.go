@@ -0,0 +1,182 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// trailStop runs a sell trailing stop that activates at 100 and then sees the price rise to 110 for
+// the given number of polls. rise is called right before the price rises.
+func trailStop(t *testing.T, server *advtest.Server, polls int, rise func()) (adv.TrailingStopState, error) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int
+	stop := adv.NewTrailingStop(server.Client(), &adv.TrailingStopRequest{
+		ProductId:    "BTC-USD",
+		Side:         "SELL",
+		BaseSize:     "0.5",
+		TrailAmount:  "5",
+		PollInterval: 5 * time.Millisecond,
+		PriceSource: func(ctx context.Context) (float64, error) {
+			switch calls++; {
+			case calls == 1:
+				return 100, nil
+			case calls == 2 && rise != nil:
+				rise()
+				return 110, nil
+			case calls <= polls+1:
+				return 110, nil
+			default:
+				cancel()
+				return 0, ctx.Err()
+			}
+		},
+	})
+
+	return stop.Run(ctx)
+}
+
+// trailStopUp trails the stop up once. Right before the rise, edits are made to be rejected and the
+// first lookup of the canceled stop as well as order creation are made to fail, so the stop has to
+// be moved by cancel/replace.
+func trailStopUp(t *testing.T, server *advtest.Server, createFailures int) (adv.TrailingStopState, error) {
+	t.Helper()
+
+	return trailStop(t, server, 1, func() {
+		server.Inject(advtest.Failure{
+			Method: http.MethodPost,
+			Path:   "/brokerage/orders/edit",
+			Body:   `{"success": false, "errors": [{"edit_failure_reason": "EDIT_FAILURE_REASON_UNKNOWN"}]}`,
+		})
+		server.Inject(advtest.Failure{
+			Method:     http.MethodGet,
+			Path:       "/brokerage/orders/historical/*",
+			Times:      1,
+			StatusCode: http.StatusServiceUnavailable,
+		})
+		server.Inject(advtest.Failure{
+			Method:     http.MethodPost,
+			Path:       "/brokerage/orders",
+			Times:      createFailures,
+			StatusCode: http.StatusInternalServerError,
+		})
+	})
+}
+
+// assertStopEdited checks that the one stop order placed was moved to 105 in place.
+func assertStopEdited(t *testing.T, server *advtest.Server, state adv.TrailingStopState) {
+	t.Helper()
+
+	if state.Edits != 1 || state.Replacements != 0 || state.Unprotected || state.Status != "OPEN" {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+	assertFloat(t, "stop price", state.StopPrice, 105)
+
+	orders := server.Orders()
+	if len(orders) != 1 || orders[0].OrderId != state.OrderId || orders[0].Status != "OPEN" {
+		t.Fatalf("expected the original stop to keep resting, got %+v", orders)
+	}
+
+	stop := orders[0].OrderConfiguration.StopLimitStopLimitGtc
+	if stop == nil {
+		t.Fatalf("expected a stop limit order, got %+v", orders[0].OrderConfiguration)
+	}
+	assertFloat(t, "resting stop price", parseTestFloat(stop.StopPrice), 105)
+}
+
+func TestTrailingStopTrailsByEditing(t *testing.T) {
+	server := setupFakeServer(t)
+
+	state, err := trailStop(t, server, 1, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the run to end with its context, got: %v", err)
+	}
+
+	assertStopEdited(t, server, state)
+}
+
+func TestTrailingStopRetriesEditAfterTransportError(t *testing.T) {
+	server := setupFakeServer(t)
+
+	state, err := trailStop(t, server, 2, func() {
+		server.Inject(advtest.Failure{
+			Method:     http.MethodPost,
+			Path:       "/brokerage/orders/edit",
+			Times:      1,
+			StatusCode: http.StatusServiceUnavailable,
+		})
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the run to end with its context, got: %v", err)
+	}
+
+	assertStopEdited(t, server, state)
+}
+
+func TestTrailingStopRetriesReplacement(t *testing.T) {
+	server := setupFakeServer(t)
+
+	state, err := trailStopUp(t, server, 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the run to end with its context, got: %v", err)
+	}
+
+	if state.Unprotected || state.Replacements != 1 || state.Status != "OPEN" {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+	assertFloat(t, "stop price", state.StopPrice, 105)
+
+	order, ok := server.Order(state.OrderId)
+	if !ok || order.Status != "OPEN" {
+		t.Fatalf("expected the replacement stop to rest, got %+v", order)
+	}
+
+	var open int
+	for _, o := range server.Orders() {
+		if o.Status == "OPEN" {
+			open++
+		}
+	}
+	if open != 1 {
+		t.Fatalf("expected exactly one resting stop, got %d", open)
+	}
+}
+
+func TestTrailingStopReportsUnprotectedPosition(t *testing.T) {
+	server := setupFakeServer(t)
+
+	// Order creation never recovers.
+	state, err := trailStopUp(t, server, 0)
+	if err == nil || errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the failed replacement to be returned, got: %v", err)
+	}
+
+	if !state.Unprotected || state.Status != "CANCELLED" || state.Replacements != 0 {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+	assertNoOpenOrders(t, server)
+}
@@ -0,0 +1,159 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func twapRequest(orderType, totalSize string) *adv.TwapRequest {
+	return &adv.TwapRequest{
+		ProductId:    "BTC-USD",
+		Side:         "BUY",
+		TotalSize:    totalSize,
+		Duration:     60 * time.Millisecond,
+		Slices:       3,
+		OrderType:    orderType,
+		PollInterval: 5 * time.Millisecond,
+	}
+}
+
+func assertNoOpenOrders(t *testing.T, server *advtest.Server) {
+	t.Helper()
+	for _, o := range server.Orders() {
+		if o.Status == "OPEN" {
+			t.Fatalf("expected no open orders, found %s", o.OrderId)
+		}
+	}
+}
+
+func TestTwapMarketSlices(t *testing.T) {
+	server := setupFakeServer(t)
+
+	report, err := adv.NewTwapExecutor(server.Client(), twapRequest(adv.ExecutionOrderTypeMarketIoc, "0.9")).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Status != adv.ExecutionStatusCompleted || len(report.ChildOrders) != 3 || report.Shortfall != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	assertFloat(t, "filled", report.FilledSize, 0.9)
+	assertFloat(t, "average price", report.AveragePrice, 101)
+	assertFloat(t, "BTC balance", server.Balance("", "BTC"), 1.9)
+}
+
+func TestTwapReportsShortfallOfPassiveSlices(t *testing.T) {
+	server := setupFakeServer(t)
+
+	// Post-only bids at the touch never fill without sellers.
+	report, err := adv.NewTwapExecutor(server.Client(), twapRequest(adv.ExecutionOrderTypeLimitGtd, "0.9")).Run(context.Background())
+	if !errors.Is(err, adv.ErrExecutionShortfall) {
+		t.Fatalf("expected a shortfall error, got: %v", err)
+	}
+
+	if report.Status != adv.ExecutionStatusIncomplete || len(report.ChildOrders) != 3 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	assertFloat(t, "shortfall", report.Shortfall, 0.9)
+	assertNoOpenOrders(t, server)
+}
+
+func TestTwapRetriesTransientOrderErrors(t *testing.T) {
+	server := setupFakeServer(t)
+
+	server.Inject(advtest.Failure{
+		Method:     http.MethodGet,
+		Path:       "/brokerage/orders/historical/*",
+		Times:      2,
+		StatusCode: http.StatusServiceUnavailable,
+	})
+
+	report, err := adv.NewTwapExecutor(server.Client(), twapRequest(adv.ExecutionOrderTypeMarketIoc, "0.9")).Run(context.Background())
+	if err != nil {
+		t.Fatalf("expected transient errors to be retried, got: %v", err)
+	}
+	if report.Status != adv.ExecutionStatusCompleted {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	assertFloat(t, "filled", report.FilledSize, 0.9)
+}
+
+func TestTwapRetriesOrderResponsesWithoutOrder(t *testing.T) {
+	server := setupFakeServer(t)
+
+	server.Inject(advtest.Failure{
+		Method: http.MethodGet,
+		Path:   "/brokerage/orders/historical/*",
+		Times:  2,
+		Body:   "{}",
+	})
+
+	report, err := adv.NewTwapExecutor(server.Client(), twapRequest(adv.ExecutionOrderTypeMarketIoc, "0.9")).Run(context.Background())
+	if err != nil {
+		t.Fatalf("expected responses without an order to be retried, got: %v", err)
+	}
+	if report.Status != adv.ExecutionStatusCompleted {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+func TestTwapRejectsInvalidRequestWithoutReport(t *testing.T) {
+	server := setupFakeServer(t)
+
+	request := twapRequest(adv.ExecutionOrderTypeMarketIoc, "0.9")
+	request.Slices = 0
+
+	report, err := adv.NewTwapExecutor(server.Client(), request).Run(context.Background())
+	if err == nil || report != nil {
+		t.Fatalf("expected an invalid request to be rejected without a report, got %+v and %v", report, err)
+	}
+}
+
+func TestTwapCancelsChildWhenOrderPollingFails(t *testing.T) {
+	server := setupFakeServer(t)
+
+	// Every poll of the resting child fails until the executor gives up on it.
+	server.Inject(advtest.Failure{
+		Method:     http.MethodGet,
+		Path:       "/brokerage/orders/historical/*",
+		Times:      3,
+		StatusCode: http.StatusInternalServerError,
+	})
+
+	request := twapRequest(adv.ExecutionOrderTypeLimitGtd, "0.9")
+	request.Duration = 3 * time.Second
+
+	report, err := adv.NewTwapExecutor(server.Client(), request).Run(context.Background())
+	if err == nil || errors.Is(err, adv.ErrExecutionShortfall) {
+		t.Fatalf("expected the run to fail, got: %v", err)
+	}
+
+	if report.Status != adv.ExecutionStatusFailed || len(report.ChildOrders) != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.ChildOrders[0].Status != "CANCELLED" {
+		t.Fatalf("expected the child order to be canceled, got %s", report.ChildOrders[0].Status)
+	}
+	assertNoOpenOrders(t, server)
+}
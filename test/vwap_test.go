@@ -0,0 +1,145 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	adv "github.com/coinbase-samples/advanced-trade-sdk-go"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/advtest"
+	"testing"
+	"time"
+)
+
+func vwapRequest(orderType, totalSize string) *adv.VwapRequest {
+	return &adv.VwapRequest{
+		ProductId:          "BTC-USD",
+		Side:               "BUY",
+		TotalSize:          totalSize,
+		Duration:           60 * time.Millisecond,
+		Interval:           20 * time.Millisecond,
+		ProfileGranularity: adv.GranularityOneMinute,
+		OrderType:          orderType,
+		PollInterval:       5 * time.Millisecond,
+	}
+}
+
+// profileVolume gives yesterday's candles at the current minute and the next one the volumes now and
+// next, which become the weights of the first two buckets of an execution starting now.
+func profileVolume(server *advtest.Server, now, next string) {
+	yesterday := time.Now().Add(-24 * time.Hour).Truncate(time.Minute)
+	for i, volume := range []string{now, next} {
+		server.AddCandles("BTC-USD", adv.Candle{
+			Start:  fmt.Sprintf("%d", yesterday.Add(time.Duration(i)*time.Minute).Unix()),
+			Open:   "100",
+			High:   "100",
+			Low:    "100",
+			Close:  "100",
+			Volume: volume,
+		})
+	}
+}
+
+// runVwapBriefly runs a two minute VWAP for d, early in a minute so that the profile buckets line up
+// with the candles, and cancels it.
+func runVwapBriefly(t *testing.T, server *advtest.Server, d time.Duration) *adv.ExecutionReport {
+	t.Helper()
+
+	if time.Until(time.Now().Truncate(time.Minute).Add(time.Minute)) < time.Second {
+		time.Sleep(time.Second)
+	}
+
+	request := vwapRequest(adv.ExecutionOrderTypeMarketIoc, "100")
+	request.Duration = 2 * time.Minute
+
+	executor := adv.NewVwapExecutor(server.Client(), request)
+	time.AfterFunc(d, executor.Cancel)
+
+	report, err := executor.Run(context.Background())
+	if !errors.Is(err, adv.ErrExecutionCancelled) {
+		t.Fatalf("expected the run to be cancelled, got: %v", err)
+	}
+	return report
+}
+
+func TestVwapWaitsForProfiledVolume(t *testing.T) {
+	server := setupFakeServer(t)
+
+	// All of the expected volume trades in the second minute, so nothing is due in the first.
+	profileVolume(server, "0", "10")
+
+	report := runVwapBriefly(t, server, 200*time.Millisecond)
+	if len(report.ChildOrders) != 0 {
+		t.Fatalf("expected no child orders before the profiled volume, got %d", len(report.ChildOrders))
+	}
+}
+
+func TestVwapSizesSlicesFromProfile(t *testing.T) {
+	server := setupFakeServer(t)
+
+	// All of the expected volume trades in the first minute, so its schedule runs at 100 per minute.
+	profileVolume(server, "10", "0")
+
+	const run = 300 * time.Millisecond
+
+	began := time.Now()
+	report := runVwapBriefly(t, server, run)
+	elapsed := time.Since(began)
+
+	if len(report.ChildOrders) == 0 {
+		t.Fatal("expected child orders while the profiled volume trades")
+	}
+
+	// A flat profile would have filled no more than half the upper bound. The last slices may be
+	// missed, so the lower bound leaves three intervals of slack.
+	lower, upper := 100*(run-60*time.Millisecond).Minutes(), 100*elapsed.Minutes()
+	if report.FilledSize < lower || report.FilledSize > upper {
+		t.Fatalf("expected between %v and %v filled after %v, got %v", lower, upper, elapsed, report.FilledSize)
+	}
+}
+
+func TestVwapCompletesByEndOfDuration(t *testing.T) {
+	server := setupFakeServer(t)
+
+	report, err := adv.NewVwapExecutor(server.Client(), vwapRequest(adv.ExecutionOrderTypeMarketIoc, "0.9")).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Status != adv.ExecutionStatusCompleted || report.Shortfall != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	assertFloat(t, "filled", report.FilledSize, 0.9)
+	assertFloat(t, "BTC balance", server.Balance("", "BTC"), 1.9)
+}
+
+func TestVwapReportsShortfallOfPassiveSlices(t *testing.T) {
+	server := setupFakeServer(t)
+
+	// Post-only bids at the touch never fill without sellers.
+	report, err := adv.NewVwapExecutor(server.Client(), vwapRequest(adv.ExecutionOrderTypeLimitGtd, "0.9")).Run(context.Background())
+	if !errors.Is(err, adv.ErrExecutionShortfall) {
+		t.Fatalf("expected a shortfall error, got: %v", err)
+	}
+
+	if report.Status != adv.ExecutionStatusIncomplete || len(report.ChildOrders) == 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	assertFloat(t, "shortfall", report.Shortfall, 0.9)
+	assertNoOpenOrders(t, server)
+}
@@ -0,0 +1,496 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+const (
+	StopDirectionStopUp   = "STOP_DIRECTION_STOP_UP"
+	StopDirectionStopDown = "STOP_DIRECTION_STOP_DOWN"
+)
+
+const maxStopReplaceAttempts = 3
+
+type TrailingStopRequest struct {
+	ProductId         string        `json:"product_id"`
+	Side              string        `json:"side"`
+	BaseSize          string        `json:"base_size"`
+	TrailAmount       string        `json:"trail_amount,omitempty"`
+	TrailPercent      float64       `json:"trail_percent,omitempty"`
+	LimitOffset       string        `json:"limit_offset,omitempty"`
+	ActivationPrice   string        `json:"activation_price,omitempty"`
+	MinMove           string        `json:"min_move,omitempty"`
+	EndTime           string        `json:"end_time,omitempty"`
+	PollInterval      time.Duration `json:"poll_interval"`
+	RetailPortfolioId string        `json:"retail_portfolio_id,omitempty"`
+
+	// PriceSource overrides the reference price, e.g. with a ticker stream. By default the best bid
+	// is used for sell stops and the best ask for buy stops.
+	PriceSource func(ctx context.Context) (float64, error) `json:"-"`
+}
+
+type TrailingStopState struct {
+	OrderId      string  `json:"order_id"`
+	Status       string  `json:"status"`
+	Activated    bool    `json:"activated"`
+	BestPrice    float64 `json:"best_price"`
+	StopPrice    float64 `json:"stop_price"`
+	LimitPrice   float64 `json:"limit_price"`
+	Edits        int     `json:"edits"`
+	Replacements int     `json:"replacements"`
+	Order        *Order  `json:"order,omitempty"`
+
+	// Unprotected is set while the stop has been canceled for replacement but no new stop is
+	// resting, including when the replacement failed and Run returned an error.
+	Unprotected bool `json:"unprotected"`
+}
+
+// TrailingStop keeps a stop-limit order trailing the best price seen since activation. Sell stops
+// protect a long position and only ever move up; buy stops protect a short and only move down.
+type TrailingStop struct {
+	client  Service
+	request *TrailingStopRequest
+
+	trail       float64
+	trailIsPct  bool
+	limitOffset float64
+	minMove     float64
+	rules       *productRules
+	size        string
+
+	mu    sync.Mutex
+	state TrailingStopState
+}
+
+func NewTrailingStop(client Service, request *TrailingStopRequest) *TrailingStop {
+	return &TrailingStop{client: client, request: request}
+}
+
+func (t *TrailingStop) State() TrailingStopState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state
+}
+
+func (t *TrailingStop) validate() error {
+	r := t.request
+
+	if len(r.ProductId) == 0 {
+		return errors.New("product id not set")
+	}
+
+	if r.Side != "BUY" && r.Side != "SELL" {
+		return fmt.Errorf("invalid side: %s", r.Side)
+	}
+
+	if size, err := parseFloat(r.BaseSize); err != nil || size <= 0 {
+		return fmt.Errorf("invalid base size: %s", r.BaseSize)
+	}
+
+	amount, err := parseFloat(r.TrailAmount)
+	if err != nil || amount < 0 {
+		return fmt.Errorf("invalid trail amount: %s", r.TrailAmount)
+	}
+
+	if (amount > 0) == (r.TrailPercent > 0) {
+		return errors.New("exactly one of trail amount or trail percent must be set")
+	}
+
+	if r.TrailPercent >= 1 {
+		return fmt.Errorf("invalid trail percent: %v", r.TrailPercent)
+	}
+
+	t.trail, t.trailIsPct = amount, false
+	if r.TrailPercent > 0 {
+		t.trail, t.trailIsPct = r.TrailPercent, true
+	}
+
+	if t.limitOffset, err = parseFloat(r.LimitOffset); err != nil || t.limitOffset < 0 {
+		return fmt.Errorf("invalid limit offset: %s", r.LimitOffset)
+	}
+
+	if t.minMove, err = parseFloat(r.MinMove); err != nil || t.minMove < 0 {
+		return fmt.Errorf("invalid min move: %s", r.MinMove)
+	}
+
+	if _, err := parseFloat(r.ActivationPrice); err != nil {
+		return fmt.Errorf("invalid activation price: %s", r.ActivationPrice)
+	}
+
+	return nil
+}
+
+// Run waits for activation, places the stop and trails it until the order reaches a terminal
+// status. If the context is done first, the resting stop is left in place so that the position
+// stays protected; cancel it explicitly if that is not wanted.
+func (t *TrailingStop) Run(ctx context.Context) (TrailingStopState, error) {
+
+	if err := t.validate(); err != nil {
+		return TrailingStopState{}, err
+	}
+
+	err := t.run(ctx)
+	return t.State(), err
+}
+
+func (t *TrailingStop) run(ctx context.Context) error {
+	r := t.request
+
+	rules, err := fetchProductRules(ctx, t.client, r.ProductId)
+	if err != nil {
+		return err
+	}
+	t.rules = rules
+
+	if t.minMove == 0 {
+		t.minMove, _ = parseFloat(rules.priceIncrement)
+	}
+
+	t.size = r.BaseSize
+
+	price, err := t.awaitActivation(ctx)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.state.Activated = true
+	t.state.BestPrice = price
+	t.mu.Unlock()
+
+	if err := t.place(ctx, t.stopFor(price)); err != nil {
+		return err
+	}
+
+	for {
+		if err := t.wait(ctx); err != nil {
+			return err
+		}
+
+		order, err := t.client.GetOrder(ctx, &GetOrderRequest{OrderId: t.State().OrderId})
+		if err != nil {
+			return fmt.Errorf("unable to get stop order: %w", err)
+		}
+
+		t.mu.Lock()
+		t.state.Status = order.Order.Status
+		t.state.Order = order.Order
+		t.mu.Unlock()
+
+		if isTerminalOrderStatus(order.Order.Status) {
+			return nil
+		}
+
+		price, err := t.price(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := t.trailTo(ctx, price); err != nil {
+			return err
+		}
+	}
+}
+
+func (t *TrailingStop) awaitActivation(ctx context.Context) (float64, error) {
+
+	activation, _ := parseFloat(t.request.ActivationPrice)
+
+	for {
+		price, err := t.price(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		if activation == 0 ||
+			(t.request.Side == "SELL" && price >= activation) ||
+			(t.request.Side == "BUY" && price <= activation) {
+			return price, nil
+		}
+
+		if err := t.wait(ctx); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (t *TrailingStop) trailTo(ctx context.Context, price float64) error {
+
+	// The stop follows the best price rather than the latest, so that a move which could not be
+	// made on an earlier poll is retried.
+	t.mu.Lock()
+	if (t.request.Side == "SELL" && price > t.state.BestPrice) || (t.request.Side == "BUY" && price < t.state.BestPrice) {
+		t.state.BestPrice = price
+	}
+	best := t.state.BestPrice
+	current := t.state.StopPrice
+	t.mu.Unlock()
+
+	stop := t.stopFor(best)
+	move := stop - current
+	if t.request.Side == "BUY" {
+		move = -move
+	}
+
+	if move < t.minMove || move <= 0 {
+		return nil
+	}
+
+	return t.move(ctx, stop)
+}
+
+func (t *TrailingStop) stopFor(price float64) float64 {
+
+	trail := t.trail
+	if t.trailIsPct {
+		trail = price * t.trail
+	}
+
+	if t.request.Side == "SELL" {
+		return price - trail
+	}
+	return price + trail
+}
+
+func (t *TrailingStop) limitFor(stop float64) float64 {
+	if t.request.Side == "SELL" {
+		return stop - t.limitOffset
+	}
+	return stop + t.limitOffset
+}
+
+func (t *TrailingStop) orderConfiguration(stop float64) OrderConfiguration {
+	r := t.request
+
+	direction := StopDirectionStopDown
+	if r.Side == "BUY" {
+		direction = StopDirectionStopUp
+	}
+
+	stopPrice := roundToIncrement(stop, t.rules.priceIncrement)
+	limitPrice := roundToIncrement(t.limitFor(stop), t.rules.priceIncrement)
+
+	if len(r.EndTime) > 0 {
+		return OrderConfiguration{StopLimitStopLimitGtd: &StopLimitGtd{
+			BaseSize:      t.size,
+			LimitPrice:    limitPrice,
+			StopPrice:     stopPrice,
+			EndTime:       r.EndTime,
+			StopDirection: direction,
+		}}
+	}
+
+	return OrderConfiguration{StopLimitStopLimitGtc: &StopLimitGtc{
+		BaseSize:      t.size,
+		LimitPrice:    limitPrice,
+		StopPrice:     stopPrice,
+		StopDirection: direction,
+	}}
+}
+
+func (t *TrailingStop) place(ctx context.Context, stop float64) error {
+	r := t.request
+
+	response, err := t.client.CreateOrder(ctx, &CreateOrderRequest{
+		ProductId:          r.ProductId,
+		Side:               r.Side,
+		ClientOrderId:      uuid.New().String(),
+		OrderConfiguration: t.orderConfiguration(stop),
+		RetailPortfolioId:  r.RetailPortfolioId,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to place stop order: %w", err)
+	}
+
+	if !response.Success {
+		return fmt.Errorf("stop order rejected: %s", createOrderFailure(response))
+	}
+
+	orderId := response.OrderId
+	if len(orderId) == 0 && response.SuccessResponse != nil {
+		orderId = response.SuccessResponse.OrderId
+	}
+
+	t.mu.Lock()
+	t.state.OrderId = orderId
+	t.state.Status = "OPEN"
+	t.state.StopPrice = stop
+	t.state.LimitPrice = t.limitFor(stop)
+	t.mu.Unlock()
+
+	return nil
+}
+
+// move edits the resting stop and falls back to cancel/replace when the edit is rejected. When the
+// edit fails without a response, such as during an outage, the stop keeps resting at its old price
+// and the move is retried on the next poll.
+func (t *TrailingStop) move(ctx context.Context, stop float64) error {
+
+	state := t.State()
+
+	edit, err := t.client.EditOrder(ctx, &EditOrderRequest{
+		OrderId:   state.OrderId,
+		Price:     roundToIncrement(t.limitFor(stop), t.rules.priceIncrement),
+		Size:      t.size,
+		StopPrice: roundToIncrement(stop, t.rules.priceIncrement),
+	})
+	if errors.Is(err, ErrKillSwitchEngaged) {
+		return err
+	}
+
+	if err != nil {
+		return nil
+	}
+
+	if edit.Success && len(edit.EditErrors) == 0 {
+		t.mu.Lock()
+		t.state.StopPrice = stop
+		t.state.LimitPrice = t.limitFor(stop)
+		t.state.Edits++
+		t.mu.Unlock()
+		return nil
+	}
+
+	cancel, err := t.client.CancelOrders(ctx, &CancelOrdersRequest{OrderIds: []string{state.OrderId}})
+	if err != nil {
+		return fmt.Errorf("unable to cancel stop order for replacement: %w", err)
+	}
+
+	for _, result := range cancel.Results {
+		if result.OrderId == state.OrderId && !result.Success {
+			// Most likely the stop triggered in the meantime; the next poll picks up its status.
+			return nil
+		}
+	}
+
+	return t.replace(state.OrderId, stop)
+}
+
+// replace places a new stop for the unfilled size of the canceled one. The position is not
+// protected until that succeeds, so it is retried on a context of its own and flagged in State
+// meanwhile.
+func (t *TrailingStop) replace(orderId string, stop float64) error {
+
+	t.mu.Lock()
+	t.state.Status = "CANCELLED"
+	t.state.Unprotected = true
+	t.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cancelChildOrderTimeout)
+	defer cancel()
+
+	var err error
+	for attempt := 0; attempt < maxStopReplaceAttempts; attempt++ {
+		if attempt > 0 {
+			if err := t.wait(ctx); err != nil {
+				break
+			}
+		}
+
+		if err = t.replaceOnce(ctx, orderId, stop); err == nil {
+			return nil
+		}
+
+		if errors.Is(err, ErrKillSwitchEngaged) {
+			break
+		}
+	}
+
+	return fmt.Errorf("stop order %s canceled but not replaced, position is unprotected: %w", orderId, err)
+}
+
+func (t *TrailingStop) replaceOnce(ctx context.Context, orderId string, stop float64) error {
+
+	canceled, err := t.client.GetOrder(ctx, &GetOrderRequest{OrderId: orderId})
+	if err != nil {
+		return fmt.Errorf("unable to get canceled stop order: %w", err)
+	}
+
+	size, _ := parseFloat(t.size)
+	filled, err := parseFloat(canceled.Order.FilledSize)
+	if err != nil {
+		return fmt.Errorf("invalid filled size %s: %w", canceled.Order.FilledSize, err)
+	}
+
+	remaining := t.size
+	if filled > 0 {
+		if size-filled < t.rules.baseMinSize {
+			// The remainder cannot be replaced; the next poll reports the canceled order and stops.
+			t.mu.Lock()
+			t.state.Unprotected = false
+			t.mu.Unlock()
+			return nil
+		}
+		remaining = floorToIncrement(size-filled, t.rules.baseIncrement)
+	}
+
+	previous := t.size
+	t.size = remaining
+	if err := t.place(ctx, stop); err != nil {
+		t.size = previous
+		return err
+	}
+
+	t.mu.Lock()
+	t.state.Unprotected = false
+	t.state.Replacements++
+	t.mu.Unlock()
+
+	return nil
+}
+
+func (t *TrailingStop) price(ctx context.Context) (float64, error) {
+
+	if t.request.PriceSource != nil {
+		return t.request.PriceSource(ctx)
+	}
+
+	bid, ask, err := fetchBestBidAsk(ctx, t.client, t.request.ProductId)
+	if err != nil {
+		return 0, err
+	}
+
+	if t.request.Side == "SELL" {
+		return bid, nil
+	}
+	return ask, nil
+}
+
+func (t *TrailingStop) wait(ctx context.Context) error {
+
+	pollInterval := t.request.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultExecutionPollInterval
+	}
+
+	timer := time.NewTimer(pollInterval)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,174 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const AlgorithmTwap = "TWAP"
+
+type TwapRequest struct {
+	ProductId         string        `json:"product_id"`
+	Side              string        `json:"side"`
+	TotalSize         string        `json:"total_size"`
+	Duration          time.Duration `json:"duration"`
+	Slices            int           `json:"slices"`
+	OrderType         string        `json:"order_type"`
+	SizeJitter        float64       `json:"size_jitter"`
+	TimeJitter        float64       `json:"time_jitter"`
+	PollInterval      time.Duration `json:"poll_interval"`
+	RetailPortfolioId string        `json:"retail_portfolio_id,omitempty"`
+	Rand              *rand.Rand    `json:"-"`
+}
+
+type TwapExecutor struct {
+	*execution
+	client  Service
+	request *TwapRequest
+	rand    *rand.Rand
+}
+
+func NewTwapExecutor(client Service, request *TwapRequest) *TwapExecutor {
+	return &TwapExecutor{
+		execution: newExecution(),
+		client:    client,
+		request:   request,
+		rand:      newRand(request.Rand),
+	}
+}
+
+func (e *TwapExecutor) validate() (float64, error) {
+	r := e.request
+
+	if err := validateParentOrder(r.ProductId, r.Side, r.TotalSize, r.OrderType); err != nil {
+		return 0, err
+	}
+
+	if r.Slices <= 0 || r.Duration <= 0 {
+		return 0, errors.New("slices and duration must be positive")
+	}
+
+	if r.SizeJitter < 0 || r.SizeJitter >= 1 || r.TimeJitter < 0 || r.TimeJitter >= 1 {
+		return 0, errors.New("size and time jitter must be in [0, 1)")
+	}
+
+	total, _ := parseFloat(r.TotalSize)
+	return total, nil
+}
+
+// Run executes the parent order and blocks until it completes, fails or is canceled through
+// Cancel or the context. An invalid request is rejected with a nil report; once execution starts
+// the report is returned in every case.
+func (e *TwapExecutor) Run(ctx context.Context) (*ExecutionReport, error) {
+
+	total, err := e.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := e.withCancel(ctx)
+	defer cancel()
+
+	e.start(ExecutionReport{
+		Algorithm:  AlgorithmTwap,
+		ProductId:  e.request.ProductId,
+		Side:       e.request.Side,
+		TargetSize: total,
+	})
+
+	return e.finish(e.run(ctx, total))
+}
+
+func (e *TwapExecutor) run(ctx context.Context, total float64) error {
+	r := e.request
+
+	rules, err := fetchProductRules(ctx, e.client, r.ProductId)
+	if err != nil {
+		return err
+	}
+	e.setMinSize(rules.baseMinSize)
+
+	bid, ask, err := fetchBestBidAsk(ctx, e.client, r.ProductId)
+	if err != nil {
+		return fmt.Errorf("unable to get arrival price: %w", err)
+	}
+
+	e.setArrivalPrice((bid + ask) / 2)
+
+	interval := r.Duration / time.Duration(r.Slices)
+
+	for slice := 0; slice < r.Slices; slice++ {
+
+		if err := e.waitIfPaused(ctx); err != nil {
+			return err
+		}
+
+		remaining := total - e.filledSize()
+		if remaining < rules.baseMinSize || remaining <= 0 {
+			return nil
+		}
+
+		size := e.sliceSize(remaining, r.Slices-slice, rules)
+		if size <= 0 {
+			return nil
+		}
+
+		wait := time.Duration(jitter(e.rand, float64(interval), r.TimeJitter))
+		started := time.Now()
+
+		child, err := executeSlice(ctx, e.client, &executionSlice{
+			productId:         r.ProductId,
+			side:              r.Side,
+			orderType:         r.OrderType,
+			retailPortfolioId: r.RetailPortfolioId,
+			size:              size,
+			deadline:          started.Add(wait),
+			pollInterval:      r.PollInterval,
+		}, rules)
+		if child != nil {
+			e.addChildOrder(child)
+		}
+		if err != nil {
+			return err
+		}
+
+		if slice < r.Slices-1 {
+			if err := e.sleep(ctx, wait-time.Since(started)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// sliceSize spreads the remaining size evenly over the remaining slices, randomised within the
+// configured jitter.
+func (e *TwapExecutor) sliceSize(remaining float64, slicesLeft int, rules *productRules) float64 {
+
+	size := remaining
+	if slicesLeft > 1 {
+		size = jitter(e.rand, remaining/float64(slicesLeft), e.request.SizeJitter)
+	}
+
+	return rules.clampSize(size, remaining, 0)
+}
@@ -18,6 +18,8 @@ package adv
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 )
 
@@ -48,3 +50,49 @@ func queryParamSep(appended bool) string {
 	}
 	return "?"
 }
+
+func parseFloat(v string) (float64, error) {
+	if len(v) == 0 {
+		return 0, nil
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func incrementDecimals(increment string) int {
+	i := strings.Index(increment, ".")
+	if i < 0 {
+		return 0
+	}
+	return len(strings.TrimRight(increment[i+1:], "0"))
+}
+
+func floorToIncrement(v float64, increment string) string {
+	inc, err := parseFloat(increment)
+	if err != nil || inc <= 0 {
+		return formatFloat(v)
+	}
+	steps := math.Floor(v/inc + 1e-9)
+	return strconv.FormatFloat(steps*inc, 'f', incrementDecimals(increment), 64)
+}
+
+func roundToIncrement(v float64, increment string) string {
+	inc, err := parseFloat(increment)
+	if err != nil || inc <= 0 {
+		return formatFloat(v)
+	}
+	steps := math.Round(v / inc)
+	return strconv.FormatFloat(steps*inc, 'f', incrementDecimals(increment), 64)
+}
+
+func ceilToIncrement(v float64, increment string) string {
+	inc, err := parseFloat(increment)
+	if err != nil || inc <= 0 {
+		return formatFloat(v)
+	}
+	steps := math.Ceil(v/inc - 1e-9)
+	return strconv.FormatFloat(steps*inc, 'f', incrementDecimals(increment), 64)
+}
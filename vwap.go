@@ -0,0 +1,198 @@
+/**
+ * Copyright 2024-present Coinbase Global, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	AlgorithmVwap = "VWAP"
+
+	defaultVwapProfileGranularity = GranularityFiveMinute
+)
+
+type VwapRequest struct {
+	ProductId            string        `json:"product_id"`
+	Side                 string        `json:"side"`
+	TotalSize            string        `json:"total_size"`
+	Duration             time.Duration `json:"duration"`
+	Interval             time.Duration `json:"interval"`
+	ProfileDays          int           `json:"profile_days"`
+	ProfileGranularity   string        `json:"profile_granularity"`
+	MaxParticipationRate float64       `json:"max_participation_rate"`
+	OrderType            string        `json:"order_type"`
+	PollInterval         time.Duration `json:"poll_interval"`
+	RetailPortfolioId    string        `json:"retail_portfolio_id,omitempty"`
+}
+
+// VwapExecutor follows a schedule derived from the historical intraday volume profile, so that
+// more is traded when the market is typically more active. Child orders can be capped to a share
+// of the volume observed in each interval.
+type VwapExecutor struct {
+	*execution
+	client  Service
+	request *VwapRequest
+}
+
+func NewVwapExecutor(client Service, request *VwapRequest) *VwapExecutor {
+	return &VwapExecutor{
+		execution: newExecution(),
+		client:    client,
+		request:   request,
+	}
+}
+
+func (e *VwapExecutor) validate() (float64, error) {
+	r := e.request
+
+	if err := validateParentOrder(r.ProductId, r.Side, r.TotalSize, r.OrderType); err != nil {
+		return 0, err
+	}
+
+	if r.Duration <= 0 || r.Interval <= 0 || r.Interval > r.Duration {
+		return 0, errors.New("duration and interval must be positive and interval must not exceed duration")
+	}
+
+	if r.MaxParticipationRate < 0 || r.MaxParticipationRate > 1 {
+		return 0, fmt.Errorf("invalid max participation rate: %v", r.MaxParticipationRate)
+	}
+
+	total, _ := parseFloat(r.TotalSize)
+	return total, nil
+}
+
+func (e *VwapExecutor) Run(ctx context.Context) (*ExecutionReport, error) {
+
+	total, err := e.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := e.withCancel(ctx)
+	defer cancel()
+
+	e.start(ExecutionReport{
+		Algorithm:  AlgorithmVwap,
+		ProductId:  e.request.ProductId,
+		Side:       e.request.Side,
+		TargetSize: total,
+	})
+
+	return e.finish(e.run(ctx, total))
+}
+
+func (e *VwapExecutor) run(ctx context.Context, total float64) error {
+	r := e.request
+
+	rules, err := fetchProductRules(ctx, e.client, r.ProductId)
+	if err != nil {
+		return err
+	}
+	e.setMinSize(rules.baseMinSize)
+
+	bid, ask, err := fetchBestBidAsk(ctx, e.client, r.ProductId)
+	if err != nil {
+		return fmt.Errorf("unable to get arrival price: %w", err)
+	}
+	e.setArrivalPrice((bid + ask) / 2)
+
+	granularity := r.ProfileGranularity
+	if len(granularity) == 0 {
+		granularity = defaultVwapProfileGranularity
+	}
+
+	start := time.Now()
+	profile, err := buildVolumeProfile(ctx, e.client, r.ProductId, granularity, r.ProfileDays, start, r.Duration)
+	if err != nil {
+		return err
+	}
+
+	tracker := newMarketVolumeTracker(e.client, r.ProductId, start)
+	end := start.Add(r.Duration)
+
+	var countedFills float64
+
+	for {
+		if err := e.sleep(ctx, r.Interval); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		filled := e.filledSize()
+		remaining := total - filled
+		if remaining < rules.baseMinSize || remaining <= 0 {
+			return nil
+		}
+
+		size := total*profile.fraction(now) - filled
+		if !now.Before(end) {
+			size = remaining
+		}
+
+		var ceiling float64
+		if r.MaxParticipationRate > 0 {
+			volume, err := tracker.poll(ctx)
+			if err != nil {
+				return err
+			}
+
+			// The tape includes this executor's own fills since the previous poll.
+			volume = math.Max(0, volume-(filled-countedFills))
+			countedFills = filled
+			ceiling = volume * r.MaxParticipationRate
+			size = math.Min(size, ceiling)
+		}
+
+		if size >= rules.baseMinSize {
+			if err := e.executeChild(ctx, rules.clampSize(size, remaining, ceiling), rules, now.Add(r.Interval)); err != nil {
+				return err
+			}
+		}
+
+		if !now.Before(end) {
+			return nil
+		}
+	}
+}
+
+func (e *VwapExecutor) executeChild(ctx context.Context, size float64, rules *productRules, deadline time.Time) error {
+	r := e.request
+
+	if size <= 0 {
+		return nil
+	}
+
+	child, err := executeSlice(ctx, e.client, &executionSlice{
+		productId:         r.ProductId,
+		side:              r.Side,
+		orderType:         r.OrderType,
+		retailPortfolioId: r.RetailPortfolioId,
+		size:              size,
+		deadline:          deadline,
+		pollInterval:      r.PollInterval,
+	}, rules)
+	if child != nil {
+		e.addChildOrder(child)
+	}
+
+	return err
+}